@@ -0,0 +1,267 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/api/idtoken"
+	"google.golang.org/api/option"
+)
+
+// typeHeader is the header with the type of the task, set by the producer.
+const typeHeader = "X-Task-Type"
+
+func init() {
+	d := &Dispatcher{
+		Queues:         []string{"default", "emails"},
+		MaxRetries:     5,
+		AppEngine:      os.Getenv("GAE_APPLICATION") != "",
+		Audience:       os.Getenv("TASKS_AUDIENCE"),
+		ServiceAccount: os.Getenv("TASKS_SERVICE_ACCOUNT"),
+	}
+	// TASKS_CERTS_URL replaces Google's certificates of the OIDC tokens, to
+	// test with a local Cloud Tasks server, such as tasktest.
+	if url := os.Getenv("TASKS_CERTS_URL"); url != "" {
+		v, err := idtoken.NewValidator(context.Background(), option.WithHTTPClient(&http.Client{
+			Transport: certsTransport(url),
+		}))
+		if err != nil {
+			log.Fatalf("idtoken.NewValidator: %v", err)
+		}
+		d.Validate = v.Validate
+	}
+	Register(d, "email", sendEmail)
+	http.Handle("/tasks", d)
+}
+
+// certsTransport sends all requests, for Google's certificates, to a URL.
+type certsTransport string
+
+func (t certsTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	r, err := http.NewRequestWithContext(req.Context(), req.Method, string(t), nil)
+	if err != nil {
+		return nil, err
+	}
+	return http.DefaultTransport.RoundTrip(r)
+}
+
+// email is the payload of email tasks.
+type email struct {
+	To      string `json:"to"`
+	Subject string `json:"subject"`
+}
+
+func sendEmail(ctx context.Context, info TaskInfo, e email) error {
+	if e.To == "" {
+		return fmt.Errorf("%w: no recipient", ErrPermanent)
+	}
+	log.Printf("Sending %q to %s (task %s, attempt %d)", e.Subject, e.To, info.Name, info.RetryCount+1)
+	return nil
+}
+
+// TaskInfo is the information Cloud Tasks sends with a task.
+type TaskInfo struct {
+	Queue string
+	Name  string
+	Type  string
+	// RetryCount is the number of previous attempts.
+	RetryCount int
+	// ExecutionCount is the number of previous attempts that got a
+	// response from the handler.
+	ExecutionCount int
+	// ETA is the schedule time of the task.
+	ETA time.Time
+	// PreviousResponse is the HTTP status of the previous attempt, or 0.
+	PreviousResponse int
+}
+
+// taskInfo returns the information of a task request, from the headers
+// of HTTP target tasks if cloudTasks is set, or else of App Engine tasks.
+// ok is false if the headers are missing.
+//
+// App Engine removes X-AppEngine-* headers from external requests, so they
+// can be trusted there, and only there. X-CloudTasks-* headers can be sent
+// by anyone, so they are only used once the OIDC token of the request is
+// verified.
+func taskInfo(r *http.Request, cloudTasks bool) (info TaskInfo, ok bool) {
+	prefix := "X-AppEngine-"
+	if cloudTasks {
+		prefix = "X-CloudTasks-"
+	}
+	info = TaskInfo{
+		Queue: r.Header.Get(prefix + "QueueName"),
+		Name:  r.Header.Get(prefix + "TaskName"),
+		Type:  r.Header.Get(typeHeader),
+	}
+	if info.Queue == "" || info.Name == "" {
+		return info, false
+	}
+	info.RetryCount, _ = strconv.Atoi(r.Header.Get(prefix + "TaskRetryCount"))
+	info.ExecutionCount, _ = strconv.Atoi(r.Header.Get(prefix + "TaskExecutionCount"))
+	info.PreviousResponse, _ = strconv.Atoi(r.Header.Get(prefix + "TaskPreviousResponse"))
+	// The ETA is in seconds since the epoch, with microseconds.
+	if eta, err := strconv.ParseFloat(r.Header.Get(prefix+"TaskETA"), 64); err == nil {
+		info.ETA = time.UnixMicro(int64(eta * 1e6))
+	}
+	return info, true
+}
+
+// ErrPermanent is wrapped by the errors of handlers to drop the task
+// instead of retrying it.
+var ErrPermanent = errors.New("permanent failure")
+
+// Dispatcher decodes tasks and calls the handler of their type.
+//
+// Cloud Tasks retries the tasks that get a response other than 2xx, so the
+// dispatcher only answers with an error status when a retry could succeed.
+type Dispatcher struct {
+	// Queues, if set, are the queues the tasks may come from.
+	Queues []string
+	// MaxRetries, if positive, is the number of retries after which a
+	// failing task is dropped, in addition to the limit of the queue.
+	MaxRetries int
+	// AppEngine reports whether the dispatcher runs on App Engine, which
+	// removes the X-AppEngine-* headers of external requests. Elsewhere,
+	// only HTTP target tasks with an OIDC token are accepted.
+	AppEngine bool
+	// Audience is the audience of the OIDC tokens of HTTP target tasks,
+	// by default their URL. If empty, only App Engine tasks are accepted.
+	Audience string
+	// ServiceAccount, if set, is the only service account whose tokens are
+	// accepted.
+	ServiceAccount string
+	// Validate validates ID tokens. It defaults to idtoken.Validate.
+	Validate func(ctx context.Context, token, audience string) (*idtoken.Payload, error)
+
+	handlers map[string]func(context.Context, TaskInfo, []byte) error
+}
+
+// Register sets the handler of a type of task, with a JSON payload.
+func Register[T any](d *Dispatcher, taskType string, fn func(context.Context, TaskInfo, T) error) {
+	if d.handlers == nil {
+		d.handlers = map[string]func(context.Context, TaskInfo, []byte) error{}
+	}
+	d.handlers[taskType] = func(ctx context.Context, info TaskInfo, body []byte) error {
+		var payload T
+		if err := json.Unmarshal(body, &payload); err != nil {
+			return fmt.Errorf("%w: decoding payload: %w", ErrPermanent, err)
+		}
+		return fn(ctx, info, payload)
+	}
+}
+
+func (d *Dispatcher) allowed(queue string) bool {
+	if len(d.Queues) == 0 {
+		return true
+	}
+	for _, q := range d.Queues {
+		if q == queue {
+			return true
+		}
+	}
+	return false
+}
+
+// verify verifies the OIDC token that Cloud Tasks sends with HTTP target
+// tasks, returning the status to answer with if it is invalid.
+func (d *Dispatcher) verify(r *http.Request) (status int, err error) {
+	if d.Audience == "" {
+		return http.StatusUnauthorized, errors.New("no audience for HTTP target tasks")
+	}
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || token == "" {
+		return http.StatusUnauthorized, errors.New("no bearer token")
+	}
+	validate := d.Validate
+	if validate == nil {
+		validate = idtoken.Validate
+	}
+	payload, err := validate(r.Context(), token, d.Audience)
+	if err != nil {
+		return http.StatusUnauthorized, fmt.Errorf("idtoken.Validate: %w", err)
+	}
+	if d.ServiceAccount != "" {
+		email, _ := payload.Claims["email"].(string)
+		verified, _ := payload.Claims["email_verified"].(bool)
+		if !verified || email != d.ServiceAccount {
+			return http.StatusForbidden, fmt.Errorf("token of %q, want %q", email, d.ServiceAccount)
+		}
+	}
+	return 0, nil
+}
+
+func (d *Dispatcher) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	cloudTasks := !d.AppEngine || r.Header.Get("X-CloudTasks-TaskName") != ""
+	if cloudTasks {
+		if status, err := d.verify(r); err != nil {
+			log.Printf("Rejected HTTP target task: %v", err)
+			http.Error(w, http.StatusText(status), status)
+			return
+		}
+	}
+	info, ok := taskInfo(r, cloudTasks)
+
+	// Answering with a 2xx status drops the tasks that can't succeed.
+	drop := func(reason string) {
+		log.Printf("Dropped task %s of queue %s: %s", info.Name, info.Queue, reason)
+		fmt.Fprintln(w, "Dropped")
+	}
+	if !ok {
+		drop("no task headers")
+		return
+	}
+	if !d.allowed(info.Queue) {
+		drop("queue not allowed")
+		return
+	}
+	handler, ok := d.handlers[info.Type]
+	if !ok {
+		drop(fmt.Sprintf("unknown type %q", info.Type))
+		return
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBodySize))
+	if err != nil {
+		log.Printf("Task %s: reading body: %v", info.Name, err)
+		http.Error(w, "Internal Error", http.StatusInternalServerError)
+		return
+	}
+
+	err = handler(r.Context(), info, body)
+	switch {
+	case err == nil:
+		fmt.Fprintln(w, "OK")
+	case errors.Is(err, ErrPermanent):
+		drop(err.Error())
+	case d.MaxRetries > 0 && info.RetryCount >= d.MaxRetries:
+		drop(fmt.Sprintf("%v, after %d retries", err, info.RetryCount))
+	default:
+		log.Printf("Task %s failed, retrying: %v", info.Name, err)
+		http.Error(w, "Internal Error - Retry", http.StatusInternalServerError)
+	}
+}
+
+// maxBodySize is the maximum size of a task in Cloud Tasks.
+const maxBodySize = 1 << 20
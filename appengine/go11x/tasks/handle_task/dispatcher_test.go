@@ -0,0 +1,214 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/api/idtoken"
+)
+
+// fakeValidate accepts the tokens "valid" and "other", of the service
+// accounts tasks@ and other@, for the audience https://example.com/tasks.
+func fakeValidate(ctx context.Context, token, audience string) (*idtoken.Payload, error) {
+	if audience != "https://example.com/tasks" {
+		return nil, errors.New("wrong audience")
+	}
+	var email string
+	switch token {
+	case "valid":
+		email = "tasks@example.iam.gserviceaccount.com"
+	case "other":
+		email = "other@example.iam.gserviceaccount.com"
+	default:
+		return nil, errors.New("invalid token")
+	}
+	return &idtoken.Payload{Audience: audience, Claims: map[string]interface{}{"email": email, "email_verified": true}}, nil
+}
+
+func newTestDispatcher() *Dispatcher {
+	return &Dispatcher{
+		Queues:         []string{"orders"},
+		MaxRetries:     3,
+		Audience:       "https://example.com/tasks",
+		ServiceAccount: "tasks@example.iam.gserviceaccount.com",
+		Validate:       fakeValidate,
+	}
+}
+
+type order struct {
+	ID int `json:"id"`
+}
+
+func TestDispatcher(t *testing.T) {
+	var got []order
+	var gotInfo TaskInfo
+	d := newTestDispatcher()
+	Register(d, "order", func(ctx context.Context, info TaskInfo, o order) error {
+		gotInfo = info
+		switch o.ID {
+		case 0:
+			return fmt.Errorf("%w: no ID", ErrPermanent)
+		case 13:
+			return errors.New("temporary failure")
+		}
+		got = append(got, o)
+		return nil
+	})
+
+	tests := []struct {
+		name    string
+		headers map[string]string
+		body    string
+		status  int
+	}{
+		{"ok", nil, `{"id":1}`, http.StatusOK},
+		{"no task headers", map[string]string{"X-CloudTasks-TaskName": "", "X-CloudTasks-QueueName": ""}, `{"id":1}`, http.StatusOK},
+		{"other queue", map[string]string{"X-CloudTasks-QueueName": "emails"}, `{"id":1}`, http.StatusOK},
+		{"unknown type", map[string]string{"X-Task-Type": "refund"}, `{"id":1}`, http.StatusOK},
+		{"bad payload", nil, `{"id":"1"}`, http.StatusOK},
+		{"permanent error", nil, `{}`, http.StatusOK},
+		{"retry", nil, `{"id":13}`, http.StatusInternalServerError},
+		{"retries exhausted", map[string]string{"X-CloudTasks-TaskRetryCount": "3"}, `{"id":13}`, http.StatusOK},
+	}
+	for _, tc := range tests {
+		req := httptest.NewRequest("POST", "/tasks", strings.NewReader(tc.body))
+		req.Header.Set("X-CloudTasks-QueueName", "orders")
+		req.Header.Set("X-CloudTasks-TaskName", "task-1")
+		req.Header.Set("X-CloudTasks-TaskRetryCount", "0")
+		req.Header.Set("X-Task-Type", "order")
+		req.Header.Set("Authorization", "Bearer valid")
+		for k, v := range tc.headers {
+			req.Header.Set(k, v)
+		}
+		rr := httptest.NewRecorder()
+		d.ServeHTTP(rr, req)
+		if rr.Code != tc.status {
+			t.Errorf("%s: status %d, want %d", tc.name, rr.Code, tc.status)
+		}
+	}
+	if len(got) != 1 || got[0].ID != 1 {
+		t.Errorf("handled orders %+v, want [{1}]", got)
+	}
+	if gotInfo.RetryCount != 3 {
+		t.Errorf("retry count %d, want 3", gotInfo.RetryCount)
+	}
+}
+
+func TestDispatcherSpoofed(t *testing.T) {
+	called := false
+	d := newTestDispatcher()
+	Register(d, "order", func(ctx context.Context, info TaskInfo, o order) error {
+		called = true
+		return nil
+	})
+	noAudience := newTestDispatcher()
+	noAudience.Audience = ""
+
+	tests := []struct {
+		name   string
+		d      *Dispatcher
+		auth   string
+		status int
+	}{
+		{"no token", d, "", http.StatusUnauthorized},
+		{"invalid token", d, "Bearer forged", http.StatusUnauthorized},
+		{"other service account", d, "Bearer other", http.StatusForbidden},
+		{"no audience", noAudience, "Bearer valid", http.StatusUnauthorized},
+	}
+	for _, tc := range tests {
+		req := httptest.NewRequest("POST", "/tasks", strings.NewReader(`{"id":1}`))
+		req.Header.Set("X-CloudTasks-QueueName", "orders")
+		req.Header.Set("X-CloudTasks-TaskName", "task-1")
+		req.Header.Set("X-Task-Type", "order")
+		if tc.auth != "" {
+			req.Header.Set("Authorization", tc.auth)
+		}
+		rr := httptest.NewRecorder()
+		tc.d.ServeHTTP(rr, req)
+		if rr.Code != tc.status {
+			t.Errorf("%s: status %d, want %d", tc.name, rr.Code, tc.status)
+		}
+	}
+	if called {
+		t.Error("handler called for a spoofed task")
+	}
+}
+
+func TestDispatcherAppEngine(t *testing.T) {
+	tests := []struct {
+		name      string
+		appEngine bool
+		status    int
+	}{
+		{"on App Engine", true, http.StatusOK},
+		// Anyone can send X-AppEngine-* headers elsewhere.
+		{"elsewhere", false, http.StatusUnauthorized},
+	}
+	for _, tc := range tests {
+		called := false
+		d := newTestDispatcher()
+		d.AppEngine = tc.appEngine
+		Register(d, "order", func(ctx context.Context, info TaskInfo, o order) error {
+			called = true
+			return nil
+		})
+		req := httptest.NewRequest("POST", "/tasks", strings.NewReader(`{"id":1}`))
+		req.Header.Set("X-AppEngine-QueueName", "orders")
+		req.Header.Set("X-AppEngine-TaskName", "task-1")
+		req.Header.Set("X-Task-Type", "order")
+		rr := httptest.NewRecorder()
+		d.ServeHTTP(rr, req)
+		if rr.Code != tc.status || called != tc.appEngine {
+			t.Errorf("%s: status %d, handler called %t, want %d, %t", tc.name, rr.Code, called, tc.status, tc.appEngine)
+		}
+	}
+}
+
+func TestTaskInfo(t *testing.T) {
+	req := httptest.NewRequest("POST", "/tasks", nil)
+	req.Header.Set("X-AppEngine-QueueName", "default")
+	req.Header.Set("X-AppEngine-TaskName", "1234")
+	req.Header.Set("X-AppEngine-TaskRetryCount", "2")
+	req.Header.Set("X-AppEngine-TaskExecutionCount", "1")
+	req.Header.Set("X-AppEngine-TaskETA", "1700000000.250000")
+	req.Header.Set("X-AppEngine-TaskPreviousResponse", "503")
+	req.Header.Set("X-Task-Type", "email")
+
+	info, ok := taskInfo(req, false)
+	want := TaskInfo{
+		Queue:            "default",
+		Name:             "1234",
+		Type:             "email",
+		RetryCount:       2,
+		ExecutionCount:   1,
+		ETA:              time.UnixMilli(1700000000250),
+		PreviousResponse: 503,
+	}
+	if !ok || !info.ETA.Equal(want.ETA) {
+		t.Fatalf("taskInfo = %+v, %t, want %+v", info, ok, want)
+	}
+	info.ETA = want.ETA
+	if info != want {
+		t.Errorf("taskInfo = %+v, want %+v", info, want)
+	}
+}
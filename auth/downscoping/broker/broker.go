@@ -0,0 +1,175 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package broker implements a token broker for Credential Access
+// Boundaries.
+//
+// The broker holds a root credential. Authenticated callers ask it for a
+// token to read the objects of a bucket starting with a prefix, and, if
+// its policy allows it, the broker exchanges the root token for a
+// downscoped token limited to those objects. Callers use NewTokenSource to
+// get tokens from the broker.
+package broker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google/downscope"
+	"google.golang.org/api/idtoken"
+)
+
+// TokenRequest is the body of the requests to the broker.
+type TokenRequest struct {
+	Bucket string `json:"bucket"`
+	// Prefix restricts the token to the objects whose names start with it.
+	Prefix string `json:"prefix"`
+}
+
+// TokenResponse is the body of the responses of the broker.
+type TokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	// ExpiresIn is the lifetime of the token in seconds.
+	ExpiresIn int64 `json:"expires_in"`
+}
+
+// Broker is an http.Handler serving downscoped tokens for POST requests
+// with a JSON TokenRequest.
+type Broker struct {
+	// RootSource is the credential the tokens are downscoped from. It
+	// needs the https://www.googleapis.com/auth/cloud-platform scope.
+	RootSource oauth2.TokenSource
+	Policy     *Policy
+	// Authenticate returns the email of the caller of a request, such as
+	// the function returned by IDTokenAuthenticator.
+	Authenticate func(r *http.Request) (string, error)
+
+	// HTTPClient, if set, is used to call the Security Token Service.
+	HTTPClient *http.Client
+	// UniverseDomain is the domain of the Security Token Service. It
+	// defaults to googleapis.com.
+	UniverseDomain string
+}
+
+// IDTokenAuthenticator returns a function authenticating the callers by
+// the Google ID tokens, for the audience, in their Authorization headers.
+func IDTokenAuthenticator(audience string) func(r *http.Request) (string, error) {
+	return func(r *http.Request) (string, error) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok {
+			return "", errors.New("missing bearer token")
+		}
+		payload, err := idtoken.Validate(r.Context(), token, audience)
+		if err != nil {
+			return "", err
+		}
+		email, _ := payload.Claims["email"].(string)
+		if verified, _ := payload.Claims["email_verified"].(bool); email == "" || !verified {
+			return "", errors.New("ID token without a verified email")
+		}
+		return email, nil
+	}
+}
+
+// Token checks the request of a caller against the policy and returns a
+// downscoped token for it.
+func (b *Broker) Token(ctx context.Context, caller string, req TokenRequest) (*oauth2.Token, error) {
+	if err := checkName(req.Bucket, req.Prefix); err != nil {
+		return nil, fmt.Errorf("broker: %w", err)
+	}
+	grant, err := b.Policy.Check(caller, req.Bucket, req.Prefix)
+	if err != nil {
+		return nil, err
+	}
+	role := grant.Role
+	if role == "" {
+		role = defaultRole
+	}
+	rule := downscope.AccessBoundaryRule{
+		AvailableResource:    "//storage.googleapis.com/projects/_/buckets/" + req.Bucket,
+		AvailablePermissions: []string{"inRole:" + role},
+	}
+	if req.Prefix != "" {
+		rule.Condition = &downscope.AvailabilityCondition{
+			Expression:  "resource.name.startsWith('projects/_/buckets/" + req.Bucket + "/objects/" + req.Prefix + "')",
+			Title:       req.Prefix + " Only",
+			Description: "Restricts a token to only be able to access objects that start with `" + req.Prefix + "`",
+		}
+	}
+	if b.HTTPClient != nil {
+		ctx = context.WithValue(ctx, oauth2.HTTPClient, b.HTTPClient)
+	}
+	ts, err := downscope.NewTokenSource(ctx, downscope.DownscopingConfig{
+		RootSource:     b.RootSource,
+		Rules:          []downscope.AccessBoundaryRule{rule},
+		UniverseDomain: b.UniverseDomain,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("broker: %w", err)
+	}
+	tok, err := ts.Token()
+	if err != nil {
+		return nil, fmt.Errorf("broker: %w", err)
+	}
+	return tok, nil
+}
+
+func (b *Broker) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	caller, err := b.Authenticate(r)
+	if err != nil {
+		log.Printf("broker: authentication failed: %v", err)
+		http.Error(w, "unauthenticated", http.StatusUnauthorized)
+		return
+	}
+	var req TokenRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<16)).Decode(&req); err != nil {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+	if err := checkName(req.Bucket, req.Prefix); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	tok, err := b.Token(r.Context(), caller, req)
+	if errors.Is(err, ErrDenied) {
+		log.Print(err)
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+	if err != nil {
+		log.Printf("broker: minting a token for %s: %v", caller, err)
+		http.Error(w, "can't mint a token", http.StatusBadGateway)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(TokenResponse{
+		AccessToken: tok.AccessToken,
+		TokenType:   tok.TokenType,
+		ExpiresIn:   int64(time.Until(tok.Expiry) / time.Second),
+	})
+}
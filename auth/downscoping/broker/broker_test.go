@@ -0,0 +1,242 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package broker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google/downscope"
+)
+
+// fakeSTS is a fake Security Token Service, returning numbered tokens and
+// keeping the rules of the last exchange.
+type fakeSTS struct {
+	expiresIn int
+
+	mu    sync.Mutex
+	calls int
+	rules []downscope.AccessBoundaryRule
+}
+
+func (s *fakeSTS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/v1/token" || r.FormValue("grant_type") != "urn:ietf:params:oauth:grant-type:token-exchange" {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	if r.FormValue("subject_token") != "root" {
+		http.Error(w, "invalid subject token", http.StatusUnauthorized)
+		return
+	}
+	var options struct {
+		AccessBoundary struct {
+			AccessBoundaryRules []downscope.AccessBoundaryRule
+		}
+	}
+	if err := json.Unmarshal([]byte(r.FormValue("options")), &options); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.mu.Lock()
+	s.calls++
+	s.rules = options.AccessBoundary.AccessBoundaryRules
+	n := s.calls
+	s.mu.Unlock()
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"access_token":      fmt.Sprintf("downscoped-%d", n),
+		"issued_token_type": "urn:ietf:params:oauth:token-type:access_token",
+		"token_type":        "Bearer",
+		"expires_in":        s.expiresIn,
+	})
+}
+
+func (s *fakeSTS) lastRules() []downscope.AccessBoundaryRule {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.rules
+}
+
+// redirect sends all requests to a test server.
+type redirect struct{ srv *httptest.Server }
+
+func (rt redirect) RoundTrip(r *http.Request) (*http.Response, error) {
+	u, err := url.Parse(rt.srv.URL)
+	if err != nil {
+		return nil, err
+	}
+	r = r.Clone(r.Context())
+	r.URL.Scheme, r.URL.Host = u.Scheme, u.Host
+	return http.DefaultTransport.RoundTrip(r)
+}
+
+const policyJSON = `{"grants": [
+	{"callers": ["app@example.com"], "bucket": "foo", "prefixes": ["profile-picture-"]},
+	{"callers": ["admin@example.com"], "bucket": "foo", "role": "roles/storage.objectAdmin"}
+]}`
+
+func setup(t *testing.T, expiresIn int) (*fakeSTS, *httptest.Server) {
+	t.Helper()
+	file := filepath.Join(t.TempDir(), "policy.json")
+	if err := os.WriteFile(file, []byte(policyJSON), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	policy, err := LoadPolicy(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sts := &fakeSTS{expiresIn: expiresIn}
+	stsSrv := httptest.NewServer(sts)
+	t.Cleanup(stsSrv.Close)
+	b := &Broker{
+		RootSource: oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "root"}),
+		Policy:     policy,
+		Authenticate: func(r *http.Request) (string, error) {
+			caller := r.Header.Get("X-Caller")
+			if caller == "" {
+				return "", errors.New("no caller")
+			}
+			return caller, nil
+		},
+		HTTPClient: &http.Client{Transport: redirect{stsSrv}},
+	}
+	srv := httptest.NewServer(b)
+	t.Cleanup(srv.Close)
+	return sts, srv
+}
+
+// callerTransport authenticates requests as a caller.
+type callerTransport string
+
+func (c callerTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	r = r.Clone(r.Context())
+	r.Header.Set("X-Caller", string(c))
+	return http.DefaultTransport.RoundTrip(r)
+}
+
+func clientFor(caller string) *http.Client {
+	return &http.Client{Transport: callerTransport(caller)}
+}
+
+func TestBroker(t *testing.T) {
+	sts, srv := setup(t, 3600)
+	ctx := context.Background()
+
+	ts := NewTokenSource(ctx, srv.URL, "foo", "profile-picture-alice", clientFor("app@example.com"))
+	tok, err := ts.Token()
+	if err != nil || tok.AccessToken != "downscoped-1" || !tok.Valid() {
+		t.Fatalf("Token = %+v, %v; want downscoped-1", tok, err)
+	}
+	rules := sts.lastRules()
+	if len(rules) != 1 || rules[0].Condition == nil {
+		t.Fatalf("rules %+v, want one rule with a condition", rules)
+	}
+	if got, want := rules[0].Condition.Expression, "resource.name.startsWith('projects/_/buckets/foo/objects/profile-picture-alice')"; got != want {
+		t.Errorf("condition %q, want %q", got, want)
+	}
+	if got, want := rules[0].AvailablePermissions, "inRole:roles/storage.objectViewer"; len(got) != 1 || got[0] != want {
+		t.Errorf("permissions %q, want %q", got, want)
+	}
+
+	// Grants without prefixes give tokens for the whole bucket.
+	ts = NewTokenSource(ctx, srv.URL, "foo", "", clientFor("admin@example.com"))
+	if _, err := ts.Token(); err != nil {
+		t.Fatal(err)
+	}
+	if rules := sts.lastRules(); rules[0].Condition != nil || rules[0].AvailablePermissions[0] != "inRole:roles/storage.objectAdmin" {
+		t.Errorf("admin rules %+v, want objectAdmin without condition", rules[0])
+	}
+}
+
+func TestBrokerErrors(t *testing.T) {
+	sts, srv := setup(t, 3600)
+	for _, tc := range []struct {
+		caller, bucket, prefix string
+		status                 string
+	}{
+		{"", "foo", "profile-picture-", "401"},
+		{"app@example.com", "foo", "avatar-", "403"},
+		{"app@example.com", "bar", "profile-picture-", "403"},
+		{"other@example.com", "foo", "profile-picture-", "403"},
+		{"app@example.com", "foo", "profile-picture-')||true||('", "400"},
+		{"app@example.com", "foo/x", "", "400"},
+	} {
+		ts := NewTokenSource(context.Background(), srv.URL, tc.bucket, tc.prefix, clientFor(tc.caller))
+		if _, err := ts.Token(); err == nil || !strings.Contains(err.Error(), tc.status) {
+			t.Errorf("Token(%q, %s/%s) = %v, want %s error", tc.caller, tc.bucket, tc.prefix, err, tc.status)
+		}
+	}
+	if sts.calls != 0 {
+		t.Errorf("%d STS calls for denied requests, want 0", sts.calls)
+	}
+
+	resp, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("GET status %d, want %d", resp.StatusCode, http.StatusMethodNotAllowed)
+	}
+}
+
+func TestTokenSourceRefresh(t *testing.T) {
+	for _, tc := range []struct {
+		expiresIn int
+		calls     int
+	}{
+		{3600, 1},
+		// Tokens about to expire are refreshed.
+		{5, 3},
+	} {
+		sts, srv := setup(t, tc.expiresIn)
+		ts := NewTokenSource(context.Background(), srv.URL, "foo", "profile-picture-", clientFor("app@example.com"))
+		for i := 0; i < 3; i++ {
+			if _, err := ts.Token(); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if sts.calls != tc.calls {
+			t.Errorf("expires in %ds: %d STS calls, want %d", tc.expiresIn, sts.calls, tc.calls)
+		}
+	}
+}
+
+func TestLoadPolicy(t *testing.T) {
+	dir := t.TempDir()
+	for i, data := range []string{
+		`{"grants": [{"bucket": "foo"}]}`,
+		`{"grants": [{"callers": ["a@example.com"], "bucket": "foo", "prefix": "x"}]}`,
+		`{"grants": [{"callers": ["a@example.com"], "bucket": "foo", "prefixes": ["it's"]}]}`,
+	} {
+		file := filepath.Join(dir, fmt.Sprintf("policy%d.json", i))
+		if err := os.WriteFile(file, []byte(data), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := LoadPolicy(file); err == nil {
+			t.Errorf("LoadPolicy(%s) succeeded, want error", data)
+		}
+	}
+}
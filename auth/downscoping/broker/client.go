@@ -0,0 +1,86 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package broker
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// NewTokenSource returns a token source getting tokens for the objects of
+// a bucket starting with prefix from the broker at url. The tokens are
+// reused until they are about to expire.
+//
+// client authenticates the requests to the broker, such as a client of
+// google.golang.org/api/idtoken for the audience of the broker. It
+// defaults to http.DefaultClient.
+func NewTokenSource(ctx context.Context, url, bucket, prefix string, client *http.Client) oauth2.TokenSource {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return oauth2.ReuseTokenSource(nil, &brokerTokenSource{
+		ctx:    ctx,
+		url:    url,
+		req:    TokenRequest{Bucket: bucket, Prefix: prefix},
+		client: client,
+	})
+}
+
+type brokerTokenSource struct {
+	ctx    context.Context
+	url    string
+	req    TokenRequest
+	client *http.Client
+}
+
+func (s *brokerTokenSource) Token() (*oauth2.Token, error) {
+	body, err := json.Marshal(s.req)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(s.ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("broker: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("broker: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<10))
+		return nil, fmt.Errorf("broker: %s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+	var tr TokenResponse
+	if err := json.NewDecoder(resp.Body).Decode(&tr); err != nil {
+		return nil, fmt.Errorf("broker: decoding token: %w", err)
+	}
+	if tr.AccessToken == "" {
+		return nil, fmt.Errorf("broker: empty token")
+	}
+	return &oauth2.Token{
+		AccessToken: tr.AccessToken,
+		TokenType:   tr.TokenType,
+		Expiry:      time.Now().Add(time.Duration(tr.ExpiresIn) * time.Second),
+	}, nil
+}
@@ -0,0 +1,62 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Command tokenbroker serves downscoped tokens to the callers allowed by a
+// policy file.
+//
+// It uses Application Default Credentials as the root credential, and
+// authenticates the callers by Google ID tokens. It is configured by the
+// environment variables POLICY_FILE, AUDIENCE, the audience of the ID
+// tokens, and PORT.
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"os"
+
+	"github.com/GoogleCloudPlatform/golang-samples/auth/downscoping/broker"
+	"golang.org/x/oauth2/google"
+)
+
+func main() {
+	policy, err := broker.LoadPolicy(os.Getenv("POLICY_FILE"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	audience := os.Getenv("AUDIENCE")
+	if audience == "" {
+		log.Fatal("AUDIENCE is required")
+	}
+	rootSource, err := google.DefaultTokenSource(context.Background(), "https://www.googleapis.com/auth/cloud-platform")
+	if err != nil {
+		log.Fatalf("failed to generate rootSource: %v", err)
+	}
+	http.Handle("/token", &broker.Broker{
+		RootSource:   rootSource,
+		Policy:       policy,
+		Authenticate: broker.IDTokenAuthenticator(audience),
+	})
+
+	port := os.Getenv("PORT")
+	if port == "" {
+		port = "8080"
+		log.Printf("Defaulting to port %s", port)
+	}
+	log.Printf("Listening on port %s", port)
+	if err := http.ListenAndServe(":"+port, nil); err != nil {
+		log.Fatal(err)
+	}
+}
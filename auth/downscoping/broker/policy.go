@@ -0,0 +1,112 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package broker
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+)
+
+// ErrDenied is returned for requests the policy doesn't allow.
+var ErrDenied = errors.New("broker: request denied by policy")
+
+// defaultRole is the role of grants without one.
+const defaultRole = "roles/storage.objectViewer"
+
+// Policy lists the buckets and prefixes callers can get tokens for.
+type Policy struct {
+	Grants []Grant `json:"grants"`
+}
+
+// Grant allows callers to get tokens for objects of a bucket.
+type Grant struct {
+	// Callers are the emails of the allowed callers.
+	Callers []string `json:"callers"`
+	Bucket  string   `json:"bucket"`
+	// Prefixes, if set, restrict the tokens to objects whose names start
+	// with one of them. A caller can request a longer prefix.
+	Prefixes []string `json:"prefixes"`
+	// Role is the role available to the tokens. It defaults to
+	// roles/storage.objectViewer.
+	Role string `json:"role"`
+}
+
+// LoadPolicy reads a JSON policy file, such as
+//
+//	{"grants": [{
+//		"callers": ["app@my-project.iam.gserviceaccount.com"],
+//		"bucket": "foo",
+//		"prefixes": ["profile-picture-"]
+//	}]}
+func LoadPolicy(file string) (*Policy, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("broker: %w", err)
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	var p Policy
+	if err := dec.Decode(&p); err != nil {
+		return nil, fmt.Errorf("broker: %s: %w", file, err)
+	}
+	for i, g := range p.Grants {
+		if len(g.Callers) == 0 || g.Bucket == "" {
+			return nil, fmt.Errorf("broker: %s: grant %d needs callers and a bucket", file, i)
+		}
+		if err := checkName(g.Bucket, g.Prefixes...); err != nil {
+			return nil, fmt.Errorf("broker: %s: grant %d: %w", file, i, err)
+		}
+	}
+	return &p, nil
+}
+
+// Check returns the grant allowing a caller to get a token for the objects
+// of a bucket starting with prefix, or an error wrapping ErrDenied.
+func (p *Policy) Check(caller, bucket, prefix string) (*Grant, error) {
+	for i := range p.Grants {
+		g := &p.Grants[i]
+		if g.Bucket != bucket || !slices.Contains(g.Callers, caller) {
+			continue
+		}
+		if len(g.Prefixes) == 0 {
+			return g, nil
+		}
+		for _, allowed := range g.Prefixes {
+			if strings.HasPrefix(prefix, allowed) {
+				return g, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("%w: %s for gs://%s/%s", ErrDenied, caller, bucket, prefix)
+}
+
+// checkName checks that a bucket and prefixes can be used in a condition
+// expression.
+func checkName(bucket string, prefixes ...string) error {
+	if bucket == "" || strings.ContainsAny(bucket, "/'\\") {
+		return fmt.Errorf("invalid bucket %q", bucket)
+	}
+	for _, p := range prefixes {
+		if strings.ContainsAny(p, "'\\") {
+			return fmt.Errorf("invalid prefix %q", p)
+		}
+	}
+	return nil
+}
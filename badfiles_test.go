@@ -66,6 +66,8 @@ var allowList = []string{
 	"**/testdata/**/*.csv",
 	"**/testdata/**/*.mp4",
 	"**/testdata/*.jsonl",
+	"**/testdata/**/*.json",
+	"**/testdata/**/*.golden",
 
 	// Healthcare data.
 	"healthcare/testdata/dicom_00000001_000.dcm",
@@ -0,0 +1,223 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Command cdnsign signs and verifies URLs and cookies for Cloud CDN and
+// Media CDN, and manages keyset files:
+//
+//	cdnsign url -keyset keys.json -ttl 1h https://media.example.com/video.mp4
+//	cdnsign url -keyset keys.json -prefix https://media.example.com/segments/ https://media.example.com/segments/1.ts
+//	cdnsign cookie -keyset keys.json https://media.example.com/segments/
+//	cdnsign verify -keyset keys.json 'https://media.example.com/video.mp4?Expires=...'
+//	cdnsign verify -keyset keys.json -cookie 'URLPrefix=...' https://media.example.com/segments/1.ts
+//	cdnsign rotate -keyset keys.json -name key-2026-02 -algorithm ed25519
+//	cdnsign remove -keyset keys.json -name key-2026-01
+//
+// Instead of a keyset, a single key can be given with -key-file, -key-name
+// and -algorithm. Key files hold the base64url encoded key.
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"time"
+
+	"github.com/GoogleCloudPlatform/golang-samples/cdn/signing"
+)
+
+func main() {
+	log.SetFlags(0)
+	if len(os.Args) < 2 {
+		usage()
+	}
+	if err := run(os.Args[1], os.Args[2:], os.Stdout, time.Now()); err != nil {
+		if errors.Is(err, errUsage) {
+			usage()
+		}
+		log.Fatal(err)
+	}
+}
+
+var errUsage = errors.New("unknown command")
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: cdnsign url|cookie|verify|rotate|remove [flags] [URL]")
+	os.Exit(2)
+}
+
+// run runs the named subcommand, writing its output to w.
+func run(cmd string, args []string, w io.Writer, now time.Time) error {
+	fs := flag.NewFlagSet(cmd, flag.ContinueOnError)
+	keysetFile := fs.String("keyset", "", "keyset file")
+	keyFile := fs.String("key-file", "", "file holding a single base64url encoded key, instead of -keyset")
+	keyName := fs.String("key-name", "", "name of the key in -key-file")
+	algorithm := fs.String("algorithm", string(signing.HMACSHA1), "algorithm of the key: hmac-sha1 for Cloud CDN, ed25519 for Media CDN")
+	loadKeyset := func() (*signing.Keyset, error) {
+		if *keysetFile != "" {
+			return signing.ReadKeyset(*keysetFile)
+		}
+		if *keyFile == "" || *keyName == "" {
+			return nil, fmt.Errorf("either -keyset or -key-file and -key-name are required")
+		}
+		secret, err := signing.ReadKeyFile(*keyFile)
+		if err != nil {
+			return nil, err
+		}
+		return signing.NewKeyset(signing.Key{Name: *keyName, Algorithm: signing.Algorithm(*algorithm), Secret: secret})
+	}
+
+	switch cmd {
+	case "url":
+		ttl := fs.Duration("ttl", time.Hour, "how long the signature is valid")
+		prefix := fs.String("prefix", "", "sign every URL with this prefix instead of a single URL")
+		if err := parse(fs, args, 1); err != nil {
+			return err
+		}
+		ks, err := loadKeyset()
+		if err != nil {
+			return err
+		}
+		var signed string
+		if *prefix != "" {
+			signed, err = ks.SignURLPrefix(fs.Arg(0), *prefix, now.Add(*ttl))
+		} else {
+			signed, err = ks.SignURL(fs.Arg(0), now.Add(*ttl))
+		}
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(w, signed)
+
+	case "cookie":
+		ttl := fs.Duration("ttl", time.Hour, "how long the signature is valid")
+		if err := parse(fs, args, 1); err != nil {
+			return err
+		}
+		ks, err := loadKeyset()
+		if err != nil {
+			return err
+		}
+		c, err := ks.SignCookie(fs.Arg(0), now.Add(*ttl))
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(w, c)
+
+	case "verify":
+		cookie := fs.String("cookie", "", "verify this cookie value for the URL instead of a signed URL")
+		if err := parse(fs, args, 1); err != nil {
+			return err
+		}
+		ks, err := loadKeyset()
+		if err != nil {
+			return err
+		}
+		if *cookie != "" {
+			err = ks.VerifyCookie(*cookie, fs.Arg(0), now)
+		} else {
+			err = ks.VerifyURL(fs.Arg(0), now)
+		}
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(w, "OK")
+
+	case "rotate":
+		name := fs.String("name", "", "name of the new key")
+		if err := parse(fs, args, 0); err != nil {
+			return err
+		}
+		if *keysetFile == "" || *name == "" {
+			return fmt.Errorf("rotate: -keyset and -name are required")
+		}
+		ks, err := signing.ReadKeyset(*keysetFile)
+		if os.IsNotExist(err) {
+			ks, err = &signing.Keyset{}, nil
+		}
+		if err != nil {
+			return err
+		}
+		k, public, err := generateKey(*name, signing.Algorithm(*algorithm))
+		if err != nil {
+			return err
+		}
+		if err := ks.Add(k); err != nil {
+			return err
+		}
+		if err := ks.WriteFile(*keysetFile); err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "Added primary key %s. Add it to the CDN before signing with it:\n%s\n", k.Name, public)
+
+	case "remove":
+		name := fs.String("name", "", "name of the key to remove")
+		if err := parse(fs, args, 0); err != nil {
+			return err
+		}
+		ks, err := signing.ReadKeyset(*keysetFile)
+		if err != nil {
+			return err
+		}
+		if err := ks.Remove(*name); err != nil {
+			return err
+		}
+		if err := ks.WriteFile(*keysetFile); err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "Removed key %s.\n", *name)
+
+	default:
+		return errUsage
+	}
+	return nil
+}
+
+// parse parses args and checks that n positional arguments remain.
+func parse(fs *flag.FlagSet, args []string, n int) error {
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != n {
+		return fmt.Errorf("%s: want %d argument(s), got %d", fs.Name(), n, fs.NArg())
+	}
+	return nil
+}
+
+// generateKey returns a new random key, and the value to register with the
+// CDN: the secret for Cloud CDN, or the public key for Media CDN.
+func generateKey(name string, alg signing.Algorithm) (signing.Key, string, error) {
+	k := signing.Key{Name: name, Algorithm: alg}
+	switch alg {
+	case signing.HMACSHA1:
+		k.Secret = make([]byte, 16)
+		if _, err := rand.Read(k.Secret); err != nil {
+			return k, "", err
+		}
+		return k, base64.URLEncoding.EncodeToString(k.Secret), nil
+	case signing.Ed25519:
+		pub, priv, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return k, "", err
+		}
+		k.Secret, k.PublicKey = priv.Seed(), pub
+		return k, base64.RawURLEncoding.EncodeToString(pub), nil
+	}
+	return k, "", fmt.Errorf("unknown algorithm %q", alg)
+}
@@ -0,0 +1,86 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRun(t *testing.T) {
+	now := time.Unix(1558131350, 0)
+	dir := t.TempDir()
+	keyFile := filepath.Join(dir, "key")
+	if err := os.WriteFile(keyFile, []byte("nZtRohdNF9m3cKM24IcK4w==\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	keyFlags := []string{"-key-file", keyFile, "-key-name", "my-key"}
+
+	var out bytes.Buffer
+	if err := run("url", append(keyFlags, "-ttl", "0s", "http://35.186.234.33/index.html"), &out, now); err != nil {
+		t.Fatalf("url: %v", err)
+	}
+	want := "http://35.186.234.33/index.html?Expires=1558131350&KeyName=my-key&Signature=fm6JZSmKNsB5sys8VGr-JE4LiiE=\n"
+	if out.String() != want {
+		t.Errorf("url: got %q, want %q", out.String(), want)
+	}
+
+	signed := strings.TrimSpace(out.String())
+	out.Reset()
+	if err := run("verify", append(keyFlags, signed), &out, now); err != nil {
+		t.Errorf("verify: %v", err)
+	}
+	if err := run("verify", append(keyFlags, signed), &out, now.Add(time.Minute)); err == nil {
+		t.Errorf("verify after expiry: got nil error")
+	}
+	if err := run("sign", nil, &out, now); err != errUsage {
+		t.Errorf("unknown command: got %v, want %v", err, errUsage)
+	}
+}
+
+func TestRotate(t *testing.T) {
+	now := time.Now()
+	keyset := filepath.Join(t.TempDir(), "keys.json")
+	var out bytes.Buffer
+	for _, name := range []string{"key-1", "key-2"} {
+		if err := run("rotate", []string{"-keyset", keyset, "-name", name, "-algorithm", "ed25519"}, &out, now); err != nil {
+			t.Fatalf("rotate %s: %v", name, err)
+		}
+	}
+
+	out.Reset()
+	if err := run("cookie", []string{"-keyset", keyset, "https://media.example.com/segments/"}, &out, now); err != nil {
+		t.Fatalf("cookie: %v", err)
+	}
+	name, rest, _ := strings.Cut(strings.TrimSpace(out.String()), "=")
+	value, _, _ := strings.Cut(rest, ";")
+	if name != "Edge-Cache-Cookie" || !strings.Contains(value, "KeyName=key-2") {
+		t.Errorf("cookie: got %s=%s, want Edge-Cache-Cookie signed with key-2", name, value)
+	}
+	if err := run("verify", []string{"-keyset", keyset, "-cookie", value, "https://media.example.com/segments/1.ts"}, &out, now); err != nil {
+		t.Errorf("verify -cookie: %v", err)
+	}
+
+	if err := run("remove", []string{"-keyset", keyset, "-name", "key-2"}, &out, now); err == nil {
+		t.Errorf("remove primary key: got nil error")
+	}
+	if err := run("remove", []string{"-keyset", keyset, "-name", "key-1"}, &out, now); err != nil {
+		t.Errorf("remove: %v", err)
+	}
+}
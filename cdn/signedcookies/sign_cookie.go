@@ -12,8 +12,11 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
-// Package signedcookie creates a signed URL for a Cloud CDN endpoint with the
-// given key.
+// Package signedcookie creates a signed cookie for a Cloud CDN endpoint with
+// the given key.
+//
+// To sign and verify cookies from the command line, with key rotation across
+// a keyset, use the cdnsign command built on the cdn/signing package.
 package signedcookie
 
 // [START cloudcdn_sign_cookie]
@@ -22,10 +25,7 @@ import (
 	"crypto/sha1"
 	"encoding/base64"
 	"fmt"
-	"io"
 	"io/ioutil"
-	"net/http"
-	"os"
 	"time"
 )
 
@@ -68,46 +68,3 @@ func readKeyFile(path string) ([]byte, error) {
 	}
 	return d[:n], nil
 }
-
-func generateSignedCookie(w io.Writer) error {
-	// The path to a file containing the base64-encoded signing key
-	keyPath := os.Getenv("KEY_PATH")
-
-	// Note: consider using the GCP Secret Manager for managing access to your
-	// signing key(s).
-	key, err := readKeyFile(keyPath)
-	if err != nil {
-		return err
-	}
-
-	var (
-		// domain and path should match the user-facing URL for accessing
-		// content.
-		domain     = "media.example.com"
-		path       = "/segments/"
-		keyName    = "my-key"
-		expiration = time.Hour * 2
-	)
-
-	signedValue, err := signCookie(fmt.Sprintf("https://%s%s", domain,
-		path), keyName, key, time.Now().Add(expiration))
-	if err != nil {
-		return err
-	}
-
-	// Use Go's http.Cookie type to construct a cookie.
-	cookie := &http.Cookie{
-		Name:   "Cloud-CDN-Cookie",
-		Value:  signedValue,
-		Path:   path, // Best practice: only send the cookie for paths it is valid for
-		Domain: domain,
-		MaxAge: int(expiration.Seconds()),
-	}
-
-	// We print this to stdout in this example. In a real application, use the
-	// SetCookie method on a http.ResponseWriter to write the cookie to the
-	// user.
-	fmt.Fprintln(w, cookie)
-
-	return nil
-}
@@ -20,6 +20,8 @@ import (
 	"os"
 	"testing"
 	"time"
+
+	"github.com/GoogleCloudPlatform/golang-samples/cdn/signing"
 )
 
 func TestReadKeyFile(t *testing.T) {
@@ -129,3 +131,35 @@ func TestSignCookie(t *testing.T) {
 			})
 	}
 }
+
+// TestSignCookieKeyset checks that the sample signs cookies like the
+// cdn/signing package, which cdnsign uses.
+func TestSignCookieKeyset(t *testing.T) {
+	key := signing.Key{
+		Name:      "my-key",
+		Algorithm: signing.HMACSHA1,
+		Secret: []byte{0x9d, 0x9b, 0x51, 0xa2, 0x17, 0x4d, 0x17, 0xd9,
+			0xb7, 0x70, 0xa3, 0x36, 0xe0, 0x87, 0x0a, 0xe3},
+	}
+	ks, err := signing.NewKeyset(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	prefix := "https://media.example.com/segments/"
+	expires := time.Unix(1558131350, 0)
+
+	want, err := ks.SignCookie(prefix, expires)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := signCookie(prefix, key.Name, key.Secret, expires)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != want.Value {
+		t.Errorf("signCookie = %s, want %s", got, want.Value)
+	}
+	if err := ks.VerifyCookie(got, prefix+"1.ts", expires.Add(-time.Minute)); err != nil {
+		t.Errorf("VerifyCookie: %v", err)
+	}
+}
@@ -14,6 +14,9 @@
 
 // Package signedurl creates a signed URL for a Cloud CDN endpoint with the
 // given key.
+//
+// To sign and verify URLs from the command line, with key rotation across
+// a keyset, use the cdnsign command built on the cdn/signing package.
 package signedurl
 
 // [START cloudcdn_sign_url]
@@ -23,9 +26,7 @@ import (
 	"crypto/sha1"
 	"encoding/base64"
 	"fmt"
-	"io"
 	"io/ioutil"
-	"os"
 	"strings"
 	"time"
 )
@@ -94,29 +95,3 @@ func readKeyFile(path string) ([]byte, error) {
 	}
 	return d[:n], nil
 }
-
-func generateSignedURLs(w io.Writer) error {
-	// The path to a file containing the base64-encoded signing key
-	keyPath := os.Getenv("KEY_PATH")
-
-	// Note: consider using the GCP Secret Manager for managing access to your
-	// signing key(s).
-	key, err := readKeyFile(keyPath)
-	if err != nil {
-		return err
-	}
-
-	// Generate a signed URL for a specific URL
-	url := signURL("https://example.com/media/1234.m3e8", "my-key", key, time.Now().Add(time.Hour*24))
-	fmt.Fprintln(w, url)
-
-	// Generate a signed URL for
-	urlPrefix, err := signURLWithPrefix("https://www.google.com/", "my-key", key, time.Unix(1549751401, 0))
-	if err != nil {
-		return err
-	}
-
-	fmt.Fprintln(w, urlPrefix)
-
-	return nil
-}
@@ -0,0 +1,170 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package signing signs and verifies URLs, URL prefixes and cookies for
+// Cloud CDN and Media CDN.
+//
+// Cloud CDN signs requests with HMAC-SHA1 keys, and Media CDN with Ed25519
+// keys. The two schemes share the Expires, KeyName and URLPrefix fields, but
+// differ in the signature algorithm, the base64 padding and the cookie name.
+// The scheme is chosen by the algorithm of the key in use, so a Keyset can
+// sign for either CDN.
+//
+// See https://cloud.google.com/cdn/docs/using-signed-urls and
+// https://cloud.google.com/media-cdn/docs/use-dual-token-authentication.
+package signing
+
+import (
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"fmt"
+	"strings"
+)
+
+// Algorithm is a signature algorithm.
+type Algorithm string
+
+const (
+	// HMACSHA1 signs with a 16-byte shared secret, as Cloud CDN does.
+	HMACSHA1 Algorithm = "hmac-sha1"
+	// Ed25519 signs with an Ed25519 private key, as Media CDN does.
+	Ed25519 Algorithm = "ed25519"
+)
+
+// Cookie names read by each CDN.
+const (
+	CloudCDNCookie = "Cloud-CDN-Cookie"
+	MediaCDNCookie = "Edge-Cache-Cookie"
+)
+
+// Key is a named signing key.
+type Key struct {
+	// Name must match the name of the key added to the backend service,
+	// backend bucket or Media CDN keyset.
+	Name      string
+	Algorithm Algorithm
+	// Secret is the raw 16-byte HMAC key, or the Ed25519 private key as a
+	// 32-byte seed or 64-byte private key. It can be left empty for an
+	// Ed25519 key that is only used to verify signatures.
+	Secret []byte
+	// PublicKey is the Ed25519 public key. It is derived from Secret if
+	// unset.
+	PublicKey ed25519.PublicKey
+}
+
+// validate checks that k holds usable key material.
+func (k *Key) validate() error {
+	if k.Name == "" {
+		return fmt.Errorf("key has no name")
+	}
+	switch k.Algorithm {
+	case HMACSHA1:
+		if len(k.Secret) == 0 {
+			return fmt.Errorf("key %q: HMAC key has no secret", k.Name)
+		}
+	case Ed25519:
+		switch len(k.Secret) {
+		case 0:
+			if len(k.PublicKey) != ed25519.PublicKeySize {
+				return fmt.Errorf("key %q: Ed25519 public key is %d bytes, want %d", k.Name, len(k.PublicKey), ed25519.PublicKeySize)
+			}
+		case ed25519.SeedSize, ed25519.PrivateKeySize:
+		default:
+			return fmt.Errorf("key %q: Ed25519 private key is %d bytes, want %d or %d", k.Name, len(k.Secret), ed25519.SeedSize, ed25519.PrivateKeySize)
+		}
+	default:
+		return fmt.Errorf("key %q: unknown algorithm %q", k.Name, k.Algorithm)
+	}
+	return nil
+}
+
+// privateKey returns the Ed25519 private key, or nil if k has none.
+func (k *Key) privateKey() ed25519.PrivateKey {
+	if len(k.Secret) == ed25519.SeedSize {
+		return ed25519.NewKeyFromSeed(k.Secret)
+	}
+	if len(k.Secret) == ed25519.PrivateKeySize {
+		return ed25519.PrivateKey(k.Secret)
+	}
+	return nil
+}
+
+// publicKey returns the Ed25519 public key.
+func (k *Key) publicKey() ed25519.PublicKey {
+	if len(k.PublicKey) > 0 {
+		return k.PublicKey
+	}
+	if priv := k.privateKey(); priv != nil {
+		return priv.Public().(ed25519.PublicKey)
+	}
+	return nil
+}
+
+// encoding returns the base64 encoding of signatures and URL prefixes:
+// Cloud CDN pads them and Media CDN does not.
+func (k *Key) encoding() *base64.Encoding {
+	if k.Algorithm == Ed25519 {
+		return base64.RawURLEncoding
+	}
+	return base64.URLEncoding
+}
+
+// CookieName returns the name of the cookie that the CDN using k reads.
+func (k *Key) CookieName() string {
+	if k.Algorithm == Ed25519 {
+		return MediaCDNCookie
+	}
+	return CloudCDNCookie
+}
+
+// sign returns the encoded signature of msg.
+func (k *Key) sign(msg string) (string, error) {
+	var sig []byte
+	switch k.Algorithm {
+	case HMACSHA1:
+		mac := hmac.New(sha1.New, k.Secret)
+		mac.Write([]byte(msg))
+		sig = mac.Sum(nil)
+	case Ed25519:
+		priv := k.privateKey()
+		if priv == nil {
+			return "", fmt.Errorf("key %q has no private key", k.Name)
+		}
+		sig = ed25519.Sign(priv, []byte(msg))
+	default:
+		return "", fmt.Errorf("key %q: unknown algorithm %q", k.Name, k.Algorithm)
+	}
+	return k.encoding().EncodeToString(sig), nil
+}
+
+// verify reports whether sig is the encoded signature of msg. Padding is
+// optional, as clients may strip it.
+func (k *Key) verify(msg, sig string) bool {
+	raw, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(sig, "="))
+	if err != nil {
+		return false
+	}
+	switch k.Algorithm {
+	case HMACSHA1:
+		mac := hmac.New(sha1.New, k.Secret)
+		mac.Write([]byte(msg))
+		return hmac.Equal(raw, mac.Sum(nil))
+	case Ed25519:
+		pub := k.publicKey()
+		return pub != nil && ed25519.Verify(pub, []byte(msg), raw)
+	}
+	return false
+}
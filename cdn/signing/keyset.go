@@ -0,0 +1,189 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package signing
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Keyset holds the keys of a backend. New signatures use the primary key,
+// and signatures made with any key in the set are accepted, so keys can be
+// rotated without invalidating URLs and cookies that are still in use.
+//
+// A Keyset must not be modified while it is used concurrently.
+type Keyset struct {
+	// Primary is the name of the key used to sign.
+	Primary string
+	Keys    []Key
+}
+
+// NewKeyset returns a Keyset holding keys, with the first one as primary.
+func NewKeyset(keys ...Key) (*Keyset, error) {
+	ks := &Keyset{}
+	for i := len(keys) - 1; i >= 0; i-- {
+		if err := ks.Add(keys[i]); err != nil {
+			return nil, err
+		}
+	}
+	return ks, nil
+}
+
+// Key returns the named key.
+func (ks *Keyset) Key(name string) (*Key, bool) {
+	for i := range ks.Keys {
+		if ks.Keys[i].Name == name {
+			return &ks.Keys[i], true
+		}
+	}
+	return nil, false
+}
+
+// Add adds k to the set and makes it the primary key. Add the new key to
+// the CDN before signing with it.
+func (ks *Keyset) Add(k Key) error {
+	if err := k.validate(); err != nil {
+		return err
+	}
+	if _, ok := ks.Key(k.Name); ok {
+		return fmt.Errorf("key %q already exists", k.Name)
+	}
+	ks.Keys = append(ks.Keys, k)
+	ks.Primary = k.Name
+	return nil
+}
+
+// Remove removes the named key, so signatures made with it are no longer
+// accepted. The primary key cannot be removed.
+func (ks *Keyset) Remove(name string) error {
+	if name == ks.Primary {
+		return fmt.Errorf("cannot remove primary key %q", name)
+	}
+	for i := range ks.Keys {
+		if ks.Keys[i].Name == name {
+			ks.Keys = append(ks.Keys[:i], ks.Keys[i+1:]...)
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %q", ErrUnknownKey, name)
+}
+
+// primary returns the key to sign with.
+func (ks *Keyset) primary() (*Key, error) {
+	k, ok := ks.Key(ks.Primary)
+	if !ok {
+		return nil, fmt.Errorf("%w: primary key %q", ErrUnknownKey, ks.Primary)
+	}
+	return k, nil
+}
+
+// keysetFile is the JSON form of a Keyset. Key material is base64url
+// encoded, as in the key files that Cloud CDN and Media CDN accept.
+type keysetFile struct {
+	Primary string    `json:"primary"`
+	Keys    []keyJSON `json:"keys"`
+}
+
+type keyJSON struct {
+	Name      string    `json:"name"`
+	Algorithm Algorithm `json:"algorithm"`
+	Secret    string    `json:"secret,omitempty"`
+	PublicKey string    `json:"publicKey,omitempty"`
+}
+
+// ReadKeyset reads a keyset file, such as:
+//
+//	{
+//	  "primary": "key-2026-02",
+//	  "keys": [
+//	    {"name": "key-2026-01", "algorithm": "hmac-sha1", "secret": "nZtRohdNF9m3cKM24IcK4w=="},
+//	    {"name": "key-2026-02", "algorithm": "hmac-sha1", "secret": "..."}
+//	  ]
+//	}
+//
+// Ed25519 keys set "secret" to the private key to sign, or only
+// "publicKey" to verify.
+func ReadKeyset(name string) (*Keyset, error) {
+	b, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	var f keysetFile
+	if err := json.Unmarshal(b, &f); err != nil {
+		return nil, fmt.Errorf("parsing keyset %s: %w", name, err)
+	}
+	ks := &Keyset{Primary: f.Primary}
+	for _, kj := range f.Keys {
+		k := Key{Name: kj.Name, Algorithm: kj.Algorithm}
+		if k.Secret, err = decodeKey(kj.Secret); err != nil {
+			return nil, fmt.Errorf("keyset %s: key %q: secret: %w", name, kj.Name, err)
+		}
+		if k.PublicKey, err = decodeKey(kj.PublicKey); err != nil {
+			return nil, fmt.Errorf("keyset %s: key %q: public key: %w", name, kj.Name, err)
+		}
+		if err := k.validate(); err != nil {
+			return nil, fmt.Errorf("keyset %s: %w", name, err)
+		}
+		if _, ok := ks.Key(k.Name); ok {
+			return nil, fmt.Errorf("keyset %s: duplicate key %q", name, k.Name)
+		}
+		ks.Keys = append(ks.Keys, k)
+	}
+	if _, err := ks.primary(); err != nil {
+		return nil, fmt.Errorf("keyset %s: %w", name, err)
+	}
+	return ks, nil
+}
+
+// WriteFile writes ks to the named keyset file, readable by the owner only.
+func (ks *Keyset) WriteFile(name string) error {
+	f := keysetFile{Primary: ks.Primary}
+	for _, k := range ks.Keys {
+		kj := keyJSON{Name: k.Name, Algorithm: k.Algorithm}
+		if len(k.Secret) > 0 {
+			kj.Secret = k.encoding().EncodeToString(k.Secret)
+		}
+		if len(k.PublicKey) > 0 {
+			kj.PublicKey = base64.RawURLEncoding.EncodeToString(k.PublicKey)
+		}
+		f.Keys = append(f.Keys, kj)
+	}
+	b, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(name, append(b, '\n'), 0o600)
+}
+
+// ReadKeyFile reads a file holding a single base64url encoded key, the
+// format that gcloud accepts when adding a key to a backend.
+func ReadKeyFile(name string) ([]byte, error) {
+	b, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	return decodeKey(strings.TrimSpace(string(b)))
+}
+
+// decodeKey decodes base64url with or without padding.
+func decodeKey(s string) ([]byte, error) {
+	if s == "" {
+		return nil, nil
+	}
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+}
@@ -0,0 +1,125 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package signing
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// SignURL signs rawURL with the primary key. The URL must not already have
+// the Expires, KeyName, URLPrefix or Signature query parameters.
+func (ks *Keyset) SignURL(rawURL string, expires time.Time) (string, error) {
+	k, err := ks.primary()
+	if err != nil {
+		return "", err
+	}
+	if err := checkUnsigned(rawURL); err != nil {
+		return "", err
+	}
+	toSign := fmt.Sprintf("%s%sExpires=%d&KeyName=%s", rawURL, querySep(rawURL), expires.Unix(), k.Name)
+	sig, err := k.sign(toSign)
+	if err != nil {
+		return "", err
+	}
+	return toSign + "&Signature=" + sig, nil
+}
+
+// SignURLPrefix signs rawURL with a signature that is valid for every URL
+// starting with prefix, such as the segments of a video stream. rawURL must
+// start with prefix.
+func (ks *Keyset) SignURLPrefix(rawURL, prefix string, expires time.Time) (string, error) {
+	k, err := ks.primary()
+	if err != nil {
+		return "", err
+	}
+	if !strings.HasPrefix(rawURL, prefix) {
+		return "", fmt.Errorf("URL %q does not start with prefix %q", rawURL, prefix)
+	}
+	if err := checkUnsigned(rawURL); err != nil {
+		return "", err
+	}
+	value, err := signPrefix(k, prefix, expires, "&")
+	if err != nil {
+		return "", err
+	}
+	return rawURL + querySep(rawURL) + value, nil
+}
+
+// SignCookie returns a cookie granting access to every URL starting with
+// prefix. Its domain and path are those of prefix, so browsers only send it
+// where it is valid.
+func (ks *Keyset) SignCookie(prefix string, expires time.Time) (*http.Cookie, error) {
+	k, err := ks.primary()
+	if err != nil {
+		return nil, err
+	}
+	u, err := url.Parse(prefix)
+	if err != nil {
+		return nil, fmt.Errorf("invalid URL prefix: %w", err)
+	}
+	value, err := signPrefix(k, prefix, expires, ":")
+	if err != nil {
+		return nil, err
+	}
+	return &http.Cookie{
+		Name:    k.CookieName(),
+		Value:   value,
+		Domain:  u.Hostname(),
+		Path:    u.Path,
+		Expires: expires,
+	}, nil
+}
+
+// signPrefix returns the URLPrefix, Expires, KeyName and Signature fields
+// joined with sep: "&" in URLs, and ":" in cookies.
+func signPrefix(k *Key, prefix string, expires time.Time, sep string) (string, error) {
+	toSign := strings.Join([]string{
+		"URLPrefix=" + k.encoding().EncodeToString([]byte(prefix)),
+		fmt.Sprintf("Expires=%d", expires.Unix()),
+		"KeyName=" + k.Name,
+	}, sep)
+	sig, err := k.sign(toSign)
+	if err != nil {
+		return "", err
+	}
+	return toSign + sep + "Signature=" + sig, nil
+}
+
+// querySep returns the separator to append a query parameter to rawURL.
+func querySep(rawURL string) string {
+	if strings.Contains(rawURL, "?") {
+		return "&"
+	}
+	return "?"
+}
+
+// checkUnsigned returns an error if rawURL already has signing parameters.
+func checkUnsigned(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("invalid URL: %w", err)
+	}
+	q := u.Query()
+	for _, p := range []string{"Expires", "KeyName", "URLPrefix", "Signature"} {
+		if q.Has(p) {
+			return fmt.Errorf("URL %q already has the %s parameter", rawURL, p)
+		}
+	}
+	return nil
+}
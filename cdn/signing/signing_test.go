@@ -0,0 +1,397 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package signing
+
+import (
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// cloudCDNKey is the key used by the cdn/signedurls and cdn/signedcookies
+// samples. The expected signatures below are taken from their tests.
+var cloudCDNKey = Key{
+	Name:      "my-key",
+	Algorithm: HMACSHA1,
+	Secret: []byte{0x9d, 0x9b, 0x51, 0xa2, 0x17, 0x4d, 0x17, 0xd9,
+		0xb7, 0x70, 0xa3, 0x36, 0xe0, 0x87, 0x0a, 0xe3},
+}
+
+// mediaCDNKey is the key used by the mediacdn samples.
+var mediaCDNKey = Key{
+	Name:      "my-key",
+	Algorithm: Ed25519,
+	Secret: []byte{34, 31, 185, 24, 168, 225, 242, 115, 112, 155, 38,
+		157, 183, 65, 104, 243, 85, 182, 188, 26, 176, 101, 247, 177,
+		243, 93, 114, 156, 94, 191, 219, 75, 183, 211, 110, 78, 223,
+		133, 62, 172, 159, 217, 158, 126, 34, 6, 254, 108, 57, 194,
+		141, 93, 219, 91, 8, 162, 88, 62, 52, 75, 42, 103, 202, 238,
+	},
+}
+
+func mustKeyset(t *testing.T, keys ...Key) *Keyset {
+	t.Helper()
+	ks, err := NewKeyset(keys...)
+	if err != nil {
+		t.Fatalf("NewKeyset: %v", err)
+	}
+	return ks
+}
+
+func TestSignURL(t *testing.T) {
+	cases := []struct {
+		name    string
+		key     Key
+		url     string
+		expires time.Time
+		want    string
+	}{
+		{
+			name:    "Cloud CDN",
+			key:     cloudCDNKey,
+			url:     "http://35.186.234.33/index.html",
+			expires: time.Unix(1558131350, 0),
+			want:    "http://35.186.234.33/index.html?Expires=1558131350&KeyName=my-key&Signature=fm6JZSmKNsB5sys8VGr-JE4LiiE=",
+		},
+		{
+			name:    "Media CDN",
+			key:     mediaCDNKey,
+			url:     "http://35.186.234.33/index.html",
+			expires: time.Unix(1558131350, 0),
+			want:    "http://35.186.234.33/index.html?Expires=1558131350&KeyName=my-key&Signature=bwCkNAIuVneG0cRPwwPDk1vGmMfqR_TbFfLguwdsfF8Pdlk8INOKICYVOTHY5jHlGgwSF2jkRkm8bWZGwu-SAw",
+		},
+		{
+			name:    "Media CDN with query",
+			key:     mediaCDNKey,
+			url:     "https://www.example.com/some/path?some=query&another=param",
+			expires: time.Unix(1549751461, 0),
+			want:    "https://www.example.com/some/path?some=query&another=param&Expires=1549751461&KeyName=my-key&Signature=kM8uoFD9tfNKqOe1ulQpWUutBL4oQERxcR6sCg-brtPOSGJXqvuUOyEP1EsGzVCesI6epkY4AxYC9yCAuY1GDQ",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			ks := mustKeyset(t, c.key)
+			got, err := ks.SignURL(c.url, c.expires)
+			if err != nil {
+				t.Fatalf("SignURL: %v", err)
+			}
+			if got != c.want {
+				t.Errorf("SignURL:\ngot  %s\nwant %s", got, c.want)
+			}
+			if err := ks.VerifyURL(got, c.expires); err != nil {
+				t.Errorf("VerifyURL: %v", err)
+			}
+		})
+	}
+}
+
+func TestSignURLPrefix(t *testing.T) {
+	cases := []struct {
+		name    string
+		key     Key
+		url     string
+		prefix  string
+		expires time.Time
+		want    string
+	}{
+		{
+			name:    "Cloud CDN",
+			key:     cloudCDNKey,
+			url:     "https://media.example.com/segments/0001.ts",
+			prefix:  "https://media.example.com/segments/",
+			expires: time.Unix(1558131350, 0),
+			want:    "https://media.example.com/segments/0001.ts?URLPrefix=aHR0cHM6Ly9tZWRpYS5leGFtcGxlLmNvbS9zZWdtZW50cy8=&Expires=1558131350&KeyName=my-key&Signature=HWE5tBTZgnYVoZzVLG7BtRnOsgk=",
+		},
+		{
+			name:    "Media CDN",
+			key:     mediaCDNKey,
+			url:     "https://www.google.com/",
+			prefix:  "https://www.google.com/",
+			expires: time.Unix(1549751401, 0),
+			want:    "https://www.google.com/?URLPrefix=aHR0cHM6Ly93d3cuZ29vZ2xlLmNvbS8&Expires=1549751401&KeyName=my-key&Signature=f82Yhq9HrFXuAKNKlKpt7qk3e1BKo2OCtIy6JF0HA2j_l1IUF69ZFBXposUSky_fgvVvTpxi9IOJCONTKiMNDw",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			ks := mustKeyset(t, c.key)
+			got, err := ks.SignURLPrefix(c.url, c.prefix, c.expires)
+			if err != nil {
+				t.Fatalf("SignURLPrefix: %v", err)
+			}
+			if got != c.want {
+				t.Errorf("SignURLPrefix:\ngot  %s\nwant %s", got, c.want)
+			}
+			if err := ks.VerifyURL(got, c.expires); err != nil {
+				t.Errorf("VerifyURL: %v", err)
+			}
+			// The signature is valid for other URLs with the prefix.
+			other := strings.Replace(got, c.url, c.prefix+"other/file", 1)
+			if err := ks.VerifyURL(other, c.expires); err != nil {
+				t.Errorf("VerifyURL(%s): %v", other, err)
+			}
+		})
+	}
+
+	ks := mustKeyset(t, cloudCDNKey)
+	if _, err := ks.SignURLPrefix("https://example.com/a", "https://example.com/b", time.Now()); err == nil {
+		t.Errorf("SignURLPrefix with a URL outside the prefix: got nil error")
+	}
+}
+
+func TestSignCookie(t *testing.T) {
+	cases := []struct {
+		name     string
+		key      Key
+		prefix   string
+		expires  time.Time
+		wantName string
+		want     string
+	}{
+		{
+			name:     "Cloud CDN",
+			key:      cloudCDNKey,
+			prefix:   "https://media.example.com/segments/",
+			expires:  time.Unix(1558131350, 0),
+			wantName: CloudCDNCookie,
+			want:     "URLPrefix=aHR0cHM6Ly9tZWRpYS5leGFtcGxlLmNvbS9zZWdtZW50cy8=:Expires=1558131350:KeyName=my-key:Signature=_qwhz38bxCKdiDqENLIx4ujrw-U=",
+		},
+		{
+			name:     "Media CDN",
+			key:      mediaCDNKey,
+			prefix:   "https://www.example.com/some",
+			expires:  time.Unix(1549751461, 0),
+			wantName: MediaCDNCookie,
+			want:     "URLPrefix=aHR0cHM6Ly93d3cuZXhhbXBsZS5jb20vc29tZQ:Expires=1549751461:KeyName=my-key:Signature=MjRwgGa4vJJ5lkVt1xJSoi-LyMk5x-bf1AmUBr-2XiB6zP4LSqHsmQZoeZA4fVw6C7HCcNqQT1UzGPgGe7bpAQ",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			ks := mustKeyset(t, c.key)
+			cookie, err := ks.SignCookie(c.prefix, c.expires)
+			if err != nil {
+				t.Fatalf("SignCookie: %v", err)
+			}
+			if cookie.Name != c.wantName || cookie.Value != c.want {
+				t.Errorf("SignCookie: got %s=%s, want %s=%s", cookie.Name, cookie.Value, c.wantName, c.want)
+			}
+			if err := ks.VerifyCookie(cookie.Value, c.prefix+"/index.m3u8", c.expires); err != nil {
+				t.Errorf("VerifyCookie: %v", err)
+			}
+			if err := ks.VerifyCookie(cookie.Value, "https://elsewhere.example.com/", c.expires); !errors.Is(err, ErrPrefixMismatch) {
+				t.Errorf("VerifyCookie for another URL: got %v, want %v", err, ErrPrefixMismatch)
+			}
+		})
+	}
+}
+
+func TestVerifyURLErrors(t *testing.T) {
+	now := time.Unix(1558131350, 0)
+	ks := mustKeyset(t, cloudCDNKey)
+	signed, err := ks.SignURL("https://example.com/video.mp4", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	prefixed, err := ks.SignURLPrefix("https://example.com/videos/1.mp4", "https://example.com/videos/", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// anyPrefix is the encoded prefix of every https://example.com/ URL.
+	anyPrefix := cloudCDNKey.encoding().EncodeToString([]byte("https://example.com/"))
+
+	cases := []struct {
+		name string
+		url  string
+		now  time.Time
+		want error
+	}{
+		{"expired", signed, now.Add(time.Second), ErrExpired},
+		{"tampered path", strings.Replace(signed, "video.mp4", "other.mp4", 1), now, ErrInvalidSignature},
+		{"tampered expiry", strings.Replace(signed, "Expires=1558131350", "Expires=1858131350", 1), now, ErrInvalidSignature},
+		{"unknown key", strings.Replace(signed, "KeyName=my-key", "KeyName=old-key", 1), now, ErrUnknownKey},
+		{"no signature", "https://example.com/video.mp4?Expires=1558131350&KeyName=my-key", now, ErrMalformed},
+		{"signature not last", signed + "&extra=1", now, ErrMalformed},
+		{"prefix mismatch", strings.Replace(prefixed, "/videos/1.mp4", "/private/1.mp4", 1), now, ErrPrefixMismatch},
+		{"invalid expiry", "https://example.com/?Expires=soon&KeyName=my-key&Signature=AAAA", now, ErrMalformed},
+		// Signing parameters in front of a genuine signed tail are
+		// rejected, rather than read instead of the signed ones.
+		{"injected prefix", strings.Replace(prefixed, "?URLPrefix=", "?URLPrefix="+anyPrefix+"&Expires=99999999999&URLPrefix=", 1), now, ErrMalformed},
+		{"injected path", strings.Replace(prefixed, "/videos/1.mp4?", "/secret/payroll.csv?URLPrefix="+anyPrefix+"&Expires=99999999999&KeyName=my-key&", 1), now, ErrMalformed},
+		{"repeated expiry", strings.Replace(signed, "?Expires=", "?Expires=99999999999&Expires=", 1), now, ErrMalformed},
+	}
+	for _, c := range cases {
+		if err := ks.VerifyURL(c.url, c.now); !errors.Is(err, c.want) {
+			t.Errorf("%s: VerifyURL(%s): got %v, want %v", c.name, c.url, err, c.want)
+		}
+	}
+}
+
+func TestVerifyWithPublicKeyOnly(t *testing.T) {
+	signer := mustKeyset(t, mediaCDNKey)
+	verifier := mustKeyset(t, Key{
+		Name:      mediaCDNKey.Name,
+		Algorithm: Ed25519,
+		PublicKey: mediaCDNKey.publicKey(),
+	})
+	expires := time.Now().Add(time.Hour)
+	u, err := signer.SignURL("https://example.com/live.m3u8", expires)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := verifier.VerifyURL(u, time.Now()); err != nil {
+		t.Errorf("VerifyURL: %v", err)
+	}
+	if _, err := verifier.SignURL("https://example.com/", expires); err == nil {
+		t.Errorf("SignURL without a private key: got nil error")
+	}
+}
+
+func TestKeyRotation(t *testing.T) {
+	now := time.Unix(1558131350, 0)
+	ks := mustKeyset(t, cloudCDNKey)
+	old, err := ks.SignURL("https://example.com/a", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	next := Key{Name: "key-2", Algorithm: HMACSHA1, Secret: []byte("0123456789abcdef")}
+	if err := ks.Add(next); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	if ks.Primary != "key-2" {
+		t.Errorf("after Add: got primary %q, want key-2", ks.Primary)
+	}
+	rotated, err := ks.SignURL("https://example.com/a", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(rotated, "KeyName=key-2") {
+		t.Errorf("SignURL after rotation = %s, want KeyName=key-2", rotated)
+	}
+	// URLs signed with either key are accepted until the old key is
+	// removed.
+	for _, u := range []string{old, rotated} {
+		if err := ks.VerifyURL(u, now); err != nil {
+			t.Errorf("VerifyURL(%s): %v", u, err)
+		}
+	}
+	if err := ks.Remove("key-2"); err == nil {
+		t.Errorf("Remove(primary key): got nil error")
+	}
+	if err := ks.Remove("my-key"); err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
+	if err := ks.VerifyURL(old, now); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("VerifyURL after removing the key: got %v, want %v", err, ErrUnknownKey)
+	}
+	if err := ks.Add(next); err == nil {
+		t.Errorf("Add(duplicate key): got nil error")
+	}
+}
+
+func TestKeysetFile(t *testing.T) {
+	name := t.TempDir() + "/keyset.json"
+	ks := mustKeyset(t, mediaCDNKey, Key{Name: "cloud-cdn", Algorithm: HMACSHA1, Secret: cloudCDNKey.Secret})
+	if err := ks.WriteFile(name); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	got, err := ReadKeyset(name)
+	if err != nil {
+		t.Fatalf("ReadKeyset: %v", err)
+	}
+	if got.Primary != "my-key" || len(got.Keys) != 2 {
+		t.Fatalf("ReadKeyset: got primary %q with %d keys, want my-key with 2", got.Primary, len(got.Keys))
+	}
+	b, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// HMAC keys are written padded, as gcloud expects them.
+	if want := `"secret": "nZtRohdNF9m3cKM24IcK4w=="`; !strings.Contains(string(b), want) {
+		t.Errorf("keyset file does not contain %s:\n%s", want, b)
+	}
+
+	bad := map[string]string{
+		"no primary":    `{"keys": [{"name": "a", "algorithm": "hmac-sha1", "secret": "nZtRohdNF9m3cKM24IcK4w"}]}`,
+		"bad algorithm": `{"primary": "a", "keys": [{"name": "a", "algorithm": "rsa", "secret": "nZtRohdNF9m3cKM24IcK4w"}]}`,
+		"short ed25519": `{"primary": "a", "keys": [{"name": "a", "algorithm": "ed25519", "secret": "nZtRohdNF9m3cKM24IcK4w"}]}`,
+		"bad base64":    `{"primary": "a", "keys": [{"name": "a", "algorithm": "hmac-sha1", "secret": "not base64!"}]}`,
+		"duplicate":     `{"primary": "a", "keys": [{"name": "a", "algorithm": "hmac-sha1", "secret": "AA"}, {"name": "a", "algorithm": "hmac-sha1", "secret": "AA"}]}`,
+	}
+	for desc, content := range bad {
+		if err := os.WriteFile(name, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := ReadKeyset(name); err == nil {
+			t.Errorf("ReadKeyset(%s): got nil error", desc)
+		}
+	}
+}
+
+func TestHandler(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	ks := mustKeyset(t, cloudCDNKey)
+	origin := httptest.NewServer(ks.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "content")
+	})))
+	defer origin.Close()
+
+	expires := time.Now().Add(time.Hour)
+	signed, err := ks.SignURL(origin.URL+"/file.mp4", expires)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cookie, err := ks.SignCookie(origin.URL+"/segments/", expires)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name   string
+		url    string
+		cookie *http.Cookie
+		want   int
+	}{
+		{"signed URL", signed, nil, http.StatusOK},
+		{"signed cookie", origin.URL + "/segments/1.ts", cookie, http.StatusOK},
+		{"cookie outside prefix", origin.URL + "/other/1.ts", cookie, http.StatusForbidden},
+		{"unsigned", origin.URL + "/file.mp4", nil, http.StatusForbidden},
+	}
+	for _, c := range cases {
+		req, err := http.NewRequest("GET", c.url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c.cookie != nil {
+			req.AddCookie(c.cookie)
+		}
+		resp, err := origin.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != c.want {
+			t.Errorf("%s: got status %d, want %d", c.name, resp.StatusCode, c.want)
+		}
+	}
+}
@@ -0,0 +1,173 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package signing
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Errors returned when verification fails. They are wrapped with details.
+var (
+	ErrMalformed        = errors.New("malformed signed request")
+	ErrUnknownKey       = errors.New("unknown key")
+	ErrInvalidSignature = errors.New("invalid signature")
+	ErrExpired          = errors.New("signature expired")
+	ErrPrefixMismatch   = errors.New("URL does not match the signed prefix")
+)
+
+// VerifyURL checks a URL signed with SignURL or SignURLPrefix: the key must
+// be in the set, the signature must match and it must not have expired at
+// now.
+func (ks *Keyset) VerifyURL(rawURL string, now time.Time) error {
+	i := strings.LastIndex(rawURL, "&Signature=")
+	if i < 0 {
+		return fmt.Errorf("%w: no Signature parameter", ErrMalformed)
+	}
+	signed, sig := rawURL[:i], rawURL[i+len("&Signature="):]
+	if strings.ContainsAny(sig, "&#") {
+		return fmt.Errorf("%w: Signature is not the last parameter", ErrMalformed)
+	}
+	u, err := url.Parse(signed)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrMalformed, err)
+	}
+	q := u.Query()
+	for _, p := range []string{"Expires", "KeyName", "URLPrefix", "Signature"} {
+		if len(q[p]) > 1 {
+			return fmt.Errorf("%w: repeated %s parameter", ErrMalformed, p)
+		}
+	}
+	if !q.Has("URLPrefix") {
+		// The whole URL is signed.
+		return ks.check(signed, sig, "", q.Get("Expires"), q.Get("KeyName"), "", now)
+	}
+	// Only the fields from URLPrefix on are signed, so they are read from
+	// there: the URLPrefix, Expires and KeyName parameters, in this order.
+	j := strings.LastIndex(signed, "URLPrefix=")
+	if j < 1 || (signed[j-1] != '?' && signed[j-1] != '&') {
+		return fmt.Errorf("%w: URLPrefix is not a query parameter", ErrMalformed)
+	}
+	fields := strings.Split(signed[j:], "&")
+	if len(fields) != 3 {
+		return fmt.Errorf("%w: URLPrefix, Expires and KeyName must be the last parameters", ErrMalformed)
+	}
+	var values [3]string
+	for i, name := range []string{"URLPrefix", "Expires", "KeyName"} {
+		v, ok := strings.CutPrefix(fields[i], name+"=")
+		if !ok {
+			return fmt.Errorf("%w: URLPrefix, Expires and KeyName must be the last parameters", ErrMalformed)
+		}
+		values[i] = v
+	}
+	return ks.check(signed[j:], sig, values[0], values[1], values[2], signed[:j-1], now)
+}
+
+// VerifyCookie checks the value of a cookie made with SignCookie for a
+// request to rawURL.
+func (ks *Keyset) VerifyCookie(value, rawURL string, now time.Time) error {
+	i := strings.LastIndex(value, ":Signature=")
+	if i < 0 {
+		return fmt.Errorf("%w: no Signature field", ErrMalformed)
+	}
+	signed, sig := value[:i], value[i+len(":Signature="):]
+	fields := map[string]string{}
+	for _, f := range strings.Split(signed, ":") {
+		k, v, ok := strings.Cut(f, "=")
+		if !ok {
+			return fmt.Errorf("%w: field %q", ErrMalformed, f)
+		}
+		fields[k] = v
+	}
+	if fields["URLPrefix"] == "" {
+		return fmt.Errorf("%w: no URLPrefix field", ErrMalformed)
+	}
+	return ks.check(signed, sig, fields["URLPrefix"], fields["Expires"], fields["KeyName"], rawURL, now)
+}
+
+// check verifies sig over msg with the named key. If encodedPrefix is set,
+// target must start with the prefix it encodes.
+func (ks *Keyset) check(msg, sig, encodedPrefix, expires, keyName, target string, now time.Time) error {
+	if expires == "" || keyName == "" {
+		return fmt.Errorf("%w: Expires and KeyName are required", ErrMalformed)
+	}
+	exp, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil {
+		return fmt.Errorf("%w: Expires %q", ErrMalformed, expires)
+	}
+	k, ok := ks.Key(keyName)
+	if !ok {
+		return fmt.Errorf("%w: %q", ErrUnknownKey, keyName)
+	}
+	if !k.verify(msg, sig) {
+		return fmt.Errorf("%w: key %q", ErrInvalidSignature, keyName)
+	}
+	if encodedPrefix != "" {
+		prefix, err := decodeKey(encodedPrefix)
+		if err != nil {
+			return fmt.Errorf("%w: URLPrefix: %v", ErrMalformed, err)
+		}
+		if !strings.HasPrefix(target, string(prefix)) {
+			return fmt.Errorf("%w: %q", ErrPrefixMismatch, prefix)
+		}
+	}
+	if expiry := time.Unix(exp, 0); now.After(expiry) {
+		return fmt.Errorf("%w at %v", ErrExpired, expiry.UTC())
+	}
+	return nil
+}
+
+// VerifyRequest checks the signed URL or, failing that, the signed cookie
+// of r, as the CDN would before serving it. It is meant for origins and
+// local test proxies; the scheme of the request URL is taken from the
+// X-Forwarded-Proto header if set.
+func (ks *Keyset) VerifyRequest(r *http.Request, now time.Time) error {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	if p := r.Header.Get("X-Forwarded-Proto"); p != "" {
+		scheme = p
+	}
+	rawURL := scheme + "://" + r.Host + r.URL.RequestURI()
+	if r.URL.Query().Has("Signature") {
+		return ks.VerifyURL(rawURL, now)
+	}
+	for _, name := range []string{CloudCDNCookie, MediaCDNCookie} {
+		if c, err := r.Cookie(name); err == nil {
+			return ks.VerifyCookie(c.Value, rawURL, now)
+		}
+	}
+	return fmt.Errorf("%w: no signed URL or cookie", ErrMalformed)
+}
+
+// Handler returns a handler that serves requests passing VerifyRequest
+// with next, and answers others with 403 Forbidden.
+func (ks *Keyset) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := ks.VerifyRequest(r, time.Now()); err != nil {
+			log.Printf("Rejecting %s: %v", r.URL.Path, err)
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
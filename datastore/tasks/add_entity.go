@@ -28,7 +28,14 @@ type Task struct {
 	Desc    string    `datastore:"description"`
 	Created time.Time `datastore:"created"`
 	Done    bool      `datastore:"done"`
-	id      int64     // The integer ID used in the datastore.
+	// Priority is higher for more important tasks.
+	Priority int `datastore:"priority"`
+	// Due is the due date. Tasks without one are not indexed by due date,
+	// so they are left out of queries on it.
+	Due   time.Time `datastore:"due,omitempty"`
+	Tags  []string  `datastore:"tags"`
+	Owner string    `datastore:"owner"`
+	id    int64     // The integer ID used in the datastore.
 }
 
 // AddTask adds a task with the given description to the datastore,
@@ -0,0 +1,89 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"cloud.google.com/go/datastore"
+)
+
+// maxBatch is the maximum number of entities written in a transaction.
+const maxBatch = 500
+
+func taskKeys(ids []int64) ([]*datastore.Key, error) {
+	if len(ids) > maxBatch {
+		return nil, fmt.Errorf("%d tasks, can't change more than %d at once", len(ids), maxBatch)
+	}
+	keys := make([]*datastore.Key, len(ids))
+	for i, id := range ids {
+		keys[i] = datastore.IDKey("Task", id, nil)
+	}
+	return keys, nil
+}
+
+// updateTasks applies fn to tasks in a transaction: either all the tasks
+// are updated, or none is, for example if one of them doesn't exist.
+func updateTasks(ctx context.Context, client *datastore.Client, ids []int64, fn func(*Task)) error {
+	keys, err := taskKeys(ids)
+	if err != nil {
+		return err
+	}
+	_, err = client.RunInTransaction(ctx, func(tx *datastore.Transaction) error {
+		tasks := make([]*Task, len(keys))
+		if err := tx.GetMulti(keys, tasks); err != nil {
+			return err
+		}
+		for _, t := range tasks {
+			fn(t)
+		}
+		_, err := tx.PutMulti(keys, tasks)
+		return err
+	})
+	return err
+}
+
+// MarkAllDone marks tasks done, in a transaction.
+func MarkAllDone(ctx context.Context, client *datastore.Client, ids []int64) error {
+	return updateTasks(ctx, client, ids, func(t *Task) { t.Done = true })
+}
+
+// TagAll adds a tag to tasks, in a transaction.
+func TagAll(ctx context.Context, client *datastore.Client, tag string, ids []int64) error {
+	return updateTasks(ctx, client, ids, func(t *Task) {
+		if !slices.Contains(t.Tags, tag) {
+			t.Tags = append(t.Tags, tag)
+		}
+	})
+}
+
+// DeleteAll deletes tasks, in a transaction. It fails without deleting any
+// task if one of them doesn't exist.
+func DeleteAll(ctx context.Context, client *datastore.Client, ids []int64) error {
+	keys, err := taskKeys(ids)
+	if err != nil {
+		return err
+	}
+	_, err = client.RunInTransaction(ctx, func(tx *datastore.Transaction) error {
+		// Delete succeeds for missing entities, so check that they exist.
+		if err := tx.GetMulti(keys, make([]Task, len(keys))); err != nil {
+			return err
+		}
+		return tx.DeleteMulti(keys)
+	})
+	return err
+}
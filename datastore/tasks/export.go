@@ -0,0 +1,121 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"time"
+
+	"cloud.google.com/go/datastore"
+)
+
+// jsonTask is the JSON encoding of a task.
+type jsonTask struct {
+	ID          int64      `json:"id,omitempty"`
+	Description string     `json:"description"`
+	Created     time.Time  `json:"created"`
+	Done        bool       `json:"done"`
+	Priority    int        `json:"priority,omitempty"`
+	Due         *time.Time `json:"due,omitempty"`
+	Tags        []string   `json:"tags,omitempty"`
+	Owner       string     `json:"owner,omitempty"`
+}
+
+// ExportTasks writes all the tasks as a JSON array, by creation time.
+func ExportTasks(ctx context.Context, client *datastore.Client, w io.Writer) (int, error) {
+	var tasks []*Task
+	keys, err := client.GetAll(ctx, datastore.NewQuery("Task").Order("created"), &tasks)
+	if err != nil {
+		return 0, err
+	}
+	out := make([]jsonTask, len(tasks))
+	for i, t := range tasks {
+		out[i] = jsonTask{
+			ID:          keys[i].ID,
+			Description: t.Desc,
+			Created:     t.Created,
+			Done:        t.Done,
+			Priority:    t.Priority,
+			Tags:        t.Tags,
+			Owner:       t.Owner,
+		}
+		if !t.Due.IsZero() {
+			out[i].Due = &t.Due
+		}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return len(out), enc.Encode(out)
+}
+
+// ImportTasks reads a JSON array of tasks, as written by ExportTasks, and
+// stores the tasks. Tasks with an ID replace the existing task with the
+// ID, which is reserved so that Datastore doesn't allocate it to new tasks.
+// Others are added. Each batch of tasks is written in a transaction: if
+// the import fails, the tasks of the previous batches stay written.
+func ImportTasks(ctx context.Context, client *datastore.Client, r io.Reader) (int, error) {
+	var in []jsonTask
+	if err := json.NewDecoder(r).Decode(&in); err != nil {
+		return 0, err
+	}
+	var keys, reserved []*datastore.Key
+	var tasks []*Task
+	for _, t := range in {
+		key := datastore.IncompleteKey("Task", nil)
+		if t.ID != 0 {
+			key = datastore.IDKey("Task", t.ID, nil)
+			reserved = append(reserved, key)
+		}
+		task := &Task{
+			Desc:     t.Description,
+			Created:  t.Created,
+			Done:     t.Done,
+			Priority: t.Priority,
+			Tags:     t.Tags,
+			Owner:    t.Owner,
+		}
+		if task.Created.IsZero() {
+			task.Created = time.Now()
+		}
+		if t.Due != nil {
+			task.Due = *t.Due
+		}
+		keys = append(keys, key)
+		tasks = append(tasks, task)
+	}
+
+	if len(reserved) > 0 {
+		if err := client.ReserveIDs(ctx, reserved); err != nil {
+			return 0, err
+		}
+	}
+
+	n := 0
+	for len(keys) > 0 {
+		size := min(len(keys), maxBatch)
+		_, err := client.RunInTransaction(ctx, func(tx *datastore.Transaction) error {
+			_, err := tx.PutMulti(keys[:size], tasks[:size])
+			return err
+		})
+		if err != nil {
+			return n, err
+		}
+		n += size
+		keys, tasks = keys[size:], tasks[size:]
+	}
+	return n, nil
+}
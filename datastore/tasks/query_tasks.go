@@ -0,0 +1,132 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/datastore"
+	"google.golang.org/api/iterator"
+)
+
+// TaskQuery selects and sorts tasks. The combinations of filters and sort
+// orders need the composite indexes in index.yaml.
+type TaskQuery struct {
+	// Owner and Tag, if set, select the tasks with the owner and tag.
+	Owner string
+	Tag   string
+	// Done, if set, selects the done or the open tasks.
+	Done *bool
+	// Priority, if positive, selects the tasks with the priority.
+	Priority int
+	// DueBefore, if set, selects the tasks due before the time. It needs
+	// to sort by due date first.
+	DueBefore time.Time
+
+	// Order is a property to sort by: created, priority or due, prefixed
+	// with "-" for descending order. The default is created.
+	Order string
+	// Limit is the size of a page. The default is 20.
+	Limit int
+	// Cursor is the cursor of the page to return, from a previous query.
+	Cursor string
+}
+
+var sortable = map[string]bool{"created": true, "priority": true, "due": true}
+
+// query returns the datastore query, and the size of a page.
+func (q *TaskQuery) query() (*datastore.Query, int, error) {
+	query := datastore.NewQuery("Task")
+	if q.Owner != "" {
+		query = query.FilterField("owner", "=", q.Owner)
+	}
+	if q.Tag != "" {
+		// An equality filter on a list matches any of its values.
+		query = query.FilterField("tags", "=", q.Tag)
+	}
+	if q.Done != nil {
+		query = query.FilterField("done", "=", *q.Done)
+	}
+	if q.Priority > 0 {
+		query = query.FilterField("priority", "=", q.Priority)
+	}
+
+	order := q.Order
+	if order == "" {
+		order = "created"
+	}
+	if !sortable[strings.TrimPrefix(order, "-")] {
+		return nil, 0, fmt.Errorf("can't sort by %q", order)
+	}
+	if !q.DueBefore.IsZero() {
+		// The first sort order must be on the property of an inequality
+		// filter.
+		if strings.TrimPrefix(order, "-") != "due" {
+			return nil, 0, errors.New("filtering by due date needs sorting by due date")
+		}
+		query = query.FilterField("due", "<", q.DueBefore)
+	}
+	query = query.Order(order)
+
+	limit := q.Limit
+	if limit <= 0 {
+		limit = 20
+	}
+	query = query.Limit(limit)
+	if q.Cursor != "" {
+		cursor, err := datastore.DecodeCursor(q.Cursor)
+		if err != nil {
+			return nil, 0, fmt.Errorf("invalid cursor: %w", err)
+		}
+		query = query.Start(cursor)
+	}
+	return query, limit, nil
+}
+
+// QueryTasks returns a page of tasks, and the cursor of the next page,
+// which is empty after the last page.
+func QueryTasks(ctx context.Context, client *datastore.Client, q *TaskQuery) ([]*Task, string, error) {
+	query, limit, err := q.query()
+	if err != nil {
+		return nil, "", err
+	}
+	var tasks []*Task
+	it := client.Run(ctx, query)
+	for {
+		var task Task
+		key, err := it.Next(&task)
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, "", err
+		}
+		task.id = key.ID
+		tasks = append(tasks, &task)
+	}
+	// Only a full page may be followed by more tasks.
+	if len(tasks) < limit {
+		return tasks, "", nil
+	}
+	cursor, err := it.Cursor()
+	if err != nil {
+		return nil, "", err
+	}
+	return tasks, cursor.String(), nil
+}
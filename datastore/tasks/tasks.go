@@ -18,6 +18,9 @@ package main
 
 import (
 	"bufio"
+	"context"
+	"errors"
+	"flag"
 	"fmt"
 	"io"
 	"log"
@@ -25,6 +28,9 @@ import (
 	"strconv"
 	"strings"
 	"text/tabwriter"
+	"time"
+
+	"cloud.google.com/go/datastore"
 )
 
 func main() {
@@ -41,107 +47,250 @@ func main() {
 	// Print welcome message.
 	fmt.Println("Cloud Datastore Task List")
 	fmt.Println()
-	usage()
+	usage(os.Stdout)
 
 	// Read commands from stdin.
+	s := &session{client: client, w: os.Stdout}
 	scanner := bufio.NewScanner(os.Stdin)
 	fmt.Print("> ")
 
 	for scanner.Scan() {
-		cmd, args, n := parseCmd(scanner.Text())
-		switch cmd {
-		case "new":
-			if args == "" {
-				log.Printf("Missing description in %q command", cmd)
-				usage()
-				break
+		if err := s.exec(context.Background(), scanner.Text()); err != nil {
+			log.Print(err)
+			if errors.Is(err, errUsage) {
+				usage(os.Stdout)
 			}
-			key, err := AddTask(projID, args)
+		}
+		fmt.Print("> ")
+	}
+
+	if err := scanner.Err(); err != nil {
+		log.Fatalf("Failed reading stdin: %v", err)
+	}
+}
+
+var errUsage = errors.New("invalid command")
+
+// session runs the commands of the task list manager.
+type session struct {
+	client *datastore.Client
+	w      io.Writer
+	// last is the last list query, continued by the more command.
+	last *TaskQuery
+}
+
+// exec runs a command line.
+func (s *session) exec(ctx context.Context, line string) error {
+	cmd, args := "", []string(nil)
+	if f := strings.Fields(line); len(f) > 0 {
+		cmd, args = f[0], f[1:]
+	}
+	switch cmd {
+	case "":
+		return nil
+
+	case "new":
+		task := &Task{Created: time.Now()}
+		fs := newFlagSet(cmd)
+		fs.IntVar(&task.Priority, "p", 0, "")
+		fs.StringVar(&task.Owner, "owner", "", "")
+		tags := fs.String("tags", "", "")
+		due := fs.String("due", "", "")
+		if err := fs.Parse(args); err != nil {
+			return fmt.Errorf("%w: %v", errUsage, err)
+		}
+		task.Desc = strings.Join(fs.Args(), " ")
+		if task.Desc == "" {
+			return fmt.Errorf("%w: missing description in %q command", errUsage, cmd)
+		}
+		if *tags != "" {
+			task.Tags = strings.Split(*tags, ",")
+		}
+		if *due != "" {
+			d, err := time.ParseInLocation(time.DateOnly, *due, time.Local)
 			if err != nil {
-				log.Printf("Failed to create task: %v", err)
-				break
+				return fmt.Errorf("%w: %v", errUsage, err)
 			}
-			fmt.Printf("Created new task with ID %d\n", key.ID)
+			task.Due = d
+		}
+		key, err := s.client.Put(ctx, datastore.IncompleteKey("Task", nil), task)
+		if err != nil {
+			return fmt.Errorf("failed to create task: %w", err)
+		}
+		fmt.Fprintf(s.w, "Created new task with ID %d\n", key.ID)
 
-		case "done":
-			if n == 0 {
-				log.Printf("Missing numerical task ID in %q command", cmd)
-				usage()
-				break
-			}
-			if err := MarkDone(projID, n); err != nil {
-				log.Printf("Failed to mark task done: %v", err)
-				break
-			}
-			fmt.Printf("Task %d marked done\n", n)
+	case "done":
+		ids, err := parseIDs(cmd, args)
+		if err != nil {
+			return err
+		}
+		if err := MarkAllDone(ctx, s.client, ids); err != nil {
+			return fmt.Errorf("failed to mark tasks done: %w", err)
+		}
+		fmt.Fprintf(s.w, "%d tasks marked done\n", len(ids))
 
-		case "list":
-			tasks, err := ListTasks(projID)
+	case "delete":
+		ids, err := parseIDs(cmd, args)
+		if err != nil {
+			return err
+		}
+		if err := DeleteAll(ctx, s.client, ids); err != nil {
+			return fmt.Errorf("failed to delete tasks: %w", err)
+		}
+		fmt.Fprintf(s.w, "%d tasks deleted\n", len(ids))
+
+	case "tag":
+		if len(args) < 2 {
+			return fmt.Errorf("%w: missing tag or task IDs in %q command", errUsage, cmd)
+		}
+		ids, err := parseIDs(cmd, args[1:])
+		if err != nil {
+			return err
+		}
+		if err := TagAll(ctx, s.client, args[0], ids); err != nil {
+			return fmt.Errorf("failed to tag tasks: %w", err)
+		}
+		fmt.Fprintf(s.w, "%d tasks tagged %s\n", len(ids), args[0])
+
+	case "list":
+		q := &TaskQuery{}
+		fs := newFlagSet(cmd)
+		fs.StringVar(&q.Owner, "owner", "", "")
+		fs.StringVar(&q.Tag, "tag", "", "")
+		fs.IntVar(&q.Priority, "p", 0, "")
+		fs.StringVar(&q.Order, "sort", "", "")
+		fs.IntVar(&q.Limit, "limit", 0, "")
+		open := fs.Bool("open", false, "")
+		done := fs.Bool("done", false, "")
+		dueBefore := fs.String("due-before", "", "")
+		if err := fs.Parse(args); err != nil {
+			return fmt.Errorf("%w: %v", errUsage, err)
+		}
+		if *open || *done {
+			q.Done = done
+		}
+		if *dueBefore != "" {
+			d, err := time.ParseInLocation(time.DateOnly, *dueBefore, time.Local)
 			if err != nil {
-				log.Printf("Failed to fetch task list: %v", err)
-				break
+				return fmt.Errorf("%w: %v", errUsage, err)
 			}
-			PrintTasks(os.Stdout, tasks)
+			q.DueBefore = d
+		}
+		return s.list(ctx, q)
 
-		case "delete":
-			if n == 0 {
-				log.Printf("Missing numerical task ID in %q command", cmd)
-				usage()
-				break
-			}
-			if err := DeleteTask(projID, n); err != nil {
-				log.Printf("Failed to delete task: %v", err)
-				break
-			}
-			fmt.Printf("Task %d deleted\n", n)
+	case "more":
+		if s.last == nil || s.last.Cursor == "" {
+			return errors.New("no more tasks")
+		}
+		return s.list(ctx, s.last)
 
-		default:
-			log.Printf("Unknown command %q", cmd)
-			usage()
+	case "export", "import":
+		if len(args) != 1 {
+			return fmt.Errorf("%w: missing file in %q command", errUsage, cmd)
+		}
+		n, err := s.transfer(ctx, cmd, args[0])
+		if err != nil {
+			return fmt.Errorf("failed to %s tasks: %w", cmd, err)
 		}
+		fmt.Fprintf(s.w, "%d tasks %sed\n", n, cmd)
 
-		fmt.Print("> ")
+	default:
+		return fmt.Errorf("%w: unknown command %q", errUsage, cmd)
+	}
+	return nil
+}
+
+// list prints a page of tasks, and keeps the query for the next page.
+func (s *session) list(ctx context.Context, q *TaskQuery) error {
+	tasks, cursor, err := QueryTasks(ctx, s.client, q)
+	if err != nil {
+		return fmt.Errorf("failed to fetch task list: %w", err)
+	}
+	PrintTasks(s.w, tasks)
+	next := *q
+	next.Cursor = cursor
+	s.last = &next
+	if cursor != "" {
+		fmt.Fprintln(s.w, `Type "more" for more tasks.`)
 	}
+	return nil
+}
 
-	if err := scanner.Err(); err != nil {
-		log.Fatalf("Failed reading stdin: %v", err)
+func (s *session) transfer(ctx context.Context, cmd, name string) (int, error) {
+	if cmd == "import" {
+		f, err := os.Open(name)
+		if err != nil {
+			return 0, err
+		}
+		defer f.Close()
+		return ImportTasks(ctx, s.client, f)
 	}
+	f, err := os.Create(name)
+	if err != nil {
+		return 0, err
+	}
+	n, err := ExportTasks(ctx, s.client, f)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return n, err
+}
+
+func newFlagSet(cmd string) *flag.FlagSet {
+	fs := flag.NewFlagSet(cmd, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	return fs
+}
+
+// parseIDs parses the task IDs of a command.
+func parseIDs(cmd string, args []string) ([]int64, error) {
+	if len(args) == 0 {
+		return nil, fmt.Errorf("%w: missing numerical task ID in %q command", errUsage, cmd)
+	}
+	ids := make([]int64, len(args))
+	for i, a := range args {
+		id, err := strconv.ParseInt(a, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid task ID %q in %q command", errUsage, a, cmd)
+		}
+		ids[i] = id
+	}
+	return ids, nil
 }
 
 // PrintTasks prints the tasks to the given writer.
 func PrintTasks(w io.Writer, tasks []*Task) {
 	// Use a tab writer to help make results pretty.
 	tw := tabwriter.NewWriter(w, 8, 8, 1, ' ', 0) // Min cell size of 8.
-	fmt.Fprintf(tw, "ID\tDescription\tStatus\n")
+	fmt.Fprintf(tw, "ID\tDescription\tPriority\tDue\tOwner\tTags\tStatus\n")
 	for _, t := range tasks {
+		due := ""
+		if !t.Due.IsZero() {
+			due = t.Due.Format(time.DateOnly)
+		}
+		status := "created " + t.Created.Format(time.DateTime)
 		if t.Done {
-			fmt.Fprintf(tw, "%d\t%s\tdone\n", t.id, t.Desc)
-		} else {
-			fmt.Fprintf(tw, "%d\t%s\tcreated %v\n", t.id, t.Desc, t.Created)
+			status = "done"
 		}
+		fmt.Fprintf(tw, "%d\t%s\t%d\t%s\t%s\t%s\t%s\n", t.id, t.Desc, t.Priority, due, t.Owner, strings.Join(t.Tags, ","), status)
 	}
 	tw.Flush()
 }
 
-func usage() {
-	fmt.Print(`Usage:
+func usage(w io.Writer) {
+	fmt.Fprint(w, `Usage:
 
-  new <description>  Adds a task with a description <description>
-  done <task-id>     Marks a task as done
-  list               Lists all tasks by creation time
-  delete <task-id>   Deletes a task
+  new [-p priority] [-due YYYY-MM-DD] [-tags a,b] [-owner owner] <description>
+                       Adds a task with a description <description>
+  done <task-id>...    Marks tasks as done
+  tag <tag> <task-id>...
+                       Adds a tag to tasks
+  list [-owner owner] [-tag tag] [-open|-done] [-p priority]
+       [-due-before YYYY-MM-DD] [-sort created|-priority|due] [-limit n]
+                       Lists tasks, by creation time by default
+  more                 Lists the next page of tasks
+  delete <task-id>...  Deletes tasks
+  export <file>        Exports all tasks to a JSON file
+  import <file>        Imports tasks from a JSON file
 `)
 }
-
-// parseCmd splits a line into a command and optional extra args.
-// n will be set if the extra args can be parsed as an int64.
-func parseCmd(line string) (cmd, args string, n int64) {
-	if f := strings.Fields(line); len(f) > 0 {
-		cmd = f[0]
-		args = strings.Join(f[1:], " ")
-	}
-	if i, err := strconv.ParseInt(args, 10, 64); err == nil {
-		n = i
-	}
-	return cmd, args, n
-}
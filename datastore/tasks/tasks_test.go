@@ -20,6 +20,8 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
@@ -27,82 +29,336 @@ import (
 	"github.com/GoogleCloudPlatform/golang-samples/internal/testutil"
 )
 
-var client *datastore.Client
+// The tests run against the Datastore emulator, if it is set up:
+//
+//	gcloud beta emulators datastore start --consistency=1.0
+//	$(gcloud beta emulators datastore env-init)
+//	go test
+//
+// Otherwise, the system tests run against the GOLANG_SAMPLES_PROJECT_ID
+// project, and the tests that need an empty database are skipped.
+var (
+	client    *datastore.Client
+	projectID string
+	emulator  bool
+)
 
 func TestMain(m *testing.M) {
-	ctx := context.Background()
-	if tc, ok := testutil.ContextMain(m); ok {
+	if os.Getenv("DATASTORE_EMULATOR_HOST") != "" {
+		emulator = true
+		projectID = os.Getenv("DATASTORE_PROJECT_ID")
+		if projectID == "" {
+			projectID = "test-project"
+		}
+	} else if tc, ok := testutil.ContextMain(m); ok {
+		projectID = tc.ProjectID
+	}
+	if projectID != "" {
 		var err error
-		client, err = datastore.NewClient(ctx, tc.ProjectID)
+		client, err = datastore.NewClient(context.Background(), projectID)
 		if err != nil {
 			log.Fatalf("datastore.NewClient: %v", err)
 		}
-		defer client.Close()
 	}
-	os.Exit(m.Run())
+	code := m.Run()
+	if client != nil {
+		client.Close()
+	}
+	os.Exit(code)
+}
+
+// systemTest skips the test without the emulator or a test project.
+func systemTest(t *testing.T) {
+	t.Helper()
+	if client == nil {
+		testutil.SystemTest(t)
+	}
+}
+
+// setup skips the test without the emulator, and deletes all the tasks.
+func setup(t *testing.T) context.Context {
+	t.Helper()
+	if !emulator {
+		t.Skip("DATASTORE_EMULATOR_HOST not set")
+	}
+	ctx := context.Background()
+	keys, err := client.GetAll(ctx, datastore.NewQuery("Task").KeysOnly(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := client.DeleteMulti(ctx, keys); err != nil {
+		t.Fatal(err)
+	}
+	return ctx
 }
 
 func makeDesc() string {
-	return fmt.Sprintf("t-%d", time.Now().Unix())
+	return fmt.Sprintf("t-%d", time.Now().UnixNano())
 }
 
 func TestAddMarkDelete(t *testing.T) {
-	tc := testutil.SystemTest(t)
+	systemTest(t)
 
 	desc := makeDesc()
 
-	k, err := AddTask(tc.ProjectID, desc)
+	k, err := AddTask(projectID, desc)
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	if err := MarkDone(tc.ProjectID, k.ID); err != nil {
+	if err := MarkDone(projectID, k.ID); err != nil {
 		t.Fatal(err)
 	}
 
-	if err := DeleteTask(tc.ProjectID, k.ID); err != nil {
+	if err := DeleteTask(projectID, k.ID); err != nil {
 		t.Fatal(err)
 	}
 }
 
 func TestList(t *testing.T) {
-	t.Skip("Flaky. Eventual consistency. Re-enable once the datastore emulator works with gRPC.")
-
-	tc := testutil.SystemTest(t)
+	setup(t)
 
 	desc := makeDesc()
 
-	k, err := AddTask(tc.ProjectID, desc)
+	k, err := AddTask(projectID, desc)
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	foundTask, err := listAndGetTask(tc.ProjectID, desc)
+	foundTask, err := listAndGetTask(projectID, desc)
 	if err != nil {
-		t.Error(err)
+		t.Fatal(err)
 	}
 	if got, want := foundTask.id, k.ID; got != want {
 		t.Errorf("k.ID: got %d, want %d", got, want)
 	}
 
-	if err := MarkDone(tc.ProjectID, foundTask.id); err != nil {
+	if err := MarkDone(projectID, foundTask.id); err != nil {
 		t.Fatal(err)
 	}
 
-	foundTask, err = listAndGetTask(tc.ProjectID, desc)
+	foundTask, err = listAndGetTask(projectID, desc)
 	if err != nil {
-		t.Error(err)
+		t.Fatal(err)
 	}
 	if !foundTask.Done {
 		t.Error("foundTask.Done: got false, want true")
 	}
 
-	if err := DeleteTask(tc.ProjectID, foundTask.id); err != nil {
+	if err := DeleteTask(projectID, foundTask.id); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// addTasks adds tasks, created a second apart in order.
+func addTasks(ctx context.Context, t *testing.T, tasks ...*Task) []int64 {
+	t.Helper()
+	keys := make([]*datastore.Key, len(tasks))
+	start := time.Now().Add(-time.Hour)
+	for i, task := range tasks {
+		keys[i] = datastore.IncompleteKey("Task", nil)
+		task.Created = start.Add(time.Duration(i) * time.Second)
+	}
+	keys, err := client.PutMulti(ctx, keys, tasks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := make([]int64, len(keys))
+	for i, k := range keys {
+		ids[i] = k.ID
+	}
+	return ids
+}
+
+func descs(tasks []*Task) string {
+	var d []string
+	for _, t := range tasks {
+		d = append(d, t.Desc)
+	}
+	return strings.Join(d, " ")
+}
+
+func TestQueryTasks(t *testing.T) {
+	ctx := setup(t)
+	day := func(n int) time.Time { return time.Date(2026, 1, n, 0, 0, 0, 0, time.UTC) }
+	addTasks(ctx, t,
+		&Task{Desc: "a", Priority: 1, Owner: "ann", Tags: []string{"home"}, Due: day(3)},
+		&Task{Desc: "b", Priority: 3, Owner: "bob", Tags: []string{"work", "urgent"}, Due: day(1)},
+		&Task{Desc: "c", Priority: 2, Owner: "ann", Tags: []string{"work"}},
+		&Task{Desc: "d", Priority: 3, Owner: "ann", Done: true, Due: day(2)},
+	)
+
+	done, open := true, false
+	tests := []struct {
+		name string
+		q    TaskQuery
+		want string
+	}{
+		{"all", TaskQuery{}, "a b c d"},
+		{"newest first", TaskQuery{Order: "-created"}, "d c b a"},
+		{"owner", TaskQuery{Owner: "ann"}, "a c d"},
+		{"tag", TaskQuery{Tag: "work"}, "b c"},
+		{"open by priority", TaskQuery{Done: &open, Order: "-priority"}, "b c a"},
+		{"done", TaskQuery{Done: &done}, "d"},
+		{"priority", TaskQuery{Priority: 3}, "b d"},
+		// Tasks without due date are not indexed by due date.
+		{"by due date", TaskQuery{Order: "due"}, "b d a"},
+		{"due before", TaskQuery{DueBefore: day(3), Order: "due"}, "b d"},
+		{"owner by due date", TaskQuery{Owner: "ann", Order: "due"}, "d a"},
+	}
+	for _, tc := range tests {
+		tasks, cursor, err := QueryTasks(ctx, client, &tc.q)
+		if err != nil {
+			t.Errorf("%s: %v", tc.name, err)
+			continue
+		}
+		if got := descs(tasks); got != tc.want || cursor != "" {
+			t.Errorf("%s: got %q, cursor %q, want %q and no cursor", tc.name, got, cursor, tc.want)
+		}
+	}
+
+	for _, q := range []TaskQuery{{Order: "owner"}, {DueBefore: day(3)}, {Cursor: "bad"}} {
+		if _, _, err := QueryTasks(ctx, client, &q); err == nil {
+			t.Errorf("QueryTasks(%+v) succeeded, want error", q)
+		}
+	}
+}
+
+func TestQueryTasksPages(t *testing.T) {
+	ctx := setup(t)
+	addTasks(ctx, t, &Task{Desc: "a"}, &Task{Desc: "b"}, &Task{Desc: "c"}, &Task{Desc: "d"}, &Task{Desc: "e"})
+
+	q := &TaskQuery{Limit: 2}
+	var pages []string
+	for {
+		tasks, cursor, err := QueryTasks(ctx, client, q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pages = append(pages, descs(tasks))
+		if cursor == "" {
+			break
+		}
+		q.Cursor = cursor
+	}
+	if got, want := strings.Join(pages, "|"), "a b|c d|e"; got != want {
+		t.Errorf("pages %q, want %q", got, want)
+	}
+}
+
+func TestBulk(t *testing.T) {
+	ctx := setup(t)
+	ids := addTasks(ctx, t, &Task{Desc: "a"}, &Task{Desc: "b", Tags: []string{"x"}}, &Task{Desc: "c"})
+
+	if err := MarkAllDone(ctx, client, ids[:2]); err != nil {
+		t.Fatalf("MarkAllDone: %v", err)
+	}
+	if err := TagAll(ctx, client, "x", ids); err != nil {
+		t.Fatalf("TagAll: %v", err)
+	}
+	tasks := make([]Task, len(ids))
+	keys, _ := taskKeys(ids)
+	if err := client.GetMulti(ctx, keys, tasks); err != nil {
 		t.Fatal(err)
 	}
+	for i, want := range []bool{true, true, false} {
+		if tasks[i].Done != want || len(tasks[i].Tags) != 1 {
+			t.Errorf("task %s: done %t, tags %q; want %t, [x]", tasks[i].Desc, tasks[i].Done, tasks[i].Tags, want)
+		}
+	}
+
+	// A missing task fails the whole transaction.
+	missing := append([]int64{ids[2]}, 1<<40)
+	if err := MarkAllDone(ctx, client, missing); err == nil {
+		t.Error("MarkAllDone with a missing task succeeded, want error")
+	}
+	if err := DeleteAll(ctx, client, missing); err == nil {
+		t.Error("DeleteAll with a missing task succeeded, want error")
+	}
+	var task Task
+	if err := client.Get(ctx, keys[2], &task); err != nil || task.Done {
+		t.Errorf("task c after the failed transactions: done %t, %v; want not done, not deleted", task.Done, err)
+	}
+
+	if err := DeleteAll(ctx, client, ids); err != nil {
+		t.Fatalf("DeleteAll: %v", err)
+	}
+	if n, err := client.Count(ctx, datastore.NewQuery("Task")); err != nil || n != 0 {
+		t.Errorf("%d tasks left, %v; want 0", n, err)
+	}
+}
+
+func TestExportImport(t *testing.T) {
+	ctx := setup(t)
+	due := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	ids := addTasks(ctx, t,
+		&Task{Desc: "a", Priority: 2, Due: due, Tags: []string{"x", "y"}, Owner: "ann"},
+		&Task{Desc: "b", Done: true},
+	)
+	var buf bytes.Buffer
+	if n, err := ExportTasks(ctx, client, &buf); err != nil || n != 2 {
+		t.Fatalf("ExportTasks = %d, %v; want 2 tasks", n, err)
+	}
+	exported := buf.String()
+	for _, want := range []string{fmt.Sprintf(`"id": %d`, ids[0]), `"due": "2026-03-01T00:00:00Z"`, `"owner": "ann"`} {
+		if !strings.Contains(exported, want) {
+			t.Errorf("export does not contain %s:\n%s", want, exported)
+		}
+	}
+
+	// Importing replaces the tasks with IDs, and adds the others.
+	if err := client.Delete(ctx, datastore.IDKey("Task", ids[0], nil)); err != nil {
+		t.Fatal(err)
+	}
+	input := strings.Replace(exported, "[", `[{"description": "c"},`, 1)
+	if n, err := ImportTasks(ctx, client, strings.NewReader(input)); err != nil || n != 3 {
+		t.Fatalf("ImportTasks = %d, %v; want 3 tasks", n, err)
+	}
+	var task Task
+	if err := client.Get(ctx, datastore.IDKey("Task", ids[0], nil), &task); err != nil {
+		t.Fatalf("imported task: %v", err)
+	}
+	if task.Desc != "a" || !task.Due.Equal(due) || task.Owner != "ann" || len(task.Tags) != 2 {
+		t.Errorf("imported task %+v", task)
+	}
+	if n, _ := client.Count(ctx, datastore.NewQuery("Task")); n != 3 {
+		t.Errorf("%d tasks after import, want 3", n)
+	}
+}
+
+func TestSession(t *testing.T) {
+	ctx := setup(t)
+	var out bytes.Buffer
+	s := &session{client: client, w: &out}
+	file := filepath.Join(t.TempDir(), "tasks.json")
+	for _, line := range []string{
+		"new -p 2 -tags home,garden -owner ann -due 2026-05-01 water the plants",
+		"new buy milk",
+		"new -p 3 call mom",
+		"list -sort -priority -limit 2",
+		"more",
+		"export " + file,
+	} {
+		if err := s.exec(ctx, line); err != nil {
+			t.Fatalf("%s: %v", line, err)
+		}
+	}
+	got := out.String()
+	for _, want := range []string{"water the plants", "2026-05-01", "home,garden", `Type "more"`, "3 tasks exported"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("output does not contain %q:\n%s", want, got)
+		}
+	}
+
+	for _, line := range []string{"new", "done x", "tag home", "list -sort owner", "more", "frobnicate"} {
+		if err := s.exec(ctx, line); err == nil {
+			t.Errorf("%s: succeeded, want error", line)
+		}
+	}
 }
 
 func TestCreateClientWithDatabase(t *testing.T) {
+	// The emulator only serves the default database.
 	test := testutil.SystemTest(t)
 	projectID := test.ProjectID
 	databaseName := "customdb"
@@ -22,7 +22,9 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"strings"
 
+	"github.com/GoogleCloudPlatform/golang-samples/endpoints/getting-started/gateway"
 	"github.com/gorilla/mux"
 )
 
@@ -41,7 +43,22 @@ func main() {
 	r.Path("/auth/info/auth0").Methods("GET").
 		HandlerFunc(authInfoHandler)
 
-	http.Handle("/", r)
+	// Without the Extensible Service Proxy, such as locally, set
+	// ENDPOINTS_OPENAPI to the OpenAPI document, such as openapi.yaml, to
+	// check the requests in the app, with the API keys in
+	// ENDPOINTS_API_KEYS, separated by commas.
+	if file := os.Getenv("ENDPOINTS_OPENAPI"); file != "" {
+		g, err := gateway.Load(file)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if keys := os.Getenv("ENDPOINTS_API_KEYS"); keys != "" {
+			g.APIKeys = strings.Split(keys, ",")
+		}
+		http.Handle("/", g.Handler(r))
+	} else {
+		http.Handle("/", r)
+	}
 
 	port := os.Getenv("PORT")
 	if port == "" {
@@ -0,0 +1,304 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package gateway checks requests as the Extensible Service Proxy does,
+// so that an Endpoints API can run without it, such as locally.
+//
+// The gateway reads the OpenAPI document of the API. It rejects the
+// requests of unknown operations, checks the API keys and the JWTs of the
+// security requirements of the operations, and enforces the quota limits
+// of x-google-management, per API key. For requests with JWTs, it sets
+// the X-Endpoint-API-UserInfo header like ESP.
+package gateway
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// UserInfoHeader is the header with the user of the JWT of a request, a
+// base64-encoded JSON object.
+const UserInfoHeader = "X-Endpoint-API-UserInfo"
+
+// Gateway checks the requests of an API.
+type Gateway struct {
+	// APIKeys are the valid API keys.
+	APIKeys []string
+	// HTTPClient fetches the keys of the JWTs. It defaults to
+	// http.DefaultClient.
+	HTTPClient *http.Client
+	// Now returns the current time. It defaults to time.Now.
+	Now func() time.Time
+
+	spec *spec
+	ops  []*operation
+
+	mu     sync.Mutex
+	keys   map[string]*keySet
+	window time.Time
+	usage  map[usageKey]int64
+}
+
+type usageKey struct {
+	apiKey, limit string
+}
+
+// Load reads an OpenAPI 2.0 document, such as openapi.yaml.
+func Load(file string) (*Gateway, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("gateway: %w", err)
+	}
+	return Parse(data)
+}
+
+// Parse parses an OpenAPI 2.0 document.
+func Parse(data []byte) (*Gateway, error) {
+	s, ops, err := parseSpec(data)
+	if err != nil {
+		return nil, fmt.Errorf("gateway: %w", err)
+	}
+	return &Gateway{spec: s, ops: ops}, nil
+}
+
+func (g *Gateway) now() time.Time {
+	if g.Now != nil {
+		return g.Now()
+	}
+	return time.Now()
+}
+
+func (g *Gateway) httpClient() *http.Client {
+	if g.HTTPClient != nil {
+		return g.HTTPClient
+	}
+	return http.DefaultClient
+}
+
+func (g *Gateway) keySet(uri string) *keySet {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.keys == nil {
+		g.keys = map[string]*keySet{}
+	}
+	s, ok := g.keys[uri]
+	if !ok {
+		s = &keySet{uri: uri}
+		g.keys[uri] = s
+	}
+	return s
+}
+
+// status is an error with an HTTP status code.
+type status struct {
+	code int
+	msg  string
+}
+
+func (s *status) Error() string { return s.msg }
+
+func errorf(code int, format string, a ...interface{}) error {
+	return &status{code: code, msg: fmt.Sprintf(format, a...)}
+}
+
+// Handler returns a handler checking the requests before calling h.
+// CORS preflight requests, with the OPTIONS method, are passed to h
+// unless the document defines them.
+func (g *Gateway) Handler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The header can only be set by the gateway.
+		r.Header.Del(UserInfoHeader)
+		op := g.operation(r)
+		if op == nil && r.Method == http.MethodOptions {
+			h.ServeHTTP(w, r)
+			return
+		}
+		if op == nil {
+			writeError(w, errorf(http.StatusNotFound, "Method does not exist."))
+			return
+		}
+		userInfo, apiKey, err := g.check(r, op)
+		if err == nil {
+			err = g.allocateQuota(op, apiKey)
+		}
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		if userInfo != "" {
+			r.Header.Set(UserInfoHeader, userInfo)
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// writeError writes an error response like those of the app.
+func writeError(w http.ResponseWriter, err error) {
+	var s *status
+	if !errors.As(err, &s) {
+		s = &status{code: http.StatusInternalServerError, msg: err.Error()}
+	}
+	b, _ := json.Marshal(struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	}{s.code, s.msg})
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(s.code)
+	w.Write(b)
+}
+
+func (g *Gateway) operation(r *http.Request) *operation {
+	for _, op := range g.ops {
+		if op.match(r.Method, r.URL.Path) {
+			return op
+		}
+	}
+	return nil
+}
+
+// check checks that a request satisfies a security requirement of an
+// operation, trying them in order. It returns the user info header for
+// the JWT of the requirement, if any, and the API key of the request.
+func (g *Gateway) check(r *http.Request, op *operation) (userInfo, apiKey string, err error) {
+	reqs := op.requirements(g.spec)
+	if len(reqs) == 0 {
+		return "", "", nil
+	}
+	for _, req := range reqs {
+		userInfo, apiKey, err = g.checkRequirement(r, req)
+		if err == nil {
+			return userInfo, apiKey, nil
+		}
+	}
+	// Report the error of the last requirement.
+	return "", "", err
+}
+
+func (g *Gateway) checkRequirement(r *http.Request, req requirement) (userInfo, apiKey string, err error) {
+	names := make([]string, 0, len(req))
+	for name := range req {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		sch := g.spec.SecurityDefinitions[name]
+		switch sch.Type {
+		case "apiKey":
+			key := r.URL.Query().Get(sch.Name)
+			if sch.In == "header" {
+				key = r.Header.Get(sch.Name)
+			}
+			if key == "" {
+				return "", "", errorf(http.StatusUnauthorized, "Method doesn't allow unregistered callers (callers without established identity). Please use API Key or other form of API consumer identity to call this API.")
+			}
+			if !slices.Contains(g.APIKeys, key) {
+				return "", "", errorf(http.StatusBadRequest, "API key not valid. Please pass a valid API key.")
+			}
+			apiKey = key
+		case "oauth2":
+			token := bearerToken(r)
+			if token == "" {
+				return "", "", errorf(http.StatusUnauthorized, "Jwt is missing")
+			}
+			c, err := g.validateJWT(r.Context(), sch, g.audiences(sch), token)
+			if err != nil {
+				return "", "", errorf(http.StatusUnauthorized, "Jwt is not valid: %v", err)
+			}
+			userInfo = encodeUserInfo(sch.Issuer, c)
+		}
+	}
+	return userInfo, apiKey, nil
+}
+
+// audiences returns the accepted audiences of a scheme, which default to
+// the name of the service, with or without the https scheme.
+func (g *Gateway) audiences(sch *scheme) []string {
+	if sch.Audiences == "" {
+		return []string{g.spec.Host, "https://" + g.spec.Host}
+	}
+	var auds []string
+	for _, a := range strings.Split(sch.Audiences, ",") {
+		if a = strings.TrimSpace(a); a != "" {
+			auds = append(auds, a)
+		}
+	}
+	return auds
+}
+
+// bearerToken returns the token of the Authorization header, or of the
+// access_token query parameter.
+func bearerToken(r *http.Request) string {
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		return token
+	}
+	return r.URL.Query().Get("access_token")
+}
+
+// encodeUserInfo returns the user info header of a JWT. Like ESP, the
+// claims are a JSON string.
+func encodeUserInfo(issuer string, c *claims) string {
+	info := map[string]interface{}{
+		"issuer":    issuer,
+		"id":        c.Subject,
+		"audiences": []string(c.Audience),
+		"claims":    string(c.raw),
+	}
+	if email, ok := c.m["email"]; ok {
+		info["email"] = email
+	}
+	if azp, ok := c.m["azp"]; ok {
+		info["authorized_party"] = azp
+	}
+	b, _ := json.Marshal(info)
+	return base64.StdEncoding.EncodeToString(b)
+}
+
+// allocateQuota charges the metric costs of an operation to the quota
+// limits of an API key, for the current minute. Requests without API keys
+// share the limits.
+func (g *Gateway) allocateQuota(op *operation, apiKey string) error {
+	if len(op.Quota.MetricCosts) == 0 {
+		return nil
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if window := g.now().Truncate(time.Minute); !window.Equal(g.window) {
+		g.window = window
+		g.usage = map[usageKey]int64{}
+	}
+	charges := map[usageKey]int64{}
+	for _, l := range g.spec.Management.Quota.Limits {
+		cost, ok := op.Quota.MetricCosts[l.Metric]
+		if !ok {
+			continue
+		}
+		k := usageKey{apiKey, l.Name}
+		if g.usage[k]+cost > l.Values["STANDARD"] {
+			return errorf(http.StatusTooManyRequests, "Quota exceeded for quota metric '%s' and limit '%s'.", l.Metric, l.Name)
+		}
+		charges[k] = cost
+	}
+	for k, cost := range charges {
+		g.usage[k] += cost
+	}
+	return nil
+}
@@ -0,0 +1,338 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gateway
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/go-jose/go-jose/v4/jwt"
+)
+
+// keyServer serves the keys of the JWTs of openapi.yaml: a JSON Web Key
+// Set for the service account, and certificates for Firebase.
+type keyServer struct {
+	ec  *ecdsa.PrivateKey
+	rsa *rsa.PrivateKey
+	srv *httptest.Server
+}
+
+func newKeyServer(t *testing.T) *keyServer {
+	t.Helper()
+	ks := &keyServer{}
+	var err error
+	if ks.ec, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader); err != nil {
+		t.Fatal(err)
+	}
+	if ks.rsa, err = rsa.GenerateKey(rand.Reader, 2048); err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "securetoken.system.gserviceaccount.com"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &ks.rsa.PublicKey, ks.rsa)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certs, _ := json.Marshal(map[string]string{
+		"firebase-key": string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})),
+	})
+	jwks, _ := json.Marshal(jose.JSONWebKeySet{Keys: []jose.JSONWebKey{
+		{Key: &ks.ec.PublicKey, KeyID: "sa-key", Algorithm: string(jose.ES256), Use: "sig"},
+	}})
+	ks.srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/service_accounts/v1/metadata/x509/") {
+			w.Write(certs)
+			return
+		}
+		w.Write(jwks)
+	}))
+	t.Cleanup(ks.srv.Close)
+	return ks
+}
+
+// RoundTrip sends all requests to the key server.
+func (ks *keyServer) RoundTrip(r *http.Request) (*http.Response, error) {
+	u, err := url.Parse(ks.srv.URL)
+	if err != nil {
+		return nil, err
+	}
+	r = r.Clone(r.Context())
+	r.URL.Scheme, r.URL.Host = u.Scheme, u.Host
+	return http.DefaultTransport.RoundTrip(r)
+}
+
+// sign returns a JWT with the claims, signed with the EC key, for the
+// service account, or the RSA key, for Firebase.
+func (ks *keyServer) sign(t *testing.T, firebase bool, claims map[string]interface{}) string {
+	t.Helper()
+	key := jose.SigningKey{Algorithm: jose.ES256, Key: jose.JSONWebKey{Key: ks.ec, KeyID: "sa-key"}}
+	if firebase {
+		key = jose.SigningKey{Algorithm: jose.RS256, Key: jose.JSONWebKey{Key: ks.rsa, KeyID: "firebase-key"}}
+	}
+	signer, err := jose.NewSigner(key, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := jwt.Signed(signer).Claims(claims).Serialize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+// userInfo writes the user info header, decoded, or "anonymous".
+var userInfo = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	info := r.Header.Get(UserInfoHeader)
+	if info == "" {
+		w.Write([]byte("anonymous"))
+		return
+	}
+	b, _ := base64.StdEncoding.DecodeString(info)
+	w.Write(b)
+})
+
+func serve(h http.Handler, method, target string, header http.Header) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, nil)
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestAPIKey(t *testing.T) {
+	g, err := Load("../openapi.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	g.APIKeys = []string{"good-key"}
+	h := g.Handler(userInfo)
+	for _, tc := range []struct {
+		method, target string
+		code           int
+	}{
+		{"POST", "/echo?key=good-key", http.StatusOK},
+		{"POST", "/echo", http.StatusUnauthorized},
+		{"POST", "/echo?key=bad-key", http.StatusBadRequest},
+		{"GET", "/echo?key=good-key", http.StatusNotFound},
+		{"GET", "/unknown", http.StatusNotFound},
+		// CORS preflight requests are passed to the app.
+		{"OPTIONS", "/auth/info/firebase", http.StatusOK},
+	} {
+		if rr := serve(h, tc.method, tc.target, nil); rr.Code != tc.code {
+			t.Errorf("%s %s: status %d, want %d: %s", tc.method, tc.target, rr.Code, tc.code, rr.Body)
+		}
+	}
+}
+
+func TestJWT(t *testing.T) {
+	ks := newKeyServer(t)
+	g, err := Load("../openapi.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	g.HTTPClient = &http.Client{Transport: ks}
+	h := g.Handler(userInfo)
+	exp := time.Now().Add(time.Hour).Unix()
+	sa := map[string]interface{}{
+		"iss": "jwt-client.endpoints.sample.google.com",
+		"aud": "echo.endpoints.sample.google.com",
+		"sub": "sa-id",
+		"exp": exp,
+	}
+	firebase := map[string]interface{}{
+		"iss":   "https://securetoken.google.com/YOUR-PROJECT-ID",
+		"aud":   "YOUR-PROJECT-ID",
+		"sub":   "user-id",
+		"email": "user@example.com",
+		"exp":   exp,
+	}
+	// with returns claims with k set to v, or removed if v is nil.
+	with := func(claims map[string]interface{}, k string, v interface{}) map[string]interface{} {
+		c := map[string]interface{}{}
+		for k, v := range claims {
+			c[k] = v
+		}
+		c[k] = v
+		if v == nil {
+			delete(c, k)
+		}
+		return c
+	}
+	bearer := func(token string) http.Header {
+		return http.Header{"Authorization": {"Bearer " + token}}
+	}
+
+	for _, tc := range []struct {
+		target string
+		token  string
+		id     string
+		email  string
+	}{
+		{"/auth/info/googlejwt", ks.sign(t, false, sa), "sa-id", ""},
+		{"/auth/info/firebase", ks.sign(t, true, firebase), "user-id", "user@example.com"},
+	} {
+		rr := serve(h, "GET", tc.target, bearer(tc.token))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("%s: status %d: %s", tc.target, rr.Code, rr.Body)
+		}
+		var info struct {
+			ID, Email, Issuer string
+			Audiences         []string
+			Claims            string
+		}
+		if err := json.Unmarshal(rr.Body.Bytes(), &info); err != nil {
+			t.Fatalf("%s: user info %s: %v", tc.target, rr.Body, err)
+		}
+		if info.ID != tc.id || info.Email != tc.email || len(info.Audiences) != 1 || !strings.Contains(info.Claims, `"sub":"`+tc.id+`"`) {
+			t.Errorf("%s: user info %+v, want id %q and email %q", tc.target, info, tc.id, tc.email)
+		}
+	}
+
+	for name, tc := range map[string]struct {
+		target string
+		header http.Header
+	}{
+		"missing":         {"/auth/info/googlejwt", nil},
+		"spoofed":         {"/auth/info/googlejwt", http.Header{UserInfoHeader: {"e30="}}},
+		"wrong audience":  {"/auth/info/googlejwt", bearer(ks.sign(t, false, with(sa, "aud", "other")))},
+		"wrong issuer":    {"/auth/info/googlejwt", bearer(ks.sign(t, false, with(sa, "iss", "other")))},
+		"expired":         {"/auth/info/googlejwt", bearer(ks.sign(t, false, with(sa, "exp", time.Now().Add(-time.Hour).Unix())))},
+		"no expiration":   {"/auth/info/googlejwt", bearer(ks.sign(t, false, with(sa, "exp", nil)))},
+		"wrong key":       {"/auth/info/googlejwt", bearer(ks.sign(t, true, with(firebase, "iss", sa["iss"])))},
+		"other scheme":    {"/auth/info/firebase", bearer(ks.sign(t, false, sa))},
+		"tampered claims": {"/auth/info/firebase", bearer(strings.Replace(ks.sign(t, true, firebase), ".", ".e30", 1))},
+	} {
+		if rr := serve(h, "GET", tc.target, tc.header); rr.Code != http.StatusUnauthorized {
+			t.Errorf("%s: status %d, want %d: %s", name, rr.Code, http.StatusUnauthorized, rr.Body)
+		}
+	}
+}
+
+const quotaSpec = `
+swagger: "2.0"
+host: "quota.example.com"
+paths:
+  "/read/{id}":
+    get:
+      operationId: "read"
+      x-google-quota:
+        metricCosts:
+          read-requests: 1
+  "/expensive":
+    get:
+      operationId: "expensive"
+      x-google-quota:
+        metricCosts:
+          read-requests: 3
+  "/free":
+    get:
+      operationId: "free"
+      security: []
+security:
+- api_key: []
+securityDefinitions:
+  api_key:
+    type: "apiKey"
+    name: "x-api-key"
+    in: "header"
+x-google-management:
+  metrics:
+  - name: "read-requests"
+    valueType: INT64
+    metricKind: DELTA
+  quota:
+    limits:
+    - name: "read-limit"
+      metric: "read-requests"
+      unit: "1/min/{project}"
+      values:
+        STANDARD: 4
+`
+
+func TestQuota(t *testing.T) {
+	g, err := Parse([]byte(quotaSpec))
+	if err != nil {
+		t.Fatal(err)
+	}
+	g.APIKeys = []string{"a", "b"}
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	g.Now = func() time.Time { return now }
+	h := g.Handler(userInfo)
+	get := func(key, target string) int {
+		return serve(h, "GET", target, http.Header{"X-Api-Key": {key}}).Code
+	}
+
+	for i := 0; i < 4; i++ {
+		if code := get("a", "/read/1"); code != http.StatusOK {
+			t.Fatalf("read %d: status %d", i, code)
+		}
+	}
+	if code := get("a", "/read/2"); code != http.StatusTooManyRequests {
+		t.Errorf("read over the limit: status %d, want %d", code, http.StatusTooManyRequests)
+	}
+	// The limits are per key, and reset every minute.
+	if code := get("b", "/expensive"); code != http.StatusOK {
+		t.Errorf("read with another key: status %d", code)
+	}
+	if code := get("b", "/expensive"); code != http.StatusTooManyRequests {
+		t.Errorf("expensive read over the limit: status %d, want %d", code, http.StatusTooManyRequests)
+	}
+	if code := get("b", "/read/1"); code != http.StatusOK {
+		t.Errorf("read within the limit after a rejected request: status %d", code)
+	}
+	if code := get("", "/free"); code != http.StatusOK {
+		t.Errorf("operation without security: status %d", code)
+	}
+	now = now.Add(time.Minute)
+	if code := get("a", "/read/1"); code != http.StatusOK {
+		t.Errorf("read in the next minute: status %d", code)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, spec := range []string{
+		"paths: [",
+		"securityDefinitions: {basic: {type: basic}}",
+		"securityDefinitions: {jwt: {type: oauth2}}",
+		"securityDefinitions: {key: {type: apiKey, in: cookie}}",
+		"paths: {/x: {get: {security: [{undefined: []}]}}}",
+		"x-google-management: {quota: {limits: [{name: l, metric: m, unit: 1/d/{project}}]}}",
+	} {
+		if _, err := Parse([]byte(spec)); err == nil {
+			t.Errorf("Parse(%q) succeeded, want error", spec)
+		}
+	}
+}
@@ -0,0 +1,171 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gateway
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/go-jose/go-jose/v4/jwt"
+)
+
+const (
+	// keysMaxAge is how long the keys of an issuer are cached.
+	keysMaxAge = 5 * time.Minute
+	// keysMinRefresh is the minimum time between fetches for unknown
+	// keys.
+	keysMinRefresh = 30 * time.Second
+	// leeway is the tolerated clock skew.
+	leeway = 30 * time.Second
+)
+
+// algorithms are the accepted JWT signature algorithms.
+var algorithms = []jose.SignatureAlgorithm{jose.RS256, jose.ES256}
+
+// keySet caches the keys at a JWKS URI.
+type keySet struct {
+	uri string
+
+	mu      sync.Mutex
+	keys    *jose.JSONWebKeySet
+	fetched time.Time
+}
+
+// key returns the key with an ID, fetching the keys when they are older
+// than keysMaxAge, or for unknown keys.
+func (s *keySet) key(ctx context.Context, client *http.Client, now time.Time, kid string) (*jose.JSONWebKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := s.lookup(kid)
+	age := now.Sub(s.fetched)
+	if key != nil && age < keysMaxAge {
+		return key, nil
+	}
+	if s.keys == nil || age >= keysMinRefresh {
+		if err := s.fetch(ctx, client, now); err != nil {
+			// Keep using the previous keys if the server is unavailable.
+			if key != nil {
+				return key, nil
+			}
+			return nil, err
+		}
+		if key = s.lookup(kid); key != nil {
+			return key, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown key %q", kid)
+}
+
+func (s *keySet) lookup(kid string) *jose.JSONWebKey {
+	if s.keys == nil {
+		return nil
+	}
+	if keys := s.keys.Key(kid); len(keys) > 0 {
+		return &keys[0]
+	}
+	return nil
+}
+
+// fetch fetches the keys, with s.mu held. They are a JSON Web Key Set, or
+// a map of key IDs to X.509 certificates, as for Firebase.
+func (s *keySet) fetch(ctx context.Context, client *http.Client, now time.Time) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", s.uri, nil)
+	if err != nil {
+		return err
+	}
+	s.fetched = now
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("fetching keys: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("fetching keys from %s: %s", s.uri, resp.Status)
+	}
+	var doc map[string]json.RawMessage
+	if err := json.NewDecoder(resp.Body).Decode(&doc); err != nil {
+		return fmt.Errorf("decoding keys: %w", err)
+	}
+
+	keys := &jose.JSONWebKeySet{}
+	if set, ok := doc["keys"]; ok {
+		if err := json.Unmarshal(set, &keys.Keys); err != nil {
+			return fmt.Errorf("decoding keys: %w", err)
+		}
+	} else {
+		for kid, raw := range doc {
+			var cert string
+			if err := json.Unmarshal(raw, &cert); err != nil {
+				return fmt.Errorf("key %q: %w", kid, err)
+			}
+			block, _ := pem.Decode([]byte(cert))
+			if block == nil {
+				return fmt.Errorf("key %q: no PEM certificate", kid)
+			}
+			c, err := x509.ParseCertificate(block.Bytes)
+			if err != nil {
+				return fmt.Errorf("key %q: %w", kid, err)
+			}
+			keys.Keys = append(keys.Keys, jose.JSONWebKey{Key: c.PublicKey, KeyID: kid})
+		}
+	}
+	s.keys = keys
+	return nil
+}
+
+// claims are the claims of a validated JWT.
+type claims struct {
+	jwt.Claims
+	raw json.RawMessage
+	m   map[string]interface{}
+}
+
+// validateJWT validates a JWT for an oauth2 scheme. audiences are the
+// accepted audiences.
+func (g *Gateway) validateJWT(ctx context.Context, sch *scheme, audiences []string, token string) (*claims, error) {
+	tok, err := jwt.ParseSigned(token, algorithms)
+	if err != nil {
+		return nil, err
+	}
+	now := g.now()
+	key, err := g.keySet(sch.JWKSURI).key(ctx, g.httpClient(), now, tok.Headers[0].KeyID)
+	if err != nil {
+		return nil, err
+	}
+	c := &claims{}
+	if err := tok.Claims(key.Key, &c.Claims, &c.raw, &c.m); err != nil {
+		return nil, err
+	}
+	if c.Expiry == nil {
+		return nil, errors.New("JWT without expiration")
+	}
+	err = c.ValidateWithLeeway(jwt.Expected{
+		Issuer:      sch.Issuer,
+		AnyAudience: audiences,
+		Time:        now,
+	}, leeway)
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
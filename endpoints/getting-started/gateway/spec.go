@@ -0,0 +1,162 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gateway
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// spec is the part of an OpenAPI 2.0 document used by the gateway.
+type spec struct {
+	Host                string                          `yaml:"host"`
+	BasePath            string                          `yaml:"basePath"`
+	Paths               map[string]map[string]yaml.Node `yaml:"paths"`
+	Security            []requirement                   `yaml:"security"`
+	SecurityDefinitions map[string]*scheme              `yaml:"securityDefinitions"`
+	Management          struct {
+		Quota struct {
+			Limits []quotaLimit `yaml:"limits"`
+		} `yaml:"quota"`
+	} `yaml:"x-google-management"`
+}
+
+// requirement is a security requirement: the names of the schemes that
+// must all be satisfied.
+type requirement map[string][]string
+
+// scheme is a security definition.
+type scheme struct {
+	Type string `yaml:"type"`
+	// Name and In locate the API keys.
+	Name string `yaml:"name"`
+	In   string `yaml:"in"`
+	// Issuer, JWKSURI and Audiences validate the JWTs of oauth2 schemes.
+	Issuer    string `yaml:"x-google-issuer"`
+	JWKSURI   string `yaml:"x-google-jwks_uri"`
+	Audiences string `yaml:"x-google-audiences"`
+}
+
+// quotaLimit limits the requests per minute of each API key.
+type quotaLimit struct {
+	Name   string           `yaml:"name"`
+	Metric string           `yaml:"metric"`
+	Unit   string           `yaml:"unit"`
+	Values map[string]int64 `yaml:"values"`
+}
+
+// operation is an operation of the API.
+type operation struct {
+	ID string `yaml:"operationId"`
+	// Security, if set, overrides the security of the API.
+	Security *[]requirement `yaml:"security"`
+	Quota    struct {
+		MetricCosts map[string]int64 `yaml:"metricCosts"`
+	} `yaml:"x-google-quota"`
+
+	// segments is the path, split by slashes, with "{}" for templates.
+	segments []string
+	method   string
+}
+
+// parseSpec parses an OpenAPI document and checks that the gateway
+// supports its security definitions and quotas.
+func parseSpec(data []byte) (*spec, []*operation, error) {
+	var s spec
+	if err := yaml.Unmarshal(data, &s); err != nil {
+		return nil, nil, err
+	}
+	for name, sch := range s.SecurityDefinitions {
+		switch sch.Type {
+		case "apiKey":
+			if sch.In != "query" && sch.In != "header" {
+				return nil, nil, fmt.Errorf("security definition %s: API keys in %q are not supported", name, sch.In)
+			}
+		case "oauth2":
+			if sch.Issuer == "" || sch.JWKSURI == "" {
+				return nil, nil, fmt.Errorf("security definition %s: x-google-issuer and x-google-jwks_uri are required", name)
+			}
+		default:
+			return nil, nil, fmt.Errorf("security definition %s: type %q is not supported", name, sch.Type)
+		}
+	}
+	for _, l := range s.Management.Quota.Limits {
+		if l.Unit != "1/min/{project}" {
+			return nil, nil, fmt.Errorf("quota limit %s: unit %q is not supported", l.Name, l.Unit)
+		}
+	}
+
+	var ops []*operation
+	for path, item := range s.Paths {
+		for method, node := range item {
+			method = strings.ToUpper(method)
+			switch method {
+			case http.MethodGet, http.MethodPut, http.MethodPost, http.MethodDelete,
+				http.MethodOptions, http.MethodHead, http.MethodPatch:
+			default:
+				// Such as the parameters of the path.
+				continue
+			}
+			op := &operation{method: method}
+			if err := node.Decode(op); err != nil {
+				return nil, nil, fmt.Errorf("%s %s: %w", method, path, err)
+			}
+			for _, seg := range strings.Split(strings.Trim(s.BasePath+path, "/"), "/") {
+				if strings.HasPrefix(seg, "{") && strings.HasSuffix(seg, "}") {
+					seg = "{}"
+				}
+				op.segments = append(op.segments, seg)
+			}
+			for _, req := range op.requirements(&s) {
+				for name := range req {
+					if s.SecurityDefinitions[name] == nil {
+						return nil, nil, fmt.Errorf("%s %s: undefined security definition %s", method, path, name)
+					}
+				}
+			}
+			ops = append(ops, op)
+		}
+	}
+	return &s, ops, nil
+}
+
+// requirements returns the security requirements of an operation, any of
+// which must be satisfied. There are none for operations without security.
+func (op *operation) requirements(s *spec) []requirement {
+	if op.Security != nil {
+		return *op.Security
+	}
+	return s.Security
+}
+
+// match reports whether an operation matches a request.
+func (op *operation) match(method, path string) bool {
+	if op.method != method {
+		return false
+	}
+	segs := strings.Split(strings.Trim(path, "/"), "/")
+	if len(segs) != len(op.segments) {
+		return false
+	}
+	for i, seg := range op.segments {
+		if seg != segs[i] && (seg != "{}" || segs[i] == "") {
+			return false
+		}
+	}
+	return true
+}
@@ -3,16 +3,19 @@ module github.com/GoogleCloudPlatform/golang-samples/endpoints
 go 1.23.0
 
 require (
+	github.com/go-jose/go-jose/v4 v4.0.4
 	github.com/golang/protobuf v1.5.4
 	github.com/gorilla/mux v1.8.1
 	golang.org/x/net v0.34.0
 	golang.org/x/oauth2 v0.25.0
 	google.golang.org/grpc v1.69.4
 	google.golang.org/grpc/examples v0.0.0-20250121182809-67bee55a47db
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
 	cloud.google.com/go/compute/metadata v0.6.0 // indirect
+	golang.org/x/crypto v0.32.0 // indirect
 	golang.org/x/sys v0.29.0 // indirect
 	golang.org/x/text v0.21.0 // indirect
 	google.golang.org/genproto/googleapis/rpc v0.0.0-20250115164207-1a7da9e5054f // indirect
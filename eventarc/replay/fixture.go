@@ -0,0 +1,169 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// Content modes a fixture can be sent in. CloudEvents are sent in binary
+// or structured mode; legacy background events only have one encoding.
+const (
+	modeBinary     = "binary"
+	modeStructured = "structured"
+	modeBackground = "background"
+)
+
+// Fixture is a recorded event loaded from a JSON or YAML file.
+//
+// Exactly one of CloudEvent and Background is set. CloudEvent holds the
+// event in the structured JSON format. Background holds a legacy Cloud
+// Functions event with its "context" and "data" members, as delivered to
+// functions such as HelloGCS and HelloPubSub in functions/helloworld.
+type Fixture struct {
+	// Name identifies the fixture in output and golden file names. It
+	// defaults to the base name of the fixture file.
+	Name string `json:"name,omitempty" yaml:"name,omitempty"`
+	// Path is the request path. It defaults to "/".
+	Path       string                 `json:"path,omitempty" yaml:"path,omitempty"`
+	CloudEvent map[string]interface{} `json:"cloudevent,omitempty" yaml:"cloudevent,omitempty"`
+	Background map[string]interface{} `json:"background,omitempty" yaml:"background,omitempty"`
+}
+
+// loadFixture reads a fixture from a .json, .yaml or .yml file.
+func loadFixture(name string) (*Fixture, error) {
+	b, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	var f Fixture
+	switch ext := filepath.Ext(name); ext {
+	case ".json":
+		err = json.Unmarshal(b, &f)
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(b, &f)
+	default:
+		return nil, fmt.Errorf("%s: unsupported fixture extension %q", name, ext)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	if f.Name == "" {
+		f.Name = strings.TrimSuffix(filepath.Base(name), filepath.Ext(name))
+	}
+	if f.Path == "" {
+		f.Path = "/"
+	}
+	if (f.CloudEvent == nil) == (f.Background == nil) {
+		return nil, fmt.Errorf("%s: fixture must set exactly one of cloudevent or background", name)
+	}
+	return &f, nil
+}
+
+// write stores f as YAML, or as JSON if name ends in ".json".
+func (f *Fixture) write(name string) error {
+	var b []byte
+	var err error
+	if filepath.Ext(name) == ".json" {
+		b, err = json.MarshalIndent(f, "", "  ")
+		b = append(b, '\n')
+	} else {
+		b, err = yaml.Marshal(f)
+	}
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(name, b, 0o644)
+}
+
+// modes returns the content modes f can be sent in, restricted to the
+// CloudEvents modes in want.
+func (f *Fixture) modes(want []string) []string {
+	if f.Background != nil {
+		return []string{modeBackground}
+	}
+	var modes []string
+	for _, m := range want {
+		if m == modeBinary || m == modeStructured {
+			modes = append(modes, m)
+		}
+	}
+	return modes
+}
+
+// event returns the fixture's CloudEvent. Parsing it validates the
+// required CloudEvents attributes.
+func (f *Fixture) event() (cloudevents.Event, error) {
+	var e cloudevents.Event
+	b, err := json.Marshal(f.CloudEvent)
+	if err != nil {
+		return e, err
+	}
+	if err := json.Unmarshal(b, &e); err != nil {
+		return e, fmt.Errorf("fixture %s: invalid CloudEvent: %w", f.Name, err)
+	}
+	return e, nil
+}
+
+// newRequest builds the request delivering f to baseURL in the given mode.
+func (f *Fixture) newRequest(ctx context.Context, baseURL, mode string) (*http.Request, error) {
+	url := strings.TrimSuffix(baseURL, "/") + f.Path
+	switch mode {
+	case modeBinary:
+		e, err := f.event()
+		if err != nil {
+			return nil, err
+		}
+		return cloudevents.NewHTTPRequestFromEvent(ctx, url, e)
+	case modeStructured:
+		e, err := f.event()
+		if err != nil {
+			return nil, err
+		}
+		b, err := json.Marshal(e)
+		if err != nil {
+			return nil, err
+		}
+		r, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(b))
+		if err != nil {
+			return nil, err
+		}
+		r.Header.Set("Content-Type", cloudevents.ApplicationCloudEventsJSON)
+		return r, nil
+	case modeBackground:
+		b, err := json.Marshal(f.Background)
+		if err != nil {
+			return nil, err
+		}
+		r, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(b))
+		if err != nil {
+			return nil, err
+		}
+		r.Header.Set("Content-Type", "application/json")
+		return r, nil
+	}
+	return nil, errors.New("unknown mode " + mode)
+}
@@ -0,0 +1,82 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// result is the part of a handler response that is compared with a golden
+// file.
+type result struct {
+	Status int
+	Body   string
+}
+
+// String formats r the way it is stored in golden files.
+func (r result) String() string {
+	return fmt.Sprintf("HTTP %d\n\n%s", r.Status, r.Body)
+}
+
+// send delivers f to baseURL in mode and returns the handler's response.
+func send(ctx context.Context, client *http.Client, baseURL string, f *Fixture, mode string) (result, error) {
+	req, err := f.newRequest(ctx, baseURL, mode)
+	if err != nil {
+		return result{}, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return result{}, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return result{}, err
+	}
+	return result{Status: resp.StatusCode, Body: string(body)}, nil
+}
+
+// goldenPath returns the golden file for fixture f sent in mode.
+func goldenPath(dir string, f *Fixture, mode string) string {
+	return filepath.Join(dir, f.Name+"."+mode+".golden")
+}
+
+// checkGolden compares got with the golden file at name and returns a
+// human-readable diff, or "" if they match. If update is set, the golden
+// file is rewritten instead.
+func checkGolden(name string, got result, update bool) (string, error) {
+	if update {
+		if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+			return "", err
+		}
+		return "", os.WriteFile(name, []byte(got.String()), 0o644)
+	}
+	want, err := os.ReadFile(name)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", fmt.Errorf("missing golden file %s, rerun with -update to create it", name)
+	}
+	if err != nil {
+		return "", err
+	}
+	return cmp.Diff(string(want), got.String()), nil
+}
@@ -0,0 +1,129 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Command replay sends recorded events to a locally running event handler
+// and compares the responses with golden files.
+//
+// It can regression-test Eventarc services such as HelloStorage and
+// Cloud Functions such as HelloPubSub without deploying a trigger:
+//
+//	replay run -url http://localhost:8080 -golden testdata/golden testdata/*.yaml
+//	replay run -update ...   # rewrite the golden files
+//	replay record -listen :8081 -out testdata
+//
+// Fixtures are JSON or YAML files holding either a CloudEvent in the
+// structured format or a legacy background event. CloudEvents are sent in
+// both binary and structured content modes unless -modes says otherwise.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+func main() {
+	log.SetFlags(0)
+	if len(os.Args) < 2 {
+		usage()
+	}
+	var err error
+	switch os.Args[1] {
+	case "run":
+		err = runCommand(os.Args[2:])
+	case "record":
+		err = recordCommand(os.Args[2:])
+	default:
+		usage()
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: replay run [flags] fixture...")
+	fmt.Fprintln(os.Stderr, "       replay record [flags]")
+	os.Exit(2)
+}
+
+func runCommand(args []string) error {
+	fs := flag.NewFlagSet("run", flag.ExitOnError)
+	url := fs.String("url", "http://localhost:8080", "base URL of the handler under test")
+	golden := fs.String("golden", "testdata/golden", "directory holding golden response files")
+	modes := fs.String("modes", modeBinary+","+modeStructured, "comma-separated CloudEvents content modes to send")
+	update := fs.Bool("update", false, "rewrite golden files with the current responses")
+	timeout := fs.Duration("timeout", 10*time.Second, "timeout for each request")
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		return fmt.Errorf("run: no fixture files given")
+	}
+
+	client := &http.Client{Timeout: *timeout}
+	failed, err := replay(context.Background(), os.Stdout, client, *url, fs.Args(), strings.Split(*modes, ","), *golden, *update)
+	if err != nil {
+		return err
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d response(s) differ from golden files", failed)
+	}
+	return nil
+}
+
+func recordCommand(args []string) error {
+	fs := flag.NewFlagSet("record", flag.ExitOnError)
+	listen := fs.String("listen", ":8081", "address to receive events on")
+	out := fs.String("out", "testdata", "directory to write fixture files to")
+	fs.Parse(args)
+	return record(*listen, *out)
+}
+
+// replay sends every fixture in files to baseURL in each applicable mode,
+// reports the outcome for each to w and returns the number of responses
+// that did not match their golden file.
+func replay(ctx context.Context, w io.Writer, client *http.Client, baseURL string, files, modes []string, goldenDir string, update bool) (int, error) {
+	failed := 0
+	for _, name := range files {
+		f, err := loadFixture(name)
+		if err != nil {
+			return failed, err
+		}
+		for _, mode := range f.modes(modes) {
+			got, err := send(ctx, client, baseURL, f, mode)
+			if err != nil {
+				return failed, fmt.Errorf("%s (%s): %w", f.Name, mode, err)
+			}
+			diff, err := checkGolden(goldenPath(goldenDir, f, mode), got, update)
+			if err != nil {
+				return failed, err
+			}
+			switch {
+			case update:
+				fmt.Fprintf(w, "UPDATED %s (%s)\n", f.Name, mode)
+			case diff != "":
+				failed++
+				fmt.Fprintf(w, "FAIL    %s (%s): response mismatch (-want +got):\n%s\n", f.Name, mode, diff)
+			default:
+				fmt.Fprintf(w, "ok      %s (%s)\n", f.Name, mode)
+			}
+		}
+	}
+	return failed, nil
+}
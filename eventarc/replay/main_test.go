@@ -0,0 +1,214 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+)
+
+var fixtures = []string{
+	"testdata/storage_finalized.yaml",
+	"testdata/pubsub_published.json",
+	"testdata/legacy_storage.yaml",
+}
+
+var allModes = []string{modeBinary, modeStructured}
+
+// echoHandler answers CloudEvents with their type and subject, and
+// background events with their event type, much like the samples log them.
+func echoHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Ce-Specversion") != "" || r.Header.Get("Content-Type") == cloudevents.ApplicationCloudEventsJSON {
+		e, err := cloudevents.NewEventFromHTTPRequest(r)
+		if err != nil {
+			http.Error(w, "Bad Request: expected CloudEvent", http.StatusBadRequest)
+			return
+		}
+		fmt.Fprintf(w, "CloudEvent %s: %s %s\n", e.ID(), e.Type(), e.Subject())
+		return
+	}
+	var bg struct {
+		Context struct {
+			EventID   string `json:"eventId"`
+			EventType string `json:"eventType"`
+		} `json:"context"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&bg); err != nil {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+	fmt.Fprintf(w, "Background event %s: %s at %s\n", bg.Context.EventID, bg.Context.EventType, r.URL.Path)
+}
+
+func TestReplay(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(echoHandler))
+	defer srv.Close()
+
+	var out bytes.Buffer
+	failed, err := replay(context.Background(), &out, srv.Client(), srv.URL, fixtures, allModes, "testdata/golden", false)
+	if err != nil {
+		t.Fatalf("replay: %v", err)
+	}
+	if failed != 0 {
+		t.Errorf("replay: %d failures:\n%s", failed, out.String())
+	}
+	// Two CloudEvent fixtures in two modes and one background event.
+	if got, want := strings.Count(out.String(), "ok "), 5; got != want {
+		t.Errorf("got %d passing responses, want %d:\n%s", got, want, out.String())
+	}
+}
+
+func TestReplayMismatch(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	var out bytes.Buffer
+	failed, err := replay(context.Background(), &out, srv.Client(), srv.URL, fixtures[:1], []string{modeStructured}, "testdata/golden", false)
+	if err != nil {
+		t.Fatalf("replay: %v", err)
+	}
+	if failed != 1 {
+		t.Errorf("replay: got %d failures, want 1", failed)
+	}
+	if want := "-want +got"; !strings.Contains(out.String(), want) {
+		t.Errorf("replay output %q does not contain a diff", out.String())
+	}
+}
+
+func TestReplayUpdate(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(echoHandler))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	if _, err := replay(context.Background(), io.Discard, srv.Client(), srv.URL, fixtures, allModes, dir, false); err == nil {
+		t.Errorf("replay with missing golden files: got nil error")
+	}
+	if _, err := replay(context.Background(), io.Discard, srv.Client(), srv.URL, fixtures, allModes, dir, true); err != nil {
+		t.Fatalf("replay -update: %v", err)
+	}
+	failed, err := replay(context.Background(), io.Discard, srv.Client(), srv.URL, fixtures, allModes, dir, false)
+	if err != nil || failed != 0 {
+		t.Errorf("replay after update: got (%d, %v), want (0, nil)", failed, err)
+	}
+}
+
+func TestRecord(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	dir := t.TempDir()
+	rec := httptest.NewServer(&recorder{dir: dir})
+	defer rec.Close()
+
+	// Record every fixture, then replay the recordings and compare them
+	// with the golden files of the originals.
+	for _, name := range fixtures {
+		f, err := loadFixture(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		mode := f.modes([]string{modeBinary})[0]
+		req, err := f.newRequest(context.Background(), rec.URL, mode)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := rec.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusNoContent {
+			t.Fatalf("recording %s: got status %d, want %d", name, resp.StatusCode, http.StatusNoContent)
+		}
+	}
+
+	recorded, err := filepath.Glob(filepath.Join(dir, "*.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recorded) != len(fixtures) {
+		t.Fatalf("got %d recorded fixtures, want %d", len(recorded), len(fixtures))
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(echoHandler))
+	defer srv.Close()
+	for i, name := range recorded {
+		orig, err := loadFixture(fixtures[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		f, err := loadFixture(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, mode := range f.modes(allModes) {
+			got, err := send(context.Background(), srv.Client(), srv.URL, f, mode)
+			if err != nil {
+				t.Fatal(err)
+			}
+			diff, err := checkGolden(goldenPath("testdata/golden", orig, mode), got, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff != "" {
+				t.Errorf("%s (%s) replayed differently (-want +got):\n%s", name, mode, diff)
+			}
+		}
+	}
+}
+
+func TestRecordRejectsUnknownPayload(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	rr := httptest.NewRecorder()
+	rec := &recorder{dir: t.TempDir()}
+	rec.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"hello":"world"}`)))
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("got status %d, want %d", rr.Code, http.StatusBadRequest)
+	}
+}
+
+func TestLoadFixtureErrors(t *testing.T) {
+	dir := t.TempDir()
+	tests := map[string]string{
+		"empty.yaml":  "name: empty\n",
+		"both.json":   `{"cloudevent": {"id": "1"}, "background": {"data": {}}}`,
+		"fixture.txt": "cloudevent: {}\n",
+	}
+	for name, content := range tests {
+		p := filepath.Join(dir, name)
+		if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := loadFixture(p); err == nil {
+			t.Errorf("loadFixture(%s): got nil error", name)
+		}
+	}
+}
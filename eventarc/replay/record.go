@@ -0,0 +1,117 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+)
+
+// recorder is an http.Handler that saves every event it receives as a
+// fixture file in dir. Point an Eventarc trigger or a Pub/Sub push
+// subscription at it to capture real events for replay.
+type recorder struct {
+	dir string
+
+	mu sync.Mutex
+	n  int
+}
+
+var unsafeName = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+func (rec *recorder) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f, id, err := fixtureFromRequest(r)
+	if err != nil {
+		log.Printf("record: %v", err)
+		http.Error(w, "Bad Request: expected CloudEvent or background event", http.StatusBadRequest)
+		return
+	}
+
+	rec.mu.Lock()
+	rec.n++
+	f.Name = fmt.Sprintf("%03d-%s", rec.n, unsafeName.ReplaceAllString(id, "_"))
+	rec.mu.Unlock()
+
+	name := filepath.Join(rec.dir, f.Name+".yaml")
+	if err := f.write(name); err != nil {
+		log.Printf("record: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	log.Printf("Recorded %s", name)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// fixtureFromRequest converts a delivered event into a fixture, returning
+// the event ID as well.
+func fixtureFromRequest(r *http.Request) (*Fixture, string, error) {
+	f := &Fixture{Path: r.URL.Path}
+
+	// CloudEvents carry a ce-specversion header in binary mode and a
+	// dedicated media type in structured mode.
+	if r.Header.Get("Ce-Specversion") != "" || r.Header.Get("Content-Type") == cloudevents.ApplicationCloudEventsJSON {
+		e, err := cloudevents.NewEventFromHTTPRequest(r)
+		if err != nil {
+			return nil, "", fmt.Errorf("cloudevents.NewEventFromHTTPRequest: %w", err)
+		}
+		b, err := json.Marshal(e)
+		if err != nil {
+			return nil, "", err
+		}
+		if err := json.Unmarshal(b, &f.CloudEvent); err != nil {
+			return nil, "", err
+		}
+		return f, e.ID(), nil
+	}
+
+	b, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, "", err
+	}
+	var bg struct {
+		Context struct {
+			EventID string `json:"eventId"`
+		} `json:"context"`
+		Data json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(b, &bg); err != nil {
+		return nil, "", fmt.Errorf("json.Unmarshal: %w", err)
+	}
+	if bg.Context.EventID == "" || bg.Data == nil {
+		return nil, "", fmt.Errorf("background event needs context.eventId and data")
+	}
+	if err := json.Unmarshal(b, &f.Background); err != nil {
+		return nil, "", err
+	}
+	return f, bg.Context.EventID, nil
+}
+
+// record serves a recorder on addr until the process exits.
+func record(addr, dir string) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	log.Printf("Recording events to %s, listening on %s", dir, addr)
+	return http.ListenAndServe(addr, &recorder{dir: dir})
+}
@@ -0,0 +1,22 @@
+module github.com/GoogleCloudPlatform/golang-samples/eventarc/router
+
+go 1.23.0
+
+require (
+	github.com/cloudevents/sdk-go/v2 v2.15.2
+	github.com/googleapis/google-cloudevents-go v0.8.0
+	google.golang.org/protobuf v1.36.3
+)
+
+require (
+	github.com/google/go-cmp v0.6.0 // indirect
+	github.com/google/uuid v1.6.0 // indirect
+	github.com/json-iterator/go v1.1.12 // indirect
+	github.com/modern-go/concurrent v0.0.0-20180306012644-bacd9c7ef1dd // indirect
+	github.com/modern-go/reflect2 v1.0.2 // indirect
+	github.com/stretchr/testify v1.10.0 // indirect
+	go.uber.org/multierr v1.11.0 // indirect
+	go.uber.org/zap v1.27.0 // indirect
+	golang.org/x/time v0.9.0 // indirect
+	google.golang.org/genproto/googleapis/rpc v0.0.0-20231212172506-995d672761c0 // indirect
+)
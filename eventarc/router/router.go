@@ -0,0 +1,250 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package router dispatches Eventarc CloudEvents to typed handlers.
+//
+// A Router matches each incoming event by its type and a source pattern,
+// decodes the payload into the matching Google event type, and maps the
+// handler's result onto an HTTP status that Eventarc understands: 2xx
+// acknowledges the event, any other status asks for redelivery.
+//
+// Both binary and structured CloudEvents content modes are accepted.
+package router
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"path"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/googleapis/google-cloudevents-go/cloud/auditdata"
+	"github.com/googleapis/google-cloudevents-go/cloud/storagedata"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// Handler processes a CloudEvent that has already been matched by a Router.
+type Handler func(ctx context.Context, e cloudevents.Event) error
+
+// StorageHandler processes a Cloud Storage object event.
+type StorageHandler func(ctx context.Context, e cloudevents.Event, data *storagedata.StorageObjectData) error
+
+// PubSubHandler processes a Pub/Sub message published event.
+type PubSubHandler func(ctx context.Context, e cloudevents.Event, data *MessagePublishedData) error
+
+// AuditLogHandler processes a Cloud Audit Logs event.
+type AuditLogHandler func(ctx context.Context, e cloudevents.Event, data *auditdata.LogEntryData) error
+
+// MessagePublishedData contains the full Pub/Sub message.
+// See the documentation for more details:
+// https://cloud.google.com/eventarc/docs/cloudevents#pubsub
+type MessagePublishedData struct {
+	Message      PubSubMessage `json:"message"`
+	Subscription string        `json:"subscription"`
+}
+
+// PubSubMessage is the payload of a Pub/Sub event.
+// See the documentation for more details:
+// https://cloud.google.com/pubsub/docs/reference/rest/v1/PubsubMessage
+type PubSubMessage struct {
+	Data        []byte            `json:"data,omitempty"`
+	Attributes  map[string]string `json:"attributes,omitempty"`
+	MessageID   string            `json:"messageId"`
+	PublishTime time.Time         `json:"publishTime"`
+	OrderingKey string            `json:"orderingKey,omitempty"`
+}
+
+type route struct {
+	eventType string
+	source    string
+	required  []string
+	handler   Handler
+}
+
+// Router is an http.Handler that dispatches CloudEvents to the first
+// registered route whose event type and source pattern match.
+type Router struct {
+	routes []route
+}
+
+// New returns an empty Router.
+func New() *Router {
+	return &Router{}
+}
+
+// Handle registers h for events of eventType whose source matches the
+// source pattern. The pattern uses path.Match syntax, so
+// "//storage.googleapis.com/projects/_/buckets/*" matches any bucket; an
+// empty pattern matches every source. Events missing any of the required
+// context attributes or extensions are rejected before h is called.
+func (rt *Router) Handle(eventType, source string, h Handler, required ...string) {
+	if _, err := path.Match(source, ""); err != nil {
+		panic(fmt.Sprintf("router: invalid source pattern %q: %v", source, err))
+	}
+	rt.routes = append(rt.routes, route{
+		eventType: eventType,
+		source:    source,
+		required:  required,
+		handler:   h,
+	})
+}
+
+// HandleStorage registers h for a Cloud Storage event type, such as
+// "google.cloud.storage.object.v1.finalized".
+func (rt *Router) HandleStorage(eventType, source string, h StorageHandler, required ...string) {
+	rt.Handle(eventType, source, func(ctx context.Context, e cloudevents.Event) error {
+		var data storagedata.StorageObjectData
+		if err := unmarshalProto(e, &data); err != nil {
+			return err
+		}
+		return h(ctx, e, &data)
+	}, required...)
+}
+
+// HandlePubSub registers h for Pub/Sub events, published with the type
+// "google.cloud.pubsub.topic.v1.messagePublished".
+func (rt *Router) HandlePubSub(source string, h PubSubHandler, required ...string) {
+	rt.Handle(PubSubMessagePublished, source, func(ctx context.Context, e cloudevents.Event) error {
+		var data MessagePublishedData
+		if err := e.DataAs(&data); err != nil {
+			return &decodeError{err: fmt.Errorf("event.DataAs: %w", err)}
+		}
+		return h(ctx, e, &data)
+	}, required...)
+}
+
+// HandleAuditLog registers h for Cloud Audit Logs events, published with
+// the type "google.cloud.audit.log.v1.written". Audit log events usually
+// also carry the "servicename" and "methodname" extensions, which can be
+// listed in required.
+func (rt *Router) HandleAuditLog(source string, h AuditLogHandler, required ...string) {
+	rt.Handle(AuditLogWritten, source, func(ctx context.Context, e cloudevents.Event) error {
+		var data auditdata.LogEntryData
+		if err := unmarshalProto(e, &data); err != nil {
+			return err
+		}
+		return h(ctx, e, &data)
+	}, required...)
+}
+
+// Event types published by Eventarc that have typed handlers.
+const (
+	PubSubMessagePublished = "google.cloud.pubsub.topic.v1.messagePublished"
+	AuditLogWritten        = "google.cloud.audit.log.v1.written"
+)
+
+// ServeHTTP parses the request as a CloudEvent and dispatches it.
+//
+// Eventarc redelivers events answered with any status but 2xx. Malformed
+// events, events missing required attributes, events that no route
+// matches and payloads that cannot be decoded are logged and acknowledged
+// with 200 OK, because a retry cannot succeed. Handler errors are answered
+// with 500 Internal Server Error so that Eventarc redelivers the event,
+// unless the error was wrapped with Permanent: those are logged and
+// acknowledged too.
+func (rt *Router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Expected HTTP POST request with CloudEvent payload", http.StatusMethodNotAllowed)
+		return
+	}
+	e, err := cloudevents.NewEventFromHTTPRequest(r)
+	if err != nil {
+		log.Printf("cloudevents.NewEventFromHTTPRequest (retry denied): %v", err)
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	rte, ok := rt.match(e)
+	if !ok {
+		log.Printf("event %s (retry denied): no route for type %q, source %q", e.ID(), e.Type(), e.Source())
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	if missing := missingAttributes(e, rte.required); len(missing) > 0 {
+		log.Printf("event %s (retry denied): missing required attributes %v", e.ID(), missing)
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	err = rte.handler(r.Context(), *e)
+	var de *decodeError
+	switch {
+	case err == nil:
+		w.WriteHeader(http.StatusOK)
+	case errors.As(err, &de), IsPermanent(err):
+		// A retry cannot fix this, so acknowledge the event to stop redelivery.
+		log.Printf("event %s (retry denied): %v", e.ID(), err)
+		w.WriteHeader(http.StatusOK)
+	default:
+		log.Printf("event %s (retry expected): %v", e.ID(), err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+	}
+}
+
+// match returns the first route that matches the type and source of e.
+func (rt *Router) match(e *cloudevents.Event) (route, bool) {
+	for _, r := range rt.routes {
+		if r.eventType != e.Type() {
+			continue
+		}
+		if r.source == "" {
+			return r, true
+		}
+		// The pattern was validated in Handle, so the error can be ignored.
+		if ok, _ := path.Match(r.source, e.Source()); ok {
+			return r, true
+		}
+	}
+	return route{}, false
+}
+
+// missingAttributes returns the names in required that e does not set,
+// either as an optional context attribute or as an extension.
+func missingAttributes(e *cloudevents.Event, required []string) []string {
+	var missing []string
+	for _, name := range required {
+		var set bool
+		switch name {
+		case "subject":
+			set = e.Subject() != ""
+		case "time":
+			set = !e.Time().IsZero()
+		case "dataschema":
+			set = e.DataSchema() != ""
+		case "datacontenttype":
+			set = e.DataContentType() != ""
+		default:
+			_, set = e.Extensions()[name]
+		}
+		if !set {
+			missing = append(missing, name)
+		}
+	}
+	return missing
+}
+
+// unmarshalProto decodes the JSON data of e into a Google event proto.
+func unmarshalProto(e cloudevents.Event, m proto.Message) error {
+	// Google event payloads may gain fields over time, and audit logs
+	// include an `@type` annotation; both make a strict Unmarshal fail.
+	options := protojson.UnmarshalOptions{DiscardUnknown: true}
+	if err := options.Unmarshal(e.Data(), m); err != nil {
+		return &decodeError{err: fmt.Errorf("protojson.Unmarshal: %w", err)}
+	}
+	return nil
+}
@@ -0,0 +1,215 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package router
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"path/filepath"
+	"testing"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/googleapis/google-cloudevents-go/cloud/auditdata"
+	"github.com/googleapis/google-cloudevents-go/cloud/storagedata"
+)
+
+// newTestRouter returns a Router whose handlers record a summary of the
+// event they received in got and then return handlerErr.
+func newTestRouter(got *string, handlerErr error) *Router {
+	rt := New()
+	rt.HandleStorage("google.cloud.storage.object.v1.finalized", "//storage.googleapis.com/projects/_/buckets/example-bucket",
+		func(ctx context.Context, e cloudevents.Event, data *storagedata.StorageObjectData) error {
+			*got = path.Join(data.GetBucket(), data.GetName())
+			return handlerErr
+		}, "subject")
+	rt.HandlePubSub("//pubsub.googleapis.com/projects/*/topics/*",
+		func(ctx context.Context, e cloudevents.Event, data *MessagePublishedData) error {
+			*got = fmt.Sprintf("%s origin=%s", data.Message.Data, data.Message.Attributes["origin"])
+			return handlerErr
+		})
+	rt.HandleAuditLog("",
+		func(ctx context.Context, e cloudevents.Event, data *auditdata.LogEntryData) error {
+			*got = fmt.Sprintf("%s by %s", data.GetProtoPayload().GetMethodName(), data.GetProtoPayload().GetAuthenticationInfo().GetPrincipalEmail())
+			return handlerErr
+		}, "servicename", "methodname")
+	return rt
+}
+
+// structuredRequest returns the recorded event as a structured mode request.
+func structuredRequest(t *testing.T, recorded []byte) *http.Request {
+	t.Helper()
+	r := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(recorded))
+	r.Header.Set("Content-Type", "application/cloudevents+json")
+	return r
+}
+
+// binaryRequest returns the recorded event as a binary mode request.
+func binaryRequest(t *testing.T, recorded []byte) *http.Request {
+	t.Helper()
+	var e cloudevents.Event
+	if err := json.Unmarshal(recorded, &e); err != nil {
+		t.Fatalf("json.Unmarshal: %v", err)
+	}
+	r, err := cloudevents.NewHTTPRequestFromEvent(context.Background(), "http://localhost", e)
+	if err != nil {
+		t.Fatalf("cloudevents.NewHTTPRequestFromEvent: %v", err)
+	}
+	return r
+}
+
+func TestRouter(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	tests := []struct {
+		name       string
+		file       string
+		handlerErr error
+		wantStatus int
+		wantGot    string
+	}{
+		{
+			name:       "storage",
+			file:       "storage_finalized.json",
+			wantStatus: http.StatusOK,
+			wantGot:    "example-bucket/example-object.txt",
+		},
+		{
+			name:       "storage retryable error",
+			file:       "storage_finalized.json",
+			handlerErr: errors.New("write failed"),
+			wantStatus: http.StatusInternalServerError,
+			wantGot:    "example-bucket/example-object.txt",
+		},
+		{
+			name:       "storage permanent error",
+			file:       "storage_finalized.json",
+			handlerErr: Permanent(errors.New("access denied")),
+			wantStatus: http.StatusOK,
+			wantGot:    "example-bucket/example-object.txt",
+		},
+		{
+			name:       "storage unmatched source",
+			file:       "storage_other_bucket.json",
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "storage missing subject",
+			file:       "storage_no_subject.json",
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "storage malformed data",
+			file:       "storage_malformed_data.json",
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "pubsub",
+			file:       "pubsub_published.json",
+			wantStatus: http.StatusOK,
+			wantGot:    "World origin=replay",
+		},
+		{
+			name:       "audit log",
+			file:       "audit_iam_key_created.json",
+			wantStatus: http.StatusOK,
+			wantGot:    "google.iam.admin.v1.CreateServiceAccountKey by user@example.com",
+		},
+	}
+
+	modes := []struct {
+		name       string
+		newRequest func(*testing.T, []byte) *http.Request
+	}{
+		{name: "binary", newRequest: binaryRequest},
+		{name: "structured", newRequest: structuredRequest},
+	}
+
+	for _, tc := range tests {
+		recorded, err := os.ReadFile(filepath.Join("testdata", tc.file))
+		if err != nil {
+			t.Fatalf("os.ReadFile: %v", err)
+		}
+		for _, mode := range modes {
+			t.Run(tc.name+"/"+mode.name, func(t *testing.T) {
+				var got string
+				rt := newTestRouter(&got, tc.handlerErr)
+
+				rr := httptest.NewRecorder()
+				rt.ServeHTTP(rr, mode.newRequest(t, recorded))
+
+				if rr.Code != tc.wantStatus {
+					t.Errorf("status: got %d, want %d (body %q)", rr.Code, tc.wantStatus, rr.Body.String())
+				}
+				if got != tc.wantGot {
+					t.Errorf("handler saw %q, want %q", got, tc.wantGot)
+				}
+			})
+		}
+	}
+}
+
+func TestRouterNotCloudEvent(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	var got string
+	rt := newTestRouter(&got, nil)
+
+	tests := []struct {
+		method     string
+		body       string
+		wantStatus int
+	}{
+		{method: http.MethodPost, body: `{"bucket":"example-bucket"}`, wantStatus: http.StatusOK},
+		{method: http.MethodGet, wantStatus: http.StatusMethodNotAllowed},
+	}
+	for _, tc := range tests {
+		rr := httptest.NewRecorder()
+		rt.ServeHTTP(rr, httptest.NewRequest(tc.method, "/", bytes.NewBufferString(tc.body)))
+		if rr.Code != tc.wantStatus {
+			t.Errorf("%s %q: got status %d, want %d", tc.method, tc.body, rr.Code, tc.wantStatus)
+		}
+	}
+	if got != "" {
+		t.Errorf("handler called with %q, want no call", got)
+	}
+}
+
+func TestIsPermanent(t *testing.T) {
+	base := errors.New("boom")
+	if IsPermanent(base) {
+		t.Errorf("IsPermanent(%v) = true, want false", base)
+	}
+	wrapped := fmt.Errorf("handler: %w", Permanent(base))
+	if !IsPermanent(wrapped) {
+		t.Errorf("IsPermanent(%v) = false, want true", wrapped)
+	}
+	if !errors.Is(wrapped, base) {
+		t.Errorf("errors.Is(%v, %v) = false, want true", wrapped, base)
+	}
+	if Permanent(nil) != nil {
+		t.Errorf("Permanent(nil) != nil")
+	}
+}
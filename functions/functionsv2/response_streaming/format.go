@@ -0,0 +1,176 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package responsestreaming
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"strconv"
+	"strings"
+
+	"cloud.google.com/go/bigquery"
+)
+
+// format is an output format for rows.
+type format struct {
+	contentType string
+	newEncoder  func(io.Writer) encoder
+}
+
+// encoder writes rows, and how the stream ended.
+type encoder interface {
+	row(columns []string, values []bigquery.Value) error
+	// done is called after the last of n rows.
+	done(n int) error
+	// fail reports an error that ends the stream early.
+	fail(err error) error
+}
+
+var (
+	ndjsonFormat = format{"application/x-ndjson", func(w io.Writer) encoder { return &ndjsonEncoder{json.NewEncoder(w)} }}
+	csvFormat    = format{"text/csv; charset=utf-8", func(w io.Writer) encoder { return &csvEncoder{w: csv.NewWriter(w)} }}
+	sseFormat    = format{"text/event-stream", func(w io.Writer) encoder { return &sseEncoder{w} }}
+)
+
+// mediaTypes maps the media types of the Accept header to formats.
+var mediaTypes = map[string]format{
+	"application/x-ndjson": ndjsonFormat,
+	"application/jsonl":    ndjsonFormat,
+	"application/json":     ndjsonFormat,
+	"text/csv":             csvFormat,
+	"text/event-stream":    sseFormat,
+	"*/*":                  ndjsonFormat,
+	"application/*":        ndjsonFormat,
+	"text/*":               csvFormat,
+}
+
+// negotiate returns the format of the Accept header value with the highest
+// quality. NDJSON is used without an Accept header.
+func negotiate(accept string) (format, bool) {
+	if strings.TrimSpace(accept) == "" {
+		return ndjsonFormat, true
+	}
+	var best format
+	bestQ, found := 0.0, false
+	for _, mr := range strings.Split(accept, ",") {
+		mt, params, err := mime.ParseMediaType(strings.TrimSpace(mr))
+		if err != nil {
+			continue
+		}
+		f, ok := mediaTypes[mt]
+		if !ok {
+			continue
+		}
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+		if q > bestQ {
+			best, bestQ, found = f, q, true
+		}
+	}
+	return best, found
+}
+
+// rowObject returns a row as a JSON object.
+func rowObject(columns []string, values []bigquery.Value) map[string]bigquery.Value {
+	obj := make(map[string]bigquery.Value, len(values))
+	for i, v := range values {
+		name := strconv.Itoa(i)
+		if i < len(columns) {
+			name = columns[i]
+		}
+		obj[name] = v
+	}
+	return obj
+}
+
+// ndjsonEncoder writes a JSON object per row, and an object with an error
+// field if the stream fails.
+type ndjsonEncoder struct {
+	enc *json.Encoder
+}
+
+func (e *ndjsonEncoder) row(columns []string, values []bigquery.Value) error {
+	return e.enc.Encode(rowObject(columns, values))
+}
+
+func (e *ndjsonEncoder) done(int) error { return nil }
+
+func (e *ndjsonEncoder) fail(err error) error {
+	return e.enc.Encode(map[string]string{"error": err.Error()})
+}
+
+// csvEncoder writes a header and a record per row. CSV has no way to report
+// errors, which are only reported in the trailer.
+type csvEncoder struct {
+	w      *csv.Writer
+	header bool
+}
+
+func (e *csvEncoder) row(columns []string, values []bigquery.Value) error {
+	if !e.header {
+		e.header = true
+		if err := e.w.Write(columns); err != nil {
+			return err
+		}
+	}
+	record := make([]string, len(values))
+	for i, v := range values {
+		if v != nil {
+			record[i] = fmt.Sprint(v)
+		}
+	}
+	e.w.Write(record)
+	// Flush every row, so that it is streamed right away.
+	e.w.Flush()
+	return e.w.Error()
+}
+
+func (e *csvEncoder) done(int) error { return nil }
+
+func (e *csvEncoder) fail(error) error { return nil }
+
+// sseEncoder writes server-sent events: a row event per row, then a done
+// event with the row count, or an error event.
+type sseEncoder struct {
+	w io.Writer
+}
+
+func (e *sseEncoder) event(name string, data any) error {
+	b, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(e.w, "event: %s\ndata: %s\n\n", name, b)
+	return err
+}
+
+func (e *sseEncoder) row(columns []string, values []bigquery.Value) error {
+	return e.event("row", rowObject(columns, values))
+}
+
+func (e *sseEncoder) done(n int) error {
+	return e.event("done", map[string]int{"rows": n})
+}
+
+func (e *sseEncoder) fail(err error) error {
+	return e.event("error", map[string]string{"error": err.Error()})
+}
@@ -19,11 +19,14 @@ package responsestreaming
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
 	"os"
+	"strconv"
+	"sync"
 
 	"cloud.google.com/go/bigquery"
 	"github.com/GoogleCloudPlatform/functions-framework-go/functions"
@@ -34,52 +37,143 @@ func init() {
 	functions.HTTP("streamBigQuery", streamBigQuery)
 }
 
-// streamBigQuery retrieves a large payload from BigQuery public dataset and streams its rows.
-func streamBigQuery(w http.ResponseWriter, r *http.Request) {
+const (
+	defaultLimit = 1000
+	maxLimit     = 100000
+)
+
+// errorTrailer is the HTTP trailer set when streaming fails after the
+// response has started.
+const errorTrailer = "X-Stream-Error"
+
+// bigqueryClient is a global BigQuery client, to avoid initializing a new
+// client for every request.
+var bigqueryClient = sync.OnceValues(func() (*bigquery.Client, error) {
 	projectID := os.Getenv("GOOGLE_CLOUD_PROJECT")
 	if projectID == "" {
-		fmt.Println("GOOGLE_CLOUD_PROJECT environment variable must be set.")
-		os.Exit(1)
+		return nil, errors.New("GOOGLE_CLOUD_PROJECT environment variable must be set")
 	}
-	ctx := context.Background()
 	// Must include project ID in client.
-	client, err := bigquery.NewClient(ctx, projectID)
+	return bigquery.NewClient(context.Background(), projectID)
+})
+
+// streamBigQuery streams the abstracts of a BigQuery public dataset that
+// contain the q query parameter, up to limit rows. The rows are written as
+// NDJSON, CSV or server-sent events, depending on the Accept header.
+func streamBigQuery(w http.ResponseWriter, r *http.Request) {
+	f, ok := negotiate(r.Header.Get("Accept"))
+	if !ok {
+		http.Error(w, "Supported formats: application/x-ndjson, text/csv, text/event-stream", http.StatusNotAcceptable)
+		return
+	}
+	limit := defaultLimit
+	if l := r.URL.Query().Get("limit"); l != "" {
+		var err error
+		if limit, err = strconv.Atoi(l); err != nil || limit < 1 || limit > maxLimit {
+			http.Error(w, fmt.Sprintf("limit must be between 1 and %d", maxLimit), http.StatusBadRequest)
+			return
+		}
+	}
+
+	client, err := bigqueryClient()
 	if err != nil {
-		log.Fatalf("bigquery.NewClient: %v", err)
+		log.Printf("bigquery.NewClient: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
 	}
-	defer client.Close()
 
-	// Run the query and ensure the job finishes without error.
-	rows, err := query(ctx, client)
+	// The request context is canceled when the client disconnects, which
+	// stops the query and the iterator.
+	ctx := r.Context()
+	rows, err := query(ctx, client, r.URL.Query().Get("q"), limit)
 	if err != nil {
-		log.Fatal(err)
+		log.Printf("query: %v", err)
+		http.Error(w, "Query failed", http.StatusInternalServerError)
+		return
 	}
 
-	// Stream out the payload by iterating rows and flushing out the writer.
-	streamResults(w, rows)
+	w.Header().Set("Content-Type", f.contentType)
+	w.Header().Set("Trailer", errorTrailer)
+	n, err := streamResults(ctx, w, f, &bigqueryRows{it: rows})
+	if err != nil {
+		// The status was already sent: report the error in the body and
+		// in the trailer instead.
+		log.Printf("Streaming stopped after %d rows: %v", n, err)
+		w.Header().Set(errorTrailer, err.Error())
+	}
 }
 
-// query returns a row iterator suitable for reading query results.
-func query(ctx context.Context, client *bigquery.Client) (*bigquery.RowIterator, error) {
+// query returns a row iterator over at most limit abstracts containing term.
+func query(ctx context.Context, client *bigquery.Client, term string, limit int) (*bigquery.RowIterator, error) {
 	q := client.Query(
-		"SELECT abstract FROM `bigquery-public-data.breathe.bioasq` LIMIT 1000")
+		"SELECT abstract FROM `bigquery-public-data.breathe.bioasq` " +
+			"WHERE CONTAINS_SUBSTR(abstract, @term) LIMIT @limit")
+	q.Parameters = []bigquery.QueryParameter{
+		{Name: "term", Value: term},
+		{Name: "limit", Value: limit},
+	}
 	q.Location = "US"
 	return q.Read(ctx)
 }
 
-// streamResults streams results from a query of BigQuery's public dataset.
-func streamResults(w io.Writer, it *bigquery.RowIterator) {
+// rowSource is a source of rows, such as the results of a query.
+type rowSource interface {
+	// Next returns the column names and values of the next row, or
+	// iterator.Done after the last row.
+	Next() (columns []string, values []bigquery.Value, err error)
+}
+
+// bigqueryRows is a rowSource reading a BigQuery row iterator.
+type bigqueryRows struct {
+	it      *bigquery.RowIterator
+	columns []string
+}
+
+func (b *bigqueryRows) Next() ([]string, []bigquery.Value, error) {
+	var row []bigquery.Value
+	if err := b.it.Next(&row); err != nil {
+		return nil, nil, err
+	}
+	// The schema is known once the first row is read.
+	if b.columns == nil {
+		for _, f := range b.it.Schema {
+			b.columns = append(b.columns, f.Name)
+		}
+	}
+	return b.columns, row, nil
+}
+
+// streamResults writes rows to w in format f, flushing each row to the
+// client. It stops when ctx is done, and reports errors in the body. It
+// returns the number of rows written.
+func streamResults(ctx context.Context, w io.Writer, f format, rows rowSource) (int, error) {
+	enc := f.newEncoder(w)
+	flush := func() {}
+	if fl, ok := w.(http.Flusher); ok {
+		flush = fl.Flush
+	}
+	n := 0
 	for {
-		var row []bigquery.Value
-		err := it.Next(&row)
+		if err := ctx.Err(); err != nil {
+			// Nobody is listening anymore.
+			return n, err
+		}
+		columns, values, err := rows.Next()
 		if err == iterator.Done {
-			break
+			return n, enc.done(n)
+		}
+		if err != nil {
+			if werr := enc.fail(err); werr != nil {
+				return n, werr
+			}
+			flush()
+			return n, err
 		}
-		fmt.Fprintln(w, row[0])
-		if f, ok := w.(http.Flusher); ok {
-			f.Flush()
-			fmt.Fprintln(w, "Successfully flushed row")
+		if err := enc.row(columns, values); err != nil {
+			return n, err
 		}
+		n++
+		flush()
 	}
 }
 
@@ -16,12 +16,15 @@ package responsestreaming
 
 import (
 	"context"
+	"errors"
+	"net/http"
 	"net/http/httptest"
 	"os"
 	"strings"
 	"testing"
 
 	"cloud.google.com/go/bigquery"
+	"google.golang.org/api/iterator"
 )
 
 func TestResponseStreaming(t *testing.T) {
@@ -35,16 +38,201 @@ func TestResponseStreaming(t *testing.T) {
 		t.Fatalf("bigquery.NewClient: %v", err)
 	}
 
-	rows, err := query(ctx, client)
+	rows, err := query(ctx, client, "", 10)
 	if err != nil {
 		t.Fatal(err)
 	}
 
 	w := httptest.NewRecorder()
-	streamResults(w, rows)
+	n, err := streamResults(ctx, w, ndjsonFormat, &bigqueryRows{it: rows})
+	if err != nil {
+		t.Fatalf("streamResults: %v", err)
+	}
+	if n != 10 {
+		t.Errorf("streamResults wrote %d rows, want 10", n)
+	}
+	if got := strings.Count(w.Body.String(), `{"abstract":`); got != 10 {
+		t.Errorf("got %d abstracts, want 10:\n%s", got, w.Body)
+	}
+}
+
+// fakeRows returns rows, then err if set.
+type fakeRows struct {
+	columns []string
+	rows    [][]bigquery.Value
+	err     error
+	// onNext is called before each row is returned.
+	onNext func(i int)
+	next   int
+}
+
+func (f *fakeRows) Next() ([]string, []bigquery.Value, error) {
+	if f.next == len(f.rows) {
+		if f.err != nil {
+			return nil, nil, f.err
+		}
+		return nil, nil, iterator.Done
+	}
+	if f.onNext != nil {
+		f.onNext(f.next)
+	}
+	f.next++
+	return f.columns, f.rows[f.next-1], nil
+}
+
+func newFakeRows() *fakeRows {
+	return &fakeRows{
+		columns: []string{"abstract", "year"},
+		rows: [][]bigquery.Value{
+			{"Plain text", int64(2020)},
+			{`With "quotes", commas`, nil},
+		},
+	}
+}
+
+func TestStreamResults(t *testing.T) {
+	errQuery := errors.New("backend unavailable")
+	tests := []struct {
+		name   string
+		format format
+		err    error
+		want   string
+	}{
+		{
+			name:   "NDJSON",
+			format: ndjsonFormat,
+			want: `{"abstract":"Plain text","year":2020}
+{"abstract":"With \"quotes\", commas","year":null}
+`,
+		},
+		{
+			name:   "NDJSON error",
+			format: ndjsonFormat,
+			err:    errQuery,
+			want: `{"abstract":"Plain text","year":2020}
+{"abstract":"With \"quotes\", commas","year":null}
+{"error":"backend unavailable"}
+`,
+		},
+		{
+			name:   "CSV",
+			format: csvFormat,
+			want: `abstract,year
+Plain text,2020
+"With ""quotes"", commas",
+`,
+		},
+		{
+			name:   "SSE",
+			format: sseFormat,
+			want: `event: row
+data: {"abstract":"Plain text","year":2020}
+
+event: row
+data: {"abstract":"With \"quotes\", commas","year":null}
+
+event: done
+data: {"rows":2}
+
+`,
+		},
+		{
+			name:   "SSE error",
+			format: sseFormat,
+			err:    errQuery,
+			want: `event: row
+data: {"abstract":"Plain text","year":2020}
 
-	want := true
-	if got := strings.Contains(w.Body.String(), "Successfully flushed row"); got != want {
-		t.Errorf("Response contains Successfully flushed row: %t, want %t", got, want)
+event: row
+data: {"abstract":"With \"quotes\", commas","year":null}
+
+event: error
+data: {"error":"backend unavailable"}
+
+`,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			rows := newFakeRows()
+			rows.err = tc.err
+			w := httptest.NewRecorder()
+			n, err := streamResults(context.Background(), w, tc.format, rows)
+			if err != tc.err {
+				t.Errorf("streamResults returned error %v, want %v", err, tc.err)
+			}
+			if n != 2 {
+				t.Errorf("streamResults wrote %d rows, want 2", n)
+			}
+			if got := w.Body.String(); got != tc.want {
+				t.Errorf("got body:\n%s\nwant:\n%s", got, tc.want)
+			}
+			if !w.Flushed {
+				t.Error("rows were not flushed")
+			}
+		})
+	}
+}
+
+func TestStreamResultsDisconnect(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	rows := newFakeRows()
+	// The client goes away after the first row.
+	rows.onNext = func(i int) {
+		if i == 0 {
+			cancel()
+		}
+	}
+	w := httptest.NewRecorder()
+	n, err := streamResults(ctx, w, ndjsonFormat, rows)
+	if err != context.Canceled {
+		t.Errorf("streamResults returned error %v, want %v", err, context.Canceled)
+	}
+	if n != 1 || rows.next != 1 {
+		t.Errorf("streamResults wrote %d rows and read %d, want 1 of each", n, rows.next)
+	}
+}
+
+func TestNegotiate(t *testing.T) {
+	tests := []struct {
+		accept string
+		want   string
+	}{
+		{"", ndjsonFormat.contentType},
+		{"*/*", ndjsonFormat.contentType},
+		{"text/csv", csvFormat.contentType},
+		{"text/event-stream", sseFormat.contentType},
+		{"application/x-ndjson, text/csv;q=0.5", ndjsonFormat.contentType},
+		{"application/x-ndjson;q=0.2, text/csv;q=0.5", csvFormat.contentType},
+		{"text/html, text/event-stream;q=0.9", sseFormat.contentType},
+		{"text/html", ""},
+		{"text/csv;q=0", ""},
+	}
+	for _, tc := range tests {
+		f, ok := negotiate(tc.accept)
+		if got := f.contentType; got != tc.want || ok != (tc.want != "") {
+			t.Errorf("negotiate(%q) = %q, %v, want %q", tc.accept, got, ok, tc.want)
+		}
+	}
+}
+
+func TestStreamBigQueryBadRequest(t *testing.T) {
+	tests := []struct {
+		target, accept string
+		want           int
+	}{
+		{"/", "text/html", http.StatusNotAcceptable},
+		{"/?limit=0", "", http.StatusBadRequest},
+		{"/?limit=many", "", http.StatusBadRequest},
+		{"/?limit=1000000", "", http.StatusBadRequest},
+	}
+	for _, tc := range tests {
+		r := httptest.NewRequest("GET", tc.target, nil)
+		r.Header.Set("Accept", tc.accept)
+		w := httptest.NewRecorder()
+		streamBigQuery(w, r)
+		if w.Code != tc.want {
+			t.Errorf("GET %s with Accept %q: got status %d, want %d", tc.target, tc.accept, w.Code, tc.want)
+		}
 	}
 }
@@ -18,49 +18,79 @@
 package http
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"net/http"
+	"os"
+	"sync"
 
+	"cloud.google.com/go/storage"
 	"github.com/GoogleCloudPlatform/functions-framework-go/functions"
 )
 
-// UploadFile processes a 'multipart/form-data' upload request.
-func UploadFile(w http.ResponseWriter, r *http.Request) {
-	const maxMemory = 2 * 1024 * 1024 // 2 megabytes.
+// storageClient is created on first use, for functions storing uploads in
+// Cloud Storage.
+var storageClient = sync.OnceValues(func() (*storage.Client, error) {
+	return storage.NewClient(context.Background())
+})
 
-	// ParseMultipartForm parses a request body as multipart/form-data.
-	// The whole request body is parsed and up to a total of maxMemory bytes of
-	// its file parts are stored in memory, with the remainder stored on
-	// disk in temporary files.
+// newUploader returns an Uploader storing files in the UPLOAD_BUCKET bucket
+// if set, or else in the UPLOAD_DIR directory if set. Otherwise, files are
+// checked and discarded.
+func newUploader() (*Uploader, error) {
+	u := &Uploader{
+		MaxFileSize:  10 << 20,
+		MaxTotalSize: 30 << 20,
+		AllowedTypes: []string{"image/png", "image/jpeg", "image/gif", "image/webp", "application/pdf", "text/plain"},
+		Sink:         DiscardSink{},
+	}
+	if bucket := os.Getenv("UPLOAD_BUCKET"); bucket != "" {
+		client, err := storageClient()
+		if err != nil {
+			return nil, fmt.Errorf("storage.NewClient: %w", err)
+		}
+		u.Sink = &GCSSink{Bucket: client.Bucket(bucket), Prefix: "uploads/"}
+	} else if dir := os.Getenv("UPLOAD_DIR"); dir != "" {
+		// Note that the local disk of a function is in memory, and files
+		// saved there may not persist after the current invocation
+		// completes. Only use a directory for local development.
+		u.Sink = &DirSink{Dir: dir}
+	}
+	return u, nil
+}
 
-	// Note that any files saved during a particular invocation may not
-	// persist after the current invocation completes; persistent files
-	// should be stored elsewhere, such as in a Cloud Storage bucket.
-	if err := r.ParseMultipartForm(maxMemory); err != nil {
-		http.Error(w, "Unable to parse form", http.StatusBadRequest)
-		log.Printf("Error parsing form: %v", err)
+// UploadFile processes a 'multipart/form-data' upload request.
+func UploadFile(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	u, err := newUploader()
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		log.Printf("newUploader: %v", err)
 		return
 	}
 
-	// Be sure to remove all temporary files after your function is finished.
-	defer func() {
-		if err := r.MultipartForm.RemoveAll(); err != nil {
-			http.Error(w, "Error cleaning up form files", http.StatusInternalServerError)
-			log.Printf("Error cleaning up form files: %v", err)
-		}
-	}()
-
-	// r.MultipartForm.File contains *multipart.FileHeader values for every
-	// file in the form. You can access the file contents using
-	// *multipart.FileHeader's Open method.
-	for _, headers := range r.MultipartForm.File {
-		for _, h := range headers {
-			fmt.Fprintf(w, "File uploaded: %q (%v bytes)", h.Filename, h.Size)
-			// Use h.Open() to read the contents of the file.
+	// Upload streams each file to its sink as it is read from the request,
+	// rather than parsing the whole form first as ParseMultipartForm does.
+	files, err := u.Upload(r.Context(), w, r)
+	if err != nil {
+		status := uploadStatus(err)
+		if status == http.StatusInternalServerError {
+			http.Error(w, "Internal Server Error", status)
+		} else {
+			http.Error(w, err.Error(), status)
 		}
+		log.Printf("Error uploading files (%d stored): %v", len(files), err)
+		return
 	}
 
+	for _, f := range files {
+		fmt.Fprintf(w, "File uploaded: %q (%v bytes, %s, sha256 %s)\n", f.Name, f.Size, f.ContentType, f.SHA256)
+	}
 }
 
 func init() {
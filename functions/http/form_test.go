@@ -19,6 +19,8 @@ import (
 	"fmt"
 	"mime/multipart"
 	"net/http/httptest"
+	"os"
+	"strings"
 	"testing"
 )
 
@@ -34,12 +36,42 @@ func TestUploadFile(t *testing.T) {
 	req.Header.Set("Content-Type", w.FormDataContentType())
 	w.Close()
 
+	t.Setenv("UPLOAD_DIR", t.TempDir())
 	rr := httptest.NewRecorder()
 
 	UploadFile(rr, req)
 
-	want := `File uploaded: "my_file.txt" (18 bytes)`
+	want := `File uploaded: "my_file.txt" (18 bytes, text/plain; charset=utf-8, sha256 fd5eed5b1c155ce0eb9021acb98dd777f6400273a5a0a00959f5b0a474962f1c)` + "\n"
 	if got := rr.Body.String(); got != want {
 		t.Errorf("UploadFile got %q, want to contain %q", got, want)
 	}
 }
+
+func TestUploadFileDiscard(t *testing.T) {
+	buf := &bytes.Buffer{}
+	w := multipart.NewWriter(buf)
+	fWriter, err := w.CreateFormFile("file", "my_file.txt")
+	if err != nil {
+		t.Errorf("Unable to create file: %v", err)
+	}
+	fmt.Fprintf(fWriter, "Content of my file")
+	w.Close()
+	req := httptest.NewRequest("POST", "/", buf)
+	req.Header.Set("Content-Type", w.FormDataContentType())
+
+	// Without UPLOAD_BUCKET and UPLOAD_DIR, nothing is written to disk.
+	t.Setenv("UPLOAD_BUCKET", "")
+	t.Setenv("UPLOAD_DIR", "")
+	tmp := t.TempDir()
+	t.Setenv("TMPDIR", tmp)
+	rr := httptest.NewRecorder()
+
+	UploadFile(rr, req)
+
+	if got, want := rr.Body.String(), `File uploaded: "my_file.txt" (18 bytes`; !strings.HasPrefix(got, want) {
+		t.Errorf("UploadFile got %q, want prefix %q", got, want)
+	}
+	if entries, err := os.ReadDir(tmp); err != nil || len(entries) != 0 {
+		t.Errorf("temporary directory has %v, %v, want it empty", entries, err)
+	}
+}
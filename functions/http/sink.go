@@ -0,0 +1,136 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package http
+
+import (
+	"context"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+
+	"cloud.google.com/go/storage"
+)
+
+// Sink stores uploaded files.
+type Sink interface {
+	// Create returns a writer for the file name. Closing the writer
+	// stores the file, unless ctx is done, in which case the file is
+	// discarded.
+	Create(ctx context.Context, name, contentType string) (io.WriteCloser, error)
+}
+
+// DirSink stores files in a local directory. Files are written to a
+// temporary file first, so partial files are never visible.
+//
+// Files written by a function may not outlive the invocation, and count
+// against its memory: use a GCSSink to keep them.
+type DirSink struct {
+	Dir string
+}
+
+// Create creates a temporary file in the directory.
+func (s *DirSink) Create(ctx context.Context, name, contentType string) (io.WriteCloser, error) {
+	if err := os.MkdirAll(s.Dir, 0o755); err != nil {
+		return nil, err
+	}
+	f, err := os.CreateTemp(s.Dir, "."+name+".*")
+	if err != nil {
+		return nil, err
+	}
+	return &dirWriter{ctx: ctx, f: f, path: filepath.Join(s.Dir, name)}, nil
+}
+
+type dirWriter struct {
+	ctx  context.Context
+	f    *os.File
+	path string
+}
+
+func (w *dirWriter) Write(p []byte) (int, error) {
+	return w.f.Write(p)
+}
+
+// Close renames the temporary file to its final name.
+func (w *dirWriter) Close() error {
+	err := w.f.Close()
+	if err == nil {
+		err = w.ctx.Err()
+	}
+	if err == nil {
+		err = os.Rename(w.f.Name(), w.path)
+	}
+	if err != nil {
+		os.Remove(w.f.Name())
+	}
+	return err
+}
+
+// DiscardSink discards files. The Uploader still checks and hashes them.
+type DiscardSink struct{}
+
+// Create returns a writer discarding the file.
+func (DiscardSink) Create(ctx context.Context, name, contentType string) (io.WriteCloser, error) {
+	return nopCloser{io.Discard}, nil
+}
+
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error { return nil }
+
+// GCSSink stores files as objects of a Cloud Storage bucket, named after
+// the file with Prefix prepended.
+type GCSSink struct {
+	Bucket *storage.BucketHandle
+	Prefix string
+}
+
+// Create starts writing an object. Cloud Storage only creates the object
+// once the writer is closed.
+func (s *GCSSink) Create(ctx context.Context, name, contentType string) (io.WriteCloser, error) {
+	obj := s.Bucket.Object(s.Prefix + name)
+	w := obj.NewWriter(ctx)
+	w.ContentType = contentType
+	return &gcsWriter{ctx: ctx, obj: obj, w: w, crc: crc32.New(crc32.MakeTable(crc32.Castagnoli))}, nil
+}
+
+type gcsWriter struct {
+	ctx context.Context
+	obj *storage.ObjectHandle
+	w   *storage.Writer
+	crc hash.Hash32
+}
+
+func (w *gcsWriter) Write(p []byte) (int, error) {
+	w.crc.Write(p)
+	return w.w.Write(p)
+}
+
+// Close finishes the upload, and checks that the object has the CRC32C
+// checksum of the bytes written.
+func (w *gcsWriter) Close() error {
+	if err := w.w.Close(); err != nil {
+		return err
+	}
+	if got, want := w.w.Attrs().CRC32C, w.crc.Sum32(); got != want {
+		w.obj.Delete(context.WithoutCancel(w.ctx))
+		return fmt.Errorf("object %s: CRC32C %08x, want %08x", w.obj.ObjectName(), got, want)
+	}
+	return nil
+}
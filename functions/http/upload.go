@@ -0,0 +1,191 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package http
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"slices"
+)
+
+// maxFormOverhead is the room left in a request for the part headers and
+// the form fields, on top of the file contents.
+const maxFormOverhead = 64 << 10
+
+// sniffLen is the number of bytes http.DetectContentType looks at.
+const sniffLen = 512
+
+// Uploader streams the files of multipart/form-data requests to a Sink,
+// without buffering them in memory or on disk.
+type Uploader struct {
+	Sink Sink
+	// MaxFileSize is the maximum size of a file, in bytes.
+	MaxFileSize int64
+	// MaxTotalSize is the maximum size of all the files of a request.
+	MaxTotalSize int64
+	// AllowedTypes lists the media types, such as "image/png", that files
+	// may have. Types are sniffed from the file contents: the Content-Type
+	// sent by clients is ignored.
+	AllowedTypes []string
+}
+
+// UploadedFile describes a stored file.
+type UploadedFile struct {
+	Field       string `json:"field"`
+	Name        string `json:"name"`
+	Size        int64  `json:"size"`
+	ContentType string `json:"contentType"`
+	SHA256      string `json:"sha256"`
+}
+
+// uploadError is an error caused by the request, with the status code to
+// answer it with.
+type uploadError struct {
+	status int
+	err    error
+}
+
+func (e *uploadError) Error() string { return e.err.Error() }
+func (e *uploadError) Unwrap() error { return e.err }
+
+// uploadStatus returns the status code for an error returned by Upload.
+func uploadStatus(err error) int {
+	var ue *uploadError
+	if errors.As(err, &ue) {
+		return ue.status
+	}
+	return http.StatusInternalServerError
+}
+
+// readError classifies an error reading the request body.
+func readError(err error) error {
+	var mbe *http.MaxBytesError
+	if errors.As(err, &mbe) {
+		return &uploadError{http.StatusRequestEntityTooLarge, fmt.Errorf("request larger than %d bytes", mbe.Limit)}
+	}
+	return &uploadError{http.StatusBadRequest, fmt.Errorf("malformed multipart body: %w", err)}
+}
+
+// Upload stores the files of r, in order, and returns them. Other form
+// fields are ignored. If a file fails, it is discarded, and the files
+// stored before it are returned with the error.
+func (u *Uploader) Upload(ctx context.Context, w http.ResponseWriter, r *http.Request) ([]UploadedFile, error) {
+	r.Body = http.MaxBytesReader(w, r.Body, u.MaxTotalSize+maxFormOverhead)
+	mr, err := r.MultipartReader()
+	if errors.Is(err, http.ErrNotMultipart) {
+		return nil, &uploadError{http.StatusUnsupportedMediaType, err}
+	}
+	if err != nil {
+		return nil, &uploadError{http.StatusBadRequest, err}
+	}
+
+	var files []UploadedFile
+	var total int64
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			return files, nil
+		}
+		if err != nil {
+			return files, readError(err)
+		}
+		if part.FileName() == "" {
+			// NextPart skips the rest of the part.
+			continue
+		}
+		f, err := u.save(ctx, part, u.MaxTotalSize-total)
+		if err != nil {
+			return files, fmt.Errorf("%q: %w", part.FileName(), err)
+		}
+		total += f.Size
+		files = append(files, f)
+	}
+}
+
+// save stores a file part of up to quota bytes.
+func (u *Uploader) save(ctx context.Context, part *multipart.Part, quota int64) (UploadedFile, error) {
+	f := UploadedFile{Field: part.FormName(), Name: part.FileName()}
+	if f.Name == "." || f.Name == ".." || f.Name == "/" {
+		return f, &uploadError{http.StatusBadRequest, errors.New("invalid file name")}
+	}
+
+	lr := &limitReader{r: part, n: u.MaxFileSize, err: fmt.Errorf("file larger than %d bytes", u.MaxFileSize)}
+	if quota < u.MaxFileSize {
+		lr.n = quota
+		lr.err = fmt.Errorf("files larger than %d bytes in total", u.MaxTotalSize)
+	}
+	br := bufio.NewReaderSize(lr, sniffLen)
+	head, err := br.Peek(sniffLen)
+	if err != nil && err != io.EOF {
+		return f, err
+	}
+	f.ContentType = http.DetectContentType(head)
+	mediaType, _, _ := mime.ParseMediaType(f.ContentType)
+	if !slices.Contains(u.AllowedTypes, mediaType) {
+		return f, &uploadError{http.StatusUnsupportedMediaType, fmt.Errorf("content type %s not allowed", mediaType)}
+	}
+
+	// Cancelling ctx discards the file if it fails half way.
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	sw, err := u.Sink.Create(ctx, f.Name, f.ContentType)
+	if err != nil {
+		return f, err
+	}
+	h := sha256.New()
+	f.Size, err = io.Copy(io.MultiWriter(sw, h), br)
+	if err != nil {
+		cancel()
+		sw.Close()
+		return f, err
+	}
+	if err := sw.Close(); err != nil {
+		return f, err
+	}
+	f.SHA256 = hex.EncodeToString(h.Sum(nil))
+	return f, nil
+}
+
+// limitReader reads from r, failing with a 413 error past n bytes. Other
+// errors from r are classified by readError.
+type limitReader struct {
+	r   io.Reader
+	n   int64
+	err error
+}
+
+func (l *limitReader) Read(p []byte) (int, error) {
+	// Read one byte past the limit to tell whether it is exceeded.
+	if int64(len(p)) > l.n+1 {
+		p = p[:l.n+1]
+	}
+	n, err := l.r.Read(p)
+	if int64(n) > l.n {
+		return int(l.n), &uploadError{http.StatusRequestEntityTooLarge, l.err}
+	}
+	l.n -= int64(n)
+	if err != nil && err != io.EOF {
+		err = readError(err)
+	}
+	return n, err
+}
@@ -0,0 +1,264 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package http
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// memSink keeps the files in memory.
+type memSink struct {
+	files map[string][]byte
+}
+
+func (s *memSink) Create(ctx context.Context, name, contentType string) (io.WriteCloser, error) {
+	return &memWriter{ctx: ctx, sink: s, name: name}, nil
+}
+
+type memWriter struct {
+	ctx  context.Context
+	sink *memSink
+	name string
+	buf  bytes.Buffer
+}
+
+func (w *memWriter) Write(p []byte) (int, error) { return w.buf.Write(p) }
+
+func (w *memWriter) Close() error {
+	if err := w.ctx.Err(); err != nil {
+		return err
+	}
+	w.sink.files[w.name] = w.buf.Bytes()
+	return nil
+}
+
+var png = append([]byte("\x89PNG\r\n\x1a\n"), make([]byte, 100)...)
+
+type formFile struct {
+	name    string
+	content []byte
+}
+
+// multipartBody returns a form with the files, and its content type.
+func multipartBody(t *testing.T, files ...formFile) (*bytes.Buffer, string) {
+	t.Helper()
+	buf := &bytes.Buffer{}
+	mw := multipart.NewWriter(buf)
+	mw.WriteField("title", "holidays")
+	for _, f := range files {
+		fw, err := mw.CreateFormFile("file", f.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fw.Write(f.content)
+	}
+	mw.Close()
+	return buf, mw.FormDataContentType()
+}
+
+func upload(sink Sink, body io.Reader, contentType string) ([]UploadedFile, error) {
+	u := &Uploader{
+		Sink:         sink,
+		MaxFileSize:  200,
+		MaxTotalSize: 300,
+		AllowedTypes: []string{"image/png", "text/plain"},
+	}
+	r := httptest.NewRequest("POST", "/", body)
+	r.Header.Set("Content-Type", contentType)
+	return u.Upload(context.Background(), httptest.NewRecorder(), r)
+}
+
+func TestUpload(t *testing.T) {
+	sink := &memSink{files: map[string][]byte{}}
+	body, contentType := multipartBody(t,
+		formFile{"photo.png", png},
+		formFile{"../../notes.txt", []byte("hello")})
+
+	files, err := upload(sink, body, contentType)
+	if err != nil {
+		t.Fatalf("Upload: %v", err)
+	}
+	sum := sha256.Sum256(png)
+	want := []UploadedFile{
+		{Field: "file", Name: "photo.png", Size: int64(len(png)), ContentType: "image/png", SHA256: hex.EncodeToString(sum[:])},
+		{Field: "file", Name: "notes.txt", Size: 5, ContentType: "text/plain; charset=utf-8",
+			SHA256: "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"},
+	}
+	if len(files) != len(want) {
+		t.Fatalf("Upload returned %d files, want %d", len(files), len(want))
+	}
+	for i := range want {
+		if files[i] != want[i] {
+			t.Errorf("file %d = %+v, want %+v", i, files[i], want[i])
+		}
+	}
+	if !bytes.Equal(sink.files["photo.png"], png) || string(sink.files["notes.txt"]) != "hello" {
+		t.Errorf("stored files differ from the uploaded ones")
+	}
+}
+
+func TestUploadErrors(t *testing.T) {
+	big := bytes.Repeat([]byte("a"), 201)
+	valid, validType := multipartBody(t, formFile{"a.txt", []byte("a")})
+	tests := []struct {
+		name        string
+		body        func() (io.Reader, string)
+		status      int
+		storedFiles int
+	}{
+		{
+			name: "file too large",
+			body: func() (io.Reader, string) {
+				return multipartBody(t, formFile{"a.txt", []byte("a")}, formFile{"big.txt", big})
+			},
+			status:      http.StatusRequestEntityTooLarge,
+			storedFiles: 1,
+		},
+		{
+			name: "total too large",
+			body: func() (io.Reader, string) {
+				half := bytes.Repeat([]byte("a"), 150)
+				return multipartBody(t, formFile{"a.txt", half}, formFile{"b.txt", half}, formFile{"c.txt", half})
+			},
+			status:      http.StatusRequestEntityTooLarge,
+			storedFiles: 2,
+		},
+		{
+			name: "type not allowed",
+			body: func() (io.Reader, string) {
+				return multipartBody(t, formFile{"a.exe", []byte("MZ\x90\x00\x03\x00\x00\x00")})
+			},
+			status: http.StatusUnsupportedMediaType,
+		},
+		{
+			name: "not multipart",
+			body: func() (io.Reader, string) {
+				return strings.NewReader("a"), "text/plain"
+			},
+			status: http.StatusUnsupportedMediaType,
+		},
+		{
+			name: "missing boundary",
+			body: func() (io.Reader, string) {
+				return strings.NewReader("a"), "multipart/form-data"
+			},
+			status: http.StatusBadRequest,
+		},
+		{
+			name: "truncated",
+			body: func() (io.Reader, string) {
+				b := valid.Bytes()
+				return bytes.NewReader(b[:len(b)-20]), validType
+			},
+			status: http.StatusBadRequest,
+		},
+		{
+			name: "invalid file name",
+			body: func() (io.Reader, string) {
+				return multipartBody(t, formFile{"..", []byte("a")})
+			},
+			status: http.StatusBadRequest,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			sink := &memSink{files: map[string][]byte{}}
+			body, contentType := tc.body()
+			files, err := upload(sink, body, contentType)
+			if err == nil {
+				t.Fatalf("Upload succeeded, want error")
+			}
+			if got := uploadStatus(err); got != tc.status {
+				t.Errorf("status = %d, want %d (%v)", got, tc.status, err)
+			}
+			if len(files) != tc.storedFiles || len(sink.files) != tc.storedFiles {
+				t.Errorf("Upload stored %d files and returned %d, want %d", len(sink.files), len(files), tc.storedFiles)
+			}
+		})
+	}
+}
+
+func TestUploadRequestTooLarge(t *testing.T) {
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	mw.WriteField("notes", strings.Repeat("a", 300+maxFormOverhead))
+	mw.Close()
+
+	_, err := upload(&memSink{files: map[string][]byte{}}, &buf, mw.FormDataContentType())
+	if got := uploadStatus(err); got != http.StatusRequestEntityTooLarge {
+		t.Errorf("status = %d, want 413 (%v)", got, err)
+	}
+}
+
+func TestUploadFileErrors(t *testing.T) {
+	t.Setenv("UPLOAD_DIR", t.TempDir())
+
+	rr := httptest.NewRecorder()
+	UploadFile(rr, httptest.NewRequest("GET", "/", nil))
+	if rr.Code != http.StatusMethodNotAllowed {
+		t.Errorf("GET: status %d, want 405", rr.Code)
+	}
+
+	body, contentType := multipartBody(t, formFile{"a.bin", []byte{0, 1, 2}})
+	r := httptest.NewRequest("POST", "/", body)
+	r.Header.Set("Content-Type", contentType)
+	rr = httptest.NewRecorder()
+	UploadFile(rr, r)
+	if rr.Code != http.StatusUnsupportedMediaType {
+		t.Errorf("binary file: status %d, want 415", rr.Code)
+	}
+}
+
+func TestDirSink(t *testing.T) {
+	dir := t.TempDir()
+	s := &DirSink{Dir: dir}
+
+	w, err := s.Create(context.Background(), "a.txt", "text/plain")
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.WriteString(w, "hello")
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if b, err := os.ReadFile(filepath.Join(dir, "a.txt")); err != nil || string(b) != "hello" {
+		t.Errorf("a.txt = %q, %v, want hello", b, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	w, err = s.Create(ctx, "b.txt", "text/plain")
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.WriteString(w, "partial")
+	cancel()
+	if err := w.Close(); err == nil {
+		t.Error("Close after cancel succeeded, want error")
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Errorf("directory has %d files, want only a.txt", len(entries))
+	}
+}
@@ -16,42 +16,39 @@
 
 // Package imagemagick contains an example of using ImageMagick to process a
 // file uploaded to Cloud Storage.
+//
+// Images are moderated with Vision SafeSearch according to the rules in
+// the MODERATION_RULES environment variable (see ParseRules), which blur
+// images that are very likely adult or violent by default. Transformed
+// images are written to BLURRED_BUCKET_NAME, and quarantined images are
+// moved to QUARANTINE_BUCKET_NAME. IMAGE_TRANSFORMER selects "imagemagick"
+// or "go" to transform images; by default ImageMagick is used if it is
+// installed.
 package imagemagick
 
 import (
 	"context"
-	"errors"
 	"fmt"
-	"log"
+	"io"
 	"os"
-	"os/exec"
+	"sync"
 
 	"cloud.google.com/go/storage"
 	vision "cloud.google.com/go/vision/apiv1"
 	"cloud.google.com/go/vision/v2/apiv1/visionpb"
 )
 
-// Global API clients used across function invocations.
+// Global API clients used across function invocations. They are created on
+// first use.
 var (
-	storageClient *storage.Client
-	visionClient  *vision.ImageAnnotatorClient
+	storageClient = sync.OnceValues(func() (*storage.Client, error) {
+		return storage.NewClient(context.Background())
+	})
+	visionClient = sync.OnceValues(func() (*vision.ImageAnnotatorClient, error) {
+		return vision.NewImageAnnotatorClient(context.Background())
+	})
 )
 
-func init() {
-	// Declare a separate err variable to avoid shadowing the client variables.
-	var err error
-
-	storageClient, err = storage.NewClient(context.Background())
-	if err != nil {
-		log.Fatalf("storage.NewClient: %v", err)
-	}
-
-	visionClient, err = vision.NewImageAnnotatorClient(context.Background())
-	if err != nil {
-		log.Fatalf("vision.NewAnnotatorClient: %v", err)
-	}
-}
-
 // [END functions_imagemagick_setup]
 
 // [START functions_imagemagick_analyze]
@@ -62,57 +59,107 @@ type GCSEvent struct {
 	Name   string `json:"name"`
 }
 
-// BlurOffensiveImages blurs offensive images uploaded to GCS.
+// BlurOffensiveImages moderates images uploaded to GCS.
 func BlurOffensiveImages(ctx context.Context, e GCSEvent) error {
-	outputBucket := os.Getenv("BLURRED_BUCKET_NAME")
-	if outputBucket == "" {
-		return errors.New("BLURRED_BUCKET_NAME must be set")
+	m, err := moderatorFromEnv(os.Getenv)
+	if err != nil {
+		return err
 	}
-
-	img := vision.NewImageFromURI(fmt.Sprintf("gs://%s/%s", e.Bucket, e.Name))
-
-	resp, err := visionClient.DetectSafeSearch(ctx, img, nil)
+	sc, err := storageClient()
 	if err != nil {
-		return fmt.Errorf("AnnotateImage: %w", err)
+		return fmt.Errorf("storage.NewClient: %w", err)
 	}
-
-	if resp.GetAdult() == visionpb.Likelihood_VERY_LIKELY ||
-		resp.GetViolence() == visionpb.Likelihood_VERY_LIKELY {
-		return blur(ctx, e.Bucket, outputBucket, e.Name)
+	vc, err := visionClient()
+	if err != nil {
+		return fmt.Errorf("vision.NewImageAnnotatorClient: %w", err)
 	}
-	log.Printf("The image %q was detected as OK.", e.Name)
-	return nil
+	m.Store = gcsStore{sc}
+	m.Detector = visionDetector{vc}
+	_, err = m.Moderate(ctx, e.Bucket, e.Name)
+	return err
 }
 
 // [END functions_imagemagick_analyze]
 
-// [START functions_imagemagick_blur]
-
-// blur blurs the image stored at gs://inputBucket/name and stores the result in
-// gs://outputBucket/name.
-func blur(ctx context.Context, inputBucket, outputBucket, name string) error {
-	inputBlob := storageClient.Bucket(inputBucket).Object(name)
-	r, err := inputBlob.NewReader(ctx)
+// moderatorFromEnv returns a Moderator configured by environment
+// variables, without its Store and Detector.
+func moderatorFromEnv(getenv func(string) string) (*Moderator, error) {
+	m := &Moderator{
+		Rules:            DefaultRules,
+		OutputBucket:     getenv("BLURRED_BUCKET_NAME"),
+		QuarantineBucket: getenv("QUARANTINE_BUCKET_NAME"),
+	}
+	if s := getenv("MODERATION_RULES"); s != "" {
+		rules, err := ParseRules(s)
+		if err != nil {
+			return nil, fmt.Errorf("MODERATION_RULES: %w", err)
+		}
+		m.Rules = rules
+	}
+	t, err := newTransformer(getenv("IMAGE_TRANSFORMER"))
 	if err != nil {
-		return fmt.Errorf("NewReader: %w", err)
+		return nil, fmt.Errorf("IMAGE_TRANSFORMER: %w", err)
 	}
+	m.Transformer = t
+	if err := m.validate(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// visionDetector is a Detector using the Vision API.
+type visionDetector struct {
+	client *vision.ImageAnnotatorClient
+}
 
-	outputBlob := storageClient.Bucket(outputBucket).Object(name)
-	w := outputBlob.NewWriter(ctx)
-	defer w.Close()
+func (d visionDetector) DetectSafeSearch(ctx context.Context, uri string) (*visionpb.SafeSearchAnnotation, error) {
+	return d.client.DetectSafeSearch(ctx, vision.NewImageFromURI(uri), nil)
+}
 
-	// Use - as input and output to use stdin and stdout.
-	cmd := exec.Command("convert", "-", "-blur", "0x8", "-")
-	cmd.Stdin = r
-	cmd.Stdout = w
+// gcsStore is a Store using Cloud Storage.
+type gcsStore struct {
+	client *storage.Client
+}
 
-	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("cmd.Run: %w", err)
+func (s gcsStore) NewReader(ctx context.Context, bucket, name string) (io.ReadCloser, string, error) {
+	r, err := s.client.Bucket(bucket).Object(name).NewReader(ctx)
+	if err != nil {
+		return nil, "", err
 	}
+	return r, r.Attrs.ContentType, nil
+}
 
-	log.Printf("Blurred image uploaded to gs://%s/%s", outputBlob.BucketName(), outputBlob.ObjectName())
+func (s gcsStore) NewWriter(ctx context.Context, bucket, name, contentType string, metadata map[string]string) io.WriteCloser {
+	w := s.client.Bucket(bucket).Object(name).NewWriter(ctx)
+	w.ContentType = contentType
+	w.Metadata = metadata
+	return w
+}
+
+func (s gcsStore) Copy(ctx context.Context, bucket, name, dstBucket string, metadata map[string]string) error {
+	src := s.client.Bucket(bucket).Object(name)
+	attrs, err := src.Attrs(ctx)
+	if err != nil {
+		return err
+	}
+	c := s.client.Bucket(dstBucket).Object(name).CopierFrom(src)
+	c.ContentType = attrs.ContentType
+	c.Metadata = map[string]string{}
+	for k, v := range attrs.Metadata {
+		c.Metadata[k] = v
+	}
+	for k, v := range metadata {
+		c.Metadata[k] = v
+	}
+	_, err = c.Run(ctx)
+	return err
+}
 
-	return nil
+func (s gcsStore) Delete(ctx context.Context, bucket, name string) error {
+	return s.client.Bucket(bucket).Object(name).Delete(ctx)
 }
 
-// [END functions_imagemagick_blur]
+func (s gcsStore) SetMetadata(ctx context.Context, bucket, name string, metadata map[string]string) error {
+	_, err := s.client.Bucket(bucket).Object(name).Update(ctx, storage.ObjectAttrsToUpdate{Metadata: metadata})
+	return err
+}
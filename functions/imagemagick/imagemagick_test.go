@@ -38,13 +38,17 @@ func TestBlurOffensiveImages(t *testing.T) {
 		Name:   "functions/zombie.jpg",
 	}
 	ctx := context.Background()
+	client, err := storageClient()
+	if err != nil {
+		t.Fatalf("storage.NewClient: %v", err)
+	}
 
-	inputBlob := storageClient.Bucket(projectID).Object(e.Name)
+	inputBlob := client.Bucket(projectID).Object(e.Name)
 	if _, err := inputBlob.Attrs(ctx); err != nil {
 		t.Skipf("could not get input file: %s: %v", inputBlob.ObjectName(), err)
 	}
 
-	b := storageClient.Bucket(outputBucket)
+	b := client.Bucket(outputBucket)
 	b.Create(ctx, projectID, nil)
 	outputBlob := b.Object(e.Name)
 	outputBlob.Delete(ctx) // Ensure the output file doesn't already exist.
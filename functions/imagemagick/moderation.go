@@ -0,0 +1,265 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package imagemagick
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"slices"
+	"strings"
+
+	"cloud.google.com/go/vision/v2/apiv1/visionpb"
+)
+
+// Detector runs SafeSearch detection on an image.
+type Detector interface {
+	DetectSafeSearch(ctx context.Context, uri string) (*visionpb.SafeSearchAnnotation, error)
+}
+
+// Store reads and writes objects.
+type Store interface {
+	// NewReader returns the content of an object and its content type.
+	NewReader(ctx context.Context, bucket, name string) (io.ReadCloser, string, error)
+	// NewWriter returns a writer to an object, which is only created if
+	// Close succeeds. Canceling ctx aborts the write.
+	NewWriter(ctx context.Context, bucket, name, contentType string, metadata map[string]string) io.WriteCloser
+	// Copy copies an object to another bucket, adding metadata.
+	Copy(ctx context.Context, bucket, name, dstBucket string, metadata map[string]string) error
+	Delete(ctx context.Context, bucket, name string) error
+	// SetMetadata adds metadata to an object.
+	SetMetadata(ctx context.Context, bucket, name string, metadata map[string]string) error
+}
+
+// Action is what is done to an image that matches a rule.
+type Action string
+
+// Actions that can be taken on images.
+const (
+	// Blur writes a blurred copy of the image to the output bucket.
+	Blur Action = "blur"
+	// Pixelate writes a pixelated copy of the image to the output bucket.
+	// It takes precedence over Blur.
+	Pixelate Action = "pixelate"
+	// Quarantine moves the image to the quarantine bucket.
+	Quarantine Action = "quarantine"
+	// Tag adds the SafeSearch results to the object metadata.
+	Tag Action = "tag"
+)
+
+// Categories are the SafeSearch categories rules can refer to.
+var Categories = []string{"adult", "spoof", "medical", "violence", "racy"}
+
+// likelihood returns the likelihood of a category in a.
+func likelihood(a *visionpb.SafeSearchAnnotation, category string) visionpb.Likelihood {
+	switch category {
+	case "adult":
+		return a.GetAdult()
+	case "spoof":
+		return a.GetSpoof()
+	case "medical":
+		return a.GetMedical()
+	case "violence":
+		return a.GetViolence()
+	case "racy":
+		return a.GetRacy()
+	}
+	return visionpb.Likelihood_UNKNOWN
+}
+
+// Rule takes actions on images whose likelihood of being in a category is
+// at least a threshold.
+type Rule struct {
+	Category  string
+	Threshold visionpb.Likelihood
+	Actions   []Action
+}
+
+// DefaultRules blur images that are very likely adult or violent.
+var DefaultRules = []Rule{
+	{Category: "adult", Threshold: visionpb.Likelihood_VERY_LIKELY, Actions: []Action{Blur}},
+	{Category: "violence", Threshold: visionpb.Likelihood_VERY_LIKELY, Actions: []Action{Blur}},
+}
+
+// ParseRules parses rules separated by semicolons, each a category, a
+// threshold and the actions to take, such as:
+//
+//	adult>=LIKELY:blur,tag;violence>=POSSIBLE:quarantine
+func ParseRules(s string) ([]Rule, error) {
+	var rules []Rule
+	for _, rs := range strings.Split(s, ";") {
+		rs = strings.TrimSpace(rs)
+		if rs == "" {
+			continue
+		}
+		cond, actions, ok := strings.Cut(rs, ":")
+		category, threshold, ok2 := strings.Cut(cond, ">=")
+		if !ok || !ok2 {
+			return nil, fmt.Errorf("rule %q: want category>=LIKELIHOOD:action,...", rs)
+		}
+		r := Rule{Category: strings.ToLower(strings.TrimSpace(category))}
+		if !slices.Contains(Categories, r.Category) {
+			return nil, fmt.Errorf("rule %q: unknown category %q, want one of %v", rs, r.Category, Categories)
+		}
+		v, ok := visionpb.Likelihood_value[strings.ToUpper(strings.TrimSpace(threshold))]
+		if !ok || v <= int32(visionpb.Likelihood_UNKNOWN) {
+			return nil, fmt.Errorf("rule %q: invalid likelihood %q", rs, threshold)
+		}
+		r.Threshold = visionpb.Likelihood(v)
+		for _, a := range strings.Split(actions, ",") {
+			switch a := Action(strings.ToLower(strings.TrimSpace(a))); a {
+			case Blur, Pixelate, Quarantine, Tag:
+				r.Actions = append(r.Actions, a)
+			default:
+				return nil, fmt.Errorf("rule %q: unknown action %q", rs, a)
+			}
+		}
+		rules = append(rules, r)
+	}
+	return rules, nil
+}
+
+// Moderator applies rules to images.
+type Moderator struct {
+	Detector    Detector
+	Store       Store
+	Transformer Transformer
+	Rules       []Rule
+	// OutputBucket receives blurred and pixelated images.
+	OutputBucket string
+	// QuarantineBucket receives quarantined images.
+	QuarantineBucket string
+}
+
+// Decision is the outcome of moderating an image.
+type Decision struct {
+	// Categories are the categories of the rules that matched.
+	Categories []string
+	Actions    []Action
+}
+
+// validate checks that the buckets needed by the rules are set.
+func (m *Moderator) validate() error {
+	for _, r := range m.Rules {
+		for _, a := range r.Actions {
+			if (a == Blur || a == Pixelate) && m.OutputBucket == "" {
+				return fmt.Errorf("an output bucket is required to %s images", a)
+			}
+			if a == Quarantine && m.QuarantineBucket == "" {
+				return fmt.Errorf("a quarantine bucket is required to quarantine images")
+			}
+		}
+	}
+	return nil
+}
+
+// Moderate runs SafeSearch on gs://bucket/name and takes the actions of the
+// rules it matches.
+func (m *Moderator) Moderate(ctx context.Context, bucket, name string) (*Decision, error) {
+	if err := m.validate(); err != nil {
+		return nil, err
+	}
+	a, err := m.Detector.DetectSafeSearch(ctx, fmt.Sprintf("gs://%s/%s", bucket, name))
+	if err != nil {
+		return nil, fmt.Errorf("DetectSafeSearch: %w", err)
+	}
+
+	d := &Decision{}
+	for _, r := range m.Rules {
+		if likelihood(a, r.Category) < r.Threshold {
+			continue
+		}
+		if !slices.Contains(d.Categories, r.Category) {
+			d.Categories = append(d.Categories, r.Category)
+		}
+		for _, act := range r.Actions {
+			if !slices.Contains(d.Actions, act) {
+				d.Actions = append(d.Actions, act)
+			}
+		}
+	}
+	if len(d.Actions) == 0 {
+		log.Printf("The image %q was detected as OK.", name)
+		return d, nil
+	}
+
+	var metadata map[string]string
+	if slices.Contains(d.Actions, Tag) {
+		metadata = tags(a, d)
+	}
+	switch {
+	case slices.Contains(d.Actions, Pixelate):
+		err = m.transform(ctx, Pixelate, bucket, name, metadata)
+	case slices.Contains(d.Actions, Blur):
+		err = m.transform(ctx, Blur, bucket, name, metadata)
+	}
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case slices.Contains(d.Actions, Quarantine):
+		// The metadata goes with the quarantined copy.
+		if err := m.Store.Copy(ctx, bucket, name, m.QuarantineBucket, metadata); err != nil {
+			return nil, fmt.Errorf("quarantine: %w", err)
+		}
+		if err := m.Store.Delete(ctx, bucket, name); err != nil {
+			return nil, fmt.Errorf("quarantine: %w", err)
+		}
+		log.Printf("Image %q quarantined to gs://%s/%s", name, m.QuarantineBucket, name)
+	case metadata != nil:
+		if err := m.Store.SetMetadata(ctx, bucket, name, metadata); err != nil {
+			return nil, fmt.Errorf("tag: %w", err)
+		}
+	}
+	return d, nil
+}
+
+// tags returns the metadata describing the moderation of an image.
+func tags(a *visionpb.SafeSearchAnnotation, d *Decision) map[string]string {
+	metadata := map[string]string{
+		"moderation-categories": strings.Join(d.Categories, ","),
+	}
+	for _, c := range Categories {
+		metadata["safesearch-"+c] = likelihood(a, c).String()
+	}
+	return metadata
+}
+
+// transform writes the image at gs://bucket/name transformed by op to the
+// output bucket.
+func (m *Moderator) transform(ctx context.Context, op Action, bucket, name string, metadata map[string]string) error {
+	r, contentType, err := m.Store.NewReader(ctx, bucket, name)
+	if err != nil {
+		return fmt.Errorf("NewReader: %w", err)
+	}
+	defer r.Close()
+
+	// Cancel the write if the transform fails, so that no partial image is
+	// created.
+	wctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	w := m.Store.NewWriter(wctx, m.OutputBucket, name, contentType, metadata)
+	if err := m.Transformer.Transform(ctx, op, r, w); err != nil {
+		cancel()
+		w.Close()
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("writing gs://%s/%s: %w", m.OutputBucket, name, err)
+	}
+	log.Printf("Image with %s applied uploaded to gs://%s/%s", op, m.OutputBucket, name)
+	return nil
+}
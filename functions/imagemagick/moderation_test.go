@@ -0,0 +1,277 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package imagemagick
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image/jpeg"
+	"io"
+	"maps"
+	"os"
+	"slices"
+	"testing"
+
+	"cloud.google.com/go/vision/v2/apiv1/visionpb"
+)
+
+// fakeDetector returns the annotation of each URI.
+type fakeDetector map[string]*visionpb.SafeSearchAnnotation
+
+func (d fakeDetector) DetectSafeSearch(ctx context.Context, uri string) (*visionpb.SafeSearchAnnotation, error) {
+	a, ok := d[uri]
+	if !ok {
+		return nil, fmt.Errorf("no annotation for %s", uri)
+	}
+	return a, nil
+}
+
+type memObject struct {
+	data        []byte
+	contentType string
+	metadata    map[string]string
+}
+
+// memStore is a Store keeping objects in memory, by "bucket/name".
+type memStore map[string]*memObject
+
+func (s memStore) NewReader(ctx context.Context, bucket, name string) (io.ReadCloser, string, error) {
+	o, ok := s[bucket+"/"+name]
+	if !ok {
+		return nil, "", fmt.Errorf("%s/%s not found", bucket, name)
+	}
+	return io.NopCloser(bytes.NewReader(o.data)), o.contentType, nil
+}
+
+func (s memStore) NewWriter(ctx context.Context, bucket, name, contentType string, metadata map[string]string) io.WriteCloser {
+	return &memWriter{ctx: ctx, s: s, key: bucket + "/" + name, o: &memObject{contentType: contentType, metadata: metadata}}
+}
+
+type memWriter struct {
+	ctx context.Context
+	s   memStore
+	key string
+	o   *memObject
+	buf bytes.Buffer
+}
+
+func (w *memWriter) Write(p []byte) (int, error) { return w.buf.Write(p) }
+
+func (w *memWriter) Close() error {
+	if err := w.ctx.Err(); err != nil {
+		return err
+	}
+	w.o.data = w.buf.Bytes()
+	w.s[w.key] = w.o
+	return nil
+}
+
+func (s memStore) Copy(ctx context.Context, bucket, name, dstBucket string, metadata map[string]string) error {
+	o, ok := s[bucket+"/"+name]
+	if !ok {
+		return fmt.Errorf("%s/%s not found", bucket, name)
+	}
+	c := *o
+	c.metadata = maps.Clone(o.metadata)
+	if c.metadata == nil {
+		c.metadata = map[string]string{}
+	}
+	maps.Copy(c.metadata, metadata)
+	s[dstBucket+"/"+name] = &c
+	return nil
+}
+
+func (s memStore) Delete(ctx context.Context, bucket, name string) error {
+	delete(s, bucket+"/"+name)
+	return nil
+}
+
+func (s memStore) SetMetadata(ctx context.Context, bucket, name string, metadata map[string]string) error {
+	o, ok := s[bucket+"/"+name]
+	if !ok {
+		return fmt.Errorf("%s/%s not found", bucket, name)
+	}
+	if o.metadata == nil {
+		o.metadata = map[string]string{}
+	}
+	maps.Copy(o.metadata, metadata)
+	return nil
+}
+
+func TestParseRules(t *testing.T) {
+	got, err := ParseRules("adult>=LIKELY:blur,tag; violence>=possible:quarantine")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Rule{
+		{Category: "adult", Threshold: visionpb.Likelihood_LIKELY, Actions: []Action{Blur, Tag}},
+		{Category: "violence", Threshold: visionpb.Likelihood_POSSIBLE, Actions: []Action{Quarantine}},
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("ParseRules got %v, want %v", got, want)
+	}
+
+	for _, s := range []string{
+		"adult:blur",
+		"gore>=LIKELY:blur",
+		"adult>=SOMEWHAT:blur",
+		"adult>=UNKNOWN:blur",
+		"adult>=LIKELY:delete",
+	} {
+		if _, err := ParseRules(s); err == nil {
+			t.Errorf("ParseRules(%q) succeeded, want error", s)
+		}
+	}
+}
+
+func TestModerate(t *testing.T) {
+	zombie, err := os.ReadFile("testdata/zombie.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	const (
+		uploads    = "uploads"
+		blurred    = "blurred"
+		quarantine = "quarantine"
+	)
+	tests := []struct {
+		name       string
+		annotation *visionpb.SafeSearchAnnotation
+		rules      string
+		wantAction []Action
+		// wantObjects are the objects in the store afterwards, with the
+		// metadata they must have.
+		wantObjects map[string]map[string]string
+	}{
+		{
+			name:        "OK",
+			annotation:  &visionpb.SafeSearchAnnotation{Adult: visionpb.Likelihood_LIKELY},
+			wantObjects: map[string]map[string]string{"uploads/zombie.jpg": nil},
+		},
+		{
+			name:       "default rules",
+			annotation: &visionpb.SafeSearchAnnotation{Violence: visionpb.Likelihood_VERY_LIKELY},
+			wantAction: []Action{Blur},
+			wantObjects: map[string]map[string]string{
+				"uploads/zombie.jpg": nil,
+				"blurred/zombie.jpg": nil,
+			},
+		},
+		{
+			name:       "pixelate and tag",
+			annotation: &visionpb.SafeSearchAnnotation{Racy: visionpb.Likelihood_POSSIBLE, Violence: visionpb.Likelihood_LIKELY},
+			rules:      "racy>=POSSIBLE:blur,tag;violence>=LIKELY:pixelate",
+			wantAction: []Action{Blur, Tag, Pixelate},
+			wantObjects: map[string]map[string]string{
+				"uploads/zombie.jpg": {"moderation-categories": "racy,violence", "safesearch-violence": "LIKELY"},
+				"blurred/zombie.jpg": {"moderation-categories": "racy,violence", "safesearch-racy": "POSSIBLE"},
+			},
+		},
+		{
+			name:       "quarantine",
+			annotation: &visionpb.SafeSearchAnnotation{Adult: visionpb.Likelihood_VERY_LIKELY},
+			rules:      "adult>=LIKELY:quarantine,tag",
+			wantAction: []Action{Quarantine, Tag},
+			wantObjects: map[string]map[string]string{
+				"quarantine/zombie.jpg": {"moderation-categories": "adult", "safesearch-adult": "VERY_LIKELY"},
+			},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			rules := DefaultRules
+			if tc.rules != "" {
+				var err error
+				if rules, err = ParseRules(tc.rules); err != nil {
+					t.Fatal(err)
+				}
+			}
+			store := memStore{"uploads/zombie.jpg": {data: zombie, contentType: "image/jpeg"}}
+			m := &Moderator{
+				Detector:         fakeDetector{"gs://uploads/zombie.jpg": tc.annotation},
+				Store:            store,
+				Transformer:      GoTransformer{},
+				Rules:            rules,
+				OutputBucket:     blurred,
+				QuarantineBucket: quarantine,
+			}
+			d, err := m.Moderate(context.Background(), uploads, "zombie.jpg")
+			if err != nil {
+				t.Fatalf("Moderate: %v", err)
+			}
+			if !slices.Equal(d.Actions, tc.wantAction) {
+				t.Errorf("got actions %v, want %v", d.Actions, tc.wantAction)
+			}
+			if got := slices.Sorted(maps.Keys(store)); !slices.Equal(got, slices.Sorted(maps.Keys(tc.wantObjects))) {
+				t.Fatalf("got objects %v, want %v", got, slices.Sorted(maps.Keys(tc.wantObjects)))
+			}
+			for key, want := range tc.wantObjects {
+				o := store[key]
+				for k, v := range want {
+					if o.metadata[k] != v {
+						t.Errorf("%s: metadata %s = %q, want %q", key, k, o.metadata[k], v)
+					}
+				}
+				if o.contentType != "image/jpeg" {
+					t.Errorf("%s: content type %q, want image/jpeg", key, o.contentType)
+				}
+				if _, err := jpeg.Decode(bytes.NewReader(o.data)); err != nil {
+					t.Errorf("%s is not a JPEG image: %v", key, err)
+				}
+				if key == "blurred/zombie.jpg" && bytes.Equal(o.data, zombie) {
+					t.Errorf("%s was not transformed", key)
+				}
+			}
+		})
+	}
+}
+
+func TestModerateTransformError(t *testing.T) {
+	store := memStore{"uploads/notes.txt": {data: []byte("not an image"), contentType: "text/plain"}}
+	m := &Moderator{
+		Detector:     fakeDetector{"gs://uploads/notes.txt": {Adult: visionpb.Likelihood_VERY_LIKELY}},
+		Store:        store,
+		Transformer:  GoTransformer{},
+		Rules:        DefaultRules,
+		OutputBucket: "blurred",
+	}
+	if _, err := m.Moderate(context.Background(), "uploads", "notes.txt"); err == nil {
+		t.Fatal("Moderate succeeded, want error")
+	}
+	if _, ok := store["blurred/notes.txt"]; ok {
+		t.Error("a partial output was written")
+	}
+}
+
+func TestModeratorFromEnv(t *testing.T) {
+	tests := []struct {
+		env     map[string]string
+		wantErr bool
+	}{
+		{env: map[string]string{"BLURRED_BUCKET_NAME": "blurred"}},
+		{env: map[string]string{}, wantErr: true},
+		{env: map[string]string{"MODERATION_RULES": "racy>=LIKELY:tag"}},
+		{env: map[string]string{"MODERATION_RULES": "racy>=LIKELY:quarantine"}, wantErr: true},
+		{env: map[string]string{"MODERATION_RULES": "racy>=LIKELY:quarantine", "QUARANTINE_BUCKET_NAME": "q"}},
+		{env: map[string]string{"BLURRED_BUCKET_NAME": "blurred", "IMAGE_TRANSFORMER": "gimp"}, wantErr: true},
+	}
+	for _, tc := range tests {
+		_, err := moderatorFromEnv(func(k string) string { return tc.env[k] })
+		if (err != nil) != tc.wantErr {
+			t.Errorf("moderatorFromEnv(%v) got error %v, want error: %t", tc.env, err, tc.wantErr)
+		}
+	}
+}
@@ -0,0 +1,174 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package imagemagick
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"os/exec"
+)
+
+// Transformer blurs or pixelates images.
+type Transformer interface {
+	Transform(ctx context.Context, op Action, r io.Reader, w io.Writer) error
+}
+
+// newTransformer returns the transformer named by name: "imagemagick",
+// "go", or "" to use ImageMagick if the convert binary is installed and
+// Go otherwise.
+func newTransformer(name string) (Transformer, error) {
+	switch name {
+	case "imagemagick":
+		return ImageMagick{}, nil
+	case "go":
+		return GoTransformer{}, nil
+	case "":
+		if _, err := exec.LookPath("convert"); err == nil {
+			return ImageMagick{}, nil
+		}
+		return GoTransformer{}, nil
+	}
+	return nil, fmt.Errorf("unknown transformer %q, want imagemagick or go", name)
+}
+
+// [START functions_imagemagick_blur]
+
+// ImageMagick transforms images with the ImageMagick convert command.
+type ImageMagick struct{}
+
+// Transform runs convert on the image read from r, and writes the result
+// to w.
+func (ImageMagick) Transform(ctx context.Context, op Action, r io.Reader, w io.Writer) error {
+	var args []string
+	switch op {
+	case Blur:
+		args = []string{"-blur", "0x8"}
+	case Pixelate:
+		args = []string{"-scale", "5%", "-scale", "2000%"}
+	default:
+		return fmt.Errorf("unsupported operation %q", op)
+	}
+	// Use - as input and output to use stdin and stdout.
+	cmd := exec.CommandContext(ctx, "convert", append(append([]string{"-"}, args...), "-")...)
+	cmd.Stdin = r
+	cmd.Stdout = w
+
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("cmd.Run: %w", err)
+	}
+	return nil
+}
+
+// [END functions_imagemagick_blur]
+
+// GoTransformer transforms JPEG, PNG and GIF images in Go, without
+// external tools. The output has the format of the input.
+type GoTransformer struct{}
+
+// Transform decodes the image read from r, transforms it and encodes it
+// to w.
+func (GoTransformer) Transform(ctx context.Context, op Action, r io.Reader, w io.Writer) error {
+	src, format, err := image.Decode(r)
+	if err != nil {
+		return fmt.Errorf("image.Decode: %w", err)
+	}
+	img := image.NewRGBA(src.Bounds())
+	draw.Draw(img, img.Bounds(), src, src.Bounds().Min, draw.Src)
+	switch op {
+	case Blur:
+		blurRGBA(img, 8)
+	case Pixelate:
+		b := img.Bounds()
+		pixelateRGBA(img, max(8, max(b.Dx(), b.Dy())/20))
+	default:
+		return fmt.Errorf("unsupported operation %q", op)
+	}
+	switch format {
+	case "jpeg":
+		return jpeg.Encode(w, img, &jpeg.Options{Quality: 90})
+	case "gif":
+		return gif.Encode(w, img, nil)
+	default:
+		return png.Encode(w, img)
+	}
+}
+
+// blurRGBA blurs img in place with three passes of a box blur of the given
+// radius, which approximates a Gaussian blur.
+func blurRGBA(img *image.RGBA, radius int) {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	tmp := make([]uint8, len(img.Pix))
+	for pass := 0; pass < 3; pass++ {
+		// Horizontal pass from img to tmp, then vertical pass back.
+		for y := 0; y < h; y++ {
+			boxBlur(img.Pix[y*img.Stride:], tmp[y*img.Stride:], w, 4, radius)
+		}
+		for x := 0; x < w; x++ {
+			boxBlur(tmp[x*4:], img.Pix[x*4:], h, img.Stride, radius)
+		}
+	}
+}
+
+// boxBlur blurs n pixels of 4 channels from src to dst, the pixels being
+// stride bytes apart. Pixels past the edges repeat the edge pixels.
+func boxBlur(src, dst []uint8, n, stride, radius int) {
+	at := func(i int) int { return min(max(i, 0), n-1) * stride }
+	for c := 0; c < 4; c++ {
+		sum := 0
+		for i := -radius; i <= radius; i++ {
+			sum += int(src[at(i)+c])
+		}
+		for i := 0; i < n; i++ {
+			dst[i*stride+c] = uint8(sum / (2*radius + 1))
+			sum += int(src[at(i+radius+1)+c]) - int(src[at(i-radius)+c])
+		}
+	}
+}
+
+// pixelateRGBA replaces each size by size block of img by its average
+// color.
+func pixelateRGBA(img *image.RGBA, size int) {
+	b := img.Bounds()
+	for by := b.Min.Y; by < b.Max.Y; by += size {
+		for bx := b.Min.X; bx < b.Max.X; bx += size {
+			block := image.Rect(bx, by, bx+size, by+size).Intersect(b)
+			var sum [4]int
+			for y := block.Min.Y; y < block.Max.Y; y++ {
+				for x := block.Min.X; x < block.Max.X; x++ {
+					p := img.PixOffset(x, y)
+					for c := 0; c < 4; c++ {
+						sum[c] += int(img.Pix[p+c])
+					}
+				}
+			}
+			n := block.Dx() * block.Dy()
+			for y := block.Min.Y; y < block.Max.Y; y++ {
+				for x := block.Min.X; x < block.Max.X; x++ {
+					p := img.PixOffset(x, y)
+					for c := 0; c < 4; c++ {
+						img.Pix[p+c] = uint8(sum[c] / n)
+					}
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,115 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package imagemagick
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"image/png"
+	"os/exec"
+	"testing"
+)
+
+// checkerboard returns a PNG image of black and white squares.
+func checkerboard(t *testing.T, size, square int) []byte {
+	t.Helper()
+	img := image.NewGray(image.Rect(0, 0, size, size))
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			if (x/square+y/square)%2 == 0 {
+				img.SetGray(x, y, color.Gray{Y: 255})
+			}
+		}
+	}
+	var b bytes.Buffer
+	if err := png.Encode(&b, img); err != nil {
+		t.Fatal(err)
+	}
+	return b.Bytes()
+}
+
+func transformPNG(t *testing.T, tr Transformer, op Action, in []byte) image.Image {
+	t.Helper()
+	var out bytes.Buffer
+	if err := tr.Transform(context.Background(), op, bytes.NewReader(in), &out); err != nil {
+		t.Fatalf("Transform(%s): %v", op, err)
+	}
+	img, format, err := image.Decode(&out)
+	if err != nil {
+		t.Fatalf("Transform(%s) output: %v", op, err)
+	}
+	if format != "png" {
+		t.Errorf("Transform(%s) output format %s, want png", op, format)
+	}
+	return img
+}
+
+func gray(img image.Image, x, y int) uint8 {
+	return color.GrayModel.Convert(img.At(x, y)).(color.Gray).Y
+}
+
+func TestGoTransformerBlur(t *testing.T) {
+	img := transformPNG(t, GoTransformer{}, Blur, checkerboard(t, 64, 4))
+	if got := img.Bounds(); got != image.Rect(0, 0, 64, 64) {
+		t.Errorf("blurred image bounds %v, want 64x64", got)
+	}
+	// Blurring small squares gives an even gray.
+	for _, p := range []image.Point{{0, 0}, {10, 20}, {32, 32}, {63, 63}} {
+		if g := gray(img, p.X, p.Y); g < 96 || g > 160 {
+			t.Errorf("blurred pixel %v = %d, want about 128", p, g)
+		}
+	}
+}
+
+func TestGoTransformerPixelate(t *testing.T) {
+	// 160 pixels give blocks of 8 pixels, each covering two squares of 4.
+	img := transformPNG(t, GoTransformer{}, Pixelate, checkerboard(t, 160, 4))
+	for _, p := range []image.Point{{0, 0}, {7, 7}, {80, 80}} {
+		if g := gray(img, p.X, p.Y); g != 127 {
+			t.Errorf("pixelated pixel %v = %d, want 127", p, g)
+		}
+	}
+
+	// Blocks inside a square keep its color.
+	img = transformPNG(t, GoTransformer{}, Pixelate, checkerboard(t, 160, 16))
+	if g := gray(img, 0, 0); g != 255 {
+		t.Errorf("pixelated pixel (0,0) = %d, want 255", g)
+	}
+	if g := gray(img, 16, 0); g != 0 {
+		t.Errorf("pixelated pixel (16,0) = %d, want 0", g)
+	}
+}
+
+func TestGoTransformerErrors(t *testing.T) {
+	var out bytes.Buffer
+	if err := (GoTransformer{}).Transform(context.Background(), Blur, bytes.NewReader([]byte("text")), &out); err == nil {
+		t.Error("Transform of text succeeded, want error")
+	}
+	if err := (GoTransformer{}).Transform(context.Background(), Tag, bytes.NewReader(checkerboard(t, 8, 2)), &out); err == nil {
+		t.Error("Transform with tag succeeded, want error")
+	}
+}
+
+func TestImageMagick(t *testing.T) {
+	if _, err := exec.LookPath("convert"); err != nil {
+		t.Skip("ImageMagick is not installed")
+	}
+	img := transformPNG(t, ImageMagick{}, Blur, checkerboard(t, 64, 4))
+	if g := gray(img, 32, 32); g < 96 || g > 160 {
+		t.Errorf("blurred pixel (32,32) = %d, want about 128", g)
+	}
+}
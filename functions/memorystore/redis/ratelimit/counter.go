@@ -0,0 +1,161 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ratelimit
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// Counter counts events per key.
+type Counter struct {
+	Pool   *redis.Pool
+	Prefix string
+	// TTL, if set, expires a key after it was last incremented.
+	TTL time.Duration
+}
+
+// incrScript increments KEYS[1] and, if ARGV[1] is positive, sets it to
+// expire after ARGV[1] milliseconds.
+var incrScript = redis.NewScript(1, `
+local n = redis.call('INCR', KEYS[1])
+if tonumber(ARGV[1]) > 0 then
+  redis.call('PEXPIRE', KEYS[1], ARGV[1])
+end
+return n
+`)
+
+// Incr increments the count of key and returns it.
+func (c *Counter) Incr(key string) (int64, error) {
+	return redis.Int64(do(c.Pool, func(conn redis.Conn) (interface{}, error) {
+		return incrScript.Do(conn, c.Prefix+key, c.TTL.Milliseconds())
+	}))
+}
+
+// Get returns the count of key, 0 if it has no count.
+func (c *Counter) Get(key string) (int64, error) {
+	n, err := redis.Int64(do(c.Pool, func(conn redis.Conn) (interface{}, error) {
+		return conn.Do("GET", c.Prefix+key)
+	}))
+	if err == redis.ErrNil {
+		return 0, nil
+	}
+	return n, err
+}
+
+// Expiry returns how long until key expires, or 0 if it doesn't exist or
+// has no expiry.
+func (c *Counter) Expiry(key string) (time.Duration, error) {
+	ms, err := redis.Int64(do(c.Pool, func(conn redis.Conn) (interface{}, error) {
+		return conn.Do("PTTL", c.Prefix+key)
+	}))
+	if err != nil || ms < 0 {
+		return 0, err
+	}
+	return time.Duration(ms) * time.Millisecond, nil
+}
+
+// Reset deletes the count of key.
+func (c *Counter) Reset(key string) error {
+	_, err := do(c.Pool, func(conn redis.Conn) (interface{}, error) {
+		return conn.Do("DEL", c.Prefix+key)
+	})
+	return err
+}
+
+// FixedWindow allows Limit requests per key in each window of Window,
+// windows starting at multiples of Window since the Unix epoch. It uses a
+// counter per key and window, which expires with the window.
+type FixedWindow struct {
+	Pool   *redis.Pool
+	Prefix string
+	Limit  int64
+	Window time.Duration
+	// Now returns the current time. It defaults to time.Now.
+	Now func() time.Time
+}
+
+// Allow counts a request for key.
+func (f *FixedWindow) Allow(key string) (Result, error) {
+	now := clock(f.Now)
+	start := now.Truncate(f.Window)
+	end := start.Add(f.Window)
+	k := fmt.Sprintf("%s%s:%d", f.Prefix, key, start.UnixMilli())
+	n, err := redis.Int64(do(f.Pool, func(conn redis.Conn) (interface{}, error) {
+		return incrScript.Do(conn, k, end.Sub(now).Milliseconds()+1)
+	}))
+	if err != nil {
+		return Result{}, fmt.Errorf("fixed window %q: %w", key, err)
+	}
+	res := Result{Allowed: n <= f.Limit, Limit: f.Limit, Remaining: max(f.Limit-n, 0)}
+	if !res.Allowed {
+		res.RetryAfter = end.Sub(now)
+	}
+	return res, nil
+}
+
+// slidingScript counts the requests of the last window in the sorted set
+// KEYS[1], scored by time in milliseconds. ARGV holds the time, the window
+// in milliseconds, the limit and a unique member for the request. It
+// returns whether the request is allowed, the number of requests in the
+// window, and for denied requests the milliseconds until the oldest
+// request leaves the window.
+var slidingScript = redis.NewScript(1, `
+local now = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local limit = tonumber(ARGV[3])
+redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', now - window)
+local n = redis.call('ZCARD', KEYS[1])
+if n < limit then
+  redis.call('ZADD', KEYS[1], now, ARGV[4])
+  redis.call('PEXPIRE', KEYS[1], window)
+  return {1, n + 1, 0}
+end
+local oldest = redis.call('ZRANGE', KEYS[1], 0, 0, 'WITHSCORES')
+return {0, n, tonumber(oldest[2]) + window - now}
+`)
+
+// SlidingWindow allows Limit requests per key in any period of Window. It
+// keeps the time of each allowed request in the window, so it is exact but
+// uses memory proportional to Limit.
+type SlidingWindow struct {
+	Pool   *redis.Pool
+	Prefix string
+	Limit  int64
+	Window time.Duration
+	// Now returns the current time. It defaults to time.Now.
+	Now func() time.Time
+}
+
+// Allow counts a request for key if it is allowed.
+func (s *SlidingWindow) Allow(key string) (Result, error) {
+	now := clock(s.Now).UnixMilli()
+	member := fmt.Sprintf("%d-%x", now, rand.Uint64())
+	v, err := toInt64s(do(s.Pool, func(conn redis.Conn) (interface{}, error) {
+		return slidingScript.Do(conn, s.Prefix+key, now, s.Window.Milliseconds(), s.Limit, member)
+	}))
+	if err != nil {
+		return Result{}, fmt.Errorf("sliding window %q: %w", key, err)
+	}
+	return Result{
+		Allowed:    v[0] == 1,
+		Limit:      s.Limit,
+		Remaining:  max(s.Limit-v[1], 0),
+		RetryAfter: time.Duration(v[2]) * time.Millisecond,
+	}, nil
+}
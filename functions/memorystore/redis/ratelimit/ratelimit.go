@@ -0,0 +1,112 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package ratelimit provides counters and rate limiters stored in Redis,
+// such as a Memorystore instance shared by many function instances.
+//
+// Every operation is a single command or Lua script, so it is atomic across
+// clients. Keys expire once they are no longer needed. Times come from the
+// client clock, which must be roughly in sync across clients.
+package ratelimit
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// Result is the outcome of a rate limited request.
+type Result struct {
+	Allowed bool
+	// Limit is the number of requests allowed in a window, or the capacity
+	// of a token bucket.
+	Limit int64
+	// Remaining is the number of requests still allowed right now.
+	Remaining int64
+	// RetryAfter is how long to wait before the request may be allowed,
+	// when it is not.
+	RetryAfter time.Duration
+}
+
+// Limiter decides whether requests identified by a key are allowed.
+type Limiter interface {
+	Allow(key string) (Result, error)
+}
+
+// clock returns the time, or time.Now if now is nil.
+func clock(now func() time.Time) time.Time {
+	if now == nil {
+		return time.Now()
+	}
+	return now()
+}
+
+// do runs a command on a connection from pool.
+func do(pool *redis.Pool, f func(redis.Conn) (interface{}, error)) (interface{}, error) {
+	conn := pool.Get()
+	defer conn.Close()
+	return f(conn)
+}
+
+// WriteHeaders sets the X-RateLimit-Limit and X-RateLimit-Remaining headers
+// of a response, and Retry-After if the request is not allowed.
+func WriteHeaders(w http.ResponseWriter, res Result) {
+	w.Header().Set("X-RateLimit-Limit", strconv.FormatInt(res.Limit, 10))
+	w.Header().Set("X-RateLimit-Remaining", strconv.FormatInt(res.Remaining, 10))
+	if !res.Allowed {
+		// Retry-After is in whole seconds; round up so that retrying
+		// right on time succeeds.
+		secs := int64(math.Ceil(res.RetryAfter.Seconds()))
+		w.Header().Set("Retry-After", strconv.FormatInt(max(secs, 1), 10))
+	}
+}
+
+// Middleware serves requests allowed by l with next, and answers the others
+// with 429 Too Many Requests. Requests are identified by key. If Redis
+// fails, requests are allowed: the limiter protects the service, and should
+// not take it down.
+func Middleware(l Limiter, key func(*http.Request) string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		res, err := l.Allow(key(r))
+		if err != nil {
+			log.Printf("ratelimit: %v", err)
+			next.ServeHTTP(w, r)
+			return
+		}
+		WriteHeaders(w, res)
+		if !res.Allowed {
+			http.Error(w, "Too Many Requests", http.StatusTooManyRequests)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// toInt64s converts the reply of a limiter script: whether the request is
+// allowed, a count and a delay.
+func toInt64s(reply interface{}, err error) ([]int64, error) {
+	values, err := redis.Int64s(reply, err)
+	if err != nil {
+		return nil, err
+	}
+	if len(values) != 3 {
+		return nil, fmt.Errorf("script returned %d values, want 3", len(values))
+	}
+	return values, nil
+}
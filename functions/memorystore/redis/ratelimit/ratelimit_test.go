@@ -0,0 +1,206 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ratelimit
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	miniredis "github.com/alicebob/miniredis/v2"
+	"github.com/gomodule/redigo/redis"
+)
+
+// fakeClock is a settable clock.
+type fakeClock struct {
+	t time.Time
+}
+
+func (c *fakeClock) Now() time.Time          { return c.t }
+func (c *fakeClock) Advance(d time.Duration) { c.t = c.t.Add(d) }
+
+func newRedis(t *testing.T) (*miniredis.Miniredis, *redis.Pool) {
+	t.Helper()
+	s := miniredis.RunT(t)
+	addr := s.Addr()
+	pool := &redis.Pool{
+		Dial: func() (redis.Conn, error) { return redis.Dial("tcp", addr) },
+	}
+	t.Cleanup(func() { pool.Close() })
+	return s, pool
+}
+
+// check calls l.Allow and checks the result.
+func check(t *testing.T, l Limiter, key string, allowed bool, remaining int64, retryAfter time.Duration) {
+	t.Helper()
+	res, err := l.Allow(key)
+	if err != nil {
+		t.Fatalf("Allow(%q): %v", key, err)
+	}
+	if res.Allowed != allowed || res.Remaining != remaining || res.RetryAfter != retryAfter {
+		t.Errorf("Allow(%q) = allowed %t, remaining %d, retry after %v; want %t, %d, %v",
+			key, res.Allowed, res.Remaining, res.RetryAfter, allowed, remaining, retryAfter)
+	}
+}
+
+func TestCounter(t *testing.T) {
+	s, pool := newRedis(t)
+	c := &Counter{Pool: pool, Prefix: "visits:", TTL: time.Hour}
+	for want := int64(1); want <= 3; want++ {
+		if got, err := c.Incr("home"); err != nil || got != want {
+			t.Fatalf("Incr = %d, %v, want %d", got, err, want)
+		}
+	}
+	if got, err := c.Expiry("home"); err != nil || got != time.Hour {
+		t.Errorf("Expiry = %v, %v, want 1h", got, err)
+	}
+	s.FastForward(time.Hour)
+	if got, err := c.Get("home"); err != nil || got != 0 {
+		t.Errorf("Get after the TTL = %d, %v, want 0", got, err)
+	}
+
+	c.Incr("home")
+	if err := c.Reset("home"); err != nil {
+		t.Fatal(err)
+	}
+	if s.Exists("visits:home") {
+		t.Error("Reset did not delete the key")
+	}
+
+	// Without TTL, keys don't expire.
+	c.TTL = 0
+	c.Incr("forever")
+	if got, err := c.Expiry("forever"); err != nil || got != 0 {
+		t.Errorf("Expiry without TTL = %v, %v, want 0", got, err)
+	}
+}
+
+func TestFixedWindow(t *testing.T) {
+	s, pool := newRedis(t)
+	clock := &fakeClock{time.Date(2026, 1, 1, 10, 0, 15, 0, time.UTC)}
+	l := &FixedWindow{Pool: pool, Prefix: "fw:", Limit: 3, Window: time.Minute, Now: clock.Now}
+
+	check(t, l, "a", true, 2, 0)
+	check(t, l, "a", true, 1, 0)
+	check(t, l, "b", true, 2, 0)
+	clock.Advance(30 * time.Second)
+	check(t, l, "a", true, 0, 0)
+	check(t, l, "a", false, 0, 15*time.Second)
+
+	key := fmt.Sprintf("fw:a:%d", time.Date(2026, 1, 1, 10, 0, 0, 0, time.UTC).UnixMilli())
+	if ttl := s.TTL(key); ttl <= 0 || ttl > 15*time.Second+time.Millisecond {
+		t.Errorf("TTL of %s = %v, want the rest of the window", key, ttl)
+	}
+
+	// The next window starts from zero.
+	clock.Advance(15 * time.Second)
+	check(t, l, "a", true, 2, 0)
+}
+
+func TestSlidingWindow(t *testing.T) {
+	s, pool := newRedis(t)
+	clock := &fakeClock{time.Date(2026, 1, 1, 10, 0, 0, 0, time.UTC)}
+	l := &SlidingWindow{Pool: pool, Prefix: "sw:", Limit: 3, Window: 10 * time.Second, Now: clock.Now}
+
+	for i := int64(0); i < 3; i++ {
+		check(t, l, "a", true, 2-i, 0)
+		clock.Advance(time.Second)
+	}
+	// The oldest request leaves the window 10s after it was made.
+	check(t, l, "a", false, 0, 7*time.Second)
+	clock.Advance(6 * time.Second)
+	check(t, l, "a", false, 0, time.Second)
+	clock.Advance(time.Second)
+	check(t, l, "a", true, 0, 0)
+	check(t, l, "a", false, 0, time.Second)
+
+	if ttl := s.TTL("sw:a"); ttl != 10*time.Second {
+		t.Errorf("TTL of sw:a = %v, want 10s", ttl)
+	}
+}
+
+func TestTokenBucket(t *testing.T) {
+	s, pool := newRedis(t)
+	clock := &fakeClock{time.Date(2026, 1, 1, 10, 0, 0, 0, time.UTC)}
+	l := &TokenBucket{Pool: pool, Prefix: "tb:", Capacity: 2, Rate: 1, Now: clock.Now}
+
+	check(t, l, "a", true, 1, 0)
+	check(t, l, "a", true, 0, 0)
+	check(t, l, "a", false, 0, time.Second)
+	clock.Advance(500 * time.Millisecond)
+	check(t, l, "a", false, 0, 500*time.Millisecond)
+	clock.Advance(500 * time.Millisecond)
+	check(t, l, "a", true, 0, 0)
+
+	// The bucket expires once it would be full.
+	if ttl := s.TTL("tb:a"); ttl != 2001*time.Millisecond {
+		t.Errorf("TTL of tb:a = %v, want 2.001s", ttl)
+	}
+	s.FastForward(3 * time.Second)
+	clock.Advance(3 * time.Second)
+	if s.Exists("tb:a") {
+		t.Error("full bucket did not expire")
+	}
+	check(t, l, "a", true, 1, 0)
+
+	// Refills don't exceed the capacity.
+	clock.Advance(time.Hour)
+	res, err := l.AllowN("a", 2)
+	if err != nil || !res.Allowed || res.Remaining != 0 {
+		t.Errorf("AllowN(2) after an hour = %+v, %v, want allowed with 0 remaining", res, err)
+	}
+	if _, err := l.AllowN("a", 3); err == nil {
+		t.Error("AllowN over the capacity succeeded, want error")
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	s, pool := newRedis(t)
+	clock := &fakeClock{time.Date(2026, 1, 1, 10, 0, 0, 0, time.UTC)}
+	l := &TokenBucket{Pool: pool, Capacity: 1, Rate: 0.1, Now: clock.Now}
+	h := Middleware(l, func(r *http.Request) string { return r.Header.Get("X-Client") },
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	serve := func(client string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.Header.Set("X-Client", client)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+	if w := serve("a"); w.Code != http.StatusOK || w.Header().Get("X-RateLimit-Remaining") != "0" {
+		t.Errorf("first request: status %d, remaining %q, want 200, 0", w.Code, w.Header().Get("X-RateLimit-Remaining"))
+	}
+	clock.Advance(500 * time.Millisecond)
+	w := serve("a")
+	if w.Code != http.StatusTooManyRequests {
+		t.Errorf("second request: status %d, want 429", w.Code)
+	}
+	// 9.5s are rounded up.
+	if got := w.Header().Get("Retry-After"); got != "10" {
+		t.Errorf("Retry-After = %q, want 10", got)
+	}
+	if w := serve("b"); w.Code != http.StatusOK {
+		t.Errorf("other client: status %d, want 200", w.Code)
+	}
+
+	// Requests are allowed when Redis is down.
+	s.Close()
+	if w := serve("a"); w.Code != http.StatusOK {
+		t.Errorf("without Redis: status %d, want 200", w.Code)
+	}
+}
@@ -0,0 +1,93 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ratelimit
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// bucketScript takes ARGV[4] tokens from the bucket in the hash KEYS[1],
+// which holds the number of tokens and the time they were counted. ARGV
+// holds the time in milliseconds, the capacity and the refill rate in
+// tokens per millisecond. A missing bucket is full, and a bucket expires
+// once it would be full again. It returns whether the tokens were taken,
+// the whole tokens left, and otherwise the milliseconds until there are
+// enough tokens.
+var bucketScript = redis.NewScript(1, `
+local now = tonumber(ARGV[1])
+local capacity = tonumber(ARGV[2])
+local rate = tonumber(ARGV[3])
+local cost = tonumber(ARGV[4])
+local b = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(b[1]) or capacity
+local ts = tonumber(b[2]) or now
+tokens = math.min(capacity, tokens + math.max(0, now - ts) * rate)
+local allowed = 0
+local wait = 0
+if tokens >= cost then
+  tokens = tokens - cost
+  allowed = 1
+else
+  wait = math.ceil((cost - tokens) / rate)
+end
+redis.call('HMSET', KEYS[1], 'tokens', tostring(tokens), 'ts', now)
+redis.call('PEXPIRE', KEYS[1], math.ceil((capacity - tokens) / rate) + 1)
+return {allowed, math.floor(tokens), wait}
+`)
+
+// TokenBucket allows bursts of up to Capacity requests per key, refilled at
+// Rate requests per second.
+type TokenBucket struct {
+	Pool     *redis.Pool
+	Prefix   string
+	Capacity int64
+	Rate     float64
+	// Now returns the current time. It defaults to time.Now.
+	Now func() time.Time
+}
+
+// Allow takes a token for a request for key.
+func (b *TokenBucket) Allow(key string) (Result, error) {
+	return b.AllowN(key, 1)
+}
+
+// AllowN takes n tokens for key, if there are enough.
+func (b *TokenBucket) AllowN(key string, n int64) (Result, error) {
+	if n > b.Capacity {
+		return Result{}, fmt.Errorf("token bucket %q: %d tokens exceed the capacity of %d", key, n, b.Capacity)
+	}
+	if b.Rate <= 0 || math.IsInf(b.Rate, 0) {
+		return Result{}, fmt.Errorf("token bucket %q: invalid rate %v", key, b.Rate)
+	}
+	now := clock(b.Now).UnixMilli()
+	// Scripts get their arguments as strings; %g keeps small rates exact.
+	rate := fmt.Sprintf("%g", b.Rate/1000)
+	v, err := toInt64s(do(b.Pool, func(conn redis.Conn) (interface{}, error) {
+		return bucketScript.Do(conn, b.Prefix+key, now, b.Capacity, rate, n)
+	}))
+	if err != nil {
+		return Result{}, fmt.Errorf("token bucket %q: %w", key, err)
+	}
+	return Result{
+		Allowed:    v[0] == 1,
+		Limit:      b.Capacity,
+		Remaining:  v[1],
+		RetryAfter: time.Duration(v[2]) * time.Millisecond,
+	}, nil
+}
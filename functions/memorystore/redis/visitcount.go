@@ -22,14 +22,26 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
 	"os"
+	"strconv"
+	"strings"
+	"sync"
 
 	"github.com/GoogleCloudPlatform/functions-framework-go/functions"
+	"github.com/GoogleCloudPlatform/golang-samples/functions/memorystore/redis/ratelimit"
 	"github.com/gomodule/redigo/redis"
 )
 
-var redisPool *redis.Pool
+// redisClients holds what the function uses on its Redis instance.
+type redisClients struct {
+	limiter ratelimit.Limiter
+	visits  *ratelimit.Counter
+}
+
+// clients is created on first use, and shared across function invocations.
+var clients = sync.OnceValues(initializeClients)
 
 func init() {
 	// Register the HTTP handler with the Functions Framework
@@ -61,31 +73,84 @@ func initializeRedis() (*redis.Pool, error) {
 	}, nil
 }
 
-// visitCount increments the visit count on the Redis instance
-// and prints the current count in the HTTP response.
-func visitCount(w http.ResponseWriter, r *http.Request) {
-	// Initialize connection pool on first invocation
-	if redisPool == nil {
-		// Pre-declare err to avoid shadowing redisPool
-		var err error
-		redisPool, err = initializeRedis()
-		if err != nil {
-			log.Printf("initializeRedis: %v", err)
-			http.Error(w, "Error initializing connection pool", http.StatusInternalServerError)
-			return
+// initializeClients connects to Redis and returns the rate limiter and the
+// visit counter.
+func initializeClients() (*redisClients, error) {
+	pool, err := initializeRedis()
+	if err != nil {
+		return nil, fmt.Errorf("initializeRedis: %w", err)
+	}
+	limiter, err := initializeLimiter(pool)
+	if err != nil {
+		return nil, fmt.Errorf("initializeLimiter: %w", err)
+	}
+	return &redisClients{limiter: limiter, visits: &ratelimit.Counter{Pool: pool}}, nil
+}
+
+// initializeLimiter returns a limiter allowing each client RATE_LIMIT
+// requests per minute, 60 by default, in bursts of up to as many.
+func initializeLimiter(pool *redis.Pool) (ratelimit.Limiter, error) {
+	perMinute := int64(60)
+	if s := os.Getenv("RATE_LIMIT"); s != "" {
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("RATE_LIMIT must be a positive integer, got %q", s)
 		}
+		perMinute = n
 	}
+	return &ratelimit.TokenBucket{
+		Pool:     pool,
+		Prefix:   "ratelimit:",
+		Capacity: perMinute,
+		Rate:     float64(perMinute) / 60,
+	}, nil
+}
 
-	conn := redisPool.Get()
-	defer conn.Close()
+// clientIP returns the address of the client of r. Behind the Cloud
+// Functions front end, it is the last address of X-Forwarded-For, which the
+// front end appends: the previous ones are sent by the client, and can't be
+// trusted.
+func clientIP(r *http.Request) string {
+	if fwd := r.Header.Values("X-Forwarded-For"); len(fwd) > 0 {
+		last := fwd[len(fwd)-1]
+		if i := strings.LastIndex(last, ","); i >= 0 {
+			last = last[i+1:]
+		}
+		if ip := strings.TrimSpace(last); ip != "" {
+			return ip
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
 
-	counter, err := redis.Int(conn.Do("INCR", "visits"))
+// visitCount increments the visit count on the Redis instance
+// and prints the current count in the HTTP response. Clients
+// making too many requests get 429 Too Many Requests.
+func visitCount(w http.ResponseWriter, r *http.Request) {
+	c, err := clients()
 	if err != nil {
-		log.Printf("redis.Int: %v", err)
-		http.Error(w, "Error incrementing visit count", http.StatusInternalServerError)
+		log.Print(err)
+		http.Error(w, "Error initializing Redis clients", http.StatusInternalServerError)
 		return
 	}
-	fmt.Fprintf(w, "Visit count: %d", counter)
+	ratelimit.Middleware(c.limiter, clientIP, countVisit(c.visits)).ServeHTTP(w, r)
+}
+
+// countVisit returns a handler that increments and prints the visit count.
+func countVisit(visits *ratelimit.Counter) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		counter, err := visits.Incr("visits")
+		if err != nil {
+			log.Printf("visits.Incr: %v", err)
+			http.Error(w, "Error incrementing visit count", http.StatusInternalServerError)
+			return
+		}
+		fmt.Fprintf(w, "Visit count: %d", counter)
+	})
 }
 
 // [END functions_memorystore_redis]
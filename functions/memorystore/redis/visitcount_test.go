@@ -15,10 +15,12 @@
 package visitcount
 
 import (
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"strings"
+	"sync"
 	"testing"
 
 	miniredis "github.com/alicebob/miniredis/v2"
@@ -43,3 +45,61 @@ func TestVisitCount(t *testing.T) {
 		t.Errorf("VisitCount got status %v, want %v", rr.Code, http.StatusOK)
 	}
 }
+
+func TestVisitCountRateLimit(t *testing.T) {
+	s, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("miniredis.Run: %v", err)
+	}
+	defer s.Close()
+
+	os.Setenv("REDISHOST", s.Host())
+	os.Setenv("REDISPORT", s.Port())
+	os.Setenv("RATE_LIMIT", "2")
+	defer os.Unsetenv("RATE_LIMIT")
+	clients = sync.OnceValues(initializeClients) // Initialize with the new settings.
+
+	for i, want := range []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests} {
+		req := httptest.NewRequest("GET", "/", strings.NewReader(""))
+		// Changing the addresses sent by the client doesn't evade the limit.
+		req.Header.Set("X-Forwarded-For", fmt.Sprintf("198.51.100.%d, 203.0.113.7", i))
+		rr := httptest.NewRecorder()
+		visitCount(rr, req)
+		if rr.Code != want {
+			t.Errorf("request %d: got status %v, want %v", i+1, rr.Code, want)
+		}
+		if want == http.StatusTooManyRequests && rr.Header().Get("Retry-After") != "30" {
+			t.Errorf("request %d: got Retry-After %q, want 30", i+1, rr.Header().Get("Retry-After"))
+		}
+	}
+
+	// Other clients have their own limit.
+	req := httptest.NewRequest("GET", "/", strings.NewReader(""))
+	rr := httptest.NewRecorder()
+	visitCount(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Errorf("other client: got status %v, want %v", rr.Code, http.StatusOK)
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	tests := []struct {
+		forwarded []string
+		want      string
+	}{
+		{nil, "192.0.2.1"},
+		{[]string{"203.0.113.7"}, "203.0.113.7"},
+		{[]string{"198.51.100.1, 203.0.113.7"}, "203.0.113.7"},
+		{[]string{"198.51.100.1", "203.0.113.7"}, "203.0.113.7"},
+		{[]string{""}, "192.0.2.1"},
+	}
+	for _, tc := range tests {
+		req := httptest.NewRequest("GET", "/", nil)
+		for _, f := range tc.forwarded {
+			req.Header.Add("X-Forwarded-For", f)
+		}
+		if got := clientIP(req); got != tc.want {
+			t.Errorf("clientIP(X-Forwarded-For %q) = %q, want %q", tc.forwarded, got, tc.want)
+		}
+	}
+}
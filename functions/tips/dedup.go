@@ -0,0 +1,119 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tips
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// DedupStore records the IDs of processed events.
+//
+// Events are recorded after they are processed, so two deliveries of an
+// event running at the same time may both be processed: deduplication
+// makes duplicates rare, and processing should still be idempotent.
+type DedupStore interface {
+	// Done reports whether the event was processed.
+	Done(ctx context.Context, eventID string) (bool, error)
+	// MarkDone records that the event was processed.
+	MarkDone(ctx context.Context, eventID string) error
+}
+
+// MemoryStore is a DedupStore local to a function instance. It only catches
+// duplicates delivered to the same instance, which makes it best suited to
+// tests and low traffic functions.
+type MemoryStore struct {
+	// TTL is how long events are remembered.
+	TTL time.Duration
+	// Now returns the current time. It defaults to time.Now.
+	Now func() time.Time
+
+	mu     sync.Mutex
+	expiry map[string]time.Time
+}
+
+func (s *MemoryStore) now() time.Time {
+	if s.Now == nil {
+		return time.Now()
+	}
+	return s.Now()
+}
+
+// Done reports whether the event was processed within the TTL.
+func (s *MemoryStore) Done(ctx context.Context, eventID string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	exp, ok := s.expiry[eventID]
+	return ok && s.now().Before(exp), nil
+}
+
+// MarkDone records the event, and forgets expired ones.
+func (s *MemoryStore) MarkDone(ctx context.Context, eventID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	if s.expiry == nil {
+		s.expiry = map[string]time.Time{}
+	}
+	for id, exp := range s.expiry {
+		if !now.Before(exp) {
+			delete(s.expiry, id)
+		}
+	}
+	s.expiry[eventID] = now.Add(s.TTL)
+	return nil
+}
+
+// FirestoreStore is a DedupStore shared by all function instances, keeping
+// a document per event. Configure a TTL policy on the expireAt field of the
+// collection so that Firestore deletes old documents.
+type FirestoreStore struct {
+	Client     *firestore.Client
+	Collection string
+	// TTL is how long events are remembered.
+	TTL time.Duration
+}
+
+// Done reports whether the event has a document that hasn't expired.
+func (s *FirestoreStore) Done(ctx context.Context, eventID string) (bool, error) {
+	snap, err := s.Client.Collection(s.Collection).Doc(eventID).Get(ctx)
+	if status.Code(err) == codes.NotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	// TTL deletion can take a while after expiry.
+	exp, err := snap.DataAt("expireAt")
+	if t, ok := exp.(time.Time); err == nil && ok && time.Now().After(t) {
+		return false, nil
+	}
+	return true, nil
+}
+
+// MarkDone writes the document of the event.
+func (s *FirestoreStore) MarkDone(ctx context.Context, eventID string) error {
+	now := time.Now()
+	_, err := s.Client.Collection(s.Collection).Doc(eventID).Set(ctx, map[string]interface{}{
+		"processedAt": now,
+		"expireAt":    now.Add(s.TTL),
+	})
+	return err
+}
@@ -1,11 +1,16 @@
 module github.com/GoogleCloudPlatform/golang-samples/functions/tips
 
-require cloud.google.com/go/storage v1.50.0
+require (
+	cloud.google.com/go/firestore v1.18.0
+	cloud.google.com/go/functions v1.19.3
+	cloud.google.com/go/storage v1.50.0
+)
 
 require (
 	cel.dev/expr v0.19.1 // indirect
 	cloud.google.com/go/auth v0.14.0 // indirect
 	cloud.google.com/go/auth/oauth2adapt v0.2.7 // indirect
+	cloud.google.com/go/longrunning v0.6.4 // indirect
 	cloud.google.com/go/monitoring v1.23.0 // indirect
 	github.com/GoogleCloudPlatform/opentelemetry-operations-go/detectors/gcp v1.25.0 // indirect
 	github.com/GoogleCloudPlatform/opentelemetry-operations-go/exporter/metric v0.49.0 // indirect
@@ -56,7 +61,7 @@ require (
 	golang.org/x/text v0.21.0 // indirect
 	google.golang.org/api v0.217.0 // indirect
 	google.golang.org/genproto v0.0.0-20250115164207-1a7da9e5054f // indirect
-	google.golang.org/grpc v1.69.4 // indirect
+	google.golang.org/grpc v1.69.4
 	google.golang.org/protobuf v1.36.3 // indirect
 )
 
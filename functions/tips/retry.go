@@ -59,10 +59,12 @@ func RetryPubSub(ctx context.Context, m PubSubMessage) error {
 
 var misconfigured = true
 
+var errAccessDenied = errors.New("access denied to data service")
+
 // MisconfiguredDataClient simulates failure to retrieve a data storage client.
 func MisconfiguredDataClient() (interface{}, error) {
 	if misconfigured {
-		return nil, errors.New("access denied to data service")
+		return nil, errAccessDenied
 	}
 	return nil, nil
 }
@@ -0,0 +1,155 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tips
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"time"
+
+	"cloud.google.com/go/functions/metadata"
+)
+
+// Decisions logged by a RetryPolicy, one per invocation.
+const (
+	DecisionSuccess   = "success"
+	DecisionRetry     = "retry"
+	DecisionPermanent = "permanent"
+	DecisionExpired   = "expired"
+	DecisionDuplicate = "duplicate"
+)
+
+// RetryPolicy decides what happens to events whose processing fails, for
+// background functions deployed with retries enabled. Returning an error
+// from such a function makes the event be delivered again, so the policy
+// only returns errors worth retrying, for events young enough to retry.
+type RetryPolicy struct {
+	// MaxAge is the age after which events are dropped without being
+	// processed. Zero means no limit.
+	MaxAge time.Duration
+	// Retryable reports whether an error is worth retrying. By default,
+	// all errors are retried.
+	Retryable func(error) bool
+	// Store, if set, records processed events so that duplicate
+	// deliveries are skipped.
+	Store DedupStore
+	// Logger receives a structured log entry per decision. By default,
+	// entries are written to stdout as JSON, as Cloud Logging expects.
+	Logger *slog.Logger
+	// Now returns the current time. It defaults to time.Now.
+	Now func() time.Time
+}
+
+// defaultLogger writes entries to stdout, where Cloud Logging picks them up.
+var defaultLogger = newJSONLogger(os.Stdout)
+
+// newJSONLogger returns a logger writing JSON entries with the severity and
+// message fields of Cloud Logging to w.
+func newJSONLogger(w io.Writer) *slog.Logger {
+	return slog.New(slog.NewJSONHandler(w, &slog.HandlerOptions{
+		Level: slog.LevelDebug,
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if len(groups) > 0 {
+				return a
+			}
+			switch a.Key {
+			case slog.LevelKey:
+				return slog.String("severity", severity(a.Value.Any().(slog.Level)))
+			case slog.MessageKey:
+				a.Key = "message"
+			}
+			return a
+		},
+	}))
+}
+
+// severity returns the Cloud Logging severity of a slog level.
+func severity(l slog.Level) string {
+	switch {
+	case l < slog.LevelInfo:
+		return "DEBUG"
+	case l < slog.LevelWarn:
+		return "INFO"
+	case l < slog.LevelError:
+		return "WARNING"
+	default:
+		return "ERROR"
+	}
+}
+
+// WithRetryPolicy returns a background function that runs fn according to
+// p. Events without metadata, such as in local tests, are neither aged nor
+// deduplicated.
+func WithRetryPolicy[T any](p RetryPolicy, fn func(context.Context, T) error) func(context.Context, T) error {
+	logger := p.Logger
+	if logger == nil {
+		logger = defaultLogger
+	}
+	retryable := p.Retryable
+	if retryable == nil {
+		retryable = func(error) bool { return true }
+	}
+	now := p.Now
+	if now == nil {
+		now = time.Now
+	}
+
+	return func(ctx context.Context, event T) error {
+		var attrs []any
+		meta, metaErr := metadata.FromContext(ctx)
+		if metaErr == nil {
+			age := now().Sub(meta.Timestamp)
+			attrs = append(attrs,
+				slog.String("eventId", meta.EventID),
+				slog.String("eventType", meta.EventType),
+				slog.Duration("age", age))
+			if p.MaxAge > 0 && age > p.MaxAge {
+				logger.WarnContext(ctx, "Dropping event older than the retry budget", append(attrs, slog.String("decision", DecisionExpired))...)
+				return nil
+			}
+			if p.Store != nil {
+				done, err := p.Store.Done(ctx, meta.EventID)
+				if err != nil {
+					// Processing twice beats not processing at all.
+					attrs = append(attrs, slog.String("dedupError", err.Error()))
+				} else if done {
+					logger.InfoContext(ctx, "Skipping duplicate event", append(attrs, slog.String("decision", DecisionDuplicate))...)
+					return nil
+				}
+			}
+		}
+
+		err := fn(ctx, event)
+		if err != nil && retryable(err) {
+			logger.ErrorContext(ctx, "Event processing failed, retrying", append(attrs, slog.String("decision", DecisionRetry), slog.String("error", err.Error()))...)
+			return err
+		}
+
+		// The event is done with, successfully or not.
+		if metaErr == nil && p.Store != nil {
+			if serr := p.Store.MarkDone(ctx, meta.EventID); serr != nil {
+				attrs = append(attrs, slog.String("dedupError", serr.Error()))
+			}
+		}
+		if err != nil {
+			logger.ErrorContext(ctx, "Event processing failed permanently, dropping it", append(attrs, slog.String("decision", DecisionPermanent), slog.String("error", err.Error()))...)
+			return nil
+		}
+		logger.InfoContext(ctx, "Event processed", append(attrs, slog.String("decision", DecisionSuccess))...)
+		return nil
+	}
+}
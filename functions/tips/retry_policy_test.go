@@ -0,0 +1,243 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tips
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"cloud.google.com/go/functions/metadata"
+)
+
+var start = time.Date(2026, 1, 1, 10, 0, 0, 0, time.UTC)
+
+// eventContext returns the context of an event published at start.
+func eventContext(id string) context.Context {
+	return metadata.NewContext(context.Background(), &metadata.Metadata{
+		EventID:   id,
+		Timestamp: start,
+		EventType: "google.pubsub.topic.publish",
+	})
+}
+
+// policyTest runs a function through a RetryPolicy, recording its calls and
+// log entries.
+type policyTest struct {
+	now   time.Time
+	calls int
+	errs  []error // returned by the successive calls
+	logs  bytes.Buffer
+	fn    func(context.Context, PubSubMessage) error
+}
+
+func newPolicyTest(p RetryPolicy, errs ...error) *policyTest {
+	pt := &policyTest{now: start, errs: errs}
+	p.Now = func() time.Time { return pt.now }
+	p.Logger = newJSONLogger(&pt.logs)
+	pt.fn = WithRetryPolicy(p, func(ctx context.Context, m PubSubMessage) error {
+		pt.calls++
+		if len(pt.errs) == 0 {
+			return nil
+		}
+		err := pt.errs[0]
+		pt.errs = pt.errs[1:]
+		return err
+	})
+	return pt
+}
+
+// decisions returns the decisions logged so far, and their severities.
+func (pt *policyTest) decisions(t *testing.T) (got, severities []string) {
+	t.Helper()
+	dec := json.NewDecoder(bytes.NewReader(pt.logs.Bytes()))
+	for dec.More() {
+		var entry struct{ Decision, Severity string }
+		if err := dec.Decode(&entry); err != nil {
+			t.Fatalf("invalid log entry: %v", err)
+		}
+		got = append(got, entry.Decision)
+		severities = append(severities, entry.Severity)
+	}
+	return got, severities
+}
+
+func equal(a, b []string) bool {
+	return fmt.Sprint(a) == fmt.Sprint(b)
+}
+
+func TestRetryPolicyRedelivery(t *testing.T) {
+	pt := newPolicyTest(RetryPolicy{MaxAge: time.Minute, Store: &MemoryStore{TTL: time.Hour}},
+		errors.New("unavailable"), errors.New("unavailable"))
+	ctx := eventContext("1")
+
+	for i := 0; i < 2; i++ {
+		if err := pt.fn(ctx, PubSubMessage{}); err == nil {
+			t.Fatalf("attempt %d: got nil, want an error to retry", i+1)
+		}
+		pt.now = pt.now.Add(10 * time.Second)
+	}
+	if err := pt.fn(ctx, PubSubMessage{}); err != nil {
+		t.Fatalf("attempt 3: %v", err)
+	}
+	if pt.calls != 3 {
+		t.Errorf("got %d calls, want 3", pt.calls)
+	}
+	// Processed events are not processed again.
+	if err := pt.fn(ctx, PubSubMessage{}); err != nil {
+		t.Fatalf("redelivery: %v", err)
+	}
+	if pt.calls != 3 {
+		t.Errorf("redelivery was processed")
+	}
+	want := []string{DecisionRetry, DecisionRetry, DecisionSuccess, DecisionDuplicate}
+	if got, _ := pt.decisions(t); !equal(got, want) {
+		t.Errorf("decisions = %v, want %v", got, want)
+	}
+}
+
+func TestRetryPolicyPermanent(t *testing.T) {
+	errBadMessage := errors.New("bad message")
+	pt := newPolicyTest(RetryPolicy{
+		Store:     &MemoryStore{TTL: time.Hour},
+		Retryable: func(err error) bool { return !errors.Is(err, errBadMessage) },
+	}, fmt.Errorf("decode: %w", errBadMessage))
+	ctx := eventContext("1")
+
+	if err := pt.fn(ctx, PubSubMessage{}); err != nil {
+		t.Errorf("permanent failure: got %v, want nil", err)
+	}
+	// Failed events are not processed again either.
+	if err := pt.fn(ctx, PubSubMessage{}); err != nil {
+		t.Errorf("redelivery: got %v, want nil", err)
+	}
+	if pt.calls != 1 {
+		t.Errorf("got %d calls, want 1", pt.calls)
+	}
+	want := []string{DecisionPermanent, DecisionDuplicate}
+	if got, _ := pt.decisions(t); !equal(got, want) {
+		t.Errorf("decisions = %v, want %v", got, want)
+	}
+}
+
+func TestRetryPolicyRetryable(t *testing.T) {
+	errQuota := errors.New("quota exceeded")
+	pt := newPolicyTest(RetryPolicy{
+		Retryable: func(err error) bool { return errors.Is(err, errQuota) },
+	}, fmt.Errorf("write: %w", errQuota), errors.New("bad message"))
+
+	if err := pt.fn(eventContext("1"), PubSubMessage{}); !errors.Is(err, errQuota) {
+		t.Errorf("retryable error: got %v, want %v", err, errQuota)
+	}
+	if err := pt.fn(eventContext("2"), PubSubMessage{}); err != nil {
+		t.Errorf("other error: got %v, want nil", err)
+	}
+	want := []string{DecisionRetry, DecisionPermanent}
+	if got, _ := pt.decisions(t); !equal(got, want) {
+		t.Errorf("decisions = %v, want %v", got, want)
+	}
+}
+
+func TestRetryPolicyExpired(t *testing.T) {
+	pt := newPolicyTest(RetryPolicy{MaxAge: 10 * time.Second}, errors.New("unavailable"))
+	pt.now = start.Add(11 * time.Second)
+
+	if err := pt.fn(eventContext("1"), PubSubMessage{}); err != nil {
+		t.Errorf("expired event: got %v, want nil", err)
+	}
+	if pt.calls != 0 {
+		t.Errorf("expired event was processed")
+	}
+	want := []string{DecisionExpired}
+	got, severities := pt.decisions(t)
+	if !equal(got, want) {
+		t.Errorf("decisions = %v, want %v", got, want)
+	}
+	if want := []string{"WARNING"}; !equal(severities, want) {
+		t.Errorf("severities = %v, want %v", severities, want)
+	}
+}
+
+func TestRetryPolicyWithoutMetadata(t *testing.T) {
+	pt := newPolicyTest(RetryPolicy{MaxAge: time.Second, Store: &MemoryStore{TTL: time.Hour}})
+	pt.now = start.Add(time.Hour)
+
+	for i := 0; i < 2; i++ {
+		if err := pt.fn(context.Background(), PubSubMessage{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if pt.calls != 2 {
+		t.Errorf("got %d calls, want 2", pt.calls)
+	}
+}
+
+func TestMemoryStore(t *testing.T) {
+	ctx := context.Background()
+	now := start
+	s := &MemoryStore{TTL: time.Minute, Now: func() time.Time { return now }}
+
+	if done, _ := s.Done(ctx, "1"); done {
+		t.Error("Done before MarkDone = true")
+	}
+	s.MarkDone(ctx, "1")
+	if done, _ := s.Done(ctx, "1"); !done {
+		t.Error("Done after MarkDone = false")
+	}
+	now = now.Add(time.Minute)
+	if done, _ := s.Done(ctx, "1"); done {
+		t.Error("Done after the TTL = true")
+	}
+	s.MarkDone(ctx, "2")
+	if len(s.expiry) != 1 {
+		t.Errorf("MarkDone kept %d events, want 1", len(s.expiry))
+	}
+}
+
+func TestFirestoreStore(t *testing.T) {
+	if os.Getenv("FIRESTORE_EMULATOR_HOST") == "" {
+		t.Skip("FIRESTORE_EMULATOR_HOST not set")
+	}
+	ctx := context.Background()
+	client, err := firestore.NewClient(ctx, "test-project")
+	if err != nil {
+		t.Fatalf("firestore.NewClient: %v", err)
+	}
+	defer client.Close()
+
+	id := fmt.Sprintf("event-%d", time.Now().UnixNano())
+	s := &FirestoreStore{Client: client, Collection: "processedEvents", TTL: time.Hour}
+	if done, err := s.Done(ctx, id); err != nil || done {
+		t.Fatalf("Done before MarkDone = %t, %v, want false", done, err)
+	}
+	if err := s.MarkDone(ctx, id); err != nil {
+		t.Fatalf("MarkDone: %v", err)
+	}
+	if done, err := s.Done(ctx, id); err != nil || !done {
+		t.Errorf("Done after MarkDone = %t, %v, want true", done, err)
+	}
+
+	s.TTL = -time.Second
+	s.MarkDone(ctx, id)
+	if done, err := s.Done(ctx, id); err != nil || done {
+		t.Errorf("Done after the TTL = %t, %v, want false", done, err)
+	}
+}
@@ -0,0 +1,53 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tips
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// RetryPubSubWithPolicy is RetryPubSub with a RetryPolicy: it drops events
+// after 10 seconds of retries, and events already processed by this
+// instance. Access errors are not retried, as above.
+func RetryPubSubWithPolicy(ctx context.Context, m PubSubMessage) error {
+	return retryPubSub(ctx, m)
+}
+
+var retryPubSub = WithRetryPolicy(RetryPolicy{
+	MaxAge:    10 * time.Second,
+	Store:     &MemoryStore{TTL: time.Hour},
+	Retryable: func(err error) bool { return !errors.Is(err, errAccessDenied) },
+}, writeName)
+
+// writeName is RetryPubSub, returning all errors and leaving the decision
+// to retry to the policy.
+func writeName(ctx context.Context, m PubSubMessage) error {
+	name := string(m.Data)
+	if name == "" {
+		name = "World"
+	}
+
+	client, err := MisconfiguredDataClient()
+	if err != nil {
+		return fmt.Errorf("MisconfiguredDataClient: %w", err)
+	}
+	if err = FailedWriteOperation(client, name); err != nil {
+		return fmt.Errorf("FailedWriteOperation: %w", err)
+	}
+	return nil
+}
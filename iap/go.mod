@@ -2,7 +2,10 @@ module github.com/GoogleCloudPlatform/golang-samples/iap
 
 go 1.23.0
 
-require google.golang.org/api v0.217.0
+require (
+	github.com/go-jose/go-jose/v4 v4.0.4
+	google.golang.org/api v0.217.0
+)
 
 require (
 	cloud.google.com/go/auth v0.14.0 // indirect
@@ -0,0 +1,146 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package iaptest provides a key server that signs IAP JWTs, to test their
+// validation without IAP.
+package iaptest
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"time"
+)
+
+// KeyServer serves a JSON Web Key Set, and signs JWTs with its current
+// key, like IAP.
+type KeyServer struct {
+	*httptest.Server
+	// MaxAge is the max-age of the key set responses, in seconds.
+	MaxAge int
+
+	mu       sync.Mutex
+	current  string
+	keys     map[string]*ecdsa.PrivateKey
+	order    []string
+	requests int
+}
+
+// NewKeyServer starts a key server with a key.
+func NewKeyServer() *KeyServer {
+	s := &KeyServer{MaxAge: 3600, keys: map[string]*ecdsa.PrivateKey{}}
+	s.Rotate()
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveKeys))
+	return s
+}
+
+// Rotate adds a key, which signs the next JWTs, and returns its ID. The
+// previous keys are still served until removed.
+func (s *KeyServer) Rotate() string {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		panic(err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	kid := fmt.Sprintf("key-%d", len(s.order)+1)
+	s.keys[kid] = key
+	s.order = append(s.order, kid)
+	s.current = kid
+	return kid
+}
+
+// Remove stops serving a key.
+func (s *KeyServer) Remove(kid string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.keys, kid)
+}
+
+// Requests returns the number of key set requests.
+func (s *KeyServer) Requests() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests
+}
+
+func (s *KeyServer) serveKeys(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests++
+	enc := base64.RawURLEncoding
+	var keys []map[string]string
+	for _, kid := range s.order {
+		key, ok := s.keys[kid]
+		if !ok {
+			continue
+		}
+		keys = append(keys, map[string]string{
+			"kty": "EC",
+			"alg": "ES256",
+			"use": "sig",
+			"crv": "P-256",
+			"kid": kid,
+			"x":   enc.EncodeToString(key.X.FillBytes(make([]byte, 32))),
+			"y":   enc.EncodeToString(key.Y.FillBytes(make([]byte, 32))),
+		})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", s.MaxAge))
+	json.NewEncoder(w).Encode(map[string]interface{}{"keys": keys})
+}
+
+// Claims returns the claims of an IAP JWT for a user, valid for 10
+// minutes. The subject is derived from the email.
+func Claims(audience, email string) map[string]interface{} {
+	now := time.Now()
+	return map[string]interface{}{
+		"iss":   "https://cloud.google.com/iap",
+		"aud":   audience,
+		"sub":   fmt.Sprintf("accounts.google.com:%x", sha256.Sum256([]byte(email)))[:36],
+		"email": email,
+		"iat":   now.Unix(),
+		"exp":   now.Add(10 * time.Minute).Unix(),
+	}
+}
+
+// Sign returns a JWT with the claims, signed with the current key.
+func (s *KeyServer) Sign(claims map[string]interface{}) string {
+	s.mu.Lock()
+	kid := s.current
+	key := s.keys[kid]
+	s.mu.Unlock()
+
+	enc := base64.RawURLEncoding
+	header, _ := json.Marshal(map[string]string{"alg": "ES256", "typ": "JWT", "kid": kid})
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		panic(err)
+	}
+	signed := enc.EncodeToString(header) + "." + enc.EncodeToString(payload)
+	h := sha256.Sum256([]byte(signed))
+	r, ss, err := ecdsa.Sign(rand.Reader, key, h[:])
+	if err != nil {
+		panic(err)
+	}
+	sig := append(r.FillBytes(make([]byte, 32)), ss.FillBytes(make([]byte, 32))...)
+	return signed + "." + enc.EncodeToString(sig)
+}
@@ -0,0 +1,182 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package iap
+
+import (
+	"context"
+	"crypto"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+)
+
+// PublicKeysURL is the URL of the public keys of the IAP JWTs, as a JSON
+// Web Key Set.
+const PublicKeysURL = "https://www.gstatic.com/iap/verify/public_key-jwk"
+
+// KeySet is a cached JSON Web Key Set. The keys are fetched again when the
+// cache expires, as set by the Cache-Control header of the response, or
+// when a token is signed with an unknown key, after a rotation. Concurrent
+// calls share a single fetch.
+type KeySet struct {
+	// URL is the URL of the key set. It defaults to PublicKeysURL.
+	URL string
+	// Client fetches the keys. It defaults to http.DefaultClient.
+	Client *http.Client
+	// MaxAge is how long the keys are cached when the response has no
+	// max-age. It defaults to an hour.
+	MaxAge time.Duration
+	// MinRefresh is the minimum time between fetches, so that tokens with
+	// made-up key IDs, or requests while the server is unavailable, don't
+	// flood the server. It defaults to a minute.
+	MinRefresh time.Duration
+	// Now returns the current time. It defaults to time.Now.
+	Now func() time.Time
+
+	mu       sync.Mutex
+	keys     map[string]crypto.PublicKey
+	expires  time.Time
+	fetched  time.Time     // time of the last fetch
+	err      error         // error of the last fetch
+	fetching chan struct{} // closed when the fetch in progress is done
+}
+
+func (s *KeySet) now() time.Time {
+	if s.Now != nil {
+		return s.Now()
+	}
+	return time.Now()
+}
+
+// Key returns the key with an ID.
+func (s *KeySet) Key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	minRefresh := s.MinRefresh
+	if minRefresh == 0 {
+		minRefresh = time.Minute
+	}
+	s.mu.Lock()
+	for {
+		now := s.now()
+		key, ok := s.keys[kid]
+		if ok && now.Before(s.expires) {
+			s.mu.Unlock()
+			return key, nil
+		}
+		if s.fetching != nil {
+			// Use an expired key while the keys are fetched, or else wait
+			// for them.
+			if ok {
+				s.mu.Unlock()
+				return key, nil
+			}
+			done := s.fetching
+			s.mu.Unlock()
+			select {
+			case <-done:
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+			s.mu.Lock()
+			continue
+		}
+		if !s.fetched.IsZero() && now.Sub(s.fetched) < minRefresh {
+			s.mu.Unlock()
+			// Keep using the previous keys if the server is unavailable.
+			if ok {
+				return key, nil
+			}
+			if s.err != nil {
+				return nil, s.err
+			}
+			return nil, fmt.Errorf("unknown key %q", kid)
+		}
+
+		// Fetch expired keys, or unknown keys, without holding s.mu.
+		prev := s.fetched
+		s.fetched = now
+		done := make(chan struct{})
+		s.fetching = done
+		s.mu.Unlock()
+		keys, expires, err := s.fetch(ctx, now)
+		s.mu.Lock()
+		s.fetching = nil
+		close(done)
+		s.err = err
+		if err == nil {
+			s.keys, s.expires = keys, expires
+		} else if ctx.Err() != nil {
+			// A canceled request doesn't delay the next fetch.
+			s.fetched = prev
+		}
+	}
+}
+
+// fetch fetches the keys, and returns them with their expiry time.
+func (s *KeySet) fetch(ctx context.Context, now time.Time) (map[string]crypto.PublicKey, time.Time, error) {
+	url := s.URL
+	if url == "" {
+		url = PublicKeysURL
+	}
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("fetching keys: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, time.Time{}, fmt.Errorf("fetching keys: %s", resp.Status)
+	}
+	var set jose.JSONWebKeySet
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return nil, time.Time{}, fmt.Errorf("decoding keys: %w", err)
+	}
+
+	keys := map[string]crypto.PublicKey{}
+	for _, k := range set.Keys {
+		if !k.Valid() || !k.IsPublic() {
+			return nil, time.Time{}, fmt.Errorf("key %q: invalid public key", k.KeyID)
+		}
+		keys[k.KeyID] = k.Key
+	}
+	return keys, now.Add(maxAge(resp.Header.Get("Cache-Control"), s.MaxAge)), nil
+}
+
+// maxAge returns the max-age of a Cache-Control header, or def.
+func maxAge(cacheControl string, def time.Duration) time.Duration {
+	if def == 0 {
+		def = time.Hour
+	}
+	for _, d := range strings.Split(cacheControl, ",") {
+		if v, ok := strings.CutPrefix(strings.TrimSpace(d), "max-age="); ok {
+			if n, err := strconv.Atoi(v); err == nil {
+				return time.Duration(n) * time.Second
+			}
+		}
+	}
+	return def
+}
@@ -0,0 +1,209 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package iap
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/go-jose/go-jose/v4/jwt"
+)
+
+// Issuer is the issuer of the IAP JWTs.
+const Issuer = "https://cloud.google.com/iap"
+
+// AppEngineAudience returns the audience of the JWTs of an App Engine app.
+func AppEngineAudience(projectNumber, projectID string) string {
+	return fmt.Sprintf("/projects/%s/apps/%s", projectNumber, projectID)
+}
+
+// ComputeEngineAudience returns the audience of the JWTs of a backend
+// service.
+func ComputeEngineAudience(projectNumber, backendServiceID string) string {
+	return fmt.Sprintf("/projects/%s/global/backendServices/%s", projectNumber, backendServiceID)
+}
+
+// CloudRunAudience returns the audience of the JWTs of a Cloud Run service.
+func CloudRunAudience(projectNumber, region, service string) string {
+	return fmt.Sprintf("/projects/%s/locations/%s/services/%s", projectNumber, region, service)
+}
+
+// Identity is the user of a request, from a verified JWT.
+type Identity struct {
+	Email   string
+	Subject string
+	// Domain is the hosted domain of the user, if any.
+	Domain string
+	// Groups are the groups in the groups claim, if any.
+	Groups []string
+	// Claims are all the claims of the JWT.
+	Claims map[string]interface{}
+}
+
+type identityKey struct{}
+
+// FromContext returns the identity set by the middleware.
+func FromContext(ctx context.Context) (*Identity, bool) {
+	id, ok := ctx.Value(identityKey{}).(*Identity)
+	return id, ok
+}
+
+// ErrForbidden is returned for valid JWTs of users who are not allowed.
+var ErrForbidden = errors.New("iap: user not allowed")
+
+// Validator validates the JWTs of IAP.
+type Validator struct {
+	// Audiences are the accepted audiences.
+	Audiences []string
+	// Keys are the keys of the JWTs. The default fetches the keys of IAP.
+	Keys *KeySet
+
+	// AllowedDomains, if set, are the domains of the allowed users: their
+	// hosted domain, or the domain of their email.
+	AllowedDomains []string
+	// AllowedGroups, if set, are the groups of the allowed users, in the
+	// GroupsClaim claim. Users in an allowed domain or group are allowed.
+	AllowedGroups []string
+	// GroupsClaim is the claim with the groups. It defaults to "groups".
+	GroupsClaim string
+
+	// Now returns the current time. It defaults to time.Now.
+	Now func() time.Time
+}
+
+// leeway is the tolerated clock skew.
+const leeway = 30 * time.Second
+
+// algorithms are the accepted JWT signature algorithms. IAP signs with
+// ES256.
+var algorithms = []jose.SignatureAlgorithm{jose.ES256, jose.RS256}
+
+// Validate validates a JWT, and returns the identity of the user. It
+// returns an error wrapping ErrForbidden if the user is not allowed.
+func (v *Validator) Validate(ctx context.Context, token string) (*Identity, error) {
+	tok, err := jwt.ParseSigned(token, algorithms)
+	if err != nil {
+		return nil, fmt.Errorf("iap: %w", err)
+	}
+	keys := v.Keys
+	if keys == nil {
+		keys = defaultKeys
+	}
+	key, err := keys.Key(ctx, tok.Headers[0].KeyID)
+	if err != nil {
+		return nil, fmt.Errorf("iap: %w", err)
+	}
+	var std jwt.Claims
+	var claims map[string]interface{}
+	if err := tok.Claims(key, &std, &claims); err != nil {
+		return nil, fmt.Errorf("iap: %w", err)
+	}
+	if err := v.checkClaims(std); err != nil {
+		return nil, fmt.Errorf("iap: %w", err)
+	}
+
+	id := &Identity{Claims: claims}
+	id.Email, _ = claims["email"].(string)
+	id.Subject = std.Subject
+	id.Domain, _ = claims["hd"].(string)
+	groupsClaim := v.GroupsClaim
+	if groupsClaim == "" {
+		groupsClaim = "groups"
+	}
+	if groups, ok := claims[groupsClaim].([]interface{}); ok {
+		for _, g := range groups {
+			if s, ok := g.(string); ok {
+				id.Groups = append(id.Groups, s)
+			}
+		}
+	}
+	if !v.allowed(id) {
+		return id, fmt.Errorf("%w: %s", ErrForbidden, id.Email)
+	}
+	return id, nil
+}
+
+var defaultKeys = &KeySet{}
+
+func (v *Validator) checkClaims(claims jwt.Claims) error {
+	if claims.Expiry == nil || claims.IssuedAt == nil {
+		return errors.New("JWT without exp or iat")
+	}
+	now := time.Now()
+	if v.Now != nil {
+		now = v.Now()
+	}
+	return claims.ValidateWithLeeway(jwt.Expected{
+		Issuer:      Issuer,
+		AnyAudience: v.Audiences,
+		Time:        now,
+	}, leeway)
+}
+
+func (v *Validator) allowed(id *Identity) bool {
+	if len(v.AllowedDomains) == 0 && len(v.AllowedGroups) == 0 {
+		return true
+	}
+	domain := id.Domain
+	if domain == "" {
+		if i := strings.LastIndex(id.Email, "@"); i >= 0 {
+			domain = id.Email[i+1:]
+		}
+	}
+	for _, d := range v.AllowedDomains {
+		if strings.EqualFold(d, domain) {
+			return true
+		}
+	}
+	for _, g := range id.Groups {
+		if slices.Contains(v.AllowedGroups, g) {
+			return true
+		}
+	}
+	return false
+}
+
+// Handler returns a handler that validates the X-Goog-IAP-JWT-Assertion
+// header of the requests, and calls next with the identity of the user in
+// the context. It answers 401 Unauthorized for invalid JWTs, and 403
+// Forbidden for users who are not allowed.
+func (v *Validator) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := r.Header.Get("X-Goog-IAP-JWT-Assertion")
+		if token == "" {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		id, err := v.Validate(r.Context(), token)
+		if errors.Is(err, ErrForbidden) {
+			log.Print(err)
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		if err != nil {
+			log.Print(err)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), identityKey{}, id)))
+	})
+}
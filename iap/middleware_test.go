@@ -0,0 +1,243 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package iap
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/GoogleCloudPlatform/golang-samples/iap/iaptest"
+)
+
+var (
+	appEngineAud = AppEngineAudience("123456789", "my-project")
+	gceAud       = ComputeEngineAudience("123456789", "987654321")
+	cloudRunAud  = CloudRunAudience("123456789", "us-central1", "my-service")
+)
+
+func TestHandler(t *testing.T) {
+	ks := iaptest.NewKeyServer()
+	defer ks.Close()
+	v := &Validator{
+		Audiences:      []string{appEngineAud, gceAud, cloudRunAud},
+		Keys:           &KeySet{URL: ks.URL},
+		AllowedDomains: []string{"example.com"},
+		AllowedGroups:  []string{"admins@partner.com"},
+	}
+	h := v.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, ok := FromContext(r.Context())
+		if !ok {
+			t.Error("no identity in the context")
+			return
+		}
+		fmt.Fprintf(w, "%s %s", id.Email, id.Subject)
+	}))
+
+	withClaims := func(aud, email string, change func(map[string]interface{})) string {
+		claims := iaptest.Claims(aud, email)
+		if change != nil {
+			change(claims)
+		}
+		return ks.Sign(claims)
+	}
+	tests := []struct {
+		name   string
+		token  string
+		status int
+	}{
+		{"App Engine", withClaims(appEngineAud, "alice@example.com", nil), http.StatusOK},
+		{"Compute Engine", withClaims(gceAud, "alice@example.com", nil), http.StatusOK},
+		{"Cloud Run", withClaims(cloudRunAud, "alice@example.com", nil), http.StatusOK},
+		{"hosted domain", withClaims(cloudRunAud, "alice@alias.net", func(c map[string]interface{}) { c["hd"] = "example.com" }), http.StatusOK},
+		{"group", withClaims(cloudRunAud, "bob@partner.com", func(c map[string]interface{}) { c["groups"] = []string{"admins@partner.com"} }), http.StatusOK},
+		{"no token", "", http.StatusUnauthorized},
+		{"malformed", "not.a-jwt", http.StatusUnauthorized},
+		{"other domain", withClaims(appEngineAud, "eve@evil.com", nil), http.StatusForbidden},
+		{"other group", withClaims(appEngineAud, "eve@partner.com", func(c map[string]interface{}) { c["groups"] = []string{"users@partner.com"} }), http.StatusForbidden},
+		{"other audience", withClaims("/projects/1/apps/other", "alice@example.com", nil), http.StatusUnauthorized},
+		{"other issuer", withClaims(appEngineAud, "alice@example.com", func(c map[string]interface{}) { c["iss"] = "https://accounts.google.com" }), http.StatusUnauthorized},
+		{"expired", withClaims(appEngineAud, "alice@example.com", func(c map[string]interface{}) { c["exp"] = time.Now().Add(-time.Minute).Unix() }), http.StatusUnauthorized},
+		{"future", withClaims(appEngineAud, "alice@example.com", func(c map[string]interface{}) { c["iat"] = time.Now().Add(time.Minute).Unix() }), http.StatusUnauthorized},
+		{"tampered", tamper(withClaims(appEngineAud, "alice@example.com", nil)), http.StatusUnauthorized},
+	}
+	for _, tc := range tests {
+		req := httptest.NewRequest("GET", "/", nil)
+		if tc.token != "" {
+			req.Header.Set("X-Goog-IAP-JWT-Assertion", tc.token)
+		}
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		if rr.Code != tc.status {
+			t.Errorf("%s: status %d, want %d (%s)", tc.name, rr.Code, tc.status, strings.TrimSpace(rr.Body.String()))
+		}
+		if tc.name == "App Engine" && !strings.HasPrefix(rr.Body.String(), "alice@example.com accounts.google.com:") {
+			t.Errorf("%s: body %q, want the email and subject", tc.name, rr.Body.String())
+		}
+	}
+	if n := ks.Requests(); n != 1 {
+		t.Errorf("%d key set requests, want 1", n)
+	}
+}
+
+// tamper changes the claims of a JWT, keeping the signature.
+func tamper(token string) string {
+	parts := strings.Split(token, ".")
+	parts[1] = strings.TrimSuffix(parts[1], parts[1][len(parts[1])-2:]) + "xy"
+	return strings.Join(parts, ".")
+}
+
+func TestKeyRotation(t *testing.T) {
+	ks := iaptest.NewKeyServer()
+	defer ks.Close()
+	now := time.Now()
+	keys := &KeySet{URL: ks.URL, Now: func() time.Time { return now }}
+	v := &Validator{Audiences: []string{appEngineAud}, Keys: keys}
+	ctx := context.Background()
+	validate := func() error {
+		_, err := v.Validate(ctx, ks.Sign(iaptest.Claims(appEngineAud, "alice@example.com")))
+		return err
+	}
+
+	if err := validate(); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+	// A token signed with a new key fetches the keys again, once a minute
+	// at most.
+	old := ks.Rotate()
+	now = now.Add(2 * time.Minute)
+	if err := validate(); err != nil {
+		t.Fatalf("Validate after rotation: %v", err)
+	}
+	ks.Rotate()
+	if err := validate(); err == nil {
+		t.Error("Validate right after another rotation succeeded, want error")
+	}
+	if n := ks.Requests(); n != 2 {
+		t.Errorf("%d key set requests, want 2", n)
+	}
+
+	// Expired keys are fetched again, without the removed keys.
+	ks.Remove(old)
+	now = now.Add(2 * time.Hour)
+	if err := validate(); err != nil {
+		t.Fatalf("Validate after expiry: %v", err)
+	}
+	if _, err := keys.Key(ctx, old); err == nil {
+		t.Error("removed key still cached")
+	}
+	if n := ks.Requests(); n != 3 {
+		t.Errorf("%d key set requests, want 3", n)
+	}
+}
+
+// countingTransport counts the requests, and fails them once down is set.
+type countingTransport struct {
+	mu       sync.Mutex
+	requests int
+	down     bool
+}
+
+func (t *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.mu.Lock()
+	t.requests++
+	down := t.down
+	t.mu.Unlock()
+	if down {
+		return nil, errors.New("connection refused")
+	}
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestKeySetUnavailable(t *testing.T) {
+	ks := iaptest.NewKeyServer()
+	defer ks.Close()
+	now := time.Now()
+	tr := &countingTransport{}
+	v := &Validator{Audiences: []string{appEngineAud}, Keys: &KeySet{URL: ks.URL, Client: &http.Client{Transport: tr}, Now: func() time.Time { return now }}}
+	token := ks.Sign(iaptest.Claims(appEngineAud, "alice@example.com"))
+	ctx := context.Background()
+	if _, err := v.Validate(ctx, token); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+	// Cached keys are used after they expire if the key server is down,
+	// and fetched again once a minute at most.
+	tr.down = true
+	now = now.Add(2 * time.Hour)
+	for i := 0; i < 3; i++ {
+		if _, err := v.Validate(ctx, token); err != nil {
+			t.Errorf("Validate with the key server down: %v", err)
+		}
+	}
+	if tr.requests != 2 {
+		t.Errorf("%d key set requests, want 2", tr.requests)
+	}
+	now = now.Add(2 * time.Minute)
+	if _, err := v.Validate(ctx, token); err != nil {
+		t.Errorf("Validate with the key server down: %v", err)
+	}
+	if tr.requests != 3 {
+		t.Errorf("%d key set requests after a minute, want 3", tr.requests)
+	}
+}
+
+func TestKeySetConcurrent(t *testing.T) {
+	ks := iaptest.NewKeyServer()
+	defer ks.Close()
+	v := &Validator{Audiences: []string{appEngineAud}, Keys: &KeySet{URL: ks.URL}}
+	token := ks.Sign(iaptest.Claims(appEngineAud, "alice@example.com"))
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := v.Validate(context.Background(), token); err != nil {
+				t.Errorf("Validate: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := ks.Requests(); n != 1 {
+		t.Errorf("%d key set requests, want 1", n)
+	}
+}
+
+func TestValidateForbidden(t *testing.T) {
+	ks := iaptest.NewKeyServer()
+	defer ks.Close()
+	v := &Validator{Audiences: []string{appEngineAud}, Keys: &KeySet{URL: ks.URL}, AllowedDomains: []string{"example.com"}}
+	id, err := v.Validate(context.Background(), ks.Sign(iaptest.Claims(appEngineAud, "eve@evil.com")))
+	if !errors.Is(err, ErrForbidden) || id == nil || id.Email != "eve@evil.com" {
+		t.Errorf("Validate = %+v, %v; want the identity and ErrForbidden", id, err)
+	}
+}
+
+func TestMaxAge(t *testing.T) {
+	for header, want := range map[string]time.Duration{
+		"public, max-age=23040, must-revalidate": 23040 * time.Second,
+		"no-cache":                               time.Hour,
+		"":                                       time.Hour,
+	} {
+		if got := maxAge(header, 0); got != want {
+			t.Errorf("maxAge(%q) = %v, want %v", header, got, want)
+		}
+	}
+}
@@ -0,0 +1,220 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package envelope implements envelope encryption with Cloud KMS.
+//
+// Data is encrypted locally with an AES-256-GCM data encryption key (DEK),
+// and the DEK is encrypted ("wrapped") with a Cloud KMS key. The output
+// starts with a header holding the KMS key name and the wrapped DEK,
+// followed by the data in authenticated chunks, so that large files are
+// encrypted and decrypted as streams, without sending them to Cloud KMS.
+package envelope
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"sync"
+	"time"
+
+	kms "cloud.google.com/go/kms/apiv1"
+	"cloud.google.com/go/kms/apiv1/kmspb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// DefaultChunkSize is the default size of the plaintext of a chunk.
+const DefaultChunkSize = 64 << 10
+
+// Envelope encrypts and decrypts data with data keys wrapped by a Cloud KMS
+// key.
+type Envelope struct {
+	Client *kms.KeyManagementClient
+	// KeyName is the key that wraps the data keys, for encryption:
+	// projects/PROJECT/locations/LOCATION/keyRings/RING/cryptoKeys/KEY.
+	// Decryption uses the key in the header.
+	KeyName string
+	// ChunkSize is the size of the plaintext of a chunk. It defaults to
+	// DefaultChunkSize.
+	ChunkSize int
+
+	// CacheTTL, if positive, is how long data keys are cached. Encryption
+	// then reuses a data key instead of wrapping a new one for each
+	// message, deriving a key per message from it, and decryption reuses
+	// the unwrapped data keys.
+	CacheTTL time.Duration
+	// Now returns the current time. It defaults to time.Now.
+	Now func() time.Time
+
+	mu        sync.Mutex
+	encKey    *dataKey            // data key for encryption
+	decKeys   map[string]*dataKey // unwrapped data keys, by wrapped key
+	primaries map[string]string   // last primary versions seen, by key name
+}
+
+type dataKey struct {
+	key     []byte
+	wrapped []byte
+	expires time.Time
+}
+
+// ErrCorrupted is returned when an integrity check of a request or response
+// to Cloud KMS fails.
+var ErrCorrupted = errors.New("envelope: request or response corrupted in transit")
+
+func (e *Envelope) now() time.Time {
+	if e.Now != nil {
+		return e.Now()
+	}
+	return time.Now()
+}
+
+func crc32c(data []byte) int64 {
+	return int64(crc32.Checksum(data, crc32.MakeTable(crc32.Castagnoli)))
+}
+
+// wrapAAD is the additional authenticated data of the wrapped keys.
+var wrapAAD = []byte("envelope-dek-v1")
+
+// newDataKey generates a data key, wrapped by the KMS key.
+func (e *Envelope) newDataKey(ctx context.Context) (*dataKey, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	wrapped, err := e.wrap(ctx, e.KeyName, key)
+	if err != nil {
+		return nil, err
+	}
+	return &dataKey{key: key, wrapped: wrapped, expires: e.now().Add(e.CacheTTL)}, nil
+}
+
+// wrap encrypts a data key with the primary version of a KMS key.
+func (e *Envelope) wrap(ctx context.Context, keyName string, key []byte) ([]byte, error) {
+	resp, err := e.Client.Encrypt(ctx, &kmspb.EncryptRequest{
+		Name:                              keyName,
+		Plaintext:                         key,
+		PlaintextCrc32C:                   wrapperspb.Int64(crc32c(key)),
+		AdditionalAuthenticatedData:       wrapAAD,
+		AdditionalAuthenticatedDataCrc32C: wrapperspb.Int64(crc32c(wrapAAD)),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("envelope: wrapping data key: %w", err)
+	}
+	if !resp.VerifiedPlaintextCrc32C || !resp.VerifiedAdditionalAuthenticatedDataCrc32C ||
+		crc32c(resp.Ciphertext) != resp.CiphertextCrc32C.GetValue() {
+		return nil, ErrCorrupted
+	}
+	e.mu.Lock()
+	e.notePrimary(keyName, resp.Name)
+	e.mu.Unlock()
+	return resp.Ciphertext, nil
+}
+
+// notePrimary records the primary version of a key, with e.mu held, and
+// drops the cached encryption key when the primary version changed.
+func (e *Envelope) notePrimary(keyName, version string) {
+	if e.primaries == nil {
+		e.primaries = map[string]string{}
+	}
+	if prev, ok := e.primaries[keyName]; ok && prev != version && keyName == e.KeyName {
+		e.encKey = nil
+	}
+	e.primaries[keyName] = version
+}
+
+// unwrap decrypts a data key. usedPrimary reports whether the key was
+// wrapped with the primary version of the KMS key.
+func (e *Envelope) unwrap(ctx context.Context, keyName string, wrapped []byte) (key []byte, usedPrimary bool, err error) {
+	resp, err := e.Client.Decrypt(ctx, &kmspb.DecryptRequest{
+		Name:                              keyName,
+		Ciphertext:                        wrapped,
+		CiphertextCrc32C:                  wrapperspb.Int64(crc32c(wrapped)),
+		AdditionalAuthenticatedData:       wrapAAD,
+		AdditionalAuthenticatedDataCrc32C: wrapperspb.Int64(crc32c(wrapAAD)),
+	})
+	if err != nil {
+		return nil, false, fmt.Errorf("envelope: unwrapping data key: %w", err)
+	}
+	if crc32c(resp.Plaintext) != resp.PlaintextCrc32C.GetValue() {
+		return nil, false, ErrCorrupted
+	}
+	if len(resp.Plaintext) != 32 {
+		return nil, false, errors.New("envelope: invalid data key")
+	}
+	return resp.Plaintext, resp.UsedPrimary, nil
+}
+
+// encryptionKey returns the data key to encrypt a message.
+func (e *Envelope) encryptionKey(ctx context.Context) (*dataKey, error) {
+	if e.CacheTTL <= 0 {
+		return e.newDataKey(ctx)
+	}
+	e.mu.Lock()
+	k := e.encKey
+	e.mu.Unlock()
+	if k != nil && e.now().Before(k.expires) {
+		return k, nil
+	}
+	k, err := e.newDataKey(ctx)
+	if err != nil {
+		return nil, err
+	}
+	e.mu.Lock()
+	e.encKey = k
+	e.mu.Unlock()
+	return k, nil
+}
+
+// decryptionKey returns the unwrapped data key of a header.
+func (e *Envelope) decryptionKey(ctx context.Context, h *Header) ([]byte, error) {
+	now := e.now()
+	if e.CacheTTL > 0 {
+		e.mu.Lock()
+		k, ok := e.decKeys[string(h.WrappedKey)]
+		e.mu.Unlock()
+		if ok && now.Before(k.expires) {
+			return k.key, nil
+		}
+	}
+	key, _, err := e.unwrap(ctx, h.KeyName, h.WrappedKey)
+	if err != nil {
+		return nil, err
+	}
+	if e.CacheTTL > 0 {
+		e.mu.Lock()
+		if e.decKeys == nil {
+			e.decKeys = map[string]*dataKey{}
+		}
+		for w, k := range e.decKeys {
+			if !now.Before(k.expires) {
+				delete(e.decKeys, w)
+			}
+		}
+		e.decKeys[string(h.WrappedKey)] = &dataKey{key: key, wrapped: h.WrappedKey, expires: now.Add(e.CacheTTL)}
+		e.mu.Unlock()
+	}
+	return key, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
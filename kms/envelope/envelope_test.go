@@ -0,0 +1,249 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package envelope
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/kms/apiv1/kmspb"
+	"github.com/GoogleCloudPlatform/golang-samples/kms/kmstest"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+)
+
+const keyName = "projects/p/locations/global/keyRings/r/cryptoKeys/k"
+
+func setup(t *testing.T) (*Envelope, *kmstest.Server) {
+	t.Helper()
+	srv, err := kmstest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(srv.Close)
+	srv.CreateSymmetricKey(keyName)
+	client, err := srv.Client(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return &Envelope{Client: client, KeyName: keyName, ChunkSize: 1024}, srv
+}
+
+func random(n int) []byte {
+	b := make([]byte, n)
+	rand.Read(b)
+	return b
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	e, srv := setup(t)
+	ctx := context.Background()
+	// Sizes around the chunk boundaries.
+	for _, n := range []int{0, 1, 1023, 1024, 1025, 2048, 10000} {
+		plaintext := random(n)
+		ciphertext, err := e.Encrypt(ctx, plaintext)
+		if err != nil {
+			t.Fatalf("Encrypt(%d bytes): %v", n, err)
+		}
+		got, err := e.Decrypt(ctx, ciphertext)
+		if err != nil {
+			t.Fatalf("Decrypt(%d bytes): %v", n, err)
+		}
+		if !bytes.Equal(got, plaintext) {
+			t.Errorf("Decrypt(Encrypt(%d bytes)) differs", n)
+		}
+	}
+	// Without cache, each message has its own data key.
+	if n := srv.Calls("Encrypt"); n != 7 {
+		t.Errorf("%d Encrypt calls, want 7", n)
+	}
+}
+
+func TestStreaming(t *testing.T) {
+	e, _ := setup(t)
+	ctx := context.Background()
+	plaintext := random(100_000)
+	var buf bytes.Buffer
+	w, err := e.NewWriter(ctx, &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Write in odd sizes.
+	for p := plaintext; len(p) > 0; {
+		n := min(len(p), 777)
+		if _, err := w.Write(p[:n]); err != nil {
+			t.Fatal(err)
+		}
+		p = p[n:]
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write([]byte("x")); err == nil {
+		t.Error("Write after Close succeeded, want error")
+	}
+
+	h, err := ReadHeader(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.KeyName != keyName || h.ChunkSize != 1024 {
+		t.Errorf("header %+v, want key %s and 1024-byte chunks", h, keyName)
+	}
+	r, err := e.NewReader(ctx, &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := io.ReadAll(r)
+	if err != nil || !bytes.Equal(got, plaintext) {
+		t.Errorf("ReadAll = %d bytes, %v; want the plaintext", len(got), err)
+	}
+}
+
+func TestModified(t *testing.T) {
+	e, _ := setup(t)
+	ctx := context.Background()
+	ciphertext, err := e.Encrypt(ctx, random(3000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, _ := ReadHeader(bytes.NewReader(ciphertext))
+	header, _ := h.MarshalBinary()
+	chunk := 1024 + 16
+
+	flip := func(i int) []byte {
+		b := bytes.Clone(ciphertext)
+		b[i] ^= 1
+		return b
+	}
+	tests := map[string][]byte{
+		"truncated":       ciphertext[:len(ciphertext)-1],
+		"last chunk lost": ciphertext[:len(header)+2*chunk],
+		"chunks swapped": append(append(append(bytes.Clone(header),
+			ciphertext[len(header)+chunk:len(header)+2*chunk]...),
+			ciphertext[len(header):len(header)+chunk]...),
+			ciphertext[len(header)+2*chunk:]...),
+		"chunk modified":        flip(len(header) + 10),
+		"nonce prefix modified": flip(len(header) - 1),
+		"salt modified":         flip(len(header) - noncePrefixLen - 1),
+		"chunk size modified":   flip(len(header) - noncePrefixLen - saltLen - 1),
+		"extra data":            append(bytes.Clone(ciphertext), 0),
+	}
+	for name, data := range tests {
+		if _, err := e.Decrypt(ctx, data); err == nil {
+			t.Errorf("%s: Decrypt succeeded, want error", name)
+		}
+	}
+	if _, err := e.Decrypt(ctx, []byte("not encrypted")); !errors.Is(err, ErrInvalid) {
+		t.Errorf("Decrypt(not encrypted) = %v, want ErrInvalid", err)
+	}
+}
+
+func TestCache(t *testing.T) {
+	e, srv := setup(t)
+	now := time.Now()
+	e.CacheTTL = time.Minute
+	e.Now = func() time.Time { return now }
+	ctx := context.Background()
+
+	var ciphertexts [][]byte
+	for i := 0; i < 3; i++ {
+		c, err := e.Encrypt(ctx, []byte("message"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		ciphertexts = append(ciphertexts, c)
+	}
+	if bytes.Equal(ciphertexts[0], ciphertexts[1]) {
+		t.Error("ciphertexts of the same message are equal")
+	}
+	// The messages share the data key, but not the key of their chunks.
+	h0, _ := ReadHeader(bytes.NewReader(ciphertexts[0]))
+	h1, _ := ReadHeader(bytes.NewReader(ciphertexts[1]))
+	if !bytes.Equal(h0.WrappedKey, h1.WrappedKey) || bytes.Equal(h0.Salt, h1.Salt) {
+		t.Error("cached data key not reused with a new salt")
+	}
+	for _, c := range ciphertexts {
+		if _, err := e.Decrypt(ctx, c); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if enc, dec := srv.Calls("Encrypt"), srv.Calls("Decrypt"); enc != 1 || dec != 1 {
+		t.Errorf("%d Encrypt and %d Decrypt calls, want 1 and 1", enc, dec)
+	}
+
+	// Expired keys are not used.
+	now = now.Add(2 * time.Minute)
+	if _, err := e.Encrypt(ctx, []byte("message")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := e.Decrypt(ctx, ciphertexts[0]); err != nil {
+		t.Fatal(err)
+	}
+	if enc, dec := srv.Calls("Encrypt"), srv.Calls("Decrypt"); enc != 2 || dec != 2 {
+		t.Errorf("%d Encrypt and %d Decrypt calls after expiry, want 2 and 2", enc, dec)
+	}
+}
+
+func TestRewrap(t *testing.T) {
+	e, _ := setup(t)
+	ctx := context.Background()
+	plaintext := random(5000)
+	ciphertext, err := e.Encrypt(ctx, plaintext)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Wrapped with the primary version, the data is unchanged.
+	var out bytes.Buffer
+	rewrapped, err := e.Rewrap(ctx, &out, bytes.NewReader(ciphertext))
+	if err != nil || rewrapped || !bytes.Equal(out.Bytes(), ciphertext) {
+		t.Fatalf("Rewrap = %t, %v; want no change", rewrapped, err)
+	}
+
+	// Rotate the key.
+	_, err = e.Client.CreateCryptoKeyVersion(ctx, &kmspb.CreateCryptoKeyVersionRequest{Parent: keyName})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := e.Client.UpdateCryptoKeyPrimaryVersion(ctx, &kmspb.UpdateCryptoKeyPrimaryVersionRequest{Name: keyName, CryptoKeyVersionId: "2"}); err != nil {
+		t.Fatal(err)
+	}
+	out.Reset()
+	rewrapped, err = e.Rewrap(ctx, &out, bytes.NewReader(ciphertext))
+	if err != nil || !rewrapped {
+		t.Fatalf("Rewrap after rotation = %t, %v; want rewrapped", rewrapped, err)
+	}
+
+	// The data can be decrypted with the new version only.
+	if _, err := e.Client.UpdateCryptoKeyVersion(ctx, &kmspb.UpdateCryptoKeyVersionRequest{
+		CryptoKeyVersion: &kmspb.CryptoKeyVersion{Name: keyName + "/cryptoKeyVersions/1", State: kmspb.CryptoKeyVersion_DISABLED},
+		UpdateMask:       &fieldmaskpb.FieldMask{Paths: []string{"state"}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := e.Decrypt(ctx, ciphertext); err == nil {
+		t.Error("Decrypt with the old version disabled succeeded, want error")
+	}
+	got, err := e.Decrypt(ctx, out.Bytes())
+	if err != nil || !bytes.Equal(got, plaintext) {
+		t.Errorf("Decrypt(rewrapped) = %v; want the plaintext", err)
+	}
+}
@@ -0,0 +1,395 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package envelope
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+// The format of the encrypted data is:
+//
+//	magic        "KENV"
+//	version      1 byte, 1
+//	key name     2-byte length, bytes
+//	wrapped key  2-byte length, bytes
+//	chunk size   4 bytes
+//	salt         32 bytes
+//	nonce prefix 7 bytes
+//	chunks       chunk size plaintext bytes and a 16-byte tag each, the
+//	             last one shorter
+//
+// As in the AES-GCM-HKDF streaming AEAD of Tink, the chunks are encrypted
+// with a key derived from the data key and the random salt with HKDF, so
+// that data keys reused across messages by CacheTTL don't risk nonce
+// collisions. The nonce of a chunk is the nonce prefix, the 4-byte index of the chunk,
+// and 1 for the last chunk or 0 for the others, so that chunks can't be
+// reordered or dropped. The additional data of the chunks is the header
+// without the wrapped key, which changes when it's rewrapped.
+const (
+	magic          = "KENV"
+	formatVersion  = 1
+	saltLen        = 32
+	noncePrefixLen = 7
+	maxChunkSize   = 16 << 20
+)
+
+// ErrInvalid is returned for data that isn't valid encrypted data, or was
+// modified.
+var ErrInvalid = errors.New("envelope: invalid or modified data")
+
+// Header is the header of encrypted data.
+type Header struct {
+	KeyName     string
+	WrappedKey  []byte
+	ChunkSize   int
+	Salt        []byte
+	NoncePrefix []byte
+}
+
+// MarshalBinary encodes the header.
+func (h *Header) MarshalBinary() ([]byte, error) {
+	if len(h.KeyName) > math.MaxUint16 || len(h.WrappedKey) > math.MaxUint16 {
+		return nil, errors.New("envelope: header too large")
+	}
+	b := append([]byte(magic), formatVersion)
+	b = binary.BigEndian.AppendUint16(b, uint16(len(h.KeyName)))
+	b = append(b, h.KeyName...)
+	b = binary.BigEndian.AppendUint16(b, uint16(len(h.WrappedKey)))
+	b = append(b, h.WrappedKey...)
+	b = binary.BigEndian.AppendUint32(b, uint32(h.ChunkSize))
+	b = append(b, h.Salt...)
+	return append(b, h.NoncePrefix...), nil
+}
+
+// aad returns the additional data of the chunks.
+func (h *Header) aad() []byte {
+	b := append([]byte(magic), formatVersion)
+	b = binary.BigEndian.AppendUint16(b, uint16(len(h.KeyName)))
+	b = append(b, h.KeyName...)
+	b = binary.BigEndian.AppendUint32(b, uint32(h.ChunkSize))
+	b = append(b, h.Salt...)
+	return append(b, h.NoncePrefix...)
+}
+
+// chunkAEAD returns the AEAD of the chunks, with the key derived from the
+// data key and the salt of the header.
+func (h *Header) chunkAEAD(dataKey []byte) (cipher.AEAD, error) {
+	key := make([]byte, 32)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, dataKey, h.Salt, []byte(magic)), key); err != nil {
+		return nil, err
+	}
+	return newAEAD(key)
+}
+
+// ReadHeader reads the header of encrypted data.
+func ReadHeader(r io.Reader) (*Header, error) {
+	fixed := make([]byte, len(magic)+1+2)
+	if _, err := io.ReadFull(r, fixed); err != nil {
+		return nil, ErrInvalid
+	}
+	if string(fixed[:len(magic)]) != magic {
+		return nil, ErrInvalid
+	}
+	if v := fixed[len(magic)]; v != formatVersion {
+		return nil, fmt.Errorf("envelope: unsupported format version %d", v)
+	}
+	readN := func(n int) ([]byte, error) {
+		b := make([]byte, n)
+		if _, err := io.ReadFull(r, b); err != nil {
+			return nil, ErrInvalid
+		}
+		return b, nil
+	}
+	name, err := readN(int(binary.BigEndian.Uint16(fixed[len(magic)+1:])))
+	if err != nil {
+		return nil, err
+	}
+	n, err := readN(2)
+	if err != nil {
+		return nil, err
+	}
+	wrapped, err := readN(int(binary.BigEndian.Uint16(n)))
+	if err != nil {
+		return nil, err
+	}
+	rest, err := readN(4 + saltLen + noncePrefixLen)
+	if err != nil {
+		return nil, err
+	}
+	h := &Header{
+		KeyName:     string(name),
+		WrappedKey:  wrapped,
+		ChunkSize:   int(binary.BigEndian.Uint32(rest)),
+		Salt:        rest[4 : 4+saltLen],
+		NoncePrefix: rest[4+saltLen:],
+	}
+	if h.ChunkSize <= 0 || h.ChunkSize > maxChunkSize {
+		return nil, ErrInvalid
+	}
+	return h, nil
+}
+
+func nonce(prefix []byte, index uint32, last bool) []byte {
+	n := binary.BigEndian.AppendUint32(append([]byte(nil), prefix...), index)
+	if last {
+		return append(n, 1)
+	}
+	return append(n, 0)
+}
+
+// writer encrypts chunks.
+type writer struct {
+	w     io.Writer
+	aead  cipher.AEAD
+	h     *Header
+	aad   []byte
+	buf   []byte
+	index uint32
+	err   error
+}
+
+// NewWriter returns a writer that encrypts the data written to it, and
+// writes it to w. Close writes the last chunk; without it, the data can't
+// be decrypted.
+func (e *Envelope) NewWriter(ctx context.Context, w io.Writer) (io.WriteCloser, error) {
+	chunkSize := e.ChunkSize
+	if chunkSize == 0 {
+		chunkSize = DefaultChunkSize
+	}
+	if chunkSize < 0 || chunkSize > maxChunkSize {
+		return nil, fmt.Errorf("envelope: invalid chunk size %d", chunkSize)
+	}
+	k, err := e.encryptionKey(ctx)
+	if err != nil {
+		return nil, err
+	}
+	random := make([]byte, saltLen+noncePrefixLen)
+	if _, err := rand.Read(random); err != nil {
+		return nil, err
+	}
+	h := &Header{KeyName: e.KeyName, WrappedKey: k.wrapped, ChunkSize: chunkSize, Salt: random[:saltLen], NoncePrefix: random[saltLen:]}
+	aead, err := h.chunkAEAD(k.key)
+	if err != nil {
+		return nil, err
+	}
+	header, err := h.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(header); err != nil {
+		return nil, err
+	}
+	return &writer{w: w, aead: aead, h: h, aad: h.aad(), buf: make([]byte, 0, chunkSize)}, nil
+}
+
+func (w *writer) Write(p []byte) (int, error) {
+	if w.err != nil {
+		return 0, w.err
+	}
+	n := 0
+	for len(p) > 0 {
+		// A full chunk is written when more data follows, since the last
+		// chunk is sealed differently.
+		if len(w.buf) == w.h.ChunkSize {
+			if err := w.flush(false); err != nil {
+				return n, err
+			}
+		}
+		m := copy(w.buf[len(w.buf):w.h.ChunkSize], p)
+		w.buf = w.buf[:len(w.buf)+m]
+		p = p[m:]
+		n += m
+	}
+	return n, nil
+}
+
+func (w *writer) flush(last bool) error {
+	if w.index == math.MaxUint32 {
+		w.err = errors.New("envelope: too many chunks")
+		return w.err
+	}
+	out := w.aead.Seal(nil, nonce(w.h.NoncePrefix, w.index, last), w.buf, w.aad)
+	w.index++
+	w.buf = w.buf[:0]
+	if _, err := w.w.Write(out); err != nil {
+		w.err = err
+		return err
+	}
+	return nil
+}
+
+// Close writes the last chunk. It doesn't close the underlying writer.
+func (w *writer) Close() error {
+	if w.err != nil {
+		return w.err
+	}
+	err := w.flush(true)
+	if err == nil {
+		w.err = errors.New("envelope: write after close")
+	}
+	return err
+}
+
+// reader decrypts chunks.
+type reader struct {
+	r     *bufio.Reader
+	aead  cipher.AEAD
+	h     *Header
+	aad   []byte
+	chunk []byte
+	buf   []byte // decrypted data not read yet
+	index uint32
+	done  bool
+	err   error
+}
+
+// NewReader returns a reader that decrypts the data read from r. Reads
+// return ErrInvalid if the data was modified or truncated, possibly after
+// returning the plaintext of the chunks before.
+func (e *Envelope) NewReader(ctx context.Context, r io.Reader) (io.Reader, error) {
+	h, err := ReadHeader(r)
+	if err != nil {
+		return nil, err
+	}
+	key, err := e.decryptionKey(ctx, h)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := h.chunkAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	return &reader{
+		r:     bufio.NewReader(r),
+		aead:  aead,
+		h:     h,
+		aad:   h.aad(),
+		chunk: make([]byte, h.ChunkSize+aead.Overhead()),
+	}, nil
+}
+
+func (r *reader) Read(p []byte) (int, error) {
+	for len(r.buf) == 0 {
+		if r.err != nil {
+			return 0, r.err
+		}
+		if r.done {
+			return 0, io.EOF
+		}
+		r.err = r.next()
+	}
+	n := copy(p, r.buf)
+	r.buf = r.buf[n:]
+	return n, nil
+}
+
+// next decrypts the next chunk.
+func (r *reader) next() error {
+	n, err := io.ReadFull(r.r, r.chunk)
+	last := false
+	switch {
+	case err == io.EOF || err == io.ErrUnexpectedEOF:
+		last = true
+	case err != nil:
+		return err
+	default:
+		// A full chunk is the last one if nothing follows.
+		if _, err := r.r.Peek(1); err == io.EOF {
+			last = true
+		} else if err != nil {
+			return err
+		}
+	}
+	plaintext, err := r.aead.Open(r.chunk[:0], nonce(r.h.NoncePrefix, r.index, last), r.chunk[:n], r.aad)
+	if err != nil {
+		return ErrInvalid
+	}
+	r.index++
+	r.buf = plaintext
+	r.done = last
+	return nil
+}
+
+// Encrypt encrypts a message.
+func (e *Envelope) Encrypt(ctx context.Context, plaintext []byte) ([]byte, error) {
+	var b bytes.Buffer
+	w, err := e.NewWriter(ctx, &b)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(plaintext); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// Decrypt decrypts a message.
+func (e *Envelope) Decrypt(ctx context.Context, ciphertext []byte) ([]byte, error) {
+	r, err := e.NewReader(ctx, bytes.NewReader(ciphertext))
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(r)
+}
+
+// Rewrap copies encrypted data from r to w, rewrapping its data key with
+// the primary version of its KMS key if it was wrapped with another
+// version, after a rotation. The chunks are copied as is. It reports
+// whether the data key was rewrapped; if not, the data is copied
+// unchanged.
+func (e *Envelope) Rewrap(ctx context.Context, w io.Writer, r io.Reader) (bool, error) {
+	h, err := ReadHeader(r)
+	if err != nil {
+		return false, err
+	}
+	key, usedPrimary, err := e.unwrap(ctx, h.KeyName, h.WrappedKey)
+	if err != nil {
+		return false, err
+	}
+	if !usedPrimary {
+		// Wrap with the key of the data, which may not be e.KeyName.
+		wrapped, err := e.wrap(ctx, h.KeyName, key)
+		if err != nil {
+			return false, err
+		}
+		h.WrappedKey = wrapped
+	}
+	header, err := h.MarshalBinary()
+	if err != nil {
+		return false, err
+	}
+	if _, err := w.Write(header); err != nil {
+		return false, err
+	}
+	if _, err := io.Copy(w, r); err != nil {
+		return false, err
+	}
+	return !usedPrimary, nil
+}
@@ -0,0 +1,499 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package kmstest provides a fake Cloud KMS server for tests.
+//
+// The server keeps keys in memory, and implements the methods to manage
+// key versions, to encrypt and decrypt with symmetric keys, and to sign
+// with asymmetric keys.
+package kmstest
+
+import (
+	"context"
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"hash/crc32"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+
+	kms "cloud.google.com/go/kms/apiv1"
+	"cloud.google.com/go/kms/apiv1/kmspb"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// Server is a fake Cloud KMS server.
+type Server struct {
+	kmspb.UnimplementedKeyManagementServiceServer
+
+	// Addr is the address of the gRPC server.
+	Addr string
+
+	grpc *grpc.Server
+
+	mu    sync.Mutex
+	keys  map[string]*key
+	calls map[string]int
+}
+
+type key struct {
+	pb       *kmspb.CryptoKey
+	versions []*version
+}
+
+type version struct {
+	pb     *kmspb.CryptoKeyVersion
+	secret []byte        // AES-256 key of symmetric versions
+	signer crypto.Signer // private key of asymmetric signing versions
+}
+
+// NewServer starts a server on a local port.
+func NewServer() (*Server, error) {
+	lis, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		return nil, err
+	}
+	s := &Server{
+		Addr:  lis.Addr().String(),
+		grpc:  grpc.NewServer(),
+		keys:  map[string]*key{},
+		calls: map[string]int{},
+	}
+	kmspb.RegisterKeyManagementServiceServer(s.grpc, s)
+	go s.grpc.Serve(lis)
+	return s, nil
+}
+
+// Client returns a client of the server.
+func (s *Server) Client(ctx context.Context) (*kms.KeyManagementClient, error) {
+	return kms.NewKeyManagementClient(ctx,
+		option.WithEndpoint(s.Addr),
+		option.WithoutAuthentication(),
+		option.WithGRPCDialOption(grpc.WithTransportCredentials(insecure.NewCredentials())))
+}
+
+// Close stops the server.
+func (s *Server) Close() {
+	s.grpc.Stop()
+}
+
+// Calls returns the number of calls of a method, such as "Encrypt".
+func (s *Server) Calls(method string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.calls[method]
+}
+
+// CreateSymmetricKey adds a symmetric encryption key, with a first
+// version as primary.
+func (s *Server) CreateSymmetricKey(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	k := &key{pb: &kmspb.CryptoKey{
+		Name:       name,
+		Purpose:    kmspb.CryptoKey_ENCRYPT_DECRYPT,
+		CreateTime: timestamppb.Now(),
+		VersionTemplate: &kmspb.CryptoKeyVersionTemplate{
+			Algorithm: kmspb.CryptoKeyVersion_GOOGLE_SYMMETRIC_ENCRYPTION,
+		},
+	}}
+	s.keys[name] = k
+	v := s.addVersionLocked(k)
+	k.pb.Primary = v.pb
+}
+
+// CreateAsymmetricKey adds an asymmetric signing key with one of the
+// EC_SIGN_* or RSA_SIGN_* algorithms, and a first version. Versions sign
+// with local keys generated by the server.
+func (s *Server) CreateAsymmetricKey(name string, alg kmspb.CryptoKeyVersion_CryptoKeyVersionAlgorithm) error {
+	if _, ok := signingAlgorithms[alg]; !ok {
+		return fmt.Errorf("kmstest: unsupported algorithm %v", alg)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	k := &key{pb: &kmspb.CryptoKey{
+		Name:            name,
+		Purpose:         kmspb.CryptoKey_ASYMMETRIC_SIGN,
+		CreateTime:      timestamppb.Now(),
+		VersionTemplate: &kmspb.CryptoKeyVersionTemplate{Algorithm: alg},
+	}}
+	s.keys[name] = k
+	s.addVersionLocked(k)
+	return nil
+}
+
+func (s *Server) addVersionLocked(k *key) *version {
+	v := &version{pb: &kmspb.CryptoKeyVersion{
+		Name:            fmt.Sprintf("%s/cryptoKeyVersions/%d", k.pb.Name, len(k.versions)+1),
+		State:           kmspb.CryptoKeyVersion_ENABLED,
+		Algorithm:       k.pb.VersionTemplate.GetAlgorithm(),
+		ProtectionLevel: kmspb.ProtectionLevel_SOFTWARE,
+		CreateTime:      timestamppb.Now(),
+	}}
+	switch k.pb.Purpose {
+	case kmspb.CryptoKey_ENCRYPT_DECRYPT:
+		v.secret = make([]byte, 32)
+		rand.Read(v.secret)
+	case kmspb.CryptoKey_ASYMMETRIC_SIGN:
+		v.signer = generateSigner(v.pb.Algorithm)
+	}
+	k.versions = append(k.versions, v)
+	return v
+}
+
+// keyLocked returns a key, and counts a call of a method.
+func (s *Server) keyLocked(method, name string) (*key, error) {
+	s.calls[method]++
+	k, ok := s.keys[name]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "key %q not found", name)
+	}
+	return k, nil
+}
+
+// versionLocked returns a key version, and counts a call of a method.
+func (s *Server) versionLocked(method, name string) (*key, *version, error) {
+	i := strings.Index(name, "/cryptoKeyVersions/")
+	if i < 0 {
+		s.calls[method]++
+		return nil, nil, status.Errorf(codes.InvalidArgument, "invalid key version name %q", name)
+	}
+	k, err := s.keyLocked(method, name[:i])
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, v := range k.versions {
+		if v.pb.Name == name {
+			return k, v, nil
+		}
+	}
+	return nil, nil, status.Errorf(codes.NotFound, "key version %q not found", name)
+}
+
+// GetCryptoKey returns a key, with its primary version.
+func (s *Server) GetCryptoKey(ctx context.Context, req *kmspb.GetCryptoKeyRequest) (*kmspb.CryptoKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	k, err := s.keyLocked("GetCryptoKey", req.GetName())
+	if err != nil {
+		return nil, err
+	}
+	return proto.Clone(k.pb).(*kmspb.CryptoKey), nil
+}
+
+// CreateCryptoKeyVersion adds a version to a key. It doesn't become the
+// primary version.
+func (s *Server) CreateCryptoKeyVersion(ctx context.Context, req *kmspb.CreateCryptoKeyVersionRequest) (*kmspb.CryptoKeyVersion, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	k, err := s.keyLocked("CreateCryptoKeyVersion", req.GetParent())
+	if err != nil {
+		return nil, err
+	}
+	return proto.Clone(s.addVersionLocked(k).pb).(*kmspb.CryptoKeyVersion), nil
+}
+
+// GetCryptoKeyVersion returns a key version.
+func (s *Server) GetCryptoKeyVersion(ctx context.Context, req *kmspb.GetCryptoKeyVersionRequest) (*kmspb.CryptoKeyVersion, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, v, err := s.versionLocked("GetCryptoKeyVersion", req.GetName())
+	if err != nil {
+		return nil, err
+	}
+	return proto.Clone(v.pb).(*kmspb.CryptoKeyVersion), nil
+}
+
+// ListCryptoKeyVersions returns the versions of a key, in a single page.
+func (s *Server) ListCryptoKeyVersions(ctx context.Context, req *kmspb.ListCryptoKeyVersionsRequest) (*kmspb.ListCryptoKeyVersionsResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	k, err := s.keyLocked("ListCryptoKeyVersions", req.GetParent())
+	if err != nil {
+		return nil, err
+	}
+	resp := &kmspb.ListCryptoKeyVersionsResponse{}
+	for _, v := range k.versions {
+		resp.CryptoKeyVersions = append(resp.CryptoKeyVersions, proto.Clone(v.pb).(*kmspb.CryptoKeyVersion))
+	}
+	sort.Slice(resp.CryptoKeyVersions, func(i, j int) bool {
+		return resp.CryptoKeyVersions[i].Name < resp.CryptoKeyVersions[j].Name
+	})
+	resp.TotalSize = int32(len(resp.CryptoKeyVersions))
+	return resp, nil
+}
+
+// UpdateCryptoKeyVersion updates the state of a key version.
+func (s *Server) UpdateCryptoKeyVersion(ctx context.Context, req *kmspb.UpdateCryptoKeyVersionRequest) (*kmspb.CryptoKeyVersion, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, v, err := s.versionLocked("UpdateCryptoKeyVersion", req.GetCryptoKeyVersion().GetName())
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range req.GetUpdateMask().GetPaths() {
+		if path != "state" {
+			return nil, status.Errorf(codes.InvalidArgument, "can't update %q", path)
+		}
+		switch st := req.GetCryptoKeyVersion().GetState(); st {
+		case kmspb.CryptoKeyVersion_ENABLED, kmspb.CryptoKeyVersion_DISABLED:
+			v.pb.State = st
+		default:
+			return nil, status.Errorf(codes.InvalidArgument, "can't set state %v", st)
+		}
+	}
+	return proto.Clone(v.pb).(*kmspb.CryptoKeyVersion), nil
+}
+
+// UpdateCryptoKeyPrimaryVersion sets the primary version of a key.
+func (s *Server) UpdateCryptoKeyPrimaryVersion(ctx context.Context, req *kmspb.UpdateCryptoKeyPrimaryVersionRequest) (*kmspb.CryptoKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	k, err := s.keyLocked("UpdateCryptoKeyPrimaryVersion", req.GetName())
+	if err != nil {
+		return nil, err
+	}
+	name := fmt.Sprintf("%s/cryptoKeyVersions/%s", k.pb.Name, req.GetCryptoKeyVersionId())
+	for _, v := range k.versions {
+		if v.pb.Name == name {
+			if v.pb.State != kmspb.CryptoKeyVersion_ENABLED {
+				return nil, status.Errorf(codes.FailedPrecondition, "key version %q is not enabled", name)
+			}
+			k.pb.Primary = v.pb
+			return proto.Clone(k.pb).(*kmspb.CryptoKey), nil
+		}
+	}
+	return nil, status.Errorf(codes.NotFound, "key version %q not found", name)
+}
+
+func crc32c(data []byte) *wrapperspb.Int64Value {
+	return wrapperspb.Int64(int64(crc32.Checksum(data, crc32.MakeTable(crc32.Castagnoli))))
+}
+
+func newGCM(secret []byte) cipher.AEAD {
+	block, err := aes.NewCipher(secret)
+	if err != nil {
+		panic(err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		panic(err)
+	}
+	return aead
+}
+
+// Encrypt encrypts with the primary version of a key. The ciphertext
+// starts with the ID of the version.
+func (s *Server) Encrypt(ctx context.Context, req *kmspb.EncryptRequest) (*kmspb.EncryptResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	k, err := s.keyLocked("Encrypt", req.GetName())
+	if err != nil {
+		return nil, err
+	}
+	if k.pb.Purpose != kmspb.CryptoKey_ENCRYPT_DECRYPT {
+		return nil, status.Errorf(codes.FailedPrecondition, "key %q is not for encryption", k.pb.Name)
+	}
+	var primary *version
+	for _, v := range k.versions {
+		if v.pb.Name == k.pb.GetPrimary().GetName() {
+			primary = v
+		}
+	}
+	if primary.pb.State != kmspb.CryptoKeyVersion_ENABLED {
+		return nil, status.Errorf(codes.FailedPrecondition, "primary version %q is not enabled", primary.pb.Name)
+	}
+
+	id := primary.pb.Name[strings.LastIndex(primary.pb.Name, "/")+1:]
+	out := append([]byte{byte(len(id))}, id...)
+	nonce := make([]byte, 12)
+	rand.Read(nonce)
+	out = append(out, nonce...)
+	out = newGCM(primary.secret).Seal(out, nonce, req.GetPlaintext(), req.GetAdditionalAuthenticatedData())
+	return &kmspb.EncryptResponse{
+		Name:                    primary.pb.Name,
+		Ciphertext:              out,
+		CiphertextCrc32C:        crc32c(out),
+		VerifiedPlaintextCrc32C: req.GetPlaintextCrc32C() != nil && req.GetPlaintextCrc32C().GetValue() == crc32c(req.GetPlaintext()).GetValue(),
+		VerifiedAdditionalAuthenticatedDataCrc32C: req.GetAdditionalAuthenticatedDataCrc32C() != nil &&
+			req.GetAdditionalAuthenticatedDataCrc32C().GetValue() == crc32c(req.GetAdditionalAuthenticatedData()).GetValue(),
+		ProtectionLevel: primary.pb.ProtectionLevel,
+	}, nil
+}
+
+// Decrypt decrypts with the version of a key that encrypted the
+// ciphertext.
+func (s *Server) Decrypt(ctx context.Context, req *kmspb.DecryptRequest) (*kmspb.DecryptResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	k, err := s.keyLocked("Decrypt", req.GetName())
+	if err != nil {
+		return nil, err
+	}
+	in := req.GetCiphertext()
+	if len(in) < 1 || len(in) < 1+int(in[0])+12 {
+		return nil, status.Error(codes.InvalidArgument, "invalid ciphertext")
+	}
+	name := fmt.Sprintf("%s/cryptoKeyVersions/%s", k.pb.Name, in[1:1+in[0]])
+	var v *version
+	for _, kv := range k.versions {
+		if kv.pb.Name == name {
+			v = kv
+		}
+	}
+	if v == nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid ciphertext")
+	}
+	if v.pb.State != kmspb.CryptoKeyVersion_ENABLED {
+		return nil, status.Errorf(codes.FailedPrecondition, "key version %q is not enabled", name)
+	}
+	in = in[1+in[0]:]
+	plaintext, err := newGCM(v.secret).Open(nil, in[:12], in[12:], req.GetAdditionalAuthenticatedData())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid ciphertext")
+	}
+	return &kmspb.DecryptResponse{
+		Plaintext:       plaintext,
+		PlaintextCrc32C: crc32c(plaintext),
+		UsedPrimary:     v.pb.Name == k.pb.GetPrimary().GetName(),
+		ProtectionLevel: v.pb.ProtectionLevel,
+	}, nil
+}
+
+type signingAlgorithm struct {
+	hash    crypto.Hash
+	curve   elliptic.Curve // for EC keys
+	rsaBits int            // for RSA keys
+	pss     bool
+}
+
+var signingAlgorithms = map[kmspb.CryptoKeyVersion_CryptoKeyVersionAlgorithm]signingAlgorithm{
+	kmspb.CryptoKeyVersion_EC_SIGN_P256_SHA256:        {hash: crypto.SHA256, curve: elliptic.P256()},
+	kmspb.CryptoKeyVersion_EC_SIGN_P384_SHA384:        {hash: crypto.SHA384, curve: elliptic.P384()},
+	kmspb.CryptoKeyVersion_RSA_SIGN_PKCS1_2048_SHA256: {hash: crypto.SHA256, rsaBits: 2048},
+	kmspb.CryptoKeyVersion_RSA_SIGN_PKCS1_3072_SHA256: {hash: crypto.SHA256, rsaBits: 3072},
+	kmspb.CryptoKeyVersion_RSA_SIGN_PKCS1_4096_SHA256: {hash: crypto.SHA256, rsaBits: 4096},
+	kmspb.CryptoKeyVersion_RSA_SIGN_PKCS1_4096_SHA512: {hash: crypto.SHA512, rsaBits: 4096},
+	kmspb.CryptoKeyVersion_RSA_SIGN_PSS_2048_SHA256:   {hash: crypto.SHA256, rsaBits: 2048, pss: true},
+	kmspb.CryptoKeyVersion_RSA_SIGN_PSS_3072_SHA256:   {hash: crypto.SHA256, rsaBits: 3072, pss: true},
+	kmspb.CryptoKeyVersion_RSA_SIGN_PSS_4096_SHA256:   {hash: crypto.SHA256, rsaBits: 4096, pss: true},
+	kmspb.CryptoKeyVersion_RSA_SIGN_PSS_4096_SHA512:   {hash: crypto.SHA512, rsaBits: 4096, pss: true},
+}
+
+func generateSigner(alg kmspb.CryptoKeyVersion_CryptoKeyVersionAlgorithm) crypto.Signer {
+	a := signingAlgorithms[alg]
+	var (
+		k   crypto.Signer
+		err error
+	)
+	if a.curve != nil {
+		k, err = ecdsa.GenerateKey(a.curve, rand.Reader)
+	} else {
+		k, err = rsa.GenerateKey(rand.Reader, a.rsaBits)
+	}
+	if err != nil {
+		panic(err)
+	}
+	return k
+}
+
+// GetPublicKey returns the public key of an asymmetric key version, in PEM.
+func (s *Server) GetPublicKey(ctx context.Context, req *kmspb.GetPublicKeyRequest) (*kmspb.PublicKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, v, err := s.versionLocked("GetPublicKey", req.GetName())
+	if err != nil {
+		return nil, err
+	}
+	if v.signer == nil {
+		return nil, status.Errorf(codes.FailedPrecondition, "key version %q is not asymmetric", v.pb.Name)
+	}
+	if v.pb.State != kmspb.CryptoKeyVersion_ENABLED {
+		return nil, status.Errorf(codes.FailedPrecondition, "key version %q is not enabled", v.pb.Name)
+	}
+	der, err := x509.MarshalPKIXPublicKey(v.signer.Public())
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	p := string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+	return &kmspb.PublicKey{
+		Name:            v.pb.Name,
+		Pem:             p,
+		PemCrc32C:       crc32c([]byte(p)),
+		Algorithm:       v.pb.Algorithm,
+		ProtectionLevel: v.pb.ProtectionLevel,
+	}, nil
+}
+
+// AsymmetricSign signs a digest with an asymmetric key version. EC
+// signatures are DER-encoded, and RSA-PSS signatures use a salt as long as
+// the digest, like Cloud KMS.
+func (s *Server) AsymmetricSign(ctx context.Context, req *kmspb.AsymmetricSignRequest) (*kmspb.AsymmetricSignResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, v, err := s.versionLocked("AsymmetricSign", req.GetName())
+	if err != nil {
+		return nil, err
+	}
+	if v.signer == nil {
+		return nil, status.Errorf(codes.FailedPrecondition, "key version %q is not for signing", v.pb.Name)
+	}
+	if v.pb.State != kmspb.CryptoKeyVersion_ENABLED {
+		return nil, status.Errorf(codes.FailedPrecondition, "key version %q is not enabled", v.pb.Name)
+	}
+	a := signingAlgorithms[v.pb.Algorithm]
+	var digest []byte
+	switch a.hash {
+	case crypto.SHA256:
+		digest = req.GetDigest().GetSha256()
+	case crypto.SHA384:
+		digest = req.GetDigest().GetSha384()
+	case crypto.SHA512:
+		digest = req.GetDigest().GetSha512()
+	}
+	if len(digest) != a.hash.Size() {
+		return nil, status.Errorf(codes.InvalidArgument, "key version %q requires a %v digest", v.pb.Name, a.hash)
+	}
+	var opts crypto.SignerOpts = a.hash
+	if a.pss {
+		opts = &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash, Hash: a.hash}
+	}
+	sig, err := v.signer.Sign(rand.Reader, digest, opts)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return &kmspb.AsymmetricSignResponse{
+		Name:                 v.pb.Name,
+		Signature:            sig,
+		SignatureCrc32C:      crc32c(sig),
+		VerifiedDigestCrc32C: req.GetDigestCrc32C() != nil && req.GetDigestCrc32C().GetValue() == crc32c(digest).GetValue(),
+		ProtectionLevel:      v.pb.ProtectionLevel,
+	}, nil
+}
@@ -0,0 +1,147 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package signer
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/kms/apiv1/kmspb"
+	"google.golang.org/api/iterator"
+)
+
+// JWK is a public JSON Web Key.
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	// EC keys.
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+	// RSA keys.
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+}
+
+// JWKSet is a JSON Web Key Set.
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+func (p *publicKey) jwk(kid string) JWK {
+	k := JWK{Kid: kid, Alg: p.alg.jwt, Use: "sig"}
+	b64 := base64.RawURLEncoding.EncodeToString
+	switch key := p.key.(type) {
+	case *ecdsa.PublicKey:
+		k.Kty = "EC"
+		k.Crv = key.Curve.Params().Name
+		// The uncompressed point is 4, x and y.
+		ek, _ := key.ECDH()
+		b := ek.Bytes()[1:]
+		k.X, k.Y = b64(b[:len(b)/2]), b64(b[len(b)/2:])
+	case *rsa.PublicKey:
+		k.Kty = "RSA"
+		k.N = b64(key.N.Bytes())
+		k.E = b64(big.NewInt(int64(key.E)).Bytes())
+	}
+	return k
+}
+
+// JWKS returns the public keys of the enabled versions of v.KeyName, with
+// their version names as key IDs.
+func (v *Verifier) JWKS(ctx context.Context) (*JWKSet, error) {
+	if v.KeyName == "" {
+		return nil, errors.New("signer: JWKS requires a KeyName")
+	}
+	set := &JWKSet{Keys: []JWK{}}
+	it := v.Client.ListCryptoKeyVersions(ctx, &kmspb.ListCryptoKeyVersionsRequest{
+		Parent: v.KeyName,
+		Filter: "state=ENABLED",
+	})
+	for {
+		kv, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("signer: listing key versions: %w", err)
+		}
+		if kv.State != kmspb.CryptoKeyVersion_ENABLED {
+			continue
+		}
+		pub, err := v.publicKey(ctx, kv.Name)
+		if err != nil {
+			return nil, err
+		}
+		set.Keys = append(set.Keys, pub.jwk(kv.Name))
+	}
+	return set, nil
+}
+
+// JWKSHandler returns a handler that serves the JWKS of v. The key set is
+// cached for maxAge, which is also sent in Cache-Control so that clients
+// cache it too. If Cloud KMS is unavailable, the last key set is served.
+func (v *Verifier) JWKSHandler(maxAge time.Duration) http.Handler {
+	return &jwksHandler{v: v, maxAge: maxAge}
+}
+
+type jwksHandler struct {
+	v      *Verifier
+	maxAge time.Duration
+
+	mu      sync.Mutex
+	body    []byte
+	expires time.Time
+}
+
+func (h *jwksHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if now := h.v.now(); h.body == nil || !now.Before(h.expires) {
+		set, err := h.v.JWKS(r.Context())
+		if err == nil {
+			h.body, err = json.Marshal(set)
+		}
+		switch {
+		case err == nil:
+			h.expires = now.Add(h.maxAge)
+		case h.body != nil:
+			log.Printf("JWKS: serving the last key set: %v", err)
+		default:
+			log.Printf("JWKS: %v", err)
+			http.Error(w, "key set unavailable", http.StatusServiceUnavailable)
+			return
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(h.maxAge.Seconds())))
+	w.Write(h.body)
+}
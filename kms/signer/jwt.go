@@ -0,0 +1,141 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package signer
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+)
+
+// ErrInvalidToken is returned for malformed, expired or badly signed JWTs.
+var ErrInvalidToken = errors.New("signer: invalid token")
+
+// leeway is the clock skew allowed when checking the times of JWTs.
+const leeway = 30 * time.Second
+
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Typ string `json:"typ,omitempty"`
+	Kid string `json:"kid"`
+}
+
+type ecdsaSignature struct{ R, S *big.Int }
+
+// SignJWT returns a JWT with the claims, signed by the key version. The
+// "kid" header is the name of the version.
+func (s *Signer) SignJWT(ctx context.Context, claims map[string]interface{}) (string, error) {
+	header, err := json.Marshal(jwtHeader{Alg: s.pub.alg.jwt, Typ: "JWT", Kid: s.name})
+	if err != nil {
+		return "", err
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", fmt.Errorf("signer: encoding claims: %w", err)
+	}
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+
+	h := s.pub.alg.hash.New()
+	h.Write([]byte(signed))
+	sig, err := s.SignContext(ctx, h.Sum(nil), s.pub.alg.opts())
+	if err != nil {
+		return "", err
+	}
+	if key, ok := s.pub.key.(*ecdsa.PublicKey); ok {
+		// JWS signatures are r and s, each as long as the curve order.
+		var es ecdsaSignature
+		if _, err := asn1.Unmarshal(sig, &es); err != nil {
+			return "", fmt.Errorf("signer: invalid EC signature: %w", err)
+		}
+		n := (key.Curve.Params().BitSize + 7) / 8
+		sig = make([]byte, 2*n)
+		es.R.FillBytes(sig[:n])
+		es.S.FillBytes(sig[n:])
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig), nil
+}
+
+// VerifyJWT verifies a JWT signed by a version of v.KeyName, and returns
+// its claims. It checks the "exp" and "nbf" claims if present; the caller
+// checks the others, such as "aud".
+func (v *Verifier) VerifyJWT(ctx context.Context, token string) (map[string]interface{}, error) {
+	if v.KeyName == "" {
+		return nil, errors.New("signer: VerifyJWT requires a KeyName")
+	}
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrInvalidToken
+	}
+	var header jwtHeader
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, ErrInvalidToken
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	pub, err := v.publicKey(ctx, header.Kid)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+	// The algorithm is set by the key, never by the token.
+	if header.Alg != pub.alg.jwt {
+		return nil, fmt.Errorf("%w: algorithm %q, want %q", ErrInvalidToken, header.Alg, pub.alg.jwt)
+	}
+	if key, ok := pub.key.(*ecdsa.PublicKey); ok {
+		n := (key.Curve.Params().BitSize + 7) / 8
+		if len(sig) != 2*n {
+			return nil, ErrInvalidToken
+		}
+		sig, err = asn1.Marshal(ecdsaSignature{
+			R: new(big.Int).SetBytes(sig[:n]),
+			S: new(big.Int).SetBytes(sig[n:]),
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err := pub.verify([]byte(parts[0]+"."+parts[1]), sig); err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	var claims map[string]interface{}
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, ErrInvalidToken
+	}
+	now := v.now()
+	if exp, ok := claims["exp"].(float64); ok && now.After(time.Unix(int64(exp), 0).Add(leeway)) {
+		return nil, fmt.Errorf("%w: expired", ErrInvalidToken)
+	}
+	if nbf, ok := claims["nbf"].(float64); ok && now.Before(time.Unix(int64(nbf), 0).Add(-leeway)) {
+		return nil, fmt.Errorf("%w: not valid yet", ErrInvalidToken)
+	}
+	return claims, nil
+}
+
+func decodeSegment(s string, v interface{}) error {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
@@ -0,0 +1,188 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package signer signs with Cloud KMS asymmetric keys, and verifies the
+// signatures locally.
+//
+// Signer implements crypto.Signer with a key version, so that it can sign
+// X.509 certificate requests with crypto/x509, as well as JWTs. Verifier
+// verifies signatures and JWTs with the public keys of the versions, which
+// it caches, and publishes the enabled versions of a key as a JSON Web Key
+// Set.
+package signer
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+
+	kms "cloud.google.com/go/kms/apiv1"
+	"cloud.google.com/go/kms/apiv1/kmspb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// ErrCorrupted is returned when an integrity check of a request or response
+// to Cloud KMS fails.
+var ErrCorrupted = errors.New("signer: request or response corrupted in transit")
+
+// algorithm describes a signing algorithm of Cloud KMS.
+type algorithm struct {
+	hash crypto.Hash
+	pss  bool
+	jwt  string // JWS "alg"
+}
+
+var algorithms = map[kmspb.CryptoKeyVersion_CryptoKeyVersionAlgorithm]algorithm{
+	kmspb.CryptoKeyVersion_EC_SIGN_P256_SHA256:        {hash: crypto.SHA256, jwt: "ES256"},
+	kmspb.CryptoKeyVersion_EC_SIGN_P384_SHA384:        {hash: crypto.SHA384, jwt: "ES384"},
+	kmspb.CryptoKeyVersion_RSA_SIGN_PKCS1_2048_SHA256: {hash: crypto.SHA256, jwt: "RS256"},
+	kmspb.CryptoKeyVersion_RSA_SIGN_PKCS1_3072_SHA256: {hash: crypto.SHA256, jwt: "RS256"},
+	kmspb.CryptoKeyVersion_RSA_SIGN_PKCS1_4096_SHA256: {hash: crypto.SHA256, jwt: "RS256"},
+	kmspb.CryptoKeyVersion_RSA_SIGN_PKCS1_4096_SHA512: {hash: crypto.SHA512, jwt: "RS512"},
+	kmspb.CryptoKeyVersion_RSA_SIGN_PSS_2048_SHA256:   {hash: crypto.SHA256, pss: true, jwt: "PS256"},
+	kmspb.CryptoKeyVersion_RSA_SIGN_PSS_3072_SHA256:   {hash: crypto.SHA256, pss: true, jwt: "PS256"},
+	kmspb.CryptoKeyVersion_RSA_SIGN_PSS_4096_SHA256:   {hash: crypto.SHA256, pss: true, jwt: "PS256"},
+	kmspb.CryptoKeyVersion_RSA_SIGN_PSS_4096_SHA512:   {hash: crypto.SHA512, pss: true, jwt: "PS512"},
+}
+
+// opts returns the signer options of the algorithm.
+func (a algorithm) opts() crypto.SignerOpts {
+	if a.pss {
+		return &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash, Hash: a.hash}
+	}
+	return a.hash
+}
+
+// publicKey is the public key of a key version.
+type publicKey struct {
+	key crypto.PublicKey
+	alg algorithm
+}
+
+func crc32c(data []byte) int64 {
+	return int64(crc32.Checksum(data, crc32.MakeTable(crc32.Castagnoli)))
+}
+
+// getPublicKey fetches and parses the public key of a key version.
+func getPublicKey(ctx context.Context, client *kms.KeyManagementClient, name string) (*publicKey, error) {
+	resp, err := client.GetPublicKey(ctx, &kmspb.GetPublicKeyRequest{Name: name})
+	if err != nil {
+		return nil, fmt.Errorf("signer: getting public key: %w", err)
+	}
+	if resp.Name != name || crc32c([]byte(resp.Pem)) != resp.PemCrc32C.GetValue() {
+		return nil, ErrCorrupted
+	}
+	alg, ok := algorithms[resp.Algorithm]
+	if !ok {
+		return nil, fmt.Errorf("signer: unsupported algorithm %v", resp.Algorithm)
+	}
+	block, _ := pem.Decode([]byte(resp.Pem))
+	if block == nil {
+		return nil, errors.New("signer: invalid public key")
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("signer: parsing public key: %w", err)
+	}
+	switch key.(type) {
+	case *ecdsa.PublicKey, *rsa.PublicKey:
+	default:
+		return nil, fmt.Errorf("signer: unsupported public key %T", key)
+	}
+	return &publicKey{key: key, alg: alg}, nil
+}
+
+// Signer signs with a Cloud KMS key version. It implements crypto.Signer.
+type Signer struct {
+	client *kms.KeyManagementClient
+	name   string
+	pub    *publicKey
+}
+
+// New returns a signer for a key version, such as
+// projects/PROJECT/locations/LOCATION/keyRings/RING/cryptoKeys/KEY/cryptoKeyVersions/1.
+// It fetches the public key of the version.
+func New(ctx context.Context, client *kms.KeyManagementClient, name string) (*Signer, error) {
+	pub, err := getPublicKey(ctx, client, name)
+	if err != nil {
+		return nil, err
+	}
+	return &Signer{client: client, name: name, pub: pub}, nil
+}
+
+// Name returns the name of the key version.
+func (s *Signer) Name() string { return s.name }
+
+// Public returns the public key of the key version.
+func (s *Signer) Public() crypto.PublicKey { return s.pub.key }
+
+// HashFunc returns the hash function of the key version, which is the only
+// one it signs with.
+func (s *Signer) HashFunc() crypto.Hash { return s.pub.alg.hash }
+
+// Sign signs a digest, like SignContext with a background context.
+func (s *Signer) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	return s.SignContext(context.Background(), digest, opts)
+}
+
+// SignContext signs a digest computed with the hash function of the key
+// version. The padding of RSA signatures is set by the algorithm of the
+// version: opts must be *rsa.PSSOptions for RSA_SIGN_PSS versions, with a
+// salt as long as the digest, and not for the others. EC signatures are
+// DER-encoded, like those of ecdsa.PrivateKey.
+func (s *Signer) SignContext(ctx context.Context, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	alg := s.pub.alg
+	if opts.HashFunc() != alg.hash {
+		return nil, fmt.Errorf("signer: key version %s signs %v digests, not %v", s.name, alg.hash, opts.HashFunc())
+	}
+	pss, isPSS := opts.(*rsa.PSSOptions)
+	if isPSS != alg.pss {
+		return nil, fmt.Errorf("signer: PSS options don't match the padding of key version %s", s.name)
+	}
+	if isPSS && pss.SaltLength != rsa.PSSSaltLengthEqualsHash && pss.SaltLength != alg.hash.Size() {
+		return nil, fmt.Errorf("signer: key version %s requires a salt as long as the digest", s.name)
+	}
+	if len(digest) != alg.hash.Size() {
+		return nil, fmt.Errorf("signer: invalid %v digest length %d", alg.hash, len(digest))
+	}
+
+	d := &kmspb.Digest{}
+	switch alg.hash {
+	case crypto.SHA256:
+		d.Digest = &kmspb.Digest_Sha256{Sha256: digest}
+	case crypto.SHA384:
+		d.Digest = &kmspb.Digest_Sha384{Sha384: digest}
+	case crypto.SHA512:
+		d.Digest = &kmspb.Digest_Sha512{Sha512: digest}
+	}
+	resp, err := s.client.AsymmetricSign(ctx, &kmspb.AsymmetricSignRequest{
+		Name:         s.name,
+		Digest:       d,
+		DigestCrc32C: wrapperspb.Int64(crc32c(digest)),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("signer: signing: %w", err)
+	}
+	if !resp.VerifiedDigestCrc32C || resp.Name != s.name || crc32c(resp.Signature) != resp.SignatureCrc32C.GetValue() {
+		return nil, ErrCorrupted
+	}
+	return resp.Signature, nil
+}
@@ -0,0 +1,288 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package signer
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	kms "cloud.google.com/go/kms/apiv1"
+	"cloud.google.com/go/kms/apiv1/kmspb"
+	"github.com/GoogleCloudPlatform/golang-samples/kms/kmstest"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+)
+
+const keyName = "projects/p/locations/global/keyRings/r/cryptoKeys/"
+
+func setup(t *testing.T) (*kms.KeyManagementClient, *kmstest.Server) {
+	t.Helper()
+	srv, err := kmstest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(srv.Close)
+	client, err := srv.Client(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return client, srv
+}
+
+func newSigner(t *testing.T, client *kms.KeyManagementClient, srv *kmstest.Server, key string, alg kmspb.CryptoKeyVersion_CryptoKeyVersionAlgorithm) *Signer {
+	t.Helper()
+	if err := srv.CreateAsymmetricKey(keyName+key, alg); err != nil {
+		t.Fatal(err)
+	}
+	s, err := New(context.Background(), client, keyName+key+"/cryptoKeyVersions/1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestSign(t *testing.T) {
+	client, srv := setup(t)
+	ctx := context.Background()
+	v := &Verifier{Client: client}
+	message := []byte("my message")
+	for _, alg := range []kmspb.CryptoKeyVersion_CryptoKeyVersionAlgorithm{
+		kmspb.CryptoKeyVersion_EC_SIGN_P256_SHA256,
+		kmspb.CryptoKeyVersion_EC_SIGN_P384_SHA384,
+		kmspb.CryptoKeyVersion_RSA_SIGN_PKCS1_2048_SHA256,
+		kmspb.CryptoKeyVersion_RSA_SIGN_PSS_2048_SHA256,
+	} {
+		var s crypto.Signer = newSigner(t, client, srv, alg.String(), alg)
+		h := s.(*Signer).HashFunc()
+		d := h.New()
+		d.Write(message)
+		digest := d.Sum(nil)
+		sig, err := s.Sign(rand.Reader, digest, s.(*Signer).pub.alg.opts())
+		if err != nil {
+			t.Errorf("%v: Sign: %v", alg, err)
+			continue
+		}
+
+		// The signature verifies with the standard library.
+		switch pub := s.Public().(type) {
+		case *ecdsa.PublicKey:
+			if !ecdsa.VerifyASN1(pub, digest, sig) {
+				err = errors.New("invalid signature")
+			}
+		case *rsa.PublicKey:
+			if alg == kmspb.CryptoKeyVersion_RSA_SIGN_PSS_2048_SHA256 {
+				err = rsa.VerifyPSS(pub, h, digest, sig, nil)
+			} else {
+				err = rsa.VerifyPKCS1v15(pub, h, digest, sig)
+			}
+		}
+		if err != nil {
+			t.Errorf("%v: verifying with the public key: %v", alg, err)
+		}
+		name := s.(*Signer).Name()
+		if err := v.Verify(ctx, name, message, sig); err != nil {
+			t.Errorf("%v: Verify: %v", alg, err)
+		}
+		if err := v.Verify(ctx, name, []byte("other message"), sig); !errors.Is(err, ErrInvalidSignature) {
+			t.Errorf("%v: Verify(other message) = %v, want ErrInvalidSignature", alg, err)
+		}
+	}
+
+	s := newSigner(t, client, srv, "pkcs1", kmspb.CryptoKeyVersion_RSA_SIGN_PKCS1_2048_SHA256)
+	digest := sha256.Sum256(message)
+	for name, opts := range map[string]crypto.SignerOpts{
+		"other hash": crypto.SHA512,
+		"PSS":        &rsa.PSSOptions{Hash: crypto.SHA256},
+	} {
+		if _, err := s.Sign(rand.Reader, digest[:], opts); err == nil {
+			t.Errorf("Sign with %s succeeded, want error", name)
+		}
+	}
+}
+
+func TestCertificateRequest(t *testing.T) {
+	client, srv := setup(t)
+	s := newSigner(t, client, srv, "csr", kmspb.CryptoKeyVersion_EC_SIGN_P256_SHA256)
+	der, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject:  pkix.Name{CommonName: "example.com"},
+		DNSNames: []string{"example.com", "www.example.com"},
+	}, s)
+	if err != nil {
+		t.Fatalf("CreateCertificateRequest: %v", err)
+	}
+	csr, err := x509.ParseCertificateRequest(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := csr.CheckSignature(); err != nil {
+		t.Errorf("CheckSignature: %v", err)
+	}
+	if !csr.PublicKey.(*ecdsa.PublicKey).Equal(s.Public()) {
+		t.Error("the public key of the request is not the key of the version")
+	}
+}
+
+func TestJWT(t *testing.T) {
+	client, srv := setup(t)
+	ctx := context.Background()
+	now := time.Now()
+	es := newSigner(t, client, srv, "es", kmspb.CryptoKeyVersion_EC_SIGN_P256_SHA256)
+	ps := newSigner(t, client, srv, "ps", kmspb.CryptoKeyVersion_RSA_SIGN_PSS_2048_SHA256)
+	claims := func(exp time.Time) map[string]interface{} {
+		return map[string]interface{}{"sub": "alice", "exp": exp.Unix()}
+	}
+
+	for _, s := range []*Signer{es, ps} {
+		v := &Verifier{Client: client, KeyName: strings.Split(s.Name(), "/cryptoKeyVersions/")[0], Now: func() time.Time { return now }}
+		token, err := s.SignJWT(ctx, claims(now.Add(time.Hour)))
+		if err != nil {
+			t.Fatalf("SignJWT: %v", err)
+		}
+		got, err := v.VerifyJWT(ctx, token)
+		if err != nil {
+			t.Fatalf("VerifyJWT: %v", err)
+		}
+		if got["sub"] != "alice" {
+			t.Errorf("claims %v, want sub alice", got)
+		}
+
+		expired, err := s.SignJWT(ctx, claims(now.Add(-time.Hour)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		parts := strings.Split(token, ".")
+		header, _ := base64.RawURLEncoding.DecodeString(parts[0])
+		noneAlg := base64.RawURLEncoding.EncodeToString([]byte(strings.Replace(string(header), `"alg":"`+s.pub.alg.jwt+`"`, `"alg":"none"`, 1)))
+		otherClaims := base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"eve"}`))
+		for name, token := range map[string]string{
+			"expired":      expired,
+			"other claims": parts[0] + "." + otherClaims + "." + parts[2],
+			"alg none":     noneAlg + "." + parts[1] + ".",
+			"malformed":    "a.b",
+		} {
+			if _, err := v.VerifyJWT(ctx, token); !errors.Is(err, ErrInvalidToken) {
+				t.Errorf("%s %s: VerifyJWT = %v, want ErrInvalidToken", s.pub.alg.jwt, name, err)
+			}
+		}
+	}
+
+	// Tokens of other keys are rejected.
+	v := &Verifier{Client: client, KeyName: keyName + "es"}
+	token, err := ps.SignJWT(ctx, claims(now.Add(time.Hour)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := v.VerifyJWT(ctx, token); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("VerifyJWT(other key) = %v, want ErrInvalidToken", err)
+	}
+
+	// Public keys are fetched once per version.
+	token, err = es.SignJWT(ctx, claims(now.Add(time.Hour)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	before := srv.Calls("GetPublicKey")
+	for i := 0; i < 3; i++ {
+		if _, err := v.VerifyJWT(ctx, token); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := srv.Calls("GetPublicKey") - before; n != 1 {
+		t.Errorf("%d GetPublicKey calls, want 1", n)
+	}
+}
+
+func TestJWKS(t *testing.T) {
+	client, srv := setup(t)
+	ctx := context.Background()
+	s := newSigner(t, client, srv, "k", kmspb.CryptoKeyVersion_EC_SIGN_P256_SHA256)
+	for i := 0; i < 2; i++ {
+		if _, err := client.CreateCryptoKeyVersion(ctx, &kmspb.CreateCryptoKeyVersionRequest{Parent: keyName + "k"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := client.UpdateCryptoKeyVersion(ctx, &kmspb.UpdateCryptoKeyVersionRequest{
+		CryptoKeyVersion: &kmspb.CryptoKeyVersion{Name: keyName + "k/cryptoKeyVersions/2", State: kmspb.CryptoKeyVersion_DISABLED},
+		UpdateMask:       &fieldmaskpb.FieldMask{Paths: []string{"state"}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	v := &Verifier{Client: client, KeyName: keyName + "k", Now: func() time.Time { return now }}
+	ts := httptest.NewServer(v.JWKSHandler(5 * time.Minute))
+	defer ts.Close()
+	get := func() *JWKSet {
+		t.Helper()
+		resp, err := http.Get(ts.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK || resp.Header.Get("Cache-Control") != "public, max-age=300" {
+			t.Fatalf("status %d, Cache-Control %q", resp.StatusCode, resp.Header.Get("Cache-Control"))
+		}
+		var set JWKSet
+		if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+			t.Fatal(err)
+		}
+		return &set
+	}
+
+	set := get()
+	var kids []string
+	for _, k := range set.Keys {
+		kids = append(kids, strings.TrimPrefix(k.Kid, keyName+"k/cryptoKeyVersions/"))
+	}
+	if strings.Join(kids, ",") != "1,3" {
+		t.Fatalf("key IDs %v, want versions 1 and 3", kids)
+	}
+	k := set.Keys[0]
+	decode := func(s string) *big.Int {
+		b, _ := base64.RawURLEncoding.DecodeString(s)
+		return new(big.Int).SetBytes(b)
+	}
+	pub := s.Public().(*ecdsa.PublicKey)
+	if k.Kty != "EC" || k.Crv != "P-256" || k.Alg != "ES256" || k.Use != "sig" ||
+		len(k.X) != 43 || len(k.Y) != 43 || decode(k.X).Cmp(pub.X) != 0 || decode(k.Y).Cmp(pub.Y) != 0 {
+		t.Errorf("JWK %+v doesn't match the public key", k)
+	}
+
+	// The key set is cached.
+	get()
+	if n := srv.Calls("ListCryptoKeyVersions"); n != 1 {
+		t.Errorf("%d ListCryptoKeyVersions calls, want 1", n)
+	}
+	now = now.Add(10 * time.Minute)
+	get()
+	if n := srv.Calls("ListCryptoKeyVersions"); n != 2 {
+		t.Errorf("%d ListCryptoKeyVersions calls after expiry, want 2", n)
+	}
+}
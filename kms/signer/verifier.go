@@ -0,0 +1,124 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package signer
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	kms "cloud.google.com/go/kms/apiv1"
+)
+
+// ErrInvalidSignature is returned when a signature doesn't verify.
+var ErrInvalidSignature = errors.New("signer: invalid signature")
+
+// Verifier verifies signatures of Cloud KMS key versions locally. The
+// public key of a version never changes, so it is fetched once and cached.
+// Versions disabled after their key is cached still verify; JWKS only
+// lists enabled versions.
+type Verifier struct {
+	Client *kms.KeyManagementClient
+	// KeyName, if set, is the only key whose versions are accepted:
+	// projects/PROJECT/locations/LOCATION/keyRings/RING/cryptoKeys/KEY.
+	// It is required to verify JWTs and to list the keys.
+	KeyName string
+	// Now returns the current time, to check the expiry of JWTs. It
+	// defaults to time.Now.
+	Now func() time.Time
+
+	mu   sync.Mutex
+	keys map[string]*publicKey // by version name
+}
+
+func (v *Verifier) now() time.Time {
+	if v.Now != nil {
+		return v.Now()
+	}
+	return time.Now()
+}
+
+// publicKey returns the public key of a version, from the cache if
+// possible.
+func (v *Verifier) publicKey(ctx context.Context, name string) (*publicKey, error) {
+	if v.KeyName != "" && !strings.HasPrefix(name, v.KeyName+"/cryptoKeyVersions/") {
+		return nil, fmt.Errorf("signer: key version %s is not a version of %s", name, v.KeyName)
+	}
+	v.mu.Lock()
+	pub, ok := v.keys[name]
+	v.mu.Unlock()
+	if ok {
+		return pub, nil
+	}
+	pub, err := getPublicKey(ctx, v.Client, name)
+	if err != nil {
+		return nil, err
+	}
+	v.mu.Lock()
+	if v.keys == nil {
+		v.keys = map[string]*publicKey{}
+	}
+	v.keys[name] = pub
+	v.mu.Unlock()
+	return pub, nil
+}
+
+// PublicKey returns the public key of a key version.
+func (v *Verifier) PublicKey(ctx context.Context, name string) (crypto.PublicKey, error) {
+	pub, err := v.publicKey(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	return pub.key, nil
+}
+
+// Verify verifies the signature of a message by a key version. EC
+// signatures are DER-encoded, as returned by Cloud KMS.
+func (v *Verifier) Verify(ctx context.Context, name string, message, sig []byte) error {
+	pub, err := v.publicKey(ctx, name)
+	if err != nil {
+		return err
+	}
+	return pub.verify(message, sig)
+}
+
+func (p *publicKey) verify(message, sig []byte) error {
+	h := p.alg.hash.New()
+	h.Write(message)
+	digest := h.Sum(nil)
+	switch key := p.key.(type) {
+	case *ecdsa.PublicKey:
+		if !ecdsa.VerifyASN1(key, digest, sig) {
+			return ErrInvalidSignature
+		}
+	case *rsa.PublicKey:
+		var err error
+		if p.alg.pss {
+			err = rsa.VerifyPSS(key, p.alg.hash, digest, sig, p.alg.opts().(*rsa.PSSOptions))
+		} else {
+			err = rsa.VerifyPKCS1v15(key, p.alg.hash, digest, sig)
+		}
+		if err != nil {
+			return ErrInvalidSignature
+		}
+	}
+	return nil
+}
@@ -0,0 +1,7 @@
+module github.com/GoogleCloudPlatform/golang-samples/logging/structuredlog
+
+go 1.23.0
+
+require go.opentelemetry.io/otel/trace v1.34.0
+
+require go.opentelemetry.io/otel v1.34.0 // indirect
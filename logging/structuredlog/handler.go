@@ -0,0 +1,290 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package structuredlog provides a slog.Handler that writes log entries in
+// the structured JSON format understood by Cloud Logging.
+//
+// Entries written to stdout or stderr on Cloud Run, Cloud Functions, GKE and
+// App Engine are parsed by the logging agent. Special fields such as
+// severity, trace and source location are lifted into the LogEntry, so logs
+// are correlated with the request trace in the Logs Explorer.
+//
+// See https://cloud.google.com/logging/docs/structured-logging.
+package structuredlog
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"runtime"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Keys of the special fields in a structured log entry.
+const (
+	SeverityKey       = "severity"
+	MessageKey        = "message"
+	TimestampKey      = "timestamp"
+	HTTPRequestKey    = "httpRequest"
+	TraceKey          = "logging.googleapis.com/trace"
+	SpanIDKey         = "logging.googleapis.com/spanId"
+	TraceSampledKey   = "logging.googleapis.com/trace_sampled"
+	SourceLocationKey = "logging.googleapis.com/sourceLocation"
+	LabelsKey         = "logging.googleapis.com/labels"
+)
+
+// Levels for the Cloud Logging severities that have no slog counterpart.
+const (
+	LevelNotice    = slog.Level(2)
+	LevelCritical  = slog.Level(12)
+	LevelAlert     = slog.Level(16)
+	LevelEmergency = slog.Level(20)
+)
+
+// Options configure a Handler. The zero value is usable.
+type Options struct {
+	// Level is the minimum level to log. It defaults to slog.LevelInfo.
+	Level slog.Leveler
+	// ProjectID is the project that traces are stored in. If set, trace IDs
+	// are written as "projects/PROJECT_ID/traces/TRACE_ID", which Cloud
+	// Logging needs to link entries to traces. Otherwise the bare trace ID
+	// is written.
+	ProjectID string
+	// AddSource adds the caller's file, line and function to each entry.
+	AddSource bool
+	// Labels are added to every entry as user-defined LogEntry labels.
+	Labels map[string]string
+}
+
+// Handler is a slog.Handler that writes one JSON object per log entry.
+type Handler struct {
+	opts Options
+	goas []groupOrAttrs
+
+	mu *sync.Mutex
+	w  io.Writer
+}
+
+// groupOrAttrs is either a group name or attributes added with WithGroup
+// or WithAttrs.
+type groupOrAttrs struct {
+	group string
+	attrs []slog.Attr
+}
+
+// NewHandler returns a Handler that writes to w. opts may be nil.
+func NewHandler(w io.Writer, opts *Options) *Handler {
+	h := &Handler{mu: &sync.Mutex{}, w: w}
+	if opts != nil {
+		h.opts = *opts
+	}
+	if h.opts.Level == nil {
+		h.opts.Level = slog.LevelInfo
+	}
+	return h
+}
+
+// Enabled reports whether level is at least the configured minimum level.
+func (h *Handler) Enabled(ctx context.Context, level slog.Level) bool {
+	return level >= h.opts.Level.Level()
+}
+
+// WithAttrs returns a Handler that adds attrs to every entry.
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	return h.with(groupOrAttrs{attrs: attrs})
+}
+
+// WithGroup returns a Handler that nests subsequent attributes under name.
+// Special fields are always written at the top level of the entry.
+func (h *Handler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	return h.with(groupOrAttrs{group: name})
+}
+
+func (h *Handler) with(goa groupOrAttrs) *Handler {
+	h2 := *h
+	h2.goas = append(h.goas[:len(h.goas):len(h.goas)], goa)
+	return &h2
+}
+
+// Handle writes r as a single line of JSON.
+func (h *Handler) Handle(ctx context.Context, r slog.Record) error {
+	entry := map[string]interface{}{
+		SeverityKey: Severity(r.Level),
+		MessageKey:  r.Message,
+	}
+	if !r.Time.IsZero() {
+		entry[TimestampKey] = r.Time.UTC().Format(time.RFC3339Nano)
+	}
+	if h.opts.AddSource && r.PC != 0 {
+		frames := runtime.CallersFrames([]uintptr{r.PC})
+		f, _ := frames.Next()
+		entry[SourceLocationKey] = map[string]string{
+			"file":     f.File,
+			"line":     strconv.Itoa(f.Line),
+			"function": f.Function,
+		}
+	}
+	if len(h.opts.Labels) > 0 {
+		entry[LabelsKey] = h.opts.Labels
+	}
+	if t, ok := traceFromContext(ctx); ok {
+		entry[TraceKey] = t.TraceID
+		if h.opts.ProjectID != "" {
+			entry[TraceKey] = fmt.Sprintf("projects/%s/traces/%s", h.opts.ProjectID, t.TraceID)
+		}
+		if t.SpanID != "" {
+			entry[SpanIDKey] = t.SpanID
+		}
+		entry[TraceSampledKey] = t.Sampled
+	}
+
+	// Attributes from WithAttrs and the record go into the innermost group.
+	type openGroup struct {
+		parent map[string]interface{}
+		key    string
+	}
+	var groups []openGroup
+	payload := entry
+	for _, goa := range h.goas {
+		if goa.group != "" {
+			g := map[string]interface{}{}
+			payload[goa.group] = g
+			groups = append(groups, openGroup{parent: payload, key: goa.group})
+			payload = g
+			continue
+		}
+		for _, a := range goa.attrs {
+			addAttr(payload, a)
+		}
+	}
+	r.Attrs(func(a slog.Attr) bool {
+		addAttr(payload, a)
+		return true
+	})
+	// Omit empty groups, as slog's built-in handlers do.
+	for i := len(groups) - 1; i >= 0; i-- {
+		g := groups[i]
+		if len(g.parent[g.key].(map[string]interface{})) > 0 {
+			break
+		}
+		delete(g.parent, g.key)
+	}
+
+	b, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	b = append(b, '\n')
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	_, err = h.w.Write(b)
+	return err
+}
+
+// addAttr adds a to m, following the slog.Handler rules: empty attributes
+// are ignored and groups with an empty key are inlined.
+func addAttr(m map[string]interface{}, a slog.Attr) {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return
+	}
+	if a.Value.Kind() == slog.KindGroup {
+		attrs := a.Value.Group()
+		if len(attrs) == 0 {
+			return
+		}
+		g := m
+		if a.Key != "" {
+			g = map[string]interface{}{}
+			m[a.Key] = g
+		}
+		for _, ga := range attrs {
+			addAttr(g, ga)
+		}
+		return
+	}
+	m[a.Key] = jsonValue(a.Value)
+}
+
+// jsonValue converts v to a value that encoding/json can marshal.
+func jsonValue(v slog.Value) interface{} {
+	switch v.Kind() {
+	case slog.KindTime:
+		return v.Time().Format(time.RFC3339Nano)
+	case slog.KindDuration:
+		return v.Duration().String()
+	case slog.KindAny:
+		switch x := v.Any().(type) {
+		case error:
+			return x.Error()
+		case json.Marshaler:
+			return x
+		case fmt.Stringer:
+			return x.String()
+		}
+	}
+	return v.Any()
+}
+
+// Severity returns the Cloud Logging severity for level. Levels between
+// two severities map to the lower one.
+// See https://cloud.google.com/logging/docs/reference/v2/rest/v2/LogEntry#LogSeverity
+func Severity(level slog.Level) string {
+	switch {
+	case level < slog.LevelInfo:
+		return "DEBUG"
+	case level < LevelNotice:
+		return "INFO"
+	case level < slog.LevelWarn:
+		return "NOTICE"
+	case level < slog.LevelError:
+		return "WARNING"
+	case level < LevelCritical:
+		return "ERROR"
+	case level < LevelAlert:
+		return "CRITICAL"
+	case level < LevelEmergency:
+		return "ALERT"
+	}
+	return "EMERGENCY"
+}
+
+// traceFromContext returns the trace of the active OpenTelemetry span in
+// ctx, or else the trace that Middleware read from the request headers.
+func traceFromContext(ctx context.Context) (Trace, bool) {
+	if ctx == nil {
+		return Trace{}, false
+	}
+	if s := trace.SpanContextFromContext(ctx); s.IsValid() {
+		return Trace{
+			TraceID: s.TraceID().String(),
+			SpanID:  s.SpanID().String(),
+			Sampled: s.IsSampled(),
+		}, true
+	}
+	t, ok := ctx.Value(traceKey{}).(Trace)
+	return t, ok
+}
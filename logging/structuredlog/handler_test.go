@@ -0,0 +1,193 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package structuredlog
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+var testTime = time.Date(2026, 1, 2, 3, 4, 5, 600000000, time.UTC)
+
+// logAt writes a record with a fixed time, so output can be compared with
+// golden files.
+func logAt(ctx context.Context, h slog.Handler, level slog.Level, msg string, attrs ...slog.Attr) {
+	r := slog.NewRecord(testTime, level, msg, 0)
+	r.AddAttrs(attrs...)
+	if err := h.Handle(ctx, r); err != nil {
+		panic(err)
+	}
+}
+
+func TestHandlerGolden(t *testing.T) {
+	tests := []struct {
+		name string
+		opts *Options
+		log  func(h slog.Handler)
+	}{
+		{
+			name: "attrs",
+			log: func(h slog.Handler) {
+				logAt(context.Background(), h, slog.LevelInfo, "hello",
+					slog.String("component", "arbitrary-property"),
+					slog.Int("subRequests", 4),
+					slog.Duration("elapsed", 1500*time.Millisecond),
+					slog.Any("error", errors.New("boom")),
+					slog.Time("at", testTime),
+					slog.Group("empty"),
+					slog.Group("", slog.Bool("inlined", true)),
+				)
+			},
+		},
+		{
+			name: "severity",
+			opts: &Options{Level: slog.LevelDebug},
+			log: func(h slog.Handler) {
+				for _, level := range []slog.Level{
+					slog.LevelDebug, slog.LevelInfo, LevelNotice, slog.LevelWarn,
+					slog.LevelError, LevelCritical, LevelAlert, LevelEmergency,
+					slog.LevelInfo + 1, slog.LevelError + 100,
+				} {
+					logAt(context.Background(), h, level, level.String())
+				}
+			},
+		},
+		{
+			name: "traceparent",
+			opts: &Options{ProjectID: "example-project"},
+			log: func(h slog.Handler) {
+				r := httptest.NewRequest("GET", "/", nil)
+				r.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+				// The traceparent header takes precedence.
+				r.Header.Set("X-Cloud-Trace-Context", "105445aa7843bc8bf206b12000100000/1;o=0")
+				Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					logAt(r.Context(), h, slog.LevelInfo, "handled")
+				})).ServeHTTP(httptest.NewRecorder(), r)
+			},
+		},
+		{
+			name: "cloud_trace_context",
+			log: func(h slog.Handler) {
+				r := httptest.NewRequest("GET", "/", nil)
+				r.Header.Set("X-Cloud-Trace-Context", "105445aa7843bc8bf206b12000100000/1;o=1")
+				Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					logAt(r.Context(), h, slog.LevelInfo, "handled")
+				})).ServeHTTP(httptest.NewRecorder(), r)
+			},
+		},
+		{
+			name: "otel",
+			opts: &Options{ProjectID: "example-project"},
+			log: func(h slog.Handler) {
+				sc := trace.NewSpanContext(trace.SpanContextConfig{
+					TraceID: trace.TraceID{0x4b, 0xf9, 0x2f, 0x35, 0x77, 0xb3, 0x4d, 0xa6, 0xa3, 0xce, 0x92, 0x9d, 0x0e, 0x0e, 0x47, 0x36},
+					SpanID:  trace.SpanID{0x00, 0xf0, 0x67, 0xaa, 0x0b, 0xa9, 0x02, 0xb7},
+				})
+				// The span context takes precedence over request headers.
+				ctx := ContextWithTrace(context.Background(), Trace{TraceID: "ignored"})
+				ctx = trace.ContextWithSpanContext(ctx, sc)
+				logAt(ctx, h, slog.LevelWarn, "in span")
+			},
+		},
+		{
+			name: "http_request",
+			log: func(h slog.Handler) {
+				r := httptest.NewRequest("POST", "https://example.com/upload?x=1", strings.NewReader("payload"))
+				r.Header.Set("User-Agent", "test-agent")
+				r.Header.Set("Referer", "https://example.com/")
+				logAt(context.Background(), h, slog.LevelInfo, "request completed",
+					HTTPRequest(r, http.StatusCreated, 42, 1250*time.Millisecond))
+			},
+		},
+		{
+			name: "labels_and_groups",
+			opts: &Options{Labels: map[string]string{"service": "checkout", "env": "test"}},
+			log: func(h slog.Handler) {
+				h = h.WithAttrs([]slog.Attr{slog.String("requestID", "r-1")})
+				g := h.WithGroup("order").WithAttrs([]slog.Attr{slog.Int("id", 7)})
+				logAt(context.Background(), g, slog.LevelInfo, "grouped", slog.String("state", "paid"))
+				// Groups without attributes are omitted.
+				logAt(context.Background(), h.WithGroup("unused"), slog.LevelInfo, "no group")
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			tc.log(NewHandler(&buf, tc.opts))
+
+			golden := filepath.Join("testdata", tc.name+".golden")
+			if *update {
+				if err := os.WriteFile(golden, buf.Bytes(), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("os.ReadFile: %v (run go test -update to create it)", err)
+			}
+			if got := buf.String(); got != string(want) {
+				t.Errorf("output mismatch\ngot:\n%s\nwant:\n%s", got, want)
+			}
+		})
+	}
+}
+
+func TestHandlerLevel(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(NewHandler(&buf, nil))
+	logger.Debug("hidden")
+	logger.Info("shown")
+	if got := strings.Count(buf.String(), "\n"); got != 1 {
+		t.Errorf("got %d entries, want 1:\n%s", got, buf.String())
+	}
+}
+
+func TestHandlerSource(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(NewHandler(&buf, &Options{AddSource: true}))
+	logger.Info("with source")
+
+	var entry struct {
+		SourceLocation struct {
+			File     string `json:"file"`
+			Line     string `json:"line"`
+			Function string `json:"function"`
+		} `json:"logging.googleapis.com/sourceLocation"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("json.Unmarshal: %v", err)
+	}
+	loc := entry.SourceLocation
+	if filepath.Base(loc.File) != "handler_test.go" || loc.Line == "" || !strings.HasSuffix(loc.Function, ".TestHandlerSource") {
+		t.Errorf("got source location %+v, want this test", loc)
+	}
+}
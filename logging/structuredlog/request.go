@@ -0,0 +1,144 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package structuredlog
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Trace identifies the trace and span that a log entry belongs to.
+type Trace struct {
+	// TraceID is the trace ID, usually 32 hexadecimal characters.
+	TraceID string
+	// SpanID is the span ID as 16 hexadecimal characters. It may be empty.
+	SpanID  string
+	Sampled bool
+}
+
+type traceKey struct{}
+
+// ContextWithTrace returns a copy of ctx carrying t. Entries logged with the
+// returned context are correlated with t, unless ctx also holds an
+// OpenTelemetry span, which takes precedence.
+func ContextWithTrace(ctx context.Context, t Trace) context.Context {
+	return context.WithValue(ctx, traceKey{}, t)
+}
+
+// Middleware adds the trace from the request's W3C traceparent or
+// X-Cloud-Trace-Context header to the request context, so that entries
+// logged with r.Context() are correlated with the request.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if t, ok := TraceFromRequest(r); ok {
+			r = r.WithContext(ContextWithTrace(r.Context(), t))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// TraceFromRequest reads the trace from the W3C traceparent header or, if
+// that is absent or invalid, the X-Cloud-Trace-Context header.
+func TraceFromRequest(r *http.Request) (Trace, bool) {
+	if t, ok := parseTraceparent(r.Header.Get("traceparent")); ok {
+		return t, true
+	}
+	return parseCloudTraceContext(r.Header.Get("X-Cloud-Trace-Context"))
+}
+
+// parseTraceparent parses a W3C Trace Context header, such as
+// "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01".
+// See https://www.w3.org/TR/trace-context/#traceparent-header.
+func parseTraceparent(h string) (Trace, bool) {
+	parts := strings.Split(h, "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" {
+		return Trace{}, false
+	}
+	traceID, spanID, flags := parts[1], parts[2], parts[3]
+	if len(traceID) != 32 || !isHex(traceID) || strings.Trim(traceID, "0") == "" {
+		return Trace{}, false
+	}
+	if len(spanID) != 16 || !isHex(spanID) || strings.Trim(spanID, "0") == "" {
+		return Trace{}, false
+	}
+	f, err := strconv.ParseUint(flags, 16, 8)
+	if len(flags) != 2 || err != nil {
+		return Trace{}, false
+	}
+	return Trace{TraceID: traceID, SpanID: spanID, Sampled: f&1 == 1}, true
+}
+
+// parseCloudTraceContext parses an X-Cloud-Trace-Context header of the form
+// "TRACE_ID/SPAN_ID;o=OPTIONS", where SPAN_ID is a decimal number.
+// See https://cloud.google.com/trace/docs/trace-context#legacy-http-header.
+func parseCloudTraceContext(h string) (Trace, bool) {
+	h, options, _ := strings.Cut(h, ";")
+	traceID, span, _ := strings.Cut(h, "/")
+	if traceID == "" {
+		return Trace{}, false
+	}
+	t := Trace{TraceID: traceID, Sampled: options == "o=1"}
+	if id, err := strconv.ParseUint(span, 10, 64); err == nil && id != 0 {
+		t.SpanID = fmt.Sprintf("%016x", id)
+	}
+	return t, true
+}
+
+func isHex(s string) bool {
+	for _, c := range s {
+		if !('0' <= c && c <= '9' || 'a' <= c && c <= 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// HTTPRequest returns an attribute describing a completed request, in the
+// format of the LogEntry httpRequest field. Logs Explorer shows entries
+// with this field as request logs.
+// See https://cloud.google.com/logging/docs/reference/v2/rest/v2/LogEntry#HttpRequest
+func HTTPRequest(r *http.Request, status int, responseSize int64, latency time.Duration) slog.Attr {
+	attrs := []slog.Attr{
+		slog.String("requestMethod", r.Method),
+		slog.String("requestUrl", r.URL.String()),
+		slog.String("protocol", r.Proto),
+		slog.Int("status", status),
+	}
+	if r.ContentLength > 0 {
+		attrs = append(attrs, slog.String("requestSize", strconv.FormatInt(r.ContentLength, 10)))
+	}
+	if responseSize > 0 {
+		attrs = append(attrs, slog.String("responseSize", strconv.FormatInt(responseSize, 10)))
+	}
+	if ua := r.UserAgent(); ua != "" {
+		attrs = append(attrs, slog.String("userAgent", ua))
+	}
+	if ref := r.Referer(); ref != "" {
+		attrs = append(attrs, slog.String("referer", ref))
+	}
+	if ip, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		attrs = append(attrs, slog.String("remoteIp", ip))
+	}
+	// Latency is a protobuf Duration, which is encoded in JSON as seconds
+	// with an "s" suffix.
+	attrs = append(attrs, slog.String("latency", strconv.FormatFloat(latency.Seconds(), 'f', -1, 64)+"s"))
+	return slog.Attr{Key: HTTPRequestKey, Value: slog.GroupValue(attrs...)}
+}
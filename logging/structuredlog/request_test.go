@@ -0,0 +1,90 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package structuredlog
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestTraceFromRequest(t *testing.T) {
+	tests := []struct {
+		name         string
+		traceparent  string
+		cloudContext string
+		want         Trace
+		wantOK       bool
+	}{
+		{
+			name:        "traceparent sampled",
+			traceparent: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+			want:        Trace{TraceID: "4bf92f3577b34da6a3ce929d0e0e4736", SpanID: "00f067aa0ba902b7", Sampled: true},
+			wantOK:      true,
+		},
+		{
+			name:        "traceparent not sampled",
+			traceparent: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00",
+			want:        Trace{TraceID: "4bf92f3577b34da6a3ce929d0e0e4736", SpanID: "00f067aa0ba902b7"},
+			wantOK:      true,
+		},
+		{
+			name:         "invalid traceparent falls back",
+			traceparent:  "00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+			cloudContext: "105445aa7843bc8bf206b12000100000/255;o=1",
+			want:         Trace{TraceID: "105445aa7843bc8bf206b12000100000", SpanID: "00000000000000ff", Sampled: true},
+			wantOK:       true,
+		},
+		{
+			name:        "unsupported traceparent version",
+			traceparent: "ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		},
+		{
+			name:        "uppercase traceparent",
+			traceparent: "00-4BF92F3577B34DA6A3CE929D0E0E4736-00F067AA0BA902B7-01",
+		},
+		{
+			name:         "cloud trace without span",
+			cloudContext: "105445aa7843bc8bf206b12000100000",
+			want:         Trace{TraceID: "105445aa7843bc8bf206b12000100000"},
+			wantOK:       true,
+		},
+		{
+			name:         "cloud trace with invalid span",
+			cloudContext: "105445aa7843bc8bf206b12000100000/abc;o=0",
+			want:         Trace{TraceID: "105445aa7843bc8bf206b12000100000"},
+			wantOK:       true,
+		},
+		{
+			name:         "cloud trace without trace ID",
+			cloudContext: "/123",
+		},
+		{
+			name: "no headers",
+		},
+	}
+	for _, tc := range tests {
+		r := httptest.NewRequest("GET", "/", nil)
+		if tc.traceparent != "" {
+			r.Header.Set("traceparent", tc.traceparent)
+		}
+		if tc.cloudContext != "" {
+			r.Header.Set("X-Cloud-Trace-Context", tc.cloudContext)
+		}
+		got, ok := TraceFromRequest(r)
+		if got != tc.want || ok != tc.wantOK {
+			t.Errorf("TraceFromRequest(%q): got (%+v, %v), want (%+v, %v)", tc.name, got, ok, tc.want, tc.wantOK)
+		}
+	}
+}
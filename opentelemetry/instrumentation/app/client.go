@@ -20,6 +20,9 @@ import (
 	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 )
 
+// singleURL is the URL of this application's /single endpoint.
+var singleURL = "http://localhost:8080/single"
+
 // callSingle makes an http request to this application's /single endpoint.
 // The provided context is used to propagate the trace context with the
 // http headers.
@@ -27,7 +30,7 @@ import (
 func callSingle(ctx context.Context) error {
 	// otelhttp.Get makes an http GET request, just like net/http.Get.
 	// In addition, it records a span, records metrics, and propagates context.
-	res, err := otelhttp.Get(ctx, "http://localhost:8080/single")
+	res, err := otelhttp.Get(ctx, singleURL)
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,180 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Exporter names, as used by OTEL_TRACES_EXPORTER and OTEL_METRICS_EXPORTER.
+const (
+	exporterOTLP    = "otlp"
+	exporterConsole = "console"
+	exporterMemory  = "memory"
+	exporterNone    = "none"
+)
+
+// OTLP protocols, as used by OTEL_EXPORTER_OTLP_PROTOCOL.
+const (
+	protocolGRPC = "grpc"
+	protocolHTTP = "http/protobuf"
+)
+
+// Sampler names, as used by OTEL_TRACES_SAMPLER. All but samplerTail are
+// defined by the OpenTelemetry specification.
+const (
+	samplerAlwaysOn                = "always_on"
+	samplerAlwaysOff               = "always_off"
+	samplerTraceIDRatio            = "traceidratio"
+	samplerParentBasedAlwaysOn     = "parentbased_always_on"
+	samplerParentBasedAlwaysOff    = "parentbased_always_off"
+	samplerParentBasedTraceIDRatio = "parentbased_traceidratio"
+	// samplerTail records every trace and decides whether to export it once
+	// all of its spans in this process have ended. See tailSampler.
+	samplerTail = "tail"
+)
+
+// telemetryConfig selects the exporters and the sampler. It is read from
+// the YAML file named by TELEMETRY_CONFIG_FILE, if set, and then from the
+// standard OTEL_* environment variables, which take precedence.
+type telemetryConfig struct {
+	Traces struct {
+		Exporters []string `yaml:"exporters"`
+		// Sampler is one of the sampler* names.
+		Sampler string `yaml:"sampler"`
+		// Ratio is the fraction of traces kept by the ratio based samplers.
+		// The tail sampler keeps this fraction of the traces that have no
+		// error and are faster than Tail.Latency.
+		Ratio float64 `yaml:"ratio"`
+		Tail  struct {
+			// Latency is the duration of the outermost span of a trace in
+			// this process from which the trace is always kept.
+			Latency time.Duration `yaml:"latency"`
+			// MaxTraces bounds the number of traces held in memory while
+			// waiting for their spans to end.
+			MaxTraces int `yaml:"max_traces"`
+		} `yaml:"tail"`
+	} `yaml:"traces"`
+	Metrics struct {
+		Exporters []string `yaml:"exporters"`
+	} `yaml:"metrics"`
+	OTLP struct {
+		// Protocol is protocolGRPC or protocolHTTP.
+		Protocol string `yaml:"protocol"`
+	} `yaml:"otlp"`
+}
+
+// defaultConfig returns the configuration used when nothing is set: export
+// everything as OTLP over HTTP, as the collector in docker-compose.yaml
+// expects.
+func defaultConfig() telemetryConfig {
+	var cfg telemetryConfig
+	cfg.Traces.Exporters = []string{exporterOTLP}
+	cfg.Traces.Sampler = samplerParentBasedAlwaysOn
+	cfg.Traces.Ratio = 1
+	cfg.Traces.Tail.Latency = time.Second
+	cfg.Traces.Tail.MaxTraces = 10000
+	cfg.Metrics.Exporters = []string{exporterOTLP}
+	cfg.OTLP.Protocol = protocolHTTP
+	return cfg
+}
+
+// loadConfig returns the default configuration, overridden by the config
+// file and then by environment variables. getenv is usually os.Getenv.
+func loadConfig(getenv func(string) string) (telemetryConfig, error) {
+	cfg := defaultConfig()
+	if name := getenv("TELEMETRY_CONFIG_FILE"); name != "" {
+		b, err := os.ReadFile(name)
+		if err != nil {
+			return cfg, fmt.Errorf("reading telemetry config: %w", err)
+		}
+		if err := yaml.Unmarshal(b, &cfg); err != nil {
+			return cfg, fmt.Errorf("parsing telemetry config %s: %w", name, err)
+		}
+	}
+
+	if v := getenv("OTEL_TRACES_EXPORTER"); v != "" {
+		cfg.Traces.Exporters = splitList(v)
+	}
+	if v := getenv("OTEL_METRICS_EXPORTER"); v != "" {
+		cfg.Metrics.Exporters = splitList(v)
+	}
+	if v := getenv("OTEL_EXPORTER_OTLP_PROTOCOL"); v != "" {
+		cfg.OTLP.Protocol = v
+	}
+	if v := getenv("OTEL_TRACES_SAMPLER"); v != "" {
+		cfg.Traces.Sampler = v
+	}
+	if v := getenv("OTEL_TRACES_SAMPLER_ARG"); v != "" {
+		ratio, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return cfg, fmt.Errorf("OTEL_TRACES_SAMPLER_ARG: %w", err)
+		}
+		cfg.Traces.Ratio = ratio
+	}
+	if v := getenv("TAIL_SAMPLING_LATENCY"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return cfg, fmt.Errorf("TAIL_SAMPLING_LATENCY: %w", err)
+		}
+		cfg.Traces.Tail.Latency = d
+	}
+	return cfg, cfg.validate()
+}
+
+func (cfg telemetryConfig) validate() error {
+	for _, e := range append(cfg.Traces.Exporters, cfg.Metrics.Exporters...) {
+		switch e {
+		case exporterOTLP, exporterConsole, exporterMemory, exporterNone:
+		default:
+			return fmt.Errorf("unknown exporter %q", e)
+		}
+	}
+	switch cfg.OTLP.Protocol {
+	case protocolGRPC, protocolHTTP:
+	default:
+		return fmt.Errorf("unsupported OTLP protocol %q", cfg.OTLP.Protocol)
+	}
+	switch cfg.Traces.Sampler {
+	case samplerAlwaysOn, samplerAlwaysOff, samplerTraceIDRatio, samplerParentBasedAlwaysOn,
+		samplerParentBasedAlwaysOff, samplerParentBasedTraceIDRatio, samplerTail:
+	default:
+		return fmt.Errorf("unknown sampler %q", cfg.Traces.Sampler)
+	}
+	if cfg.Traces.Ratio < 0 || cfg.Traces.Ratio > 1 {
+		return fmt.Errorf("sampling ratio %v is not between 0 and 1", cfg.Traces.Ratio)
+	}
+	if cfg.Traces.Tail.MaxTraces <= 0 {
+		return fmt.Errorf("tail sampling max_traces must be positive")
+	}
+	return nil
+}
+
+// splitList splits a comma-separated list, ignoring blanks.
+func splitList(s string) []string {
+	var l []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			l = append(l, v)
+		}
+	}
+	return l
+}
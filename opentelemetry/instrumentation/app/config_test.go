@@ -0,0 +1,106 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadConfig(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "telemetry.yaml")
+	err := os.WriteFile(file, []byte(`
+traces:
+  exporters: [otlp, console]
+  sampler: tail
+  ratio: 0.25
+  tail:
+    latency: 500ms
+    max_traces: 100
+metrics:
+  exporters: [none]
+otlp:
+  protocol: grpc
+`), 0o644)
+	require.NoError(t, err)
+
+	t.Run("defaults", func(t *testing.T) {
+		cfg, err := loadConfig(mapEnv(nil))
+		require.NoError(t, err)
+		assert.Equal(t, defaultConfig(), cfg)
+	})
+
+	t.Run("file", func(t *testing.T) {
+		cfg, err := loadConfig(mapEnv(map[string]string{"TELEMETRY_CONFIG_FILE": file}))
+		require.NoError(t, err)
+		assert.Equal(t, []string{exporterOTLP, exporterConsole}, cfg.Traces.Exporters)
+		assert.Equal(t, samplerTail, cfg.Traces.Sampler)
+		assert.Equal(t, 0.25, cfg.Traces.Ratio)
+		assert.Equal(t, 500*time.Millisecond, cfg.Traces.Tail.Latency)
+		assert.Equal(t, 100, cfg.Traces.Tail.MaxTraces)
+		assert.Equal(t, []string{exporterNone}, cfg.Metrics.Exporters)
+		assert.Equal(t, protocolGRPC, cfg.OTLP.Protocol)
+	})
+
+	t.Run("environment overrides file", func(t *testing.T) {
+		cfg, err := loadConfig(mapEnv(map[string]string{
+			"TELEMETRY_CONFIG_FILE":       file,
+			"OTEL_TRACES_EXPORTER":        "memory, console",
+			"OTEL_METRICS_EXPORTER":       "memory",
+			"OTEL_EXPORTER_OTLP_PROTOCOL": "http/protobuf",
+			"OTEL_TRACES_SAMPLER":         "parentbased_traceidratio",
+			"OTEL_TRACES_SAMPLER_ARG":     "0.5",
+			"TAIL_SAMPLING_LATENCY":       "2s",
+		}))
+		require.NoError(t, err)
+		assert.Equal(t, []string{exporterMemory, exporterConsole}, cfg.Traces.Exporters)
+		assert.Equal(t, []string{exporterMemory}, cfg.Metrics.Exporters)
+		assert.Equal(t, protocolHTTP, cfg.OTLP.Protocol)
+		assert.Equal(t, samplerParentBasedTraceIDRatio, cfg.Traces.Sampler)
+		assert.Equal(t, 0.5, cfg.Traces.Ratio)
+		assert.Equal(t, 2*time.Second, cfg.Traces.Tail.Latency)
+		// Settings without an environment variable come from the file.
+		assert.Equal(t, 100, cfg.Traces.Tail.MaxTraces)
+	})
+}
+
+func TestLoadConfigErrors(t *testing.T) {
+	tests := map[string]map[string]string{
+		"missing file":      {"TELEMETRY_CONFIG_FILE": filepath.Join(t.TempDir(), "missing.yaml")},
+		"unknown exporter":  {"OTEL_TRACES_EXPORTER": "zipkin"},
+		"unknown protocol":  {"OTEL_EXPORTER_OTLP_PROTOCOL": "http/json"},
+		"unknown sampler":   {"OTEL_TRACES_SAMPLER": "jaeger_remote"},
+		"invalid ratio":     {"OTEL_TRACES_SAMPLER_ARG": "half"},
+		"ratio above one":   {"OTEL_TRACES_SAMPLER_ARG": "1.5"},
+		"invalid latency":   {"TAIL_SAMPLING_LATENCY": "500"},
+		"unknown metrics":   {"OTEL_METRICS_EXPORTER": "prometheus"},
+		"blank and unknown": {"OTEL_TRACES_EXPORTER": "otlp,", "OTEL_METRICS_EXPORTER": ",logging"},
+	}
+	for name, env := range tests {
+		if _, err := loadConfig(mapEnv(env)); err == nil {
+			t.Errorf("%s: loadConfig got nil error", name)
+		}
+	}
+}
+
+// mapEnv returns a getenv function that looks variables up in env.
+func mapEnv(env map[string]string) func(string) string {
+	return func(key string) string { return env[key] }
+}
@@ -16,13 +16,24 @@ package main
 
 import (
 	"context"
+	"io"
 	"log/slog"
 
 	"go.opentelemetry.io/otel/trace"
 )
 
-// handlerWithSpanContext adds attributes from the span context
+// newLogHandler returns a slog.Handler which writes JSON logs in the Cloud
+// Logging structured log format to w. When a Context with a span is passed
+// to logging calls, the trace and span IDs are added to the log entry.
 // [START opentelemetry_instrumentation_spancontext_logger]
+func newLogHandler(w io.Writer, level slog.Leveler) slog.Handler {
+	// Use json as our base logging format.
+	jsonHandler := slog.NewJSONHandler(w, &slog.HandlerOptions{ReplaceAttr: replacer, Level: level})
+	// Add span context attributes when Context is passed to logging calls.
+	return handlerWithSpanContext(jsonHandler)
+}
+
+// handlerWithSpanContext adds attributes from the span context
 func handlerWithSpanContext(handler slog.Handler) *spanContextLogHandler {
 	return &spanContextLogHandler{Handler: handler}
 }
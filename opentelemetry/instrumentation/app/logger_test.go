@@ -23,6 +23,7 @@ import (
 	"time"
 
 	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/trace"
 )
 
 func TestHandlerSeverity(t *testing.T) {
@@ -52,11 +53,7 @@ func TestHandlerSeverity(t *testing.T) {
 	for _, tc := range tests {
 		t.Run(tc.expectSeverity, func(t *testing.T) {
 			buf := &bytes.Buffer{}
-			jsonHandler := slog.NewJSONHandler(
-				buf,
-				&slog.HandlerOptions{ReplaceAttr: replacer, Level: slog.LevelDebug},
-			)
-			logger := slog.New(jsonHandler)
+			logger := slog.New(newLogHandler(buf, slog.LevelDebug))
 			tc.logFunc(logger)
 
 			line := &expectedLogFormat{}
@@ -70,11 +67,7 @@ func TestHandlerTimestamp(t *testing.T) {
 	ctx := context.Background()
 
 	buf := &bytes.Buffer{}
-	jsonHandler := slog.NewJSONHandler(
-		buf,
-		&slog.HandlerOptions{ReplaceAttr: replacer, Level: slog.LevelDebug},
-	)
-	logger := slog.New(jsonHandler)
+	logger := slog.New(newLogHandler(buf, slog.LevelDebug))
 	logger.InfoContext(ctx, "foo")
 
 	line := &expectedLogFormat{}
@@ -84,3 +77,25 @@ func TestHandlerTimestamp(t *testing.T) {
 	_, err := time.Parse(time.RFC3339Nano, line.Timestamp)
 	require.NoErrorf(t, err, "could not parse timestamp as RFC3339 with nanos")
 }
+
+func TestHandlerSpanContext(t *testing.T) {
+	traceID, err := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	require.NoError(t, err)
+	spanID, err := trace.SpanIDFromHex("00f067aa0ba902b7")
+	require.NoError(t, err)
+	ctx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    traceID,
+		SpanID:     spanID,
+		TraceFlags: trace.FlagsSampled,
+	}))
+
+	buf := &bytes.Buffer{}
+	logger := slog.New(newLogHandler(buf, slog.LevelDebug))
+	logger.InfoContext(ctx, "foo")
+
+	line := &expectedLogFormat{}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), line))
+	require.Equal(t, traceID.String(), line.TraceID)
+	require.Equal(t, spanID.String(), line.SpanID)
+	require.True(t, line.TraceSampled)
+}
@@ -26,7 +26,9 @@ import (
 // endpoint.
 //
 // The application is instrumented with OpenTelemetry and exports OTLP for
-// metrics and traces. It uses log/slog for logging, and writes logs to stdout.
+// metrics and traces. Exporters and sampling are configured with the
+// standard OTEL_* environment variables or a config file, see loadConfig.
+// It uses log/slog for logging, and writes logs to stdout.
 // The application does not include any GCP dependencies, and instead only uses
 // open standards for instrumentation. The OpenTelemetry collector is used to
 // route telemetry to GCP.
@@ -39,7 +41,12 @@ func main() {
 	setupLogging()
 
 	// Setup metrics, tracing, and context propagation
-	shutdown, err := setupOpenTelemetry(ctx)
+	cfg, err := loadConfig(os.Getenv)
+	if err != nil {
+		slog.ErrorContext(ctx, "error loading telemetry config", slog.Any("error", err))
+		os.Exit(1)
+	}
+	otelSDK, err := setupOpenTelemetry(ctx, cfg)
 	if err != nil {
 		slog.ErrorContext(ctx, "error setting up OpenTelemetry", slog.Any("error", err))
 		os.Exit(1)
@@ -47,7 +54,7 @@ func main() {
 
 	// Run the http server, and shutdown and flush telemetry after it exits.
 	slog.InfoContext(ctx, "server starting...")
-	if err = errors.Join(runServer(), shutdown(ctx)); err != nil {
+	if err = errors.Join(runServer(), otelSDK.Shutdown(ctx)); err != nil {
 		slog.ErrorContext(ctx, "server exited with error", slog.Any("error", err))
 		os.Exit(1)
 	}
@@ -20,6 +20,7 @@ import (
 	"io"
 	"net"
 	"net/http"
+	"net/http/httptest"
 	"strings"
 	"sync"
 	"testing"
@@ -30,6 +31,10 @@ import (
 	"go.opentelemetry.io/collector/pdata/pmetric/pmetricotlp"
 	"go.opentelemetry.io/collector/pdata/ptrace"
 	"go.opentelemetry.io/collector/pdata/ptrace/ptraceotlp"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
 
 	"github.com/GoogleCloudPlatform/golang-samples/internal/testutil"
 )
@@ -131,6 +136,154 @@ func TestWriteTelemetry(t *testing.T) {
 	wg.Wait()
 }
 
+// TestInMemoryTelemetry runs the server in process with the in-memory
+// exporters, and checks the span tree and metrics of a /multi request
+// without a collector.
+func TestInMemoryTelemetry(t *testing.T) {
+	ctx := context.Background()
+	cfg := defaultConfig()
+	cfg.Traces.Exporters = []string{exporterMemory}
+	cfg.Metrics.Exporters = []string{exporterMemory}
+	otelSDK, err := setupOpenTelemetry(ctx, cfg)
+	if err != nil {
+		t.Fatalf("setupOpenTelemetry: %v", err)
+	}
+	defer otelSDK.Shutdown(ctx)
+
+	srv := httptest.NewServer(newServeMux())
+	defer func(url string) { singleURL = url }(singleURL)
+	singleURL = srv.URL + "/single"
+	resp, err := http.Get(srv.URL + "/multi")
+	if err != nil {
+		t.Fatalf("GET /multi: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("GET /multi: got status %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+	// Close waits for the handlers, and so their spans, to finish.
+	srv.Close()
+
+	spans := otelSDK.spans.GetSpans()
+	multi := findSpans(spans, "/multi")
+	if len(multi) != 1 {
+		t.Fatalf("got %d /multi spans, want 1", len(multi))
+	}
+	root := multi[0]
+	assert.False(t, root.Parent.IsValid(), "/multi span has a parent")
+	assert.Equal(t, trace.SpanKindServer, root.SpanKind)
+	assert.Contains(t, root.Attributes, attribute.String("http.route", "/multi"))
+
+	sub := findSpans(spans, "subrequests")
+	if len(sub) != 1 {
+		t.Fatalf("got %d subrequests spans, want 1", len(sub))
+	}
+	assert.Equal(t, root.SpanContext.SpanID(), sub[0].Parent.SpanID())
+
+	clients := findSpans(spans, "HTTP GET")
+	singles := findSpans(spans, "/single")
+	if len(clients) < 3 || len(clients) > 6 {
+		t.Errorf("got %d client spans, want 3 to 6", len(clients))
+	}
+	if len(singles) != len(clients) {
+		t.Errorf("got %d /single spans for %d client spans", len(singles), len(clients))
+	}
+	parents := map[trace.SpanID]bool{}
+	for _, c := range clients {
+		assert.Equal(t, sub[0].SpanContext.SpanID(), c.Parent.SpanID(), "client span parent")
+		parents[c.SpanContext.SpanID()] = true
+	}
+	for _, s := range singles {
+		assert.True(t, parents[s.Parent.SpanID()], "/single span is not a child of a client span")
+		assert.True(t, s.Parent.IsRemote(), "/single span parent is not remote")
+		assert.Equal(t, root.SpanContext.TraceID(), s.SpanContext.TraceID())
+	}
+
+	var rm metricdata.ResourceMetrics
+	if err := otelSDK.metrics.Collect(ctx, &rm); err != nil {
+		t.Fatalf("Collect: %v", err)
+	}
+	subrequests, ok := findMetric(rm, "example.subrequests").(metricdata.Histogram[int64])
+	if !ok || len(subrequests.DataPoints) != 1 {
+		t.Fatalf("example.subrequests: got %+v, want one histogram data point", subrequests)
+	}
+	dp := subrequests.DataPoints[0]
+	assert.Equal(t, uint64(1), dp.Count)
+	assert.Equal(t, int64(len(clients)), dp.Sum)
+
+	sleep, ok := findMetric(rm, "example.sleep.duration").(metricdata.Histogram[float64])
+	if !ok || len(sleep.DataPoints) != 1 {
+		t.Fatalf("example.sleep.duration: got %+v, want one histogram data point", sleep)
+	}
+	assert.Equal(t, uint64(len(singles)), sleep.DataPoints[0].Count)
+	assert.NotNil(t, findMetric(rm, "http.server.duration"), "http.server.duration not recorded")
+}
+
+func TestTailSamplingMulti(t *testing.T) {
+	ctx := context.Background()
+	cfg := defaultConfig()
+	cfg.Traces.Exporters = []string{exporterMemory}
+	cfg.Metrics.Exporters = []string{exporterMemory}
+	// Each /single request is faster than the latency, but /multi, with at
+	// least three of them, is slower.
+	cfg.Traces.Sampler = samplerTail
+	cfg.Traces.Ratio = 0
+	cfg.Traces.Tail.Latency = 250 * time.Millisecond
+	otelSDK, err := setupOpenTelemetry(ctx, cfg)
+	if err != nil {
+		t.Fatalf("setupOpenTelemetry: %v", err)
+	}
+	defer otelSDK.Shutdown(ctx)
+
+	srv := httptest.NewServer(newServeMux())
+	defer func(url string) { singleURL = url }(singleURL)
+	singleURL = srv.URL + "/single"
+	resp, err := http.Get(srv.URL + "/multi")
+	if err != nil {
+		t.Fatalf("GET /multi: %v", err)
+	}
+	resp.Body.Close()
+	srv.Close()
+
+	// The /single server spans, whose parents are remote, end first, but
+	// the trace is decided on when /multi ends.
+	spans := otelSDK.spans.GetSpans()
+	multi := findSpans(spans, "/multi")
+	if len(multi) != 1 {
+		t.Fatalf("got %d /multi spans, want 1", len(multi))
+	}
+	singles := findSpans(spans, "/single")
+	if len(singles) < 3 {
+		t.Errorf("got %d /single spans, want at least 3", len(singles))
+	}
+	for _, s := range singles {
+		assert.Equal(t, multi[0].SpanContext.TraceID(), s.SpanContext.TraceID())
+	}
+}
+
+// findSpans returns the spans with the given name.
+func findSpans(spans tracetest.SpanStubs, name string) tracetest.SpanStubs {
+	var found tracetest.SpanStubs
+	for _, s := range spans {
+		if s.Name == name {
+			found = append(found, s)
+		}
+	}
+	return found
+}
+
+// findMetric returns the data of the named metric, or nil.
+func findMetric(rm metricdata.ResourceMetrics, name string) metricdata.Aggregation {
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if m.Name == name {
+				return m.Data
+			}
+		}
+	}
+	return nil
+}
+
 type expectedLogFormat struct {
 	Timestamp    string `json:"timestamp"`
 	Severity     string `json:"severity"`
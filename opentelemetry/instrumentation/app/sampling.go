@@ -0,0 +1,230 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tailSampler is a SpanProcessor that holds the spans of each trace until
+// all its spans started in this process have ended, and then decides
+// whether to pass them on to the next processors. A trace is kept if any of
+// its spans has an error status or its outermost span in this process took
+// at least latency; other traces are kept with the probability given by
+// ratio.
+//
+// Waiting for every open span, rather than for the first span with a remote
+// parent, matters when a service calls itself: the server span of the
+// inner request ends before the outer request it belongs to.
+//
+// The tracer provider must sample every span for tail sampling to see
+// them, so use it with sdktrace.AlwaysSample.
+type tailSampler struct {
+	next      []sdktrace.SpanProcessor
+	latency   time.Duration
+	ratio     sdktrace.Sampler
+	maxTraces int
+
+	mu      sync.Mutex
+	pending map[trace.TraceID]*list.Element // of *pendingTrace
+	order   *list.List                      // oldest pending trace first
+	// decided remembers recent decisions, for spans that start after the
+	// decision, such as asynchronous work.
+	decided      map[trace.TraceID]bool
+	decidedOrder []trace.TraceID
+}
+
+type pendingTrace struct {
+	id    trace.TraceID
+	open  int // spans started and not ended yet
+	spans []sdktrace.ReadOnlySpan
+}
+
+var _ sdktrace.SpanProcessor = (*tailSampler)(nil)
+
+func newTailSampler(latency time.Duration, ratio float64, maxTraces int, next ...sdktrace.SpanProcessor) *tailSampler {
+	return &tailSampler{
+		next:      next,
+		latency:   latency,
+		ratio:     sdktrace.TraceIDRatioBased(ratio),
+		maxTraces: maxTraces,
+		pending:   make(map[trace.TraceID]*list.Element),
+		order:     list.New(),
+		decided:   make(map[trace.TraceID]bool),
+	}
+}
+
+// OnStart counts s as an open span of its trace, unless the trace was
+// decided on already.
+func (ts *tailSampler) OnStart(_ context.Context, s sdktrace.ReadWriteSpan) {
+	id := s.SpanContext().TraceID()
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	if _, ok := ts.decided[id]; ok {
+		return
+	}
+	ts.pendingTrace(id).open++
+}
+
+// pendingTrace returns the pending trace with an ID, adding it if needed.
+// ts.mu must be held.
+func (ts *tailSampler) pendingTrace(id trace.TraceID) *pendingTrace {
+	if e, ok := ts.pending[id]; ok {
+		return e.Value.(*pendingTrace)
+	}
+	if ts.order.Len() >= ts.maxTraces {
+		// Drop the oldest trace rather than growing without bound.
+		oldest := ts.order.Remove(ts.order.Front()).(*pendingTrace)
+		delete(ts.pending, oldest.id)
+	}
+	pt := &pendingTrace{id: id}
+	ts.pending[id] = ts.order.PushBack(pt)
+	return pt
+}
+
+// OnEnd buffers s, and decides on its trace if s was its last open span.
+func (ts *tailSampler) OnEnd(s sdktrace.ReadOnlySpan) {
+	id := s.SpanContext().TraceID()
+
+	ts.mu.Lock()
+	if keep, ok := ts.decided[id]; ok {
+		ts.mu.Unlock()
+		if keep {
+			ts.export([]sdktrace.ReadOnlySpan{s})
+		}
+		return
+	}
+	// A trace dropped to make room for others is decided on with the
+	// spans that end afterwards.
+	pt := ts.pendingTrace(id)
+	pt.spans = append(pt.spans, s)
+	if pt.open--; pt.open > 0 {
+		ts.mu.Unlock()
+		return
+	}
+	ts.order.Remove(ts.pending[id])
+	delete(ts.pending, id)
+	keep := ts.keep(id, pt.spans, longest(pt.spans))
+	ts.remember(id, keep)
+	ts.mu.Unlock()
+
+	if keep {
+		ts.export(pt.spans)
+	}
+}
+
+// longest returns the duration of the longest of spans, which is the
+// outermost one when they are nested.
+func longest(spans []sdktrace.ReadOnlySpan) time.Duration {
+	var d time.Duration
+	for _, s := range spans {
+		d = max(d, s.EndTime().Sub(s.StartTime()))
+	}
+	return d
+}
+
+// keep reports whether the trace with the given spans, lasting duration,
+// should be exported.
+func (ts *tailSampler) keep(id trace.TraceID, spans []sdktrace.ReadOnlySpan, duration time.Duration) bool {
+	if duration >= ts.latency {
+		return true
+	}
+	for _, s := range spans {
+		if s.Status().Code == codes.Error {
+			return true
+		}
+	}
+	res := ts.ratio.ShouldSample(sdktrace.SamplingParameters{TraceID: id})
+	return res.Decision == sdktrace.RecordAndSample
+}
+
+// remember records a decision, forgetting the oldest once there are more
+// than maxTraces. ts.mu must be held.
+func (ts *tailSampler) remember(id trace.TraceID, keep bool) {
+	if len(ts.decidedOrder) >= ts.maxTraces {
+		delete(ts.decided, ts.decidedOrder[0])
+		ts.decidedOrder = ts.decidedOrder[1:]
+	}
+	ts.decided[id] = keep
+	ts.decidedOrder = append(ts.decidedOrder, id)
+}
+
+func (ts *tailSampler) export(spans []sdktrace.ReadOnlySpan) {
+	for _, s := range spans {
+		for _, p := range ts.next {
+			p.OnEnd(s)
+		}
+	}
+}
+
+// Shutdown decides on the traces still pending, as if their open spans had
+// ended quickly, and shuts down the next processors.
+func (ts *tailSampler) Shutdown(ctx context.Context) error {
+	ts.mu.Lock()
+	var keep []sdktrace.ReadOnlySpan
+	for e := ts.order.Front(); e != nil; e = e.Next() {
+		pt := e.Value.(*pendingTrace)
+		if ts.keep(pt.id, pt.spans, 0) {
+			keep = append(keep, pt.spans...)
+		}
+	}
+	ts.order.Init()
+	clear(ts.pending)
+	ts.mu.Unlock()
+
+	ts.export(keep)
+	var err error
+	for _, p := range ts.next {
+		err = errors.Join(err, p.Shutdown(ctx))
+	}
+	return err
+}
+
+// ForceFlush flushes the next processors. Traces with open spans stay
+// pending.
+func (ts *tailSampler) ForceFlush(ctx context.Context) error {
+	var err error
+	for _, p := range ts.next {
+		err = errors.Join(err, p.ForceFlush(ctx))
+	}
+	return err
+}
+
+// newSampler returns the head sampler for cfg. Tail sampling needs every
+// span, so it uses AlwaysSample and leaves the decision to tailSampler.
+func newSampler(cfg telemetryConfig) sdktrace.Sampler {
+	ratio := cfg.Traces.Ratio
+	switch cfg.Traces.Sampler {
+	case samplerAlwaysOn, samplerTail:
+		return sdktrace.AlwaysSample()
+	case samplerAlwaysOff:
+		return sdktrace.NeverSample()
+	case samplerTraceIDRatio:
+		return sdktrace.TraceIDRatioBased(ratio)
+	case samplerParentBasedAlwaysOff:
+		return sdktrace.ParentBased(sdktrace.NeverSample())
+	case samplerParentBasedTraceIDRatio:
+		return sdktrace.ParentBased(sdktrace.TraceIDRatioBased(ratio))
+	}
+	return sdktrace.ParentBased(sdktrace.AlwaysSample())
+}
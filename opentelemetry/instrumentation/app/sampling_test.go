@@ -0,0 +1,229 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// newTailTracer returns a tracer whose spans go through a tail sampler that
+// keeps no trace by ratio, and the exporter receiving the kept spans.
+func newTailTracer(t *testing.T, maxTraces int) (trace.Tracer, *tailSampler, *tracetest.InMemoryExporter) {
+	exp := tracetest.NewInMemoryExporter()
+	ts := newTailSampler(time.Second, 0, maxTraces, sdktrace.NewSimpleSpanProcessor(exp))
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSampler(sdktrace.AlwaysSample()), sdktrace.WithSpanProcessor(ts))
+	t.Cleanup(func() { tp.Shutdown(context.Background()) })
+	return tp.Tracer("test"), ts, exp
+}
+
+// spanNames returns the names of the exported spans, in export order.
+func spanNames(exp *tracetest.InMemoryExporter) []string {
+	var names []string
+	for _, s := range exp.GetSpans() {
+		names = append(names, s.Name)
+	}
+	return names
+}
+
+func TestTailSamplerDecisions(t *testing.T) {
+	start := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	tests := []struct {
+		name  string
+		trace func(tracer trace.Tracer)
+		want  []string
+	}{
+		{
+			name: "fast trace dropped",
+			trace: func(tracer trace.Tracer) {
+				ctx, root := tracer.Start(context.Background(), "root", trace.WithTimestamp(start))
+				_, child := tracer.Start(ctx, "child")
+				child.End()
+				root.End(trace.WithTimestamp(start.Add(10 * time.Millisecond)))
+			},
+		},
+		{
+			name: "slow trace kept",
+			trace: func(tracer trace.Tracer) {
+				ctx, root := tracer.Start(context.Background(), "root", trace.WithTimestamp(start))
+				_, child := tracer.Start(ctx, "child")
+				child.End()
+				root.End(trace.WithTimestamp(start.Add(2 * time.Second)))
+			},
+			want: []string{"child", "root"},
+		},
+		{
+			name: "error in child kept",
+			trace: func(tracer trace.Tracer) {
+				ctx, root := tracer.Start(context.Background(), "root", trace.WithTimestamp(start))
+				_, child := tracer.Start(ctx, "child")
+				child.SetStatus(codes.Error, "boom")
+				child.End()
+				root.End(trace.WithTimestamp(start.Add(10 * time.Millisecond)))
+			},
+			want: []string{"child", "root"},
+		},
+		{
+			name: "span with a remote parent",
+			trace: func(tracer trace.Tracer) {
+				remote := trace.NewSpanContext(trace.SpanContextConfig{
+					TraceID:    trace.TraceID{1},
+					SpanID:     trace.SpanID{1},
+					TraceFlags: trace.FlagsSampled,
+					Remote:     true,
+				})
+				ctx := trace.ContextWithRemoteSpanContext(context.Background(), remote)
+				_, span := tracer.Start(ctx, "server", trace.WithTimestamp(start))
+				span.SetStatus(codes.Error, "boom")
+				span.End(trace.WithTimestamp(start.Add(time.Millisecond)))
+			},
+			want: []string{"server"},
+		},
+		{
+			name: "late span follows decision",
+			trace: func(tracer trace.Tracer) {
+				ctx, root := tracer.Start(context.Background(), "root", trace.WithTimestamp(start))
+				_, async := tracer.Start(ctx, "async")
+				root.End(trace.WithTimestamp(start.Add(2 * time.Second)))
+				async.End()
+			},
+			want: []string{"root", "async"},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tracer, _, exp := newTailTracer(t, 10)
+			tc.trace(tracer)
+			got := spanNames(exp)
+			if len(got) != len(tc.want) {
+				t.Fatalf("got spans %v, want %v", got, tc.want)
+			}
+			for i := range got {
+				if got[i] != tc.want[i] {
+					t.Errorf("got spans %v, want %v", got, tc.want)
+					break
+				}
+			}
+		})
+	}
+}
+
+func TestTailSamplerRatio(t *testing.T) {
+	exp := tracetest.NewInMemoryExporter()
+	ts := newTailSampler(time.Hour, 1, 10, sdktrace.NewSimpleSpanProcessor(exp))
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(ts))
+	defer tp.Shutdown(context.Background())
+
+	_, span := tp.Tracer("test").Start(context.Background(), "root")
+	span.End()
+	if got := len(exp.GetSpans()); got != 1 {
+		t.Errorf("ratio 1: got %d spans, want 1", got)
+	}
+}
+
+func TestTailSamplerMaxTraces(t *testing.T) {
+	tracer, ts, exp := newTailTracer(t, 2)
+
+	// Leave three traces pending with an error in a child span. The first
+	// is dropped to make room for the third.
+	var roots []trace.Span
+	for i := 0; i < 3; i++ {
+		ctx, root := tracer.Start(context.Background(), "root")
+		_, child := tracer.Start(ctx, "child")
+		child.SetStatus(codes.Error, "boom")
+		child.End()
+		roots = append(roots, root)
+	}
+	if got := len(ts.pending); got != 2 {
+		t.Errorf("got %d pending traces, want 2", got)
+	}
+	// End the roots newest first, so the first root does not evict the
+	// others. It is now on its own, without an error, so it is dropped.
+	for i := len(roots) - 1; i >= 0; i-- {
+		roots[i].End()
+	}
+	if got, want := len(exp.GetSpans()), 4; got != want {
+		t.Errorf("got %d exported spans, want %d: %v", got, want, spanNames(exp))
+	}
+}
+
+func TestTailSamplerShutdown(t *testing.T) {
+	// The in-memory exporter forgets its spans on shutdown, so record them
+	// with a span processor instead.
+	rec := tracetest.NewSpanRecorder()
+	ts := newTailSampler(time.Second, 0, 10, rec)
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(ts))
+	tracer := tp.Tracer("test")
+
+	ctx, root := tracer.Start(context.Background(), "root")
+	_, child := tracer.Start(ctx, "child")
+	child.SetStatus(codes.Error, "boom")
+	child.End()
+	if err := ts.ForceFlush(context.Background()); err != nil {
+		t.Fatalf("ForceFlush: %v", err)
+	}
+	if got := len(rec.Ended()); got != 0 {
+		t.Errorf("after ForceFlush: got %d spans, want 0 while the root is running", got)
+	}
+	if err := ts.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+	if got := rec.Ended(); len(got) != 1 || got[0].Name() != "child" {
+		t.Errorf("after Shutdown: got %d spans, want the child span", len(got))
+	}
+	root.End()
+}
+
+func TestNewSampler(t *testing.T) {
+	remoteSampled := trace.ContextWithRemoteSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{1},
+		SpanID:     trace.SpanID{1},
+		TraceFlags: trace.FlagsSampled,
+		Remote:     true,
+	}))
+	tests := []struct {
+		sampler string
+		ratio   float64
+		ctx     context.Context
+		want    sdktrace.SamplingDecision
+	}{
+		{samplerAlwaysOn, 0, context.Background(), sdktrace.RecordAndSample},
+		{samplerAlwaysOff, 1, context.Background(), sdktrace.Drop},
+		{samplerTraceIDRatio, 0, remoteSampled, sdktrace.Drop},
+		{samplerParentBasedTraceIDRatio, 0, remoteSampled, sdktrace.RecordAndSample},
+		{samplerParentBasedTraceIDRatio, 0, context.Background(), sdktrace.Drop},
+		{samplerParentBasedAlwaysOff, 0, remoteSampled, sdktrace.RecordAndSample},
+		{samplerParentBasedAlwaysOn, 0, context.Background(), sdktrace.RecordAndSample},
+		{samplerTail, 0, context.Background(), sdktrace.RecordAndSample},
+	}
+	for _, tc := range tests {
+		cfg := defaultConfig()
+		cfg.Traces.Sampler = tc.sampler
+		cfg.Traces.Ratio = tc.ratio
+		res := newSampler(cfg).ShouldSample(sdktrace.SamplingParameters{
+			ParentContext: tc.ctx,
+			TraceID:       trace.TraceID{1},
+		})
+		if res.Decision != tc.want {
+			t.Errorf("%s (ratio %v): got decision %v, want %v", tc.sampler, tc.ratio, res.Decision, tc.want)
+		}
+	}
+}
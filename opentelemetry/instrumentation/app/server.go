@@ -57,19 +57,25 @@ func handleMulti(w http.ResponseWriter, r *http.Request) {
 // /multi and /single endpoints.
 // [START opentelemetry_instrumentation_run_server]
 func runServer() error {
-	handleHTTP("/single", handleSingle)
-	handleHTTP("/multi", handleMulti)
+	return http.ListenAndServe(":8080", newServeMux())
+}
 
-	return http.ListenAndServe(":8080", nil)
+// newServeMux returns a ServeMux which handles requests to the /multi and
+// /single endpoints.
+func newServeMux() *http.ServeMux {
+	mux := http.NewServeMux()
+	handleHTTP(mux, "/single", handleSingle)
+	handleHTTP(mux, "/multi", handleMulti)
+	return mux
 }
 
 // handleHTTP handles the http HandlerFunc on the specified route, and uses
 // otelhttp for context propagation, trace instrumentation, and metric
 // instrumentation.
-func handleHTTP(route string, handleFn http.HandlerFunc) {
+func handleHTTP(mux *http.ServeMux, route string, handleFn http.HandlerFunc) {
 	instrumentedHandler := otelhttp.NewHandler(otelhttp.WithRouteTag(route, handleFn), route)
 
-	http.Handle(route, instrumentedHandler)
+	mux.Handle(route, instrumentedHandler)
 }
 
 // [END opentelemetry_instrumentation_run_server]
@@ -17,59 +17,151 @@ package main
 import (
 	"context"
 	"errors"
+	"fmt"
 	"log/slog"
 	"os"
 
-	"go.opentelemetry.io/contrib/exporters/autoexport"
 	"go.opentelemetry.io/contrib/propagators/autoprop"
 	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdoutmetric"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
 	"go.opentelemetry.io/otel/sdk/metric"
 	"go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 )
 
+// telemetry holds the configured OpenTelemetry pipeline.
+type telemetry struct {
+	// spans and metrics are set when the "memory" exporter is configured
+	// for traces and metrics, respectively.
+	spans   *tracetest.InMemoryExporter
+	metrics *metric.ManualReader
+
+	shutdownFuncs []func(context.Context) error
+}
+
+// Shutdown flushes and stops every component of the pipeline.
+func (t *telemetry) Shutdown(ctx context.Context) error {
+	var err error
+	for _, fn := range t.shutdownFuncs {
+		err = errors.Join(err, fn(ctx))
+	}
+	t.shutdownFuncs = nil
+	return err
+}
+
 // setupOpenTelemetry sets up the OpenTelemetry SDK and exporters for metrics and
-// traces. If it does not return an error, call shutdown for proper cleanup.
+// traces. If it does not return an error, call Shutdown for proper cleanup.
 // [START opentelemetry_instrumentation_setup_opentelemetry]
-func setupOpenTelemetry(ctx context.Context) (shutdown func(context.Context) error, err error) {
-	var shutdownFuncs []func(context.Context) error
-
-	// shutdown combines shutdown functions from multiple OpenTelemetry
-	// components into a single function.
-	shutdown = func(ctx context.Context) error {
-		var err error
-		for _, fn := range shutdownFuncs {
-			err = errors.Join(err, fn(ctx))
-		}
-		shutdownFuncs = nil
-		return err
-	}
+func setupOpenTelemetry(ctx context.Context, cfg telemetryConfig) (*telemetry, error) {
+	t := &telemetry{}
 
 	// Configure Context Propagation to use the default W3C traceparent format
 	otel.SetTextMapPropagator(autoprop.NewTextMapPropagator())
 
-	// Configure Trace Export to send spans as OTLP
-	texporter, err := autoexport.NewSpanExporter(ctx)
-	if err != nil {
-		err = errors.Join(err, shutdown(ctx))
-		return
+	// Configure Trace Export to send spans to every configured exporter.
+	var processors []trace.SpanProcessor
+	for _, name := range cfg.Traces.Exporters {
+		p, err := t.newSpanProcessor(ctx, cfg, name)
+		if err != nil {
+			return nil, errors.Join(err, t.Shutdown(ctx))
+		}
+		if p != nil {
+			processors = append(processors, p)
+		}
 	}
-	tp := trace.NewTracerProvider(trace.WithBatcher(texporter))
-	shutdownFuncs = append(shutdownFuncs, tp.Shutdown)
+	opts := []trace.TracerProviderOption{trace.WithSampler(newSampler(cfg))}
+	if cfg.Traces.Sampler == samplerTail {
+		// Let the tail sampler decide which traces reach the exporters.
+		tail := newTailSampler(cfg.Traces.Tail.Latency, cfg.Traces.Ratio, cfg.Traces.Tail.MaxTraces, processors...)
+		processors = []trace.SpanProcessor{tail}
+	}
+	for _, p := range processors {
+		opts = append(opts, trace.WithSpanProcessor(p))
+	}
+	tp := trace.NewTracerProvider(opts...)
+	t.shutdownFuncs = append(t.shutdownFuncs, tp.Shutdown)
 	otel.SetTracerProvider(tp)
 
-	// Configure Metric Export to send metrics as OTLP
-	mreader, err := autoexport.NewMetricReader(ctx)
-	if err != nil {
-		err = errors.Join(err, shutdown(ctx))
-		return
+	// Configure Metric Export to send metrics to every configured exporter.
+	var readers []metric.Option
+	for _, name := range cfg.Metrics.Exporters {
+		r, err := t.newMetricReader(ctx, cfg, name)
+		if err != nil {
+			return nil, errors.Join(err, t.Shutdown(ctx))
+		}
+		if r != nil {
+			readers = append(readers, metric.WithReader(r))
+		}
 	}
-	mp := metric.NewMeterProvider(
-		metric.WithReader(mreader),
-	)
-	shutdownFuncs = append(shutdownFuncs, mp.Shutdown)
+	mp := metric.NewMeterProvider(readers...)
+	t.shutdownFuncs = append(t.shutdownFuncs, mp.Shutdown)
 	otel.SetMeterProvider(mp)
 
-	return shutdown, nil
+	return t, nil
+}
+
+// newSpanProcessor returns a span processor for the named exporter, or nil
+// for "none".
+func (t *telemetry) newSpanProcessor(ctx context.Context, cfg telemetryConfig, name string) (trace.SpanProcessor, error) {
+	var exp trace.SpanExporter
+	var err error
+	switch name {
+	case exporterNone:
+		return nil, nil
+	case exporterMemory:
+		// Export synchronously, so tests see spans as soon as they end.
+		t.spans = tracetest.NewInMemoryExporter()
+		return trace.NewSimpleSpanProcessor(t.spans), nil
+	case exporterConsole:
+		// Logs are written to stdout, so write spans to stderr.
+		exp, err = stdouttrace.New(stdouttrace.WithWriter(os.Stderr))
+	case exporterOTLP:
+		if cfg.OTLP.Protocol == protocolGRPC {
+			exp, err = otlptracegrpc.New(ctx)
+		} else {
+			exp, err = otlptracehttp.New(ctx)
+		}
+	default:
+		err = fmt.Errorf("unknown traces exporter %q", name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return trace.NewBatchSpanProcessor(exp), nil
+}
+
+// newMetricReader returns a metric reader for the named exporter, or nil
+// for "none".
+func (t *telemetry) newMetricReader(ctx context.Context, cfg telemetryConfig, name string) (metric.Reader, error) {
+	var exp metric.Exporter
+	var err error
+	switch name {
+	case exporterNone:
+		return nil, nil
+	case exporterMemory:
+		// Metrics are collected on demand by calling t.metrics.Collect.
+		t.metrics = metric.NewManualReader()
+		return t.metrics, nil
+	case exporterConsole:
+		exp, err = stdoutmetric.New(stdoutmetric.WithWriter(os.Stderr))
+	case exporterOTLP:
+		if cfg.OTLP.Protocol == protocolGRPC {
+			exp, err = otlpmetricgrpc.New(ctx)
+		} else {
+			exp, err = otlpmetrichttp.New(ctx)
+		}
+	default:
+		err = fmt.Errorf("unknown metrics exporter %q", name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return metric.NewPeriodicReader(exp), nil
 }
 
 // [END opentelemetry_instrumentation_setup_opentelemetry]
@@ -78,12 +170,11 @@ func setupOpenTelemetry(ctx context.Context) (shutdown func(context.Context) err
 // context attributes.
 // [START opentelemetry_instrumentation_setup_logging]
 func setupLogging() {
-	// Use json as our base logging format.
-	jsonHandler := slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{ReplaceAttr: replacer})
-	// Add span context attributes when Context is passed to logging calls.
-	instrumentedHandler := handlerWithSpanContext(jsonHandler)
+	// Write structured JSON logs, with span context attributes added when
+	// Context is passed to logging calls.
+	handler := newLogHandler(os.Stdout, slog.LevelInfo)
 	// Set this handler as the global slog handler.
-	slog.SetDefault(slog.New(instrumentedHandler))
+	slog.SetDefault(slog.New(handler))
 }
 
 // [END opentelemetry_instrumentation_setup_logging]
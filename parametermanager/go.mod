@@ -4,10 +4,13 @@ go 1.23.0
 
 require (
 	cloud.google.com/go/parametermanager v0.2.0
+	cloud.google.com/go/secretmanager v1.14.5
 	github.com/GoogleCloudPlatform/golang-samples v0.0.0-20250407185618-bc81fd377b99
 	github.com/gofrs/uuid v4.4.0+incompatible
 	google.golang.org/api v0.228.0
 	google.golang.org/grpc v1.71.1
+	google.golang.org/protobuf v1.36.6
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
@@ -53,5 +56,4 @@ require (
 	google.golang.org/genproto v0.0.0-20250303144028-a0af3efb3deb // indirect
 	google.golang.org/genproto/googleapis/api v0.0.0-20250313205543-e70fdf4c4cb4 // indirect
 	google.golang.org/genproto/googleapis/rpc v0.0.0-20250313205543-e70fdf4c4cb4 // indirect
-	google.golang.org/protobuf v1.36.6 // indirect
 )
@@ -0,0 +1,249 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package paramconfig loads configuration from Parameter Manager.
+//
+// A parameter version holds a JSON or YAML document, whose strings may
+// reference secrets, such as
+//
+//	password: __REF__(//secretmanager.googleapis.com/projects/p/secrets/db/versions/1)
+//
+// The references are replaced by the payloads of the secret versions, and
+// the document is decoded into a Go value with encoding/json, so the
+// fields of structs are named by their json tags. Values implementing
+// Validator are validated after decoding.
+package paramconfig
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	parametermanager "cloud.google.com/go/parametermanager/apiv1"
+	"cloud.google.com/go/parametermanager/apiv1/parametermanagerpb"
+	secretmanager "cloud.google.com/go/secretmanager/apiv1"
+	"cloud.google.com/go/secretmanager/apiv1/secretmanagerpb"
+	"google.golang.org/api/iterator"
+	"gopkg.in/yaml.v3"
+)
+
+// Validator is implemented by configuration values that check their
+// fields after decoding.
+type Validator interface {
+	Validate() error
+}
+
+// Loader loads parameters.
+type Loader struct {
+	Params *parametermanager.Client
+	// Secrets resolves the secret references. It can be nil if the
+	// parameters don't reference secrets.
+	Secrets *secretmanager.Client
+	// OverrideFile, if set, is a JSON or YAML file, by its extension,
+	// loaded instead of the parameters, for local development. Its
+	// secret references are resolved too.
+	OverrideFile string
+}
+
+// Load loads a parameter into v, which is a pointer, and returns the name
+// of the version it loaded. name is a parameter version,
+// projects/PROJECT/locations/LOCATION/parameters/PARAMETER/versions/VERSION,
+// or a parameter, whose most recent enabled version is loaded.
+func (l *Loader) Load(ctx context.Context, name string, v interface{}) (version string, err error) {
+	if l.OverrideFile != "" {
+		return l.OverrideFile, l.loadFile(ctx, v)
+	}
+	param, version := name, ""
+	if i := strings.LastIndex(name, "/versions/"); i >= 0 {
+		param, version = name[:i], name
+	}
+	p, err := l.Params.GetParameter(ctx, &parametermanagerpb.GetParameterRequest{Name: param})
+	if err != nil {
+		return "", fmt.Errorf("paramconfig: getting parameter: %w", err)
+	}
+	if version == "" {
+		if version, err = l.latest(ctx, param); err != nil {
+			return "", err
+		}
+	}
+	pv, err := l.Params.GetParameterVersion(ctx, &parametermanagerpb.GetParameterVersionRequest{
+		Name: version,
+		View: parametermanagerpb.View_FULL,
+	})
+	if err != nil {
+		return "", fmt.Errorf("paramconfig: getting parameter version: %w", err)
+	}
+	if pv.Disabled {
+		return "", fmt.Errorf("paramconfig: parameter version %s is disabled", version)
+	}
+	var format string
+	switch p.Format {
+	case parametermanagerpb.ParameterFormat_JSON:
+		format = "json"
+	case parametermanagerpb.ParameterFormat_YAML:
+		format = "yaml"
+	default:
+		return "", fmt.Errorf("paramconfig: parameter %s is %v, not JSON or YAML", param, p.Format)
+	}
+	if err := l.decode(ctx, pv.GetPayload().GetData(), format, v); err != nil {
+		return "", fmt.Errorf("paramconfig: %s: %w", version, err)
+	}
+	return version, nil
+}
+
+// latest returns the most recently created enabled version of a parameter.
+func (l *Loader) latest(ctx context.Context, param string) (string, error) {
+	var latest *parametermanagerpb.ParameterVersion
+	it := l.Params.ListParameterVersions(ctx, &parametermanagerpb.ListParameterVersionsRequest{Parent: param})
+	for {
+		pv, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return "", fmt.Errorf("paramconfig: listing parameter versions: %w", err)
+		}
+		if pv.Disabled {
+			continue
+		}
+		if latest == nil || pv.GetCreateTime().AsTime().After(latest.GetCreateTime().AsTime()) {
+			latest = pv
+		}
+	}
+	if latest == nil {
+		return "", fmt.Errorf("paramconfig: parameter %s has no enabled versions", param)
+	}
+	return latest.Name, nil
+}
+
+func (l *Loader) loadFile(ctx context.Context, v interface{}) error {
+	data, err := os.ReadFile(l.OverrideFile)
+	if err != nil {
+		return fmt.Errorf("paramconfig: %w", err)
+	}
+	format := "json"
+	switch filepath.Ext(l.OverrideFile) {
+	case ".yaml", ".yml":
+		format = "yaml"
+	}
+	if err := l.decode(ctx, data, format, v); err != nil {
+		return fmt.Errorf("paramconfig: %s: %w", l.OverrideFile, err)
+	}
+	return nil
+}
+
+// decode parses a document, resolves its secret references, and decodes it
+// into v.
+func (l *Loader) decode(ctx context.Context, data []byte, format string, v interface{}) error {
+	var doc interface{}
+	var err error
+	if format == "yaml" {
+		err = yaml.Unmarshal(data, &doc)
+	} else {
+		err = json.Unmarshal(data, &doc)
+	}
+	if err != nil {
+		return fmt.Errorf("parsing %s: %w", format, err)
+	}
+	r := &resolver{client: l.Secrets, secrets: map[string]string{}}
+	if doc, err = r.resolve(ctx, doc); err != nil {
+		return err
+	}
+
+	// Decode through JSON, rejecting unknown fields to catch typos.
+	b, err := json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return fmt.Errorf("decoding: %w", err)
+	}
+	if val, ok := v.(Validator); ok {
+		if err := val.Validate(); err != nil {
+			return fmt.Errorf("invalid configuration: %w", err)
+		}
+	}
+	return nil
+}
+
+// refPattern matches secret references.
+var refPattern = regexp.MustCompile(`__REF__\(//secretmanager\.googleapis\.com/([^)]+)\)`)
+
+// resolver replaces secret references, accessing each secret once.
+type resolver struct {
+	client  *secretmanager.Client
+	secrets map[string]string
+}
+
+func (r *resolver) resolve(ctx context.Context, doc interface{}) (interface{}, error) {
+	switch d := doc.(type) {
+	case string:
+		var err error
+		s := refPattern.ReplaceAllStringFunc(d, func(ref string) string {
+			if err != nil {
+				return ""
+			}
+			var secret string
+			secret, err = r.access(ctx, refPattern.FindStringSubmatch(ref)[1])
+			return secret
+		})
+		return s, err
+	case map[string]interface{}:
+		for k, v := range d {
+			v, err := r.resolve(ctx, v)
+			if err != nil {
+				return nil, err
+			}
+			d[k] = v
+		}
+	case []interface{}:
+		for i, v := range d {
+			v, err := r.resolve(ctx, v)
+			if err != nil {
+				return nil, err
+			}
+			d[i] = v
+		}
+	}
+	return doc, nil
+}
+
+func (r *resolver) access(ctx context.Context, name string) (string, error) {
+	if s, ok := r.secrets[name]; ok {
+		return s, nil
+	}
+	if r.client == nil {
+		return "", errors.New("secret references require a Secret Manager client")
+	}
+	resp, err := r.client.AccessSecretVersion(ctx, &secretmanagerpb.AccessSecretVersionRequest{Name: name})
+	if err != nil {
+		return "", fmt.Errorf("accessing secret %s: %w", name, err)
+	}
+	data := resp.GetPayload().GetData()
+	checksum := resp.GetPayload().DataCrc32C
+	if checksum == nil || *checksum != int64(crc32.Checksum(data, crc32.MakeTable(crc32.Castagnoli))) {
+		return "", fmt.Errorf("secret %s corrupted in transit", name)
+	}
+	r.secrets[name] = string(data)
+	return string(data), nil
+}
@@ -0,0 +1,99 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package paramconfig
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"time"
+)
+
+// Watch loads a parameter into a new T and calls f with it and the name of
+// its version, then checks for new versions every interval in the
+// background until ctx is done, calling f again with each new version
+// that loads. Versions that fail to load or validate are logged and
+// skipped, keeping the current configuration. With an override file, the
+// file is loaded again when it is modified.
+//
+// Watch returns an error, without starting the background checks, if
+// interval isn't positive or the first load fails.
+func Watch[T any](ctx context.Context, l *Loader, param string, interval time.Duration, f func(cfg *T, version string)) error {
+	if interval <= 0 {
+		return fmt.Errorf("paramconfig: watch interval %v isn't positive", interval)
+	}
+	// load loads the version of a state, or the override file.
+	load := func(state string) error {
+		name := state
+		if l.OverrideFile != "" {
+			name = param
+		}
+		cfg := new(T)
+		version, err := l.Load(ctx, name, cfg)
+		if err != nil {
+			return err
+		}
+		f(cfg, version)
+		return nil
+	}
+	// current identifies the version loaded, or tried last: the version
+	// name, or the modification time of the override file.
+	current, err := watchState(ctx, l, param)
+	if err != nil {
+		return err
+	}
+	if err := load(current); err != nil {
+		return err
+	}
+
+	go func() {
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-t.C:
+			}
+			state, err := watchState(ctx, l, param)
+			if err != nil {
+				log.Printf("paramconfig: %v", err)
+				continue
+			}
+			if state == current {
+				continue
+			}
+			current = state
+			if err := load(state); err != nil {
+				log.Printf("paramconfig: keeping the current configuration: %v", err)
+			}
+		}
+	}()
+	return nil
+}
+
+// watchState returns the latest version of a parameter, or the
+// modification time of the override file.
+func watchState(ctx context.Context, l *Loader, param string) (string, error) {
+	if l.OverrideFile != "" {
+		fi, err := os.Stat(l.OverrideFile)
+		if err != nil {
+			return "", err
+		}
+		return fi.ModTime().String(), nil
+	}
+	return l.latest(ctx, param)
+}
@@ -0,0 +1,289 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package paramtest provides a fake Parameter Manager server for tests.
+//
+// The server keeps parameters and their versions in memory. It also serves
+// AccessSecretVersion of Secret Manager, for the secrets added with
+// AddSecret, so that secret references in parameters can be resolved.
+package paramtest
+
+import (
+	"context"
+	"hash/crc32"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	parametermanager "cloud.google.com/go/parametermanager/apiv1"
+	"cloud.google.com/go/parametermanager/apiv1/parametermanagerpb"
+	secretmanager "cloud.google.com/go/secretmanager/apiv1"
+	"cloud.google.com/go/secretmanager/apiv1/secretmanagerpb"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Server is a fake Parameter Manager server.
+type Server struct {
+	parametermanagerpb.UnimplementedParameterManagerServer
+
+	// Addr is the address of the gRPC server.
+	Addr string
+
+	grpc *grpc.Server
+
+	mu      sync.Mutex
+	params  map[string]*parameter
+	secrets map[string][]byte
+	calls   map[string]int
+	last    time.Time
+}
+
+type parameter struct {
+	pb       *parametermanagerpb.Parameter
+	versions map[string]*parametermanagerpb.ParameterVersion
+}
+
+// NewServer starts a server on a local port.
+func NewServer() (*Server, error) {
+	lis, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		return nil, err
+	}
+	s := &Server{
+		Addr:    lis.Addr().String(),
+		grpc:    grpc.NewServer(),
+		params:  map[string]*parameter{},
+		secrets: map[string][]byte{},
+		calls:   map[string]int{},
+	}
+	parametermanagerpb.RegisterParameterManagerServer(s.grpc, s)
+	secretmanagerpb.RegisterSecretManagerServiceServer(s.grpc, &secretServer{s: s})
+	go s.grpc.Serve(lis)
+	return s, nil
+}
+
+func (s *Server) options() []option.ClientOption {
+	return []option.ClientOption{
+		option.WithEndpoint(s.Addr),
+		option.WithoutAuthentication(),
+		option.WithGRPCDialOption(grpc.WithTransportCredentials(insecure.NewCredentials())),
+	}
+}
+
+// Client returns a Parameter Manager client of the server.
+func (s *Server) Client(ctx context.Context) (*parametermanager.Client, error) {
+	return parametermanager.NewClient(ctx, s.options()...)
+}
+
+// SecretClient returns a Secret Manager client of the server.
+func (s *Server) SecretClient(ctx context.Context) (*secretmanager.Client, error) {
+	return secretmanager.NewClient(ctx, s.options()...)
+}
+
+// Close stops the server.
+func (s *Server) Close() {
+	s.grpc.Stop()
+}
+
+// Calls returns the number of calls of a method, such as
+// "GetParameterVersion".
+func (s *Server) Calls(method string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.calls[method]
+}
+
+// AddSecret adds a secret version, such as
+// projects/PROJECT/secrets/SECRET/versions/1, for AccessSecretVersion.
+func (s *Server) AddSecret(name string, data []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.secrets[name] = data
+}
+
+// nowLocked returns the current time, after the last time it returned, so
+// that versions created in a row are ordered.
+func (s *Server) nowLocked() *timestamppb.Timestamp {
+	now := time.Now()
+	if !now.After(s.last) {
+		now = s.last.Add(time.Microsecond)
+	}
+	s.last = now
+	return timestamppb.New(now)
+}
+
+// parameterLocked returns a parameter, and counts a call of a method.
+func (s *Server) parameterLocked(method, name string) (*parameter, error) {
+	s.calls[method]++
+	p, ok := s.params[name]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "parameter %q not found", name)
+	}
+	return p, nil
+}
+
+// CreateParameter creates a parameter without versions.
+func (s *Server) CreateParameter(ctx context.Context, req *parametermanagerpb.CreateParameterRequest) (*parametermanagerpb.Parameter, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls["CreateParameter"]++
+	name := req.GetParent() + "/parameters/" + req.GetParameterId()
+	if _, ok := s.params[name]; ok {
+		return nil, status.Errorf(codes.AlreadyExists, "parameter %q already exists", name)
+	}
+	pb := proto.Clone(req.GetParameter()).(*parametermanagerpb.Parameter)
+	pb.Name = name
+	pb.CreateTime = s.nowLocked()
+	pb.UpdateTime = pb.CreateTime
+	s.params[name] = &parameter{pb: pb, versions: map[string]*parametermanagerpb.ParameterVersion{}}
+	return proto.Clone(pb).(*parametermanagerpb.Parameter), nil
+}
+
+// GetParameter returns a parameter.
+func (s *Server) GetParameter(ctx context.Context, req *parametermanagerpb.GetParameterRequest) (*parametermanagerpb.Parameter, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p, err := s.parameterLocked("GetParameter", req.GetName())
+	if err != nil {
+		return nil, err
+	}
+	return proto.Clone(p.pb).(*parametermanagerpb.Parameter), nil
+}
+
+// CreateParameterVersion adds a version to a parameter.
+func (s *Server) CreateParameterVersion(ctx context.Context, req *parametermanagerpb.CreateParameterVersionRequest) (*parametermanagerpb.ParameterVersion, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p, err := s.parameterLocked("CreateParameterVersion", req.GetParent())
+	if err != nil {
+		return nil, err
+	}
+	name := p.pb.Name + "/versions/" + req.GetParameterVersionId()
+	if _, ok := p.versions[name]; ok {
+		return nil, status.Errorf(codes.AlreadyExists, "parameter version %q already exists", name)
+	}
+	v := proto.Clone(req.GetParameterVersion()).(*parametermanagerpb.ParameterVersion)
+	v.Name = name
+	v.CreateTime = s.nowLocked()
+	v.UpdateTime = v.CreateTime
+	p.versions[name] = v
+	return proto.Clone(v).(*parametermanagerpb.ParameterVersion), nil
+}
+
+// GetParameterVersion returns a parameter version. The payload of disabled
+// versions isn't returned.
+func (s *Server) GetParameterVersion(ctx context.Context, req *parametermanagerpb.GetParameterVersionRequest) (*parametermanagerpb.ParameterVersion, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	name := req.GetName()
+	i := strings.LastIndex(name, "/versions/")
+	if i < 0 {
+		s.calls["GetParameterVersion"]++
+		return nil, status.Errorf(codes.InvalidArgument, "invalid parameter version name %q", name)
+	}
+	p, err := s.parameterLocked("GetParameterVersion", name[:i])
+	if err != nil {
+		return nil, err
+	}
+	v, ok := p.versions[name]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "parameter version %q not found", name)
+	}
+	v = proto.Clone(v).(*parametermanagerpb.ParameterVersion)
+	if v.Disabled || req.GetView() == parametermanagerpb.View_BASIC {
+		v.Payload = nil
+	}
+	return v, nil
+}
+
+// ListParameterVersions returns the versions of a parameter, without
+// payloads, in a single page.
+func (s *Server) ListParameterVersions(ctx context.Context, req *parametermanagerpb.ListParameterVersionsRequest) (*parametermanagerpb.ListParameterVersionsResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p, err := s.parameterLocked("ListParameterVersions", req.GetParent())
+	if err != nil {
+		return nil, err
+	}
+	resp := &parametermanagerpb.ListParameterVersionsResponse{}
+	for _, v := range p.versions {
+		v = proto.Clone(v).(*parametermanagerpb.ParameterVersion)
+		v.Payload = nil
+		resp.ParameterVersions = append(resp.ParameterVersions, v)
+	}
+	sort.Slice(resp.ParameterVersions, func(i, j int) bool {
+		return resp.ParameterVersions[i].Name < resp.ParameterVersions[j].Name
+	})
+	return resp, nil
+}
+
+// UpdateParameterVersion enables or disables a parameter version.
+func (s *Server) UpdateParameterVersion(ctx context.Context, req *parametermanagerpb.UpdateParameterVersionRequest) (*parametermanagerpb.ParameterVersion, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	name := req.GetParameterVersion().GetName()
+	i := strings.LastIndex(name, "/versions/")
+	if i < 0 {
+		s.calls["UpdateParameterVersion"]++
+		return nil, status.Errorf(codes.InvalidArgument, "invalid parameter version name %q", name)
+	}
+	p, err := s.parameterLocked("UpdateParameterVersion", name[:i])
+	if err != nil {
+		return nil, err
+	}
+	v, ok := p.versions[name]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "parameter version %q not found", name)
+	}
+	for _, path := range req.GetUpdateMask().GetPaths() {
+		if path != "disabled" {
+			return nil, status.Errorf(codes.InvalidArgument, "can't update %q", path)
+		}
+		v.Disabled = req.GetParameterVersion().GetDisabled()
+		v.UpdateTime = s.nowLocked()
+	}
+	return proto.Clone(v).(*parametermanagerpb.ParameterVersion), nil
+}
+
+// secretServer serves the secrets of a Server.
+type secretServer struct {
+	secretmanagerpb.UnimplementedSecretManagerServiceServer
+	s *Server
+}
+
+// AccessSecretVersion returns the payload of a secret version added with
+// AddSecret.
+func (ss *secretServer) AccessSecretVersion(ctx context.Context, req *secretmanagerpb.AccessSecretVersionRequest) (*secretmanagerpb.AccessSecretVersionResponse, error) {
+	s := ss.s
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls["AccessSecretVersion"]++
+	data, ok := s.secrets[req.GetName()]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "secret version %q not found", req.GetName())
+	}
+	checksum := int64(crc32.Checksum(data, crc32.MakeTable(crc32.Castagnoli)))
+	return &secretmanagerpb.AccessSecretVersionResponse{
+		Name:    req.GetName(),
+		Payload: &secretmanagerpb.SecretPayload{Data: data, DataCrc32C: &checksum},
+	}, nil
+}
@@ -0,0 +1,244 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package certissuer
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	privateca "cloud.google.com/go/security/privateca/apiv1"
+	"cloud.google.com/go/security/privateca/apiv1/privatecapb"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+func newLocalCA(t *testing.T) *LocalCA {
+	t.Helper()
+	ca, err := NewLocalCA("Test Root", 365*24*time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ca
+}
+
+// verify verifies a certificate chain against the root of a CA.
+func verify(t *testing.T, ca *LocalCA, c *Certificate, usage x509.ExtKeyUsage) *x509.Certificate {
+	t.Helper()
+	cert, err := parseCertificate([]byte(c.PEM))
+	if err != nil {
+		t.Fatal(err)
+	}
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.Certificate())
+	if _, err := cert.Verify(x509.VerifyOptions{Roots: roots, KeyUsages: []x509.ExtKeyUsage{usage}}); err != nil {
+		t.Errorf("Verify: %v", err)
+	}
+	return cert
+}
+
+func TestLocalCA(t *testing.T) {
+	ca := newLocalCA(t)
+	key, err := GenerateKey(RSA2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := Request{
+		CommonName:     "svc",
+		DNSNames:       []string{"svc.example.com"},
+		IPAddresses:    []net.IP{net.ParseIP("10.0.0.1")},
+		EmailAddresses: []string{"ops@example.com"},
+		KeyUsage:       x509.KeyUsageDigitalSignature | x509.KeyUsageContentCommitment,
+		ExtKeyUsage:    []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth, x509.ExtKeyUsageCodeSigning},
+	}
+	csr, err := NewCSR(key, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	issued, err := ca.Issue(context.Background(), csr, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert := verify(t, ca, issued, x509.ExtKeyUsageClientAuth)
+	if cert.Subject.CommonName != "svc" || !reflect.DeepEqual(cert.DNSNames, req.DNSNames) ||
+		len(cert.IPAddresses) != 1 || !cert.IPAddresses[0].Equal(req.IPAddresses[0]) ||
+		!reflect.DeepEqual(cert.EmailAddresses, req.EmailAddresses) {
+		t.Errorf("certificate names %v %v %v %v, want those of %+v", cert.Subject, cert.DNSNames, cert.IPAddresses, cert.EmailAddresses, req)
+	}
+	if cert.KeyUsage != req.KeyUsage || !reflect.DeepEqual(cert.ExtKeyUsage, req.ExtKeyUsage) {
+		t.Errorf("certificate usages %v %v, want %v %v", cert.KeyUsage, cert.ExtKeyUsage, req.KeyUsage, req.ExtKeyUsage)
+	}
+	if cert.IsCA || cert.NotAfter.Sub(cert.NotBefore) != time.Hour {
+		t.Errorf("certificate IsCA %v, lifetime %v; want a leaf valid for an hour", cert.IsCA, cert.NotAfter.Sub(cert.NotBefore))
+	}
+
+	// The lifetime is shortened to that of the CA.
+	issued, err = ca.Issue(context.Background(), csr, 10*365*24*time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cert := verify(t, ca, issued, x509.ExtKeyUsageClientAuth); cert.NotAfter.After(ca.Certificate().NotAfter) {
+		t.Errorf("certificate expires %v, after the CA %v", cert.NotAfter, ca.Certificate().NotAfter)
+	}
+
+	if _, err := ca.Issue(context.Background(), []byte("not a CSR"), time.Hour); err == nil {
+		t.Error("Issue(invalid CSR) succeeded, want error")
+	}
+}
+
+func TestDefaultKeyUsage(t *testing.T) {
+	ca := newLocalCA(t)
+	for alg, want := range map[KeyAlgorithm]x509.KeyUsage{
+		ECDSAP256: x509.KeyUsageDigitalSignature,
+		RSA2048:   x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+	} {
+		key, err := GenerateKey(alg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		csr, err := NewCSR(key, Request{CommonName: "svc"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		issued, err := ca.Issue(context.Background(), csr, time.Hour)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cert := verify(t, ca, issued, x509.ExtKeyUsageServerAuth)
+		if cert.KeyUsage != want {
+			t.Errorf("%s: key usage %v, want %v", alg, cert.KeyUsage, want)
+		}
+	}
+}
+
+func TestManager(t *testing.T) {
+	ca := newLocalCA(t)
+	now := time.Now()
+	ca.Now = func() time.Time { return now }
+	m := &Manager{Issuer: ca, Dir: t.TempDir(), Now: func() time.Time { return now }}
+	ctx := context.Background()
+	req := Request{CommonName: "web", DNSNames: []string{"web.example.com"}, Lifetime: 30 * time.Hour}
+
+	for _, tc := range []struct {
+		after   time.Duration
+		renewed bool
+	}{
+		{0, true},
+		{time.Hour, false},
+		// Renewed a third of the lifetime before expiring.
+		{20 * time.Hour, true},
+		{time.Hour, false},
+	} {
+		now = now.Add(tc.after)
+		renewed, err := m.Renew(ctx, "web", req)
+		if err != nil || renewed != tc.renewed {
+			t.Fatalf("Renew after %v = %v, %v; want %v", tc.after, renewed, err, tc.renewed)
+		}
+	}
+	at, err := m.RenewalTime("web")
+	if want := now.Add(-time.Hour).Truncate(time.Second).Add(20 * time.Hour); err != nil || !at.Equal(want) {
+		t.Errorf("RenewalTime = %v, %v; want %v", at, err, want)
+	}
+
+	keyPair, err := tls.LoadX509KeyPair(filepath.Join(m.Dir, "web.crt"), filepath.Join(m.Dir, "web.key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keyPair.Certificate) != 2 {
+		t.Errorf("%d certificates in the chain, want 2", len(keyPair.Certificate))
+	}
+	if fi, err := os.Stat(filepath.Join(m.Dir, "web.key")); err != nil || fi.Mode().Perm() != 0o600 {
+		t.Errorf("key file mode %v, %v; want 0600", fi.Mode(), err)
+	}
+	// No temporary files are left.
+	if files, err := filepath.Glob(filepath.Join(m.Dir, "*")); err != nil || len(files) != 2 {
+		t.Errorf("files %v, %v; want web.crt and web.key", files, err)
+	}
+	if _, err := m.RenewalTime("missing"); err == nil {
+		t.Error("RenewalTime(missing) succeeded, want error")
+	}
+}
+
+// fakeCAService is a fake Certificate Authority Service issuing the
+// certificates of CSRs with a local CA.
+type fakeCAService struct {
+	privatecapb.UnimplementedCertificateAuthorityServiceServer
+	ca  *LocalCA
+	req *privatecapb.CreateCertificateRequest
+}
+
+func (s *fakeCAService) CreateCertificate(ctx context.Context, req *privatecapb.CreateCertificateRequest) (*privatecapb.Certificate, error) {
+	s.req = req
+	csr := req.GetCertificate().GetPemCsr()
+	if csr == "" {
+		return nil, status.Error(codes.InvalidArgument, "a PEM CSR is required")
+	}
+	issued, err := s.ca.Issue(ctx, []byte(csr), req.GetCertificate().GetLifetime().AsDuration())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	return &privatecapb.Certificate{
+		Name:                req.GetParent() + "/certificates/" + req.GetCertificateId(),
+		PemCertificate:      issued.PEM,
+		PemCertificateChain: issued.Chain,
+	}, nil
+}
+
+func TestCAService(t *testing.T) {
+	lis, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := grpc.NewServer()
+	fake := &fakeCAService{ca: newLocalCA(t)}
+	privatecapb.RegisterCertificateAuthorityServiceServer(srv, fake)
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	ctx := context.Background()
+	client, err := privateca.NewCertificateAuthorityClient(ctx,
+		option.WithEndpoint(lis.Addr().String()),
+		option.WithoutAuthentication(),
+		option.WithGRPCDialOption(grpc.WithTransportCredentials(insecure.NewCredentials())))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	pool := "projects/p/locations/us-central1/caPools/pool"
+	m := &Manager{Issuer: &CAService{Client: client, Pool: pool, CA: "ca"}, Dir: t.TempDir()}
+	cert, err := m.Issue(ctx, "api", Request{CommonName: "api", DNSNames: []string{"api.example.com"}, KeyAlgorithm: ECDSAP384})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cert.DNSNames[0] != "api.example.com" || cert.NotAfter.Sub(cert.NotBefore) != 30*24*time.Hour {
+		t.Errorf("certificate %v valid for %v, want api.example.com for 30 days", cert.DNSNames, cert.NotAfter.Sub(cert.NotBefore))
+	}
+	if fake.req.GetParent() != pool || fake.req.GetIssuingCertificateAuthorityId() != "ca" || fake.req.GetCertificateId() == "" {
+		t.Errorf("CreateCertificateRequest %v, want pool %s and CA ca", fake.req, pool)
+	}
+	if _, err := tls.LoadX509KeyPair(filepath.Join(m.Dir, "api.crt"), filepath.Join(m.Dir, "api.key")); err != nil {
+		t.Error(err)
+	}
+}
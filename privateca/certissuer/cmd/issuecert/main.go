@@ -0,0 +1,103 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Command issuecert generates a key and gets a certificate for it from a
+// CA pool of Certificate Authority Service, writing NAME.key and NAME.crt,
+// and renews the certificate when it is due.
+//
+// For example, to keep a certificate for web.example.com renewed:
+//
+//	issuecert -pool projects/my-project/locations/us-central1/caPools/my-pool \
+//		-name web -cn web.example.com -dns web.example.com -watch 1h
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"net"
+	"strings"
+	"time"
+
+	privateca "cloud.google.com/go/security/privateca/apiv1"
+	"github.com/GoogleCloudPlatform/golang-samples/privateca/certissuer"
+)
+
+func main() {
+	pool := flag.String("pool", "", "CA pool, projects/PROJECT/locations/LOCATION/caPools/POOL")
+	ca := flag.String("ca", "", "ID of the CA issuing the certificate; by default, the pool picks one")
+	dir := flag.String("dir", ".", "directory of the key and certificate files")
+	name := flag.String("name", "cert", "name of the key and certificate files")
+	cn := flag.String("cn", "", "common name of the certificate")
+	dns := flag.String("dns", "", "comma-separated DNS names of the certificate")
+	ips := flag.String("ip", "", "comma-separated IP addresses of the certificate")
+	alg := flag.String("key", string(certissuer.ECDSAP256), "key algorithm: ecdsa-p256, ecdsa-p384, rsa-2048 or rsa-3072")
+	lifetime := flag.Duration("lifetime", 30*24*time.Hour, "requested lifetime of the certificate")
+	renewBefore := flag.Duration("renew-before", 0, "how long before expiry to renew; by default, a third of the lifetime")
+	watch := flag.Duration("watch", 0, "if set, check for renewal at this interval instead of exiting")
+	flag.Parse()
+	if *pool == "" {
+		log.Fatal("-pool is required")
+	}
+
+	req := certissuer.Request{
+		CommonName:   *cn,
+		Lifetime:     *lifetime,
+		KeyAlgorithm: certissuer.KeyAlgorithm(*alg),
+	}
+	if *dns != "" {
+		req.DNSNames = strings.Split(*dns, ",")
+	}
+	if *ips != "" {
+		for _, s := range strings.Split(*ips, ",") {
+			ip := net.ParseIP(s)
+			if ip == nil {
+				log.Fatalf("invalid IP address %q", s)
+			}
+			req.IPAddresses = append(req.IPAddresses, ip)
+		}
+	}
+
+	ctx := context.Background()
+	client, err := privateca.NewCertificateAuthorityClient(ctx)
+	if err != nil {
+		log.Fatalf("NewCertificateAuthorityClient creation failed: %v", err)
+	}
+	defer client.Close()
+	m := &certissuer.Manager{
+		Issuer:      &certissuer.CAService{Client: client, Pool: *pool, CA: *ca},
+		Dir:         *dir,
+		RenewBefore: *renewBefore,
+	}
+
+	for {
+		renewed, err := m.Renew(ctx, *name, req)
+		if err != nil {
+			if *watch == 0 {
+				log.Fatal(err)
+			}
+			log.Print(err)
+		} else if at, err := m.RenewalTime(*name); err == nil {
+			if renewed {
+				log.Printf("Issued %s, to be renewed at %v", *name, at)
+			} else {
+				log.Printf("%s is to be renewed at %v", *name, at)
+			}
+		}
+		if *watch == 0 {
+			return
+		}
+		time.Sleep(*watch)
+	}
+}
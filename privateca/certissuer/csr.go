@@ -0,0 +1,183 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package certissuer
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"fmt"
+	"net"
+	"net/url"
+	"time"
+)
+
+// KeyAlgorithm is the algorithm of the keys generated for certificates.
+type KeyAlgorithm string
+
+// The supported key algorithms.
+const (
+	ECDSAP256 KeyAlgorithm = "ecdsa-p256"
+	ECDSAP384 KeyAlgorithm = "ecdsa-p384"
+	RSA2048   KeyAlgorithm = "rsa-2048"
+	RSA3072   KeyAlgorithm = "rsa-3072"
+)
+
+// GenerateKey generates a private key. The default algorithm is ECDSAP256.
+func GenerateKey(alg KeyAlgorithm) (crypto.Signer, error) {
+	switch alg {
+	case ECDSAP256, "":
+		return ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case ECDSAP384:
+		return ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	case RSA2048:
+		return rsa.GenerateKey(rand.Reader, 2048)
+	case RSA3072:
+		return rsa.GenerateKey(rand.Reader, 3072)
+	}
+	return nil, fmt.Errorf("certissuer: unsupported key algorithm %q", alg)
+}
+
+// Request describes a certificate to issue.
+type Request struct {
+	CommonName     string
+	Organization   string
+	DNSNames       []string
+	IPAddresses    []net.IP
+	EmailAddresses []string
+	URIs           []*url.URL
+	// KeyUsage and ExtKeyUsage default to digital signature, and key
+	// encipherment for RSA keys, for server and client authentication.
+	KeyUsage    x509.KeyUsage
+	ExtKeyUsage []x509.ExtKeyUsage
+	// Lifetime is the requested lifetime of the certificate, which the CA
+	// can shorten. It defaults to 30 days.
+	Lifetime time.Duration
+	// KeyAlgorithm is the algorithm of the key of the certificate.
+	KeyAlgorithm KeyAlgorithm
+}
+
+func (r *Request) lifetime() time.Duration {
+	if r.Lifetime == 0 {
+		return 30 * 24 * time.Hour
+	}
+	return r.Lifetime
+}
+
+var (
+	oidExtensionKeyUsage       = asn1.ObjectIdentifier{2, 5, 29, 15}
+	oidExtensionSubjectAltName = asn1.ObjectIdentifier{2, 5, 29, 17}
+	oidExtensionExtKeyUsage    = asn1.ObjectIdentifier{2, 5, 29, 37}
+)
+
+// extKeyUsageOIDs are the OIDs of the supported extended key usages.
+var extKeyUsageOIDs = map[x509.ExtKeyUsage]asn1.ObjectIdentifier{
+	x509.ExtKeyUsageServerAuth:      {1, 3, 6, 1, 5, 5, 7, 3, 1},
+	x509.ExtKeyUsageClientAuth:      {1, 3, 6, 1, 5, 5, 7, 3, 2},
+	x509.ExtKeyUsageCodeSigning:     {1, 3, 6, 1, 5, 5, 7, 3, 3},
+	x509.ExtKeyUsageEmailProtection: {1, 3, 6, 1, 5, 5, 7, 3, 4},
+	x509.ExtKeyUsageTimeStamping:    {1, 3, 6, 1, 5, 5, 7, 3, 8},
+	x509.ExtKeyUsageOCSPSigning:     {1, 3, 6, 1, 5, 5, 7, 3, 9},
+}
+
+// NewCSR returns a PEM-encoded certificate signing request for a key. The
+// key usages are requested with extensions, which the CA copies to the
+// certificate.
+func NewCSR(key crypto.Signer, req Request) ([]byte, error) {
+	ku := req.KeyUsage
+	if ku == 0 {
+		ku = x509.KeyUsageDigitalSignature
+		// Only RSA keys encrypt keys, in TLS 1.2 RSA key exchange.
+		if _, ok := key.Public().(*rsa.PublicKey); ok {
+			ku |= x509.KeyUsageKeyEncipherment
+		}
+	}
+	eku := req.ExtKeyUsage
+	if eku == nil {
+		eku = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth}
+	}
+
+	// The key usage is a bit string, with the first usage in the most
+	// significant bit.
+	var bits asn1.BitString
+	for i := 0; i < 9; i++ {
+		if ku&(1<<i) == 0 {
+			continue
+		}
+		for len(bits.Bytes) <= i/8 {
+			bits.Bytes = append(bits.Bytes, 0)
+		}
+		bits.Bytes[i/8] |= 0x80 >> (i % 8)
+		bits.BitLength = i + 1
+	}
+	kuValue, err := asn1.Marshal(bits)
+	if err != nil {
+		return nil, err
+	}
+	var oids []asn1.ObjectIdentifier
+	for _, u := range eku {
+		oid, ok := extKeyUsageOIDs[u]
+		if !ok {
+			return nil, fmt.Errorf("certissuer: unsupported extended key usage %v", u)
+		}
+		oids = append(oids, oid)
+	}
+	ekuValue, err := asn1.Marshal(oids)
+	if err != nil {
+		return nil, err
+	}
+
+	tmpl := &x509.CertificateRequest{
+		Subject:        pkix.Name{CommonName: req.CommonName},
+		DNSNames:       req.DNSNames,
+		IPAddresses:    req.IPAddresses,
+		EmailAddresses: req.EmailAddresses,
+		URIs:           req.URIs,
+		ExtraExtensions: []pkix.Extension{
+			{Id: oidExtensionKeyUsage, Critical: true, Value: kuValue},
+			{Id: oidExtensionExtKeyUsage, Value: ekuValue},
+		},
+	}
+	if req.Organization != "" {
+		tmpl.Subject.Organization = []string{req.Organization}
+	}
+	der, err := x509.CreateCertificateRequest(rand.Reader, tmpl, key)
+	if err != nil {
+		return nil, fmt.Errorf("certissuer: creating CSR: %w", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: der}), nil
+}
+
+// parseCSR parses and checks the signature of a PEM-encoded CSR.
+func parseCSR(csrPEM []byte) (*x509.CertificateRequest, error) {
+	block, _ := pem.Decode(csrPEM)
+	if block == nil || block.Type != "CERTIFICATE REQUEST" {
+		return nil, fmt.Errorf("certissuer: no PEM certificate request")
+	}
+	csr, err := x509.ParseCertificateRequest(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("certissuer: %w", err)
+	}
+	if err := csr.CheckSignature(); err != nil {
+		return nil, fmt.Errorf("certissuer: %w", err)
+	}
+	return csr, nil
+}
@@ -0,0 +1,102 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package certissuer issues certificates for keys generated locally.
+//
+// The keys never leave the machine: a certificate signing request (CSR),
+// with the names and key usages of the certificate, is submitted to an
+// Issuer, which is CA Service, with CAService, or a LocalCA, for tests.
+// A Manager writes the keys and certificate chains as PEM files and renews
+// the certificates before they expire.
+package certissuer
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	privateca "cloud.google.com/go/security/privateca/apiv1"
+	"cloud.google.com/go/security/privateca/apiv1/privatecapb"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// Issuer issues certificates for CSRs.
+type Issuer interface {
+	// Issue issues a certificate for a PEM-encoded CSR. The lifetime can
+	// be shortened by the issuer.
+	Issue(ctx context.Context, csrPEM []byte, lifetime time.Duration) (*Certificate, error)
+}
+
+// Certificate is an issued certificate.
+type Certificate struct {
+	// Name identifies the certificate for the issuer, such as the resource
+	// name of a CA Service certificate.
+	Name string
+	// PEM is the PEM-encoded certificate.
+	PEM string
+	// Chain are the PEM-encoded certificates of the issuers, in
+	// issuer-to-root order.
+	Chain []string
+}
+
+// FullChain returns the certificate followed by its chain, as written in
+// the certificate files.
+func (c *Certificate) FullChain() []byte {
+	var b strings.Builder
+	for _, p := range append([]string{c.PEM}, c.Chain...) {
+		b.WriteString(p)
+		if !strings.HasSuffix(p, "\n") {
+			b.WriteString("\n")
+		}
+	}
+	return []byte(b.String())
+}
+
+// CAService issues certificates with Certificate Authority Service.
+type CAService struct {
+	Client *privateca.CertificateAuthorityClient
+	// Pool is the CA pool, projects/PROJECT/locations/LOCATION/caPools/POOL.
+	Pool string
+	// CA, if set, is the ID of the CA of the pool issuing the
+	// certificates. By default, the pool picks a CA.
+	CA string
+}
+
+// Issue creates a certificate with a random ID in the pool.
+func (s *CAService) Issue(ctx context.Context, csrPEM []byte, lifetime time.Duration) (*Certificate, error) {
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+	cert, err := s.Client.CreateCertificate(ctx, &privatecapb.CreateCertificateRequest{
+		Parent:        s.Pool,
+		CertificateId: "cert-" + hex.EncodeToString(id),
+		Certificate: &privatecapb.Certificate{
+			CertificateConfig: &privatecapb.Certificate_PemCsr{PemCsr: string(csrPEM)},
+			Lifetime:          durationpb.New(lifetime),
+		},
+		IssuingCertificateAuthorityId: s.CA,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("certissuer: CreateCertificate: %w", err)
+	}
+	return &Certificate{
+		Name:  cert.GetName(),
+		PEM:   cert.GetPemCertificate(),
+		Chain: cert.GetPemCertificateChain(),
+	}, nil
+}
@@ -0,0 +1,171 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package certissuer
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Manager keeps certificates in a directory, renewing them before they
+// expire. The key and certificate chain of a certificate NAME are in
+// NAME.key and NAME.crt, which can be loaded with tls.LoadX509KeyPair.
+type Manager struct {
+	Issuer Issuer
+	Dir    string
+	// RenewBefore is how long before they expire certificates are
+	// renewed. It defaults to a third of their lifetime.
+	RenewBefore time.Duration
+	// Now returns the current time. It defaults to time.Now.
+	Now func() time.Time
+}
+
+func (m *Manager) now() time.Time {
+	if m.Now != nil {
+		return m.Now()
+	}
+	return time.Now()
+}
+
+func (m *Manager) files(name string) (key, cert string) {
+	return filepath.Join(m.Dir, name+".key"), filepath.Join(m.Dir, name+".crt")
+}
+
+// Issue generates a new key, gets a certificate for it and writes them.
+func (m *Manager) Issue(ctx context.Context, name string, req Request) (*x509.Certificate, error) {
+	key, err := GenerateKey(req.KeyAlgorithm)
+	if err != nil {
+		return nil, err
+	}
+	csr, err := NewCSR(key, req)
+	if err != nil {
+		return nil, err
+	}
+	issued, err := m.Issuer.Issue(ctx, csr, req.lifetime())
+	if err != nil {
+		return nil, err
+	}
+	cert, err := parseCertificate([]byte(issued.PEM))
+	if err != nil {
+		return nil, err
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	keyFile, certFile := m.files(name)
+	// Both files are written to temporary files before either replaces
+	// the previous one, so that a failed write leaves the previous pair.
+	keyTemp, err := writeTemp(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600)
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(keyTemp)
+	certTemp, err := writeTemp(certFile, issued.FullChain(), 0o644)
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(certTemp)
+	if err := os.Rename(keyTemp, keyFile); err != nil {
+		return nil, fmt.Errorf("certissuer: %w", err)
+	}
+	if err := os.Rename(certTemp, certFile); err != nil {
+		return nil, fmt.Errorf("certissuer: %w", err)
+	}
+	return cert, nil
+}
+
+// Certificate returns the certificate written for a name.
+func (m *Manager) Certificate(name string) (*x509.Certificate, error) {
+	_, certFile := m.files(name)
+	data, err := os.ReadFile(certFile)
+	if err != nil {
+		return nil, fmt.Errorf("certissuer: %w", err)
+	}
+	return parseCertificate(data)
+}
+
+// RenewalTime returns when the certificate of a name is to be renewed.
+func (m *Manager) RenewalTime(name string) (time.Time, error) {
+	cert, err := m.Certificate(name)
+	if err != nil {
+		return time.Time{}, err
+	}
+	before := m.RenewBefore
+	if before == 0 {
+		before = cert.NotAfter.Sub(cert.NotBefore) / 3
+	}
+	return cert.NotAfter.Add(-before), nil
+}
+
+// Renew issues a certificate for a name if there is none, or if it is due
+// for renewal, and reports whether it did.
+func (m *Manager) Renew(ctx context.Context, name string, req Request) (bool, error) {
+	at, err := m.RenewalTime(name)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return false, err
+	}
+	if err == nil && m.now().Before(at) {
+		return false, nil
+	}
+	if _, err := m.Issue(ctx, name, req); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// parseCertificate parses the first certificate of PEM data.
+func parseCertificate(data []byte) (*x509.Certificate, error) {
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil, errors.New("certissuer: no PEM certificate")
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("certissuer: %w", err)
+	}
+	return cert, nil
+}
+
+// writeTemp writes data to a temporary file next to name, to be renamed
+// to name, and returns its name.
+func writeTemp(name string, data []byte, perm os.FileMode) (string, error) {
+	f, err := os.CreateTemp(filepath.Dir(name), filepath.Base(name)+".tmp*")
+	if err != nil {
+		return "", fmt.Errorf("certissuer: %w", err)
+	}
+	if err := f.Chmod(perm); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return "", fmt.Errorf("certissuer: %w", err)
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return "", fmt.Errorf("certissuer: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return "", fmt.Errorf("certissuer: %w", err)
+	}
+	return f.Name(), nil
+}
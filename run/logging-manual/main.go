@@ -16,9 +16,11 @@
 package main
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
+	"io"
 	"log"
+	"log/slog"
 	"net/http"
 	"os"
 	"strings"
@@ -36,6 +38,7 @@ func main() {
 	if projectID == "" {
 		log.Println("Could not determine Google Cloud Project. Running without log correlation. For local use set the GOOGLE_CLOUD_PROJECT environment variable.")
 	}
+	setupLogging(os.Stderr)
 
 	http.HandleFunc("/", indexHandler)
 
@@ -53,59 +56,100 @@ func main() {
 
 // [START cloudrun_manual_logging_object]
 
-// Entry defines a log entry.
-type Entry struct {
-	Message  string `json:"message"`
-	Severity string `json:"severity,omitempty"`
-	Trace    string `json:"logging.googleapis.com/trace,omitempty"`
-
-	// Logs Explorer allows filtering and display of this as `jsonPayload.component`.
-	Component string `json:"component,omitempty"`
+// LevelNotice is the NOTICE severity of Cloud Logging, between INFO and
+// WARNING.
+const LevelNotice = slog.Level(2)
+
+// traceKey is the context key of the trace of a request.
+type traceKey struct{}
+
+// setupLogging makes the default slog logger write JSON entries in the
+// structured format expected by Cloud Logging to w.
+func setupLogging(w io.Writer) {
+	h := slog.NewJSONHandler(w, &slog.HandlerOptions{
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if len(groups) > 0 {
+				return a
+			}
+			switch a.Key {
+			case slog.LevelKey:
+				// Cloud Logging reads the level from the severity field.
+				return slog.String("severity", severity(a.Value.Any().(slog.Level)))
+			case slog.MessageKey:
+				// The message field is the default display field.
+				a.Key = "message"
+			}
+			return a
+		},
+	})
+	slog.SetDefault(slog.New(traceHandler{h}))
 }
 
-// String renders an entry structure to the JSON format expected by Cloud Logging.
-func (e Entry) String() string {
-	if e.Severity == "" {
-		e.Severity = "INFO"
+// severity returns the Cloud Logging severity of a level.
+func severity(l slog.Level) string {
+	switch {
+	case l < slog.LevelInfo:
+		return "DEBUG"
+	case l < LevelNotice:
+		return "INFO"
+	case l < slog.LevelWarn:
+		return "NOTICE"
+	case l < slog.LevelError:
+		return "WARNING"
+	default:
+		return "ERROR"
 	}
-	out, err := json.Marshal(e)
-	if err != nil {
-		log.Printf("json.Marshal: %v", err)
+}
+
+// traceHandler adds the trace of the request in the context to the
+// entries, so that Cloud Logging correlates them with the request.
+type traceHandler struct {
+	slog.Handler
+}
+
+func (h traceHandler) Handle(ctx context.Context, r slog.Record) error {
+	if trace, ok := ctx.Value(traceKey{}).(string); ok {
+		r.AddAttrs(slog.String("logging.googleapis.com/trace", trace))
 	}
-	return string(out)
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h traceHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return traceHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h traceHandler) WithGroup(name string) slog.Handler {
+	return traceHandler{h.Handler.WithGroup(name)}
 }
 
 // [END cloudrun_manual_logging_object]
 
 // [START cloudrun_manual_logging]
 
-func init() {
-	// Disable log prefixes such as the default timestamp.
-	// Prefix text prevents the message from being parsed as JSON.
-	// A timestamp is added when shipping logs to Cloud Logging.
-	log.SetFlags(0)
-}
-
 func indexHandler(w http.ResponseWriter, r *http.Request) {
 	// Uncomment and populate this variable in your code:
 	// projectID = "The project ID of your Cloud Run service"
 
-	// Derive the traceID associated with the current request.
-	var trace string
+	// Derive the traceID associated with the current request, from the
+	// X-Cloud-Trace-Context header, TRACE_ID/SPAN_ID;o=OPTIONS, or else
+	// the W3C traceparent header, VERSION-TRACE_ID-SPAN_ID-FLAGS.
+	ctx := r.Context()
 	if projectID != "" {
-		traceHeader := r.Header.Get("X-Cloud-Trace-Context")
-		traceParts := strings.Split(traceHeader, "/")
-		if len(traceParts) > 0 && len(traceParts[0]) > 0 {
-			trace = fmt.Sprintf("projects/%s/traces/%s", projectID, traceParts[0])
+		traceID, _, _ := strings.Cut(r.Header.Get("X-Cloud-Trace-Context"), "/")
+		if traceID == "" {
+			if parts := strings.Split(r.Header.Get("traceparent"), "-"); len(parts) == 4 {
+				traceID = parts[1]
+			}
+		}
+		if traceID != "" {
+			ctx = context.WithValue(ctx, traceKey{}, fmt.Sprintf("projects/%s/traces/%s", projectID, traceID))
 		}
 	}
 
-	log.Println(Entry{
-		Severity:  "NOTICE",
-		Message:   "This is the default display field.",
-		Component: "arbitrary-property",
-		Trace:     trace,
-	})
+	slog.Log(ctx, LevelNotice, "This is the default display field.",
+		// Logs Explorer allows filtering and display of this as `jsonPayload.component`.
+		slog.String("component", "arbitrary-property"),
+	)
 
 	fmt.Fprintln(w, "Hello Logger!")
 }
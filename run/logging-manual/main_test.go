@@ -15,13 +15,11 @@
 package main
 
 import (
-	"bufio"
 	"bytes"
 	"encoding/json"
-	"log"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
-	"os"
 	"testing"
 )
 
@@ -30,6 +28,7 @@ func TestIndexHandler(t *testing.T) {
 		name        string
 		project     string
 		traceHeader string
+		traceparent string
 		want        string
 	}{
 		{
@@ -56,16 +55,30 @@ func TestIndexHandler(t *testing.T) {
 			traceHeader: "/123",
 			want:        "",
 		},
+		{
+			name:        "project and traceparent",
+			project:     "example",
+			traceparent: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+			want:        "projects/example/traces/4bf92f3577b34da6a3ce929d0e0e4736",
+		},
 	}
 	for _, test := range tests {
 		projectID = test.project
 		req := httptest.NewRequest("GET", "/", nil)
 		req.Header.Add("X-Cloud-Trace-Context", test.traceHeader)
+		if test.traceparent != "" {
+			req.Header.Add("traceparent", test.traceparent)
+		}
 		rr := httptest.NewRecorder()
 
 		b := callHandler(indexHandler, rr, req)
 
-		var e Entry
+		var e struct {
+			Message   string `json:"message"`
+			Severity  string `json:"severity"`
+			Trace     string `json:"logging.googleapis.com/trace"`
+			Component string `json:"component"`
+		}
 		if err := json.Unmarshal(b.Bytes(), &e); err != nil {
 			t.Errorf("json.Unmarshal: %v", err)
 		}
@@ -73,19 +86,20 @@ func TestIndexHandler(t *testing.T) {
 		if e.Trace != test.want {
 			t.Errorf("indexHandler %q: want %q, got %q", test.name, test.want, e.Trace)
 		}
+		if e.Severity != "NOTICE" || e.Component != "arbitrary-property" {
+			t.Errorf("indexHandler %q: got severity %q and component %q, want NOTICE and arbitrary-property", test.name, e.Severity, e.Component)
+		}
 	}
 }
 
 // callHandler calls an HTTP handler with the provided request and returns the log output.
 func callHandler(h func(w http.ResponseWriter, r *http.Request), rr http.ResponseWriter, req *http.Request) bytes.Buffer {
 	var buf bytes.Buffer
-	writer := bufio.NewWriter(&buf)
 
-	originalWriter := os.Stderr
-	log.SetOutput(writer)
-	defer log.SetOutput(originalWriter)
+	original := slog.Default()
+	setupLogging(&buf)
+	defer slog.SetDefault(original)
 
 	h(rr, req)
-	writer.Flush()
 	return buf
 }
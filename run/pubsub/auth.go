@@ -0,0 +1,66 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strings"
+
+	"google.golang.org/api/idtoken"
+)
+
+// tokenValidator validates a Google-signed ID token. *idtoken.Validator
+// implements it.
+type tokenValidator interface {
+	Validate(ctx context.Context, token, audience string) (*idtoken.Payload, error)
+}
+
+// pushAuthenticator verifies the OIDC token that a push subscription sends
+// in the Authorization header before passing the request to next.
+type pushAuthenticator struct {
+	validator tokenValidator
+	// audience is the audience configured on the push subscription,
+	// by default the URL of the service.
+	audience string
+	// serviceAccount, if set, is the only service account allowed to push.
+	serviceAccount string
+	next           http.Handler
+}
+
+func (a *pushAuthenticator) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || token == "" {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	payload, err := a.validator.Validate(r.Context(), token, a.audience)
+	if err != nil {
+		log.Printf("idtoken.Validate: %v", err)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if a.serviceAccount != "" {
+		email, _ := payload.Claims["email"].(string)
+		verified, _ := payload.Claims["email_verified"].(bool)
+		if !verified || email != a.serviceAccount {
+			log.Printf("Rejected push from %q, want %q", email, a.serviceAccount)
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+	}
+	a.next.ServeHTTP(w, r)
+}
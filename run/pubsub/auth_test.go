@@ -0,0 +1,180 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"log"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/api/idtoken"
+	"google.golang.org/api/option"
+)
+
+const (
+	testKeyID          = "test-key"
+	testAudience       = "https://pubsub-service.example.com/"
+	testServiceAccount = "push@example-project.iam.gserviceaccount.com"
+)
+
+// certsTransport answers every request with the JWKS for key, standing in
+// for Google's certificate endpoint.
+type certsTransport struct {
+	key *rsa.PublicKey
+}
+
+func (t certsTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	jwks := map[string]interface{}{
+		"keys": []map[string]string{{
+			"kid": testKeyID,
+			"kty": "RSA",
+			"alg": "RS256",
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(t.key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(t.key.E)).Bytes()),
+		}},
+	}
+	b, err := json.Marshal(jwks)
+	if err != nil {
+		return nil, err
+	}
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": {"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(string(b))),
+		Request:    r,
+	}, nil
+}
+
+// signToken returns an RS256 JWT with the given claims, signed with key.
+func signToken(t *testing.T, key *rsa.PrivateKey, claims map[string]interface{}) string {
+	t.Helper()
+	header, err := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT", "kid": testKeyID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	sum := sha256.Sum256([]byte(signed))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, sum[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func TestPushAuthenticator(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, err := idtoken.NewValidator(context.Background(), option.WithHTTPClient(&http.Client{
+		Transport: certsTransport{key: &key.PublicKey},
+	}))
+	if err != nil {
+		t.Fatalf("idtoken.NewValidator: %v", err)
+	}
+
+	claims := func(aud, email string, verified bool, exp time.Time) map[string]interface{} {
+		return map[string]interface{}{
+			"iss":            "https://accounts.google.com",
+			"aud":            aud,
+			"email":          email,
+			"email_verified": verified,
+			"sub":            "1234567890",
+			"iat":            time.Now().Unix(),
+			"exp":            exp.Unix(),
+		}
+	}
+	valid := claims(testAudience, testServiceAccount, true, time.Now().Add(time.Hour))
+
+	tests := []struct {
+		name  string
+		token string
+		want  int
+	}{
+		{name: "valid", token: signToken(t, key, valid), want: http.StatusOK},
+		{name: "missing", token: "", want: http.StatusUnauthorized},
+		{name: "malformed", token: "not-a-jwt", want: http.StatusUnauthorized},
+		{name: "wrong_key", token: signToken(t, otherKey, valid), want: http.StatusUnauthorized},
+		{
+			name:  "wrong_audience",
+			token: signToken(t, key, claims("https://other.example.com/", testServiceAccount, true, time.Now().Add(time.Hour))),
+			want:  http.StatusUnauthorized,
+		},
+		{
+			name:  "expired",
+			token: signToken(t, key, claims(testAudience, testServiceAccount, true, time.Now().Add(-time.Hour))),
+			want:  http.StatusUnauthorized,
+		},
+		{
+			name:  "wrong_service_account",
+			token: signToken(t, key, claims(testAudience, "intruder@example.com", true, time.Now().Add(time.Hour))),
+			want:  http.StatusForbidden,
+		},
+		{
+			name:  "unverified_email",
+			token: signToken(t, key, claims(testAudience, testServiceAccount, false, time.Now().Add(time.Hour))),
+			want:  http.StatusForbidden,
+		},
+	}
+	for _, test := range tests {
+		called := false
+		a := &pushAuthenticator{
+			validator:      v,
+			audience:       testAudience,
+			serviceAccount: testServiceAccount,
+			next: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				called = true
+			}),
+		}
+		req := httptest.NewRequest("POST", "/", strings.NewReader("{}"))
+		if test.token != "" {
+			req.Header.Set("Authorization", "Bearer "+test.token)
+		}
+		rr := httptest.NewRecorder()
+		a.ServeHTTP(rr, req)
+
+		if got := rr.Result().StatusCode; got != test.want {
+			t.Errorf("pushAuthenticator(%q): got status %d, want %d", test.name, got, test.want)
+		}
+		if wantCalled := test.want == http.StatusOK; called != wantCalled {
+			t.Errorf("pushAuthenticator(%q): next called = %v, want %v", test.name, called, wantCalled)
+		}
+	}
+}
@@ -18,15 +18,36 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
 	"log"
 	"net/http"
 	"os"
+	"time"
+	"unicode/utf8"
+
+	"google.golang.org/api/idtoken"
 )
 
 func main() {
-	http.HandleFunc("/", HelloPubSub)
+	var handler http.Handler = http.HandlerFunc(HelloPubSub)
+	// Verify the push subscription's OIDC token when an audience is configured.
+	if audience := os.Getenv("PUBSUB_VERIFICATION_AUDIENCE"); audience != "" {
+		v, err := idtoken.NewValidator(context.Background())
+		if err != nil {
+			log.Fatalf("idtoken.NewValidator: %v", err)
+		}
+		handler = &pushAuthenticator{
+			validator:      v,
+			audience:       audience,
+			serviceAccount: os.Getenv("PUBSUB_SERVICE_ACCOUNT"),
+			next:           handler,
+		}
+	}
+	http.Handle("/", handler)
 	// Determine port for HTTP service.
 	port := os.Getenv("PORT")
 	if port == "" {
@@ -49,13 +70,31 @@ func main() {
 // https://cloud.google.com/pubsub/docs/reference/rest/v1/PubsubMessage
 type PubSubMessage struct {
 	Message struct {
-		Data []byte `json:"data,omitempty"`
-		ID   string `json:"id"`
+		Data        []byte            `json:"data,omitempty"`
+		Attributes  map[string]string `json:"attributes,omitempty"`
+		ID          string            `json:"messageId"`
+		PublishTime time.Time         `json:"publishTime"`
+		OrderingKey string            `json:"orderingKey,omitempty"`
 	} `json:"message"`
 	Subscription string `json:"subscription"`
+	// DeliveryAttempt is only set when the subscription has a dead-letter
+	// policy. It counts deliveries of this message, starting at 1.
+	DeliveryAttempt int `json:"deliveryAttempt,omitempty"`
 }
 
+// errPoison reports a message that will never be processed successfully.
+var errPoison = errors.New("poison message")
+
+// seen holds the IDs of messages being processed or recently processed.
+// Pub/Sub delivers at least once, so the same message can arrive more than
+// once, even concurrently.
+var seen = newMessageCache(1000)
+
 // HelloPubSub receives and processes a Pub/Sub push message.
+//
+// A 2xx response acknowledges the message. Any other status makes Pub/Sub
+// redeliver it; once the subscription's maximum delivery attempts are
+// reached, the message is forwarded to its dead-letter topic.
 func HelloPubSub(w http.ResponseWriter, r *http.Request) {
 	var m PubSubMessage
 	body, err := io.ReadAll(r.Body)
@@ -72,11 +111,47 @@ func HelloPubSub(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	name := string(m.Message.Data)
+	id := m.Message.ID
+	if id != "" && !seen.addIfAbsent(id) {
+		log.Printf("Skipping duplicate delivery of message %s", id)
+		return
+	}
+	if len(m.Message.Attributes) > 0 {
+		log.Printf("Message %s attributes: %v", id, m.Message.Attributes)
+	}
+	if m.Message.OrderingKey != "" {
+		log.Printf("Message %s ordering key: %s", id, m.Message.OrderingKey)
+	}
+	if m.DeliveryAttempt > 1 {
+		log.Printf("Message %s delivery attempt: %d", id, m.DeliveryAttempt)
+	}
+
+	if err := greet(m.Message.Data); err != nil {
+		// Forget the message, so that its redeliveries are processed.
+		seen.remove(id)
+		if errors.Is(err, errPoison) {
+			// Nack the message so it reaches the dead-letter topic.
+			log.Printf("Message %s rejected (delivery attempt %d): %v", id, m.DeliveryAttempt, err)
+			http.Error(w, "Unprocessable Entity", http.StatusUnprocessableEntity)
+			return
+		}
+		log.Printf("Message %s failed, retry expected: %v", id, err)
+		http.Error(w, "Service Unavailable", http.StatusServiceUnavailable)
+		return
+	}
+}
+
+// greet logs a greeting to the name in data.
+func greet(data []byte) error {
+	if !utf8.Valid(data) {
+		return fmt.Errorf("%w: data is not UTF-8 text", errPoison)
+	}
+	name := string(data)
 	if name == "" {
 		name = "World"
 	}
 	log.Printf("Hello %s!", name)
+	return nil
 }
 
 // [END cloudrun_pubsub_handler]
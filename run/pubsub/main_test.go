@@ -23,6 +23,7 @@ import (
 	"net/http/httptest"
 	"os"
 	"strings"
+	"sync"
 	"testing"
 )
 
@@ -95,3 +96,106 @@ func TestHelloPubSub(t *testing.T) {
 		}
 	}
 }
+
+func TestHelloPubSubDelivery(t *testing.T) {
+	seen = newMessageCache(10)
+	tests := []struct {
+		name    string
+		message string
+		want    int
+		wantLog string
+	}{
+		{
+			name:    "attributes",
+			message: `{"message":{"data":"R28=","messageId":"m-1","attributes":{"origin":"test"},"orderingKey":"k"},"deliveryAttempt":2}`,
+			want:    http.StatusOK,
+			wantLog: "Message m-1 attributes: map[origin:test]\nMessage m-1 ordering key: k\nMessage m-1 delivery attempt: 2\nHello Go!\n",
+		},
+		{
+			name:    "duplicate",
+			message: `{"message":{"data":"R28=","messageId":"m-1"}}`,
+			want:    http.StatusOK,
+			wantLog: "Skipping duplicate delivery of message m-1\n",
+		},
+		{
+			name:    "poison",
+			message: `{"message":{"data":"/w==","messageId":"m-2"},"deliveryAttempt":5}`,
+			want:    http.StatusUnprocessableEntity,
+			wantLog: "Message m-2 delivery attempt: 5\nMessage m-2 rejected (delivery attempt 5): poison message: data is not UTF-8 text\n",
+		},
+		{
+			// Rejected messages are not remembered, so redeliveries are
+			// rejected again until they reach the dead-letter topic.
+			name:    "poison_redelivered",
+			message: `{"message":{"data":"/w==","messageId":"m-2"}}`,
+			want:    http.StatusUnprocessableEntity,
+			wantLog: "Message m-2 rejected (delivery attempt 0): poison message: data is not UTF-8 text\n",
+		},
+	}
+	originalFlags := log.Flags()
+	log.SetFlags(0)
+	defer log.SetFlags(originalFlags)
+	for _, test := range tests {
+		var buf strings.Builder
+		log.SetOutput(&buf)
+
+		req := httptest.NewRequest("POST", "/", strings.NewReader(test.message))
+		rr := httptest.NewRecorder()
+		HelloPubSub(rr, req)
+
+		log.SetOutput(os.Stderr)
+
+		if got := rr.Result().StatusCode; got != test.want {
+			t.Errorf("HelloPubSub(%q): got status %d, want %d", test.name, got, test.want)
+		}
+		if got := buf.String(); got != test.wantLog {
+			t.Errorf("HelloPubSub(%q): got log %q, want %q", test.name, got, test.wantLog)
+		}
+	}
+}
+
+func TestHelloPubSubConcurrentDuplicates(t *testing.T) {
+	seen = newMessageCache(10)
+	var buf strings.Builder
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req := httptest.NewRequest("POST", "/", strings.NewReader(`{"message":{"data":"R28=","messageId":"m-1"}}`))
+			HelloPubSub(httptest.NewRecorder(), req)
+		}()
+	}
+	wg.Wait()
+	if n := strings.Count(buf.String(), "Hello Go!"); n != 1 {
+		t.Errorf("message processed %d times, want once", n)
+	}
+}
+
+func TestMessageCache(t *testing.T) {
+	c := newMessageCache(3)
+	for _, id := range []string{"a", "b", "c"} {
+		if !c.addIfAbsent(id) {
+			t.Errorf("addIfAbsent(%q) = false for a new ID", id)
+		}
+	}
+	c.remove("c")
+	if c.addIfAbsent("a") { // Refreshes "a", so "b" is now the oldest.
+		t.Error("addIfAbsent(a) = true for a present ID")
+	}
+	c.addIfAbsent("d")
+	c.addIfAbsent("e")
+
+	// "b" was evicted, and "c" removed.
+	for _, tc := range []struct {
+		id   string
+		want bool
+	}{{"a", false}, {"b", true}, {"c", true}} {
+		if got := c.addIfAbsent(tc.id); got != tc.want {
+			t.Errorf("addIfAbsent(%q) = %v, want %v", tc.id, got, tc.want)
+		}
+	}
+}
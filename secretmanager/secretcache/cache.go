@@ -0,0 +1,236 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package secretcache caches the payloads of Secret Manager secrets.
+//
+// Secrets are referenced by version names, with a version number, "latest"
+// or an alias of the secret. The cache resolves them to version numbers,
+// refreshes them in the background, and refreshes them right away when a
+// Pub/Sub notification reports a change of the secret, so that services
+// reload credentials after a rotation with the change callbacks.
+package secretcache
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	secretmanager "cloud.google.com/go/secretmanager/apiv1"
+	"cloud.google.com/go/secretmanager/apiv1/secretmanagerpb"
+)
+
+// ErrCorrupted is returned when the checksum of a payload doesn't match.
+var ErrCorrupted = errors.New("secretcache: payload corrupted in transit")
+
+// Value is a secret payload.
+type Value struct {
+	// Version is the name of the version of the payload, with the project
+	// and version numbers: projects/NUMBER/secrets/SECRET/versions/NUMBER.
+	Version string
+	Data    []byte
+}
+
+// Cache caches secret payloads. Its methods can be called concurrently.
+type Cache struct {
+	client   *secretmanager.Client
+	interval time.Duration
+
+	mu      sync.Mutex
+	entries map[string]*entry // by version reference
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+type entry struct {
+	value     *Value // nil if invalidated
+	last      *Value // last payload fetched, to detect changes
+	callbacks []func(Value)
+}
+
+// New returns a cache that refreshes the secrets it holds every interval
+// in the background, until Close. If interval isn't positive, secrets are
+// only refreshed by Refresh and Notify.
+func New(client *secretmanager.Client, interval time.Duration) *Cache {
+	c := &Cache{
+		client:   client,
+		interval: interval,
+		entries:  map[string]*entry{},
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go c.run()
+	return c
+}
+
+// Close stops the background refresh. It doesn't close the client.
+func (c *Cache) Close() {
+	close(c.stop)
+	<-c.done
+}
+
+func (c *Cache) run() {
+	defer close(c.done)
+	if c.interval <= 0 {
+		<-c.stop
+		return
+	}
+	t := time.NewTicker(c.interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-c.stop:
+			return
+		case <-t.C:
+			ctx, cancel := context.WithTimeout(context.Background(), c.interval)
+			if err := c.Refresh(ctx); err != nil {
+				log.Printf("secretcache: %v", err)
+			}
+			cancel()
+		}
+	}
+}
+
+// Get returns the payload of a secret version, such as
+// projects/PROJECT/secrets/SECRET/versions/latest.
+func (c *Cache) Get(ctx context.Context, name string) ([]byte, error) {
+	v, err := c.Value(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	return v.Data, nil
+}
+
+// Value returns the payload of a secret version with its version number.
+// It is fetched if it isn't cached.
+func (c *Cache) Value(ctx context.Context, name string) (Value, error) {
+	c.mu.Lock()
+	e, ok := c.entries[name]
+	c.mu.Unlock()
+	if ok && e.value != nil {
+		return *e.value, nil
+	}
+	return c.fetch(ctx, name)
+}
+
+// OnChange registers a function called with the new payload when the
+// version that name resolves to, or its payload, changes. It fetches the
+// payload if it isn't cached, and returns it.
+func (c *Cache) OnChange(ctx context.Context, name string, f func(Value)) (Value, error) {
+	v, err := c.Value(ctx, name)
+	if err != nil {
+		return Value{}, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e := c.entries[name]
+	e.callbacks = append(e.callbacks, f)
+	return v, nil
+}
+
+// access fetches a payload and verifies its checksum.
+func access(ctx context.Context, client *secretmanager.Client, name string) (*Value, error) {
+	resp, err := client.AccessSecretVersion(ctx, &secretmanagerpb.AccessSecretVersionRequest{Name: name})
+	if err != nil {
+		return nil, fmt.Errorf("secretcache: accessing %s: %w", name, err)
+	}
+	data := resp.GetPayload().GetData()
+	checksum := resp.GetPayload().DataCrc32C
+	if checksum == nil || *checksum != int64(crc32.Checksum(data, crc32.MakeTable(crc32.Castagnoli))) {
+		return nil, ErrCorrupted
+	}
+	return &Value{Version: resp.Name, Data: data}, nil
+}
+
+// fetch fetches a payload and caches it.
+func (c *Cache) fetch(ctx context.Context, name string) (Value, error) {
+	v, err := access(ctx, c.client, name)
+	c.store(name, v)
+	if err != nil {
+		return Value{}, err
+	}
+	return *v, nil
+}
+
+// store caches a payload, or invalidates the entry of name if v is nil,
+// and calls the callbacks if the payload changed.
+func (c *Cache) store(name string, v *Value) {
+	c.mu.Lock()
+	e, ok := c.entries[name]
+	if !ok {
+		if v == nil {
+			c.mu.Unlock()
+			return
+		}
+		e = &entry{}
+		c.entries[name] = e
+	}
+	e.value = v
+	changed := v != nil && e.last != nil && (e.last.Version != v.Version || !bytes.Equal(e.last.Data, v.Data))
+	if v != nil {
+		e.last = v
+	}
+	callbacks := e.callbacks
+	c.mu.Unlock()
+	if changed {
+		for _, f := range callbacks {
+			f(*v)
+		}
+	}
+}
+
+// refresh fetches the payloads of the cached names that match. On errors,
+// the entries are invalidated if invalidate is set, and kept otherwise.
+func (c *Cache) refresh(ctx context.Context, match func(name string, e *entry) bool, invalidate bool) error {
+	c.mu.Lock()
+	var names []string
+	for name, e := range c.entries {
+		if match(name, e) {
+			names = append(names, name)
+		}
+	}
+	c.mu.Unlock()
+
+	var errs []error
+	for _, name := range names {
+		v, err := access(ctx, c.client, name)
+		if err != nil {
+			errs = append(errs, err)
+			if !invalidate {
+				continue
+			}
+		}
+		c.store(name, v)
+	}
+	return errors.Join(errs...)
+}
+
+// Refresh fetches all the cached payloads again. Payloads that can't be
+// fetched stay cached; invalidated ones are fetched again.
+func (c *Cache) Refresh(ctx context.Context) error {
+	return c.refresh(ctx, func(string, *entry) bool { return true }, false)
+}
+
+// secretName returns the name of the secret of a version.
+func secretName(version string) string {
+	if i := strings.LastIndex(version, "/versions/"); i >= 0 {
+		return version[:i]
+	}
+	return version
+}
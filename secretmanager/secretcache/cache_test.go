@@ -0,0 +1,308 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package secretcache
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	secretmanager "cloud.google.com/go/secretmanager/apiv1"
+	"cloud.google.com/go/secretmanager/apiv1/secretmanagerpb"
+	"github.com/GoogleCloudPlatform/golang-samples/secretmanager/secrettest"
+	"google.golang.org/api/idtoken"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+)
+
+const secret = "projects/p/secrets/db-password"
+
+type fixture struct {
+	t      *testing.T
+	srv    *secrettest.Server
+	client *secretmanager.Client
+	// name is the name of the secret returned by the server, with the
+	// project number.
+	name string
+}
+
+func setup(t *testing.T) *fixture {
+	t.Helper()
+	srv, err := secrettest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(srv.Close)
+	client, err := srv.Client(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Close() })
+	if _, err := client.CreateSecret(context.Background(), &secretmanagerpb.CreateSecretRequest{
+		Parent:   "projects/p",
+		SecretId: "db-password",
+		Secret:   &secretmanagerpb.Secret{},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	name := "projects/" + srv.ProjectNumber("p") + "/secrets/db-password"
+	return &fixture{t: t, srv: srv, client: client, name: name}
+}
+
+func (f *fixture) add(data string) {
+	f.t.Helper()
+	if _, err := f.client.AddSecretVersion(context.Background(), &secretmanagerpb.AddSecretVersionRequest{
+		Parent:  secret,
+		Payload: &secretmanagerpb.SecretPayload{Data: []byte(data)},
+	}); err != nil {
+		f.t.Fatal(err)
+	}
+}
+
+func (f *fixture) setAlias(alias string, version int64) {
+	f.t.Helper()
+	if _, err := f.client.UpdateSecret(context.Background(), &secretmanagerpb.UpdateSecretRequest{
+		Secret:     &secretmanagerpb.Secret{Name: secret, VersionAliases: map[string]int64{alias: version}},
+		UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"version_aliases"}},
+	}); err != nil {
+		f.t.Fatal(err)
+	}
+}
+
+func TestGet(t *testing.T) {
+	f := setup(t)
+	f.add("one")
+	f.add("two")
+	f.setAlias("prod", 1)
+	c := New(f.client, 0)
+	defer c.Close()
+	ctx := context.Background()
+
+	for ref, want := range map[string]string{
+		"latest": "two",
+		"1":      "one",
+		"prod":   "one",
+	} {
+		for i := 0; i < 2; i++ {
+			got, err := c.Get(ctx, secret+"/versions/"+ref)
+			if err != nil || string(got) != want {
+				t.Errorf("Get(%s) = %q, %v; want %q", ref, got, err, want)
+			}
+		}
+	}
+	if n := f.srv.Calls("AccessSecretVersion"); n != 3 {
+		t.Errorf("%d AccessSecretVersion calls, want 3", n)
+	}
+	v, err := c.Value(ctx, secret+"/versions/prod")
+	if err != nil || v.Version != f.name+"/versions/1" {
+		t.Errorf("Value(prod) = %+v, %v; want version 1", v, err)
+	}
+	if _, err := c.Get(ctx, secret+"/versions/3"); err == nil {
+		t.Error("Get(3) succeeded, want error")
+	}
+}
+
+func TestNotify(t *testing.T) {
+	f := setup(t)
+	f.add("one")
+	c := New(f.client, 0)
+	defer c.Close()
+	ctx := context.Background()
+	latest := secret + "/versions/latest"
+
+	var changes []string
+	v, err := c.OnChange(ctx, latest, func(v Value) {
+		changes = append(changes, strings.TrimPrefix(v.Version, f.name+"/versions/")+"="+string(v.Data))
+	})
+	if err != nil || string(v.Data) != "one" {
+		t.Fatalf("OnChange = %+v, %v", v, err)
+	}
+	get := func() string {
+		got, err := c.Get(ctx, latest)
+		if err != nil {
+			return "error"
+		}
+		return string(got)
+	}
+	// Errors are reported by Get. Notifications name the project by number.
+	notify := func(eventType string) { c.Notify(ctx, eventType, f.name) }
+
+	// The cached payload is used until a notification.
+	f.add("two")
+	if got := get(); got != "one" {
+		t.Errorf("Get before notification = %q, want one", got)
+	}
+	notify(SecretVersionAdd)
+	if got := get(); got != "two" {
+		t.Errorf("Get after %s = %q, want two", SecretVersionAdd, got)
+	}
+
+	// Notifications of other secrets and of other events are ignored.
+	calls := f.srv.Calls("AccessSecretVersion")
+	c.Notify(ctx, SecretVersionAdd, "projects/"+f.srv.ProjectNumber("p")+"/secrets/other")
+	notify("SECRET_ROTATE")
+	if n := f.srv.Calls("AccessSecretVersion"); n != calls {
+		t.Errorf("%d AccessSecretVersion calls for ignored notifications", n-calls)
+	}
+
+	// A disabled version is invalidated.
+	if _, err := f.client.DisableSecretVersion(ctx, &secretmanagerpb.DisableSecretVersionRequest{Name: secret + "/versions/2"}); err != nil {
+		t.Fatal(err)
+	}
+	notify(SecretVersionDisable)
+	if got := get(); got != "error" {
+		t.Errorf("Get after %s = %q, want error", SecretVersionDisable, got)
+	}
+	if _, err := f.client.EnableSecretVersion(ctx, &secretmanagerpb.EnableSecretVersionRequest{Name: secret + "/versions/2"}); err != nil {
+		t.Fatal(err)
+	}
+	// Names with the project ID, as requested, match too.
+	c.Notify(ctx, SecretVersionEnable, secret)
+	if got := get(); got != "two" {
+		t.Errorf("Get after %s = %q, want two", SecretVersionEnable, got)
+	}
+
+	// Aliases are resolved again on updates.
+	f.setAlias("prod", 1)
+	prod := secret + "/versions/prod"
+	if _, err := c.OnChange(ctx, prod, func(v Value) { changes = append(changes, "prod="+string(v.Data)) }); err != nil {
+		t.Fatal(err)
+	}
+	f.setAlias("prod", 2)
+	notify(SecretUpdate)
+
+	f.add("three")
+	if _, err := f.client.DestroySecretVersion(ctx, &secretmanagerpb.DestroySecretVersionRequest{Name: secret + "/versions/3"}); err != nil {
+		t.Fatal(err)
+	}
+	notify(SecretVersionDestroy)
+	if got := get(); got != "error" {
+		t.Errorf("Get after %s = %q, want error", SecretVersionDestroy, got)
+	}
+
+	if got, want := strings.Join(changes, " "), "2=two prod=two"; got != want {
+		t.Errorf("changes %q, want %q", got, want)
+	}
+}
+
+func TestBackgroundRefresh(t *testing.T) {
+	f := setup(t)
+	f.add("one")
+	c := New(f.client, 10*time.Millisecond)
+	defer c.Close()
+	changed := make(chan Value, 1)
+	if _, err := c.OnChange(context.Background(), secret+"/versions/latest", func(v Value) { changed <- v }); err != nil {
+		t.Fatal(err)
+	}
+	f.add("two")
+	select {
+	case v := <-changed:
+		if string(v.Data) != "two" {
+			t.Errorf("changed to %q, want two", v.Data)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no change after a new version")
+	}
+}
+
+func TestCorrupted(t *testing.T) {
+	f := setup(t)
+	f.add("one")
+	c := New(f.client, 0)
+	defer c.Close()
+	ctx := context.Background()
+	if _, err := c.Get(ctx, secret+"/versions/1"); err != nil {
+		t.Fatal(err)
+	}
+	f.srv.CorruptPayloads(true)
+	if _, err := c.Get(ctx, secret+"/versions/latest"); !errors.Is(err, ErrCorrupted) {
+		t.Errorf("Get = %v, want ErrCorrupted", err)
+	}
+	// Refresh keeps the payloads it can't fetch.
+	if err := c.Refresh(ctx); !errors.Is(err, ErrCorrupted) {
+		t.Errorf("Refresh = %v, want ErrCorrupted", err)
+	}
+	if got, err := c.Get(ctx, secret+"/versions/1"); err != nil || string(got) != "one" {
+		t.Errorf("Get after failed refresh = %q, %v; want one", got, err)
+	}
+}
+
+func TestPushHandler(t *testing.T) {
+	f := setup(t)
+	f.add("one")
+	c := New(f.client, 0)
+	defer c.Close()
+	ctx := context.Background()
+	if _, err := c.Get(ctx, secret+"/versions/latest"); err != nil {
+		t.Fatal(err)
+	}
+	f.add("two")
+
+	const (
+		audience = "https://example.com/push"
+		pusher   = "push@p.iam.gserviceaccount.com"
+	)
+	// validate accepts the tokens named after the service account they
+	// are for.
+	validate := func(ctx context.Context, token, aud string) (*idtoken.Payload, error) {
+		if aud != audience || !strings.HasSuffix(token, "@p.iam.gserviceaccount.com") {
+			return nil, errors.New("invalid token")
+		}
+		return &idtoken.Payload{Audience: aud, Claims: map[string]interface{}{"email": token, "email_verified": true}}, nil
+	}
+	push := `{"message":{"attributes":{"eventType":"SECRET_VERSION_ADD","secretId":"` + f.name + `"},"data":"e30="},"subscription":"projects/p/subscriptions/s"}`
+	for _, tc := range []struct {
+		name     string
+		audience string
+		auth     string
+		body     string
+		status   int
+	}{
+		{"no token", audience, "", push, http.StatusUnauthorized},
+		{"invalid token", audience, "Bearer forged", push, http.StatusUnauthorized},
+		{"other service account", audience, "Bearer other@p.iam.gserviceaccount.com", push, http.StatusForbidden},
+		{"no audience", "", "Bearer " + pusher, push, http.StatusUnauthorized},
+		{"not json", audience, "Bearer " + pusher, `not json`, http.StatusBadRequest},
+	} {
+		h := c.pushHandler(validate, tc.audience, pusher)
+		req := httptest.NewRequest("POST", "/", strings.NewReader(tc.body))
+		if tc.auth != "" {
+			req.Header.Set("Authorization", tc.auth)
+		}
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		if rr.Code != tc.status {
+			t.Errorf("%s: status %d, want %d", tc.name, rr.Code, tc.status)
+		}
+	}
+	// Rejected pushes don't refresh the cache.
+	if got, err := c.Get(ctx, secret+"/versions/latest"); err != nil || string(got) != "one" {
+		t.Errorf("Get after rejected pushes = %q, %v; want one", got, err)
+	}
+
+	req := httptest.NewRequest("POST", "/", strings.NewReader(push))
+	req.Header.Set("Authorization", "Bearer "+pusher)
+	rr := httptest.NewRecorder()
+	c.pushHandler(validate, audience, pusher).ServeHTTP(rr, req)
+	if rr.Code != http.StatusNoContent {
+		t.Errorf("push: status %d, want %d", rr.Code, http.StatusNoContent)
+	}
+	if got, err := c.Get(ctx, secret+"/versions/latest"); err != nil || string(got) != "two" {
+		t.Errorf("Get after push = %q, %v; want two", got, err)
+	}
+}
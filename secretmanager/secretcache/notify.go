@@ -0,0 +1,119 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package secretcache
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+
+	"google.golang.org/api/idtoken"
+)
+
+// Event types of Secret Manager notifications that change the payloads
+// version names resolve to.
+const (
+	SecretUpdate         = "SECRET_UPDATE" // aliases may have changed
+	SecretDelete         = "SECRET_DELETE"
+	SecretVersionAdd     = "SECRET_VERSION_ADD"
+	SecretVersionEnable  = "SECRET_VERSION_ENABLE"
+	SecretVersionDisable = "SECRET_VERSION_DISABLE"
+	SecretVersionDestroy = "SECRET_VERSION_DESTROY"
+)
+
+// Notify handles a Secret Manager notification, with the eventType and
+// secretId attributes of the Pub/Sub message. secret is the name of the
+// secret, projects/PROJECT/secrets/SECRET. Notifications name projects by
+// number, and match the cached versions of the secret whether they were
+// requested with the project ID or number. For the event types that change
+// payloads, the cached versions of the secret are fetched again, calling
+// the change callbacks, and invalidated if that fails, so that Get returns
+// the error. Other events are ignored.
+func (c *Cache) Notify(ctx context.Context, eventType, secret string) error {
+	switch eventType {
+	case SecretUpdate, SecretDelete, SecretVersionAdd, SecretVersionEnable, SecretVersionDisable, SecretVersionDestroy:
+	default:
+		return nil
+	}
+	return c.refresh(ctx, func(name string, e *entry) bool {
+		// Secret Manager returns names with the project number.
+		return secretName(name) == secret || e.last != nil && secretName(e.last.Version) == secret
+	}, true)
+}
+
+// pushRequest is the body of a Pub/Sub push request.
+type pushRequest struct {
+	Message struct {
+		Attributes struct {
+			EventType string `json:"eventType"`
+			SecretID  string `json:"secretId"`
+		} `json:"attributes"`
+	} `json:"message"`
+}
+
+// PushHandler returns a handler for a Pub/Sub push subscription to the
+// notification topic of secrets. The message is acknowledged even if the
+// secrets can't be fetched, since they are invalidated, and fetched again
+// by the next Get.
+//
+// The subscription must push with an OIDC token, which is verified:
+// audience is the audience configured on the subscription, by default the
+// URL of the endpoint, and serviceAccount, if set, is the only service
+// account allowed to push. Requests without a valid token are rejected, as
+// are all requests if audience is empty.
+func (c *Cache) PushHandler(audience, serviceAccount string) http.Handler {
+	return c.pushHandler(idtoken.Validate, audience, serviceAccount)
+}
+
+// validateFunc validates a Google-signed ID token, like idtoken.Validate.
+type validateFunc func(ctx context.Context, token, audience string) (*idtoken.Payload, error)
+
+func (c *Cache) pushHandler(validate validateFunc, audience, serviceAccount string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || token == "" || audience == "" {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		payload, err := validate(r.Context(), token, audience)
+		if err != nil {
+			log.Printf("secretcache: idtoken.Validate: %v", err)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if serviceAccount != "" {
+			email, _ := payload.Claims["email"].(string)
+			verified, _ := payload.Claims["email_verified"].(bool)
+			if !verified || email != serviceAccount {
+				log.Printf("secretcache: rejected push from %q, want %q", email, serviceAccount)
+				http.Error(w, "Forbidden", http.StatusForbidden)
+				return
+			}
+		}
+
+		var req pushRequest
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&req); err != nil {
+			http.Error(w, "invalid push request", http.StatusBadRequest)
+			return
+		}
+		a := req.Message.Attributes
+		if err := c.Notify(r.Context(), a.EventType, a.SecretID); err != nil {
+			log.Printf("secretcache: %s for %s: %v", a.EventType, a.SecretID, err)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
@@ -0,0 +1,336 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package secrettest provides a fake Secret Manager server for tests.
+//
+// The server keeps secrets in memory, and implements the methods to
+// create secrets, to add, access and change the state of versions, and to
+// update the version aliases of secrets.
+//
+// Like Secret Manager, the server accepts project IDs and project numbers
+// in names, and returns names with project numbers.
+package secrettest
+
+import (
+	"context"
+	"fmt"
+	"hash/crc32"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+
+	secretmanager "cloud.google.com/go/secretmanager/apiv1"
+	"cloud.google.com/go/secretmanager/apiv1/secretmanagerpb"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Server is a fake Secret Manager server.
+type Server struct {
+	secretmanagerpb.UnimplementedSecretManagerServiceServer
+
+	// Addr is the address of the gRPC server.
+	Addr string
+
+	grpc *grpc.Server
+
+	mu       sync.Mutex
+	secrets  map[string]*secret // by name with the project number
+	projects map[string]string  // project numbers by ID
+	calls    map[string]int
+	corrupt  bool
+}
+
+type secret struct {
+	pb       *secretmanagerpb.Secret
+	versions []*version
+}
+
+type version struct {
+	pb   *secretmanagerpb.SecretVersion
+	data []byte
+}
+
+// NewServer starts a server on a local port.
+func NewServer() (*Server, error) {
+	lis, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		return nil, err
+	}
+	s := &Server{
+		Addr:     lis.Addr().String(),
+		grpc:     grpc.NewServer(),
+		secrets:  map[string]*secret{},
+		projects: map[string]string{},
+		calls:    map[string]int{},
+	}
+	secretmanagerpb.RegisterSecretManagerServiceServer(s.grpc, s)
+	go s.grpc.Serve(lis)
+	return s, nil
+}
+
+// Client returns a client of the server.
+func (s *Server) Client(ctx context.Context) (*secretmanager.Client, error) {
+	return secretmanager.NewClient(ctx,
+		option.WithEndpoint(s.Addr),
+		option.WithoutAuthentication(),
+		option.WithGRPCDialOption(grpc.WithTransportCredentials(insecure.NewCredentials())))
+}
+
+// Close stops the server.
+func (s *Server) Close() {
+	s.grpc.Stop()
+}
+
+// Calls returns the number of calls of a method, such as
+// "AccessSecretVersion".
+func (s *Server) Calls(method string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.calls[method]
+}
+
+// CorruptPayloads sets whether the payloads returned by AccessSecretVersion
+// are corrupted, so that their checksums don't match.
+func (s *Server) CorruptPayloads(corrupt bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.corrupt = corrupt
+}
+
+// ProjectNumber returns the number of a project, given its ID. Projects
+// are numbered in the order they are first used.
+func (s *Server) ProjectNumber(id string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.projectNumberLocked(id)
+}
+
+func (s *Server) projectNumberLocked(id string) string {
+	if _, err := strconv.ParseUint(id, 10, 64); err == nil {
+		return id
+	}
+	n, ok := s.projects[id]
+	if !ok {
+		n = strconv.Itoa(100000000001 + len(s.projects))
+		s.projects[id] = n
+	}
+	return n
+}
+
+// canonicalLocked returns a name, projects/PROJECT/..., with the project
+// number instead of the project ID.
+func (s *Server) canonicalLocked(name string) string {
+	rest, ok := strings.CutPrefix(name, "projects/")
+	if !ok {
+		return name
+	}
+	project, rest, _ := strings.Cut(rest, "/")
+	name = "projects/" + s.projectNumberLocked(project)
+	if rest != "" {
+		name += "/" + rest
+	}
+	return name
+}
+
+// secretLocked returns a secret, and counts a call of a method.
+func (s *Server) secretLocked(method, name string) (*secret, error) {
+	s.calls[method]++
+	sec, ok := s.secrets[s.canonicalLocked(name)]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "secret %q not found", name)
+	}
+	return sec, nil
+}
+
+// versionLocked returns a secret version, resolving "latest" to the most
+// recently created version and the aliases of the secret to their versions.
+func (s *Server) versionLocked(method, name string) (*version, error) {
+	i := strings.LastIndex(name, "/versions/")
+	if i < 0 {
+		s.calls[method]++
+		return nil, status.Errorf(codes.InvalidArgument, "invalid version name %q", name)
+	}
+	sec, err := s.secretLocked(method, name[:i])
+	if err != nil {
+		return nil, err
+	}
+	id := name[i+len("/versions/"):]
+	n, err := strconv.Atoi(id)
+	switch {
+	case id == "latest":
+		n = len(sec.versions)
+	case err != nil:
+		alias, ok := sec.pb.VersionAliases[id]
+		if !ok {
+			return nil, status.Errorf(codes.NotFound, "alias %q not found", id)
+		}
+		n = int(alias)
+	}
+	if n < 1 || n > len(sec.versions) {
+		return nil, status.Errorf(codes.NotFound, "secret version %q not found", name)
+	}
+	return sec.versions[n-1], nil
+}
+
+// CreateSecret creates a secret without versions.
+func (s *Server) CreateSecret(ctx context.Context, req *secretmanagerpb.CreateSecretRequest) (*secretmanagerpb.Secret, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls["CreateSecret"]++
+	name := s.canonicalLocked(req.GetParent()) + "/secrets/" + req.GetSecretId()
+	if _, ok := s.secrets[name]; ok {
+		return nil, status.Errorf(codes.AlreadyExists, "secret %q already exists", name)
+	}
+	pb := proto.Clone(req.GetSecret()).(*secretmanagerpb.Secret)
+	pb.Name = name
+	pb.CreateTime = timestamppb.Now()
+	s.secrets[name] = &secret{pb: pb}
+	return proto.Clone(pb).(*secretmanagerpb.Secret), nil
+}
+
+// GetSecret returns a secret.
+func (s *Server) GetSecret(ctx context.Context, req *secretmanagerpb.GetSecretRequest) (*secretmanagerpb.Secret, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sec, err := s.secretLocked("GetSecret", req.GetName())
+	if err != nil {
+		return nil, err
+	}
+	return proto.Clone(sec.pb).(*secretmanagerpb.Secret), nil
+}
+
+// UpdateSecret updates the version aliases of a secret.
+func (s *Server) UpdateSecret(ctx context.Context, req *secretmanagerpb.UpdateSecretRequest) (*secretmanagerpb.Secret, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sec, err := s.secretLocked("UpdateSecret", req.GetSecret().GetName())
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range req.GetUpdateMask().GetPaths() {
+		if path != "version_aliases" {
+			return nil, status.Errorf(codes.InvalidArgument, "can't update %q", path)
+		}
+		for alias, n := range req.GetSecret().GetVersionAliases() {
+			if n < 1 || int(n) > len(sec.versions) {
+				return nil, status.Errorf(codes.InvalidArgument, "alias %q refers to unknown version %d", alias, n)
+			}
+		}
+		sec.pb.VersionAliases = req.GetSecret().GetVersionAliases()
+	}
+	return proto.Clone(sec.pb).(*secretmanagerpb.Secret), nil
+}
+
+// AddSecretVersion adds an enabled version to a secret.
+func (s *Server) AddSecretVersion(ctx context.Context, req *secretmanagerpb.AddSecretVersionRequest) (*secretmanagerpb.SecretVersion, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sec, err := s.secretLocked("AddSecretVersion", req.GetParent())
+	if err != nil {
+		return nil, err
+	}
+	data := req.GetPayload().GetData()
+	if c := req.GetPayload().DataCrc32C; c != nil && *c != crc32c(data) {
+		return nil, status.Error(codes.DataLoss, "checksum mismatch")
+	}
+	v := &version{
+		pb: &secretmanagerpb.SecretVersion{
+			Name:                           fmt.Sprintf("%s/versions/%d", sec.pb.Name, len(sec.versions)+1),
+			CreateTime:                     timestamppb.Now(),
+			State:                          secretmanagerpb.SecretVersion_ENABLED,
+			ClientSpecifiedPayloadChecksum: req.GetPayload().DataCrc32C != nil,
+		},
+		data: append([]byte(nil), data...),
+	}
+	sec.versions = append(sec.versions, v)
+	return proto.Clone(v.pb).(*secretmanagerpb.SecretVersion), nil
+}
+
+// GetSecretVersion returns a secret version.
+func (s *Server) GetSecretVersion(ctx context.Context, req *secretmanagerpb.GetSecretVersionRequest) (*secretmanagerpb.SecretVersion, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	v, err := s.versionLocked("GetSecretVersion", req.GetName())
+	if err != nil {
+		return nil, err
+	}
+	return proto.Clone(v.pb).(*secretmanagerpb.SecretVersion), nil
+}
+
+// AccessSecretVersion returns the payload of an enabled secret version.
+// The name of the response is the name of the version, with its number.
+func (s *Server) AccessSecretVersion(ctx context.Context, req *secretmanagerpb.AccessSecretVersionRequest) (*secretmanagerpb.AccessSecretVersionResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	v, err := s.versionLocked("AccessSecretVersion", req.GetName())
+	if err != nil {
+		return nil, err
+	}
+	if v.pb.State != secretmanagerpb.SecretVersion_ENABLED {
+		return nil, status.Errorf(codes.FailedPrecondition, "secret version %q is in %v state", v.pb.Name, v.pb.State)
+	}
+	data := append([]byte(nil), v.data...)
+	checksum := crc32c(data)
+	if s.corrupt && len(data) > 0 {
+		data[0] ^= 1
+	}
+	return &secretmanagerpb.AccessSecretVersionResponse{
+		Name:    v.pb.Name,
+		Payload: &secretmanagerpb.SecretPayload{Data: data, DataCrc32C: &checksum},
+	}, nil
+}
+
+func (s *Server) setState(method, name string, state secretmanagerpb.SecretVersion_State) (*secretmanagerpb.SecretVersion, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	v, err := s.versionLocked(method, name)
+	if err != nil {
+		return nil, err
+	}
+	if v.pb.State == secretmanagerpb.SecretVersion_DESTROYED {
+		return nil, status.Errorf(codes.FailedPrecondition, "secret version %q is destroyed", v.pb.Name)
+	}
+	v.pb.State = state
+	if state == secretmanagerpb.SecretVersion_DESTROYED {
+		v.pb.DestroyTime = timestamppb.Now()
+		v.data = nil
+	}
+	return proto.Clone(v.pb).(*secretmanagerpb.SecretVersion), nil
+}
+
+// EnableSecretVersion enables a secret version.
+func (s *Server) EnableSecretVersion(ctx context.Context, req *secretmanagerpb.EnableSecretVersionRequest) (*secretmanagerpb.SecretVersion, error) {
+	return s.setState("EnableSecretVersion", req.GetName(), secretmanagerpb.SecretVersion_ENABLED)
+}
+
+// DisableSecretVersion disables a secret version.
+func (s *Server) DisableSecretVersion(ctx context.Context, req *secretmanagerpb.DisableSecretVersionRequest) (*secretmanagerpb.SecretVersion, error) {
+	return s.setState("DisableSecretVersion", req.GetName(), secretmanagerpb.SecretVersion_DISABLED)
+}
+
+// DestroySecretVersion destroys the data of a secret version.
+func (s *Server) DestroySecretVersion(ctx context.Context, req *secretmanagerpb.DestroySecretVersionRequest) (*secretmanagerpb.SecretVersion, error) {
+	return s.setState("DestroySecretVersion", req.GetName(), secretmanagerpb.SecretVersion_DESTROYED)
+}
+
+func crc32c(data []byte) int64 {
+	return int64(crc32.Checksum(data, crc32.MakeTable(crc32.Castagnoli)))
+}
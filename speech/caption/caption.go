@@ -13,7 +13,7 @@
 // limitations under the License.
 
 // Command caption sends audio data to the Google Speech API
-// and prints its transcript.
+// and writes captions for it in the SRT or WebVTT format.
 package main
 
 import (
@@ -29,33 +29,102 @@ import (
 	"cloud.google.com/go/speech/apiv1/speechpb"
 )
 
-const usage = `Usage: caption <audiofile>
+const usage = `Usage: caption [flags] <audiofile>
 
 Audio file must be a 16-bit signed little-endian encoded
 with a sample rate of 16000.
 
-The path to the audio file may be a GCS URI (gs://...).`
+The path to the audio file may be a GCS URI (gs://...).
+
+Flags:`
 
 func main() {
-	flag.Parse()
-	if len(os.Args) < 2 {
+	format := flag.String("format", "srt", "caption format: srt or vtt")
+	out := flag.String("o", "", "file to write captions to (default stdout)")
+	diarization := flag.Bool("diarization", false, "label cues with the speaker")
+	speakers := flag.Int("speakers", 2, "maximum number of speakers, with -diarization")
+	maxDuration := flag.Duration("max-duration", defaultCueOptions.maxDuration, "maximum duration of a cue")
+	maxChars := flag.Int("max-chars", defaultCueOptions.maxChars, "maximum number of characters in a cue")
+	flag.Usage = func() {
 		fmt.Fprintln(os.Stderr, usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 || (*format != "srt" && *format != "vtt") {
+		flag.Usage()
 		os.Exit(2)
 	}
 
-	var runFunc func(io.Writer, string) error
+	ctx := context.Background()
+	resp, err := recognizeWords(ctx, flag.Arg(0), *diarization, int32(*speakers))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	w := os.Stdout
+	if *out != "" {
+		if w, err = os.Create(*out); err != nil {
+			log.Fatal(err)
+		}
+	}
+	opts := cueOptions{maxDuration: *maxDuration, maxChars: *maxChars}
+	if err := writeCaptions(w, resp, *format, *diarization, opts); err != nil {
+		log.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// recognizeWords transcribes the audio file at path, which may be a GCS
+// URI, with the start and end time of each word. With diarization, each
+// word is also tagged with its speaker.
+func recognizeWords(ctx context.Context, path string, diarization bool, maxSpeakers int32) (*speechpb.RecognizeResponse, error) {
+	client, err := speech.NewClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
 
-	path := os.Args[1]
+	audio := &speechpb.RecognitionAudio{}
 	if strings.Contains(path, "://") {
-		runFunc = recognizeGCS
+		audio.AudioSource = &speechpb.RecognitionAudio_Uri{Uri: path}
 	} else {
-		runFunc = recognize
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		audio.AudioSource = &speechpb.RecognitionAudio_Content{Content: data}
 	}
 
-	// Perform the request.
-	if err := runFunc(os.Stdout, os.Args[1]); err != nil {
-		log.Fatal(err)
+	config := &speechpb.RecognitionConfig{
+		Encoding:                   speechpb.RecognitionConfig_LINEAR16,
+		SampleRateHertz:            16000,
+		LanguageCode:               "en-US",
+		EnableWordTimeOffsets:      true,
+		EnableAutomaticPunctuation: true,
+	}
+	if diarization {
+		config.DiarizationConfig = &speechpb.SpeakerDiarizationConfig{
+			EnableSpeakerDiarization: true,
+			MinSpeakerCount:          1,
+			MaxSpeakerCount:          maxSpeakers,
+		}
+	}
+	return client.Recognize(ctx, &speechpb.RecognizeRequest{Config: config, Audio: audio})
+}
+
+// writeCaptions writes the words of resp to w as cues in the given format,
+// "srt" or "vtt".
+func writeCaptions(w io.Writer, resp *speechpb.RecognizeResponse, format string, diarization bool, opts cueOptions) error {
+	cues := buildCues(wordsFromResponse(resp, diarization), opts)
+	switch format {
+	case "srt":
+		return writeSRT(w, cues)
+	case "vtt":
+		return writeVTT(w, cues)
 	}
+	return fmt.Errorf("unknown caption format %q", format)
 }
 
 // [START speech_transcribe_sync_gcs]
@@ -0,0 +1,162 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/speech/apiv1/speechpb"
+)
+
+// word is a recognized word with its position in the audio.
+type word struct {
+	text       string
+	start, end time.Duration
+	// speaker is the speaker label, or "" without diarization.
+	speaker string
+}
+
+// cue is a caption displayed from start to end.
+type cue struct {
+	start, end time.Duration
+	speaker    string
+	text       string
+}
+
+// cueOptions limit the size of cues.
+type cueOptions struct {
+	// maxDuration is the longest a cue is displayed.
+	maxDuration time.Duration
+	// maxChars is the most characters in a cue.
+	maxChars int
+}
+
+var defaultCueOptions = cueOptions{
+	maxDuration: 5 * time.Second,
+	maxChars:    84, // Two lines of 42 characters.
+}
+
+// wordsFromResponse returns the words of the most likely alternative of
+// each result. With diarization, the last result holds every word of the
+// audio with its speaker, so only that result is used.
+func wordsFromResponse(resp *speechpb.RecognizeResponse, diarization bool) []word {
+	results := resp.GetResults()
+	if diarization && len(results) > 0 {
+		results = results[len(results)-1:]
+	}
+	var words []word
+	for _, r := range results {
+		alts := r.GetAlternatives()
+		if len(alts) == 0 {
+			continue
+		}
+		for _, w := range alts[0].GetWords() {
+			wd := word{
+				text:  w.GetWord(),
+				start: w.GetStartTime().AsDuration(),
+				end:   w.GetEndTime().AsDuration(),
+			}
+			if diarization {
+				wd.speaker = w.GetSpeakerLabel()
+				if wd.speaker == "" && w.GetSpeakerTag() != 0 {
+					wd.speaker = strconv.Itoa(int(w.GetSpeakerTag()))
+				}
+			}
+			words = append(words, wd)
+		}
+	}
+	return words
+}
+
+// buildCues groups words into cues. A cue ends after a word ending a
+// sentence, when the speaker changes, or before it would exceed the
+// duration or character limits.
+func buildCues(words []word, opts cueOptions) []cue {
+	var cues []cue
+	var cur *cue
+	for _, w := range words {
+		if cur != nil {
+			tooLong := w.end-cur.start > opts.maxDuration
+			tooWide := len(cur.text)+1+len(w.text) > opts.maxChars
+			if tooLong || tooWide || w.speaker != cur.speaker {
+				cur = nil
+			}
+		}
+		if cur == nil {
+			cues = append(cues, cue{start: w.start, speaker: w.speaker})
+			cur = &cues[len(cues)-1]
+		} else {
+			cur.text += " "
+		}
+		cur.text += w.text
+		cur.end = w.end
+		if endsSentence(w.text) {
+			cur = nil
+		}
+	}
+	return cues
+}
+
+// endsSentence reports whether a word ends with sentence punctuation.
+func endsSentence(w string) bool {
+	return strings.HasSuffix(w, ".") || strings.HasSuffix(w, "?") || strings.HasSuffix(w, "!")
+}
+
+// writeSRT writes cues in the SubRip format.
+func writeSRT(w io.Writer, cues []cue) error {
+	for i, c := range cues {
+		text := c.text
+		if c.speaker != "" {
+			text = fmt.Sprintf("Speaker %s: %s", c.speaker, text)
+		}
+		_, err := fmt.Fprintf(w, "%d\n%s --> %s\n%s\n\n", i+1, timestamp(c.start, ','), timestamp(c.end, ','), text)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// vttEscaper escapes the characters that have a meaning in WebVTT cues.
+var vttEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+// writeVTT writes cues in the WebVTT format. Speakers are marked with
+// voice spans.
+func writeVTT(w io.Writer, cues []cue) error {
+	if _, err := io.WriteString(w, "WEBVTT\n\n"); err != nil {
+		return err
+	}
+	for i, c := range cues {
+		text := vttEscaper.Replace(c.text)
+		if c.speaker != "" {
+			text = fmt.Sprintf("<v Speaker %s>%s", vttEscaper.Replace(c.speaker), text)
+		}
+		_, err := fmt.Fprintf(w, "%d\n%s --> %s\n%s\n\n", i+1, timestamp(c.start, '.'), timestamp(c.end, '.'), text)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// timestamp formats d as hh:mm:ss followed by sep and milliseconds.
+func timestamp(d time.Duration, sep byte) string {
+	ms := d.Milliseconds()
+	return fmt.Sprintf("%02d:%02d:%02d%c%03d", ms/3600000, ms/60000%60, ms/1000%60, sep, ms%1000)
+}
@@ -0,0 +1,173 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/speech/apiv1/speechpb"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+// loadResponse reads a recorded RecognizeResponse from speech/testdata.
+func loadResponse(t *testing.T, name string) *speechpb.RecognizeResponse {
+	t.Helper()
+	b, err := os.ReadFile(filepath.Join("..", "testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp := &speechpb.RecognizeResponse{}
+	if err := protojson.Unmarshal(b, resp); err != nil {
+		t.Fatalf("protojson.Unmarshal(%s): %v", name, err)
+	}
+	return resp
+}
+
+func TestWriteCaptionsGolden(t *testing.T) {
+	tests := []struct {
+		fixture     string
+		diarization bool
+	}{
+		{fixture: "recognize_words.json"},
+		{fixture: "recognize_diarization.json", diarization: true},
+	}
+	for _, tc := range tests {
+		resp := loadResponse(t, tc.fixture)
+		for _, format := range []string{"srt", "vtt"} {
+			name := strings.TrimSuffix(tc.fixture, ".json") + "." + format
+			t.Run(name, func(t *testing.T) {
+				var buf bytes.Buffer
+				if err := writeCaptions(&buf, resp, format, tc.diarization, defaultCueOptions); err != nil {
+					t.Fatalf("writeCaptions: %v", err)
+				}
+				golden := filepath.Join("..", "testdata", name+".golden")
+				if *update {
+					if err := os.WriteFile(golden, buf.Bytes(), 0o644); err != nil {
+						t.Fatal(err)
+					}
+				}
+				want, err := os.ReadFile(golden)
+				if err != nil {
+					t.Fatalf("os.ReadFile: %v (run go test -update to create it)", err)
+				}
+				if got := buf.String(); got != string(want) {
+					t.Errorf("captions mismatch\ngot:\n%s\nwant:\n%s", got, want)
+				}
+			})
+		}
+	}
+}
+
+func TestWordsFromResponse(t *testing.T) {
+	resp := loadResponse(t, "recognize_diarization.json")
+
+	// Without diarization, the words of every result but the last, which
+	// repeats them with speaker tags.
+	plain := wordsFromResponse(resp, false)
+	diarized := wordsFromResponse(resp, true)
+	if len(plain) != 2*len(diarized) {
+		t.Errorf("got %d words without diarization, want twice the %d diarized words", len(plain), len(diarized))
+	}
+	for _, w := range plain {
+		if w.speaker != "" {
+			t.Fatalf("word %q has speaker %q without diarization", w.text, w.speaker)
+		}
+	}
+	if got := diarized[0]; got.text != "I'm" || got.speaker != "1" || got.start != 100*time.Millisecond {
+		t.Errorf("first diarized word: got %+v", got)
+	}
+}
+
+func TestBuildCues(t *testing.T) {
+	at := func(text string, start, end time.Duration, speaker string) word {
+		return word{text: text, start: start, end: end, speaker: speaker}
+	}
+	s := time.Second
+	tests := []struct {
+		name  string
+		words []word
+		opts  cueOptions
+		want  []string
+	}{
+		{
+			name:  "punctuation",
+			words: []word{at("Hi.", 0, s, ""), at("Bye", s, 2*s, "")},
+			opts:  cueOptions{maxDuration: time.Minute, maxChars: 100},
+			want:  []string{"Hi.", "Bye"},
+		},
+		{
+			name:  "duration",
+			words: []word{at("one", 0, s, ""), at("two", s, 2*s, ""), at("three", 2*s, 3*s, "")},
+			opts:  cueOptions{maxDuration: 2 * s, maxChars: 100},
+			want:  []string{"one two", "three"},
+		},
+		{
+			name:  "characters",
+			words: []word{at("one", 0, s, ""), at("two", s, 2*s, ""), at("three", 2*s, 3*s, "")},
+			opts:  cueOptions{maxDuration: time.Minute, maxChars: 9},
+			want:  []string{"one two", "three"},
+		},
+		{
+			name:  "speaker change",
+			words: []word{at("yes", 0, s, "1"), at("no", s, 2*s, "2"), at("maybe", 2*s, 3*s, "2")},
+			opts:  cueOptions{maxDuration: time.Minute, maxChars: 100},
+			want:  []string{"yes", "no maybe"},
+		},
+		{
+			name:  "long word",
+			words: []word{at("supercalifragilistic", 0, 10*s, "")},
+			opts:  cueOptions{maxDuration: s, maxChars: 5},
+			want:  []string{"supercalifragilistic"},
+		},
+	}
+	for _, tc := range tests {
+		cues := buildCues(tc.words, tc.opts)
+		var got []string
+		for _, c := range cues {
+			got = append(got, c.text)
+		}
+		if strings.Join(got, "|") != strings.Join(tc.want, "|") {
+			t.Errorf("%s: got cues %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestTimestamp(t *testing.T) {
+	d := 1*time.Hour + 2*time.Minute + 3*time.Second + 45*time.Millisecond
+	if got, want := timestamp(d, ','), "01:02:03,045"; got != want {
+		t.Errorf("timestamp(%v, ','): got %q, want %q", d, got, want)
+	}
+	if got, want := timestamp(d, '.'), "01:02:03.045"; got != want {
+		t.Errorf("timestamp(%v, '.'): got %q, want %q", d, got, want)
+	}
+}
+
+func TestWriteVTTEscapes(t *testing.T) {
+	var buf bytes.Buffer
+	if err := writeVTT(&buf, []cue{{end: time.Second, text: "R&D <3"}}); err != nil {
+		t.Fatal(err)
+	}
+	if want := "R&amp;D &lt;3"; !strings.Contains(buf.String(), want) {
+		t.Errorf("writeVTT: got %q, want it to contain %q", buf.String(), want)
+	}
+}
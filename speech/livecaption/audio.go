@@ -0,0 +1,184 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+// audioFormat describes 16-bit little-endian PCM audio.
+type audioFormat struct {
+	sampleRate int
+	channels   int
+}
+
+// openAudio returns a reader of mono 16-bit PCM audio at rate samples per
+// second. WAV input is decoded, mixed down and resampled to rate; any other
+// input is raw mono 16-bit PCM that must already be at rate.
+func openAudio(r io.Reader, rate int) (io.Reader, error) {
+	br := bufio.NewReader(r)
+	header, err := br.Peek(12)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	if len(header) < 12 || string(header[:4]) != "RIFF" || string(header[8:12]) != "WAVE" {
+		return br, nil
+	}
+	data, format, err := readWAVHeader(br)
+	if err != nil {
+		return nil, fmt.Errorf("reading WAV header: %w", err)
+	}
+	if format.channels == 1 && format.sampleRate == rate {
+		return data, nil
+	}
+	return newResampler(data, format, rate), nil
+}
+
+// readWAVHeader reads the RIFF chunks of a WAV file up to its data chunk,
+// and returns a reader of the samples and their format. Only 16-bit PCM is
+// supported.
+func readWAVHeader(r io.Reader) (io.Reader, audioFormat, error) {
+	var format audioFormat
+	var riff [12]byte
+	if _, err := io.ReadFull(r, riff[:]); err != nil {
+		return nil, format, err
+	}
+	haveFormat := false
+	for {
+		var ch [8]byte
+		if _, err := io.ReadFull(r, ch[:]); err != nil {
+			return nil, format, fmt.Errorf("no data chunk: %w", err)
+		}
+		id, size := string(ch[:4]), binary.LittleEndian.Uint32(ch[4:])
+		switch id {
+		case "fmt ":
+			if size < 16 {
+				return nil, format, fmt.Errorf("fmt chunk too short: %d bytes", size)
+			}
+			fmtChunk := make([]byte, size+size%2)
+			if _, err := io.ReadFull(r, fmtChunk); err != nil {
+				return nil, format, err
+			}
+			audioFmt := binary.LittleEndian.Uint16(fmtChunk[0:])
+			bits := binary.LittleEndian.Uint16(fmtChunk[14:])
+			// 0xFFFE is WAVE_FORMAT_EXTENSIBLE, whose samples are PCM for
+			// 16-bit audio.
+			if (audioFmt != 1 && audioFmt != 0xFFFE) || bits != 16 {
+				return nil, format, fmt.Errorf("unsupported encoding %d with %d bits per sample, want 16-bit PCM", audioFmt, bits)
+			}
+			format.channels = int(binary.LittleEndian.Uint16(fmtChunk[2:]))
+			format.sampleRate = int(binary.LittleEndian.Uint32(fmtChunk[4:]))
+			if format.channels == 0 || format.sampleRate == 0 {
+				return nil, format, errors.New("invalid fmt chunk")
+			}
+			haveFormat = true
+		case "data":
+			if !haveFormat {
+				return nil, format, errors.New("data chunk before fmt chunk")
+			}
+			// Streamed WAV files may not know their size up front.
+			if size == 0 || size == math.MaxUint32 {
+				return r, format, nil
+			}
+			return io.LimitReader(r, int64(size)), format, nil
+		default:
+			if _, err := io.CopyN(io.Discard, r, int64(size+size%2)); err != nil {
+				return nil, format, err
+			}
+		}
+	}
+}
+
+// resampler mixes interleaved 16-bit PCM down to mono and converts it to
+// another sample rate with linear interpolation.
+type resampler struct {
+	r        io.Reader
+	channels int
+	// step is how far the input advances for each output sample.
+	step float64
+	// samples holds mono input samples; pos is the position of the next
+	// output sample in it.
+	samples []float64
+	pos     float64
+	in      []byte // Bytes read that don't make up a whole frame yet.
+	eof     bool
+}
+
+func newResampler(r io.Reader, from audioFormat, rate int) *resampler {
+	return &resampler{
+		r:        r,
+		channels: from.channels,
+		step:     float64(from.sampleRate) / float64(rate),
+	}
+}
+
+func (s *resampler) Read(p []byte) (int, error) {
+	n := 0
+	for n+2 <= len(p) {
+		i := int(s.pos)
+		if i+1 >= len(s.samples) {
+			if s.eof {
+				break
+			}
+			if err := s.fill(); err != nil {
+				return n, err
+			}
+			continue
+		}
+		frac := s.pos - float64(i)
+		v := s.samples[i]*(1-frac) + s.samples[i+1]*frac
+		binary.LittleEndian.PutUint16(p[n:], uint16(int16(math.Round(v))))
+		n += 2
+		s.pos += s.step
+	}
+	if n == 0 && s.eof {
+		return 0, io.EOF
+	}
+	return n, nil
+}
+
+// fill reads more input, dropping the samples that are no longer needed.
+func (s *resampler) fill() error {
+	if drop := int(s.pos); drop > 0 {
+		if drop > len(s.samples) {
+			drop = len(s.samples)
+		}
+		s.samples = append(s.samples[:0], s.samples[drop:]...)
+		s.pos -= float64(drop)
+	}
+	buf := make([]byte, 4096)
+	n, err := s.r.Read(buf)
+	s.in = append(s.in, buf[:n]...)
+	frameSize := 2 * s.channels
+	frames := len(s.in) / frameSize
+	for f := 0; f < frames; f++ {
+		sum := 0
+		for c := 0; c < s.channels; c++ {
+			sum += int(int16(binary.LittleEndian.Uint16(s.in[f*frameSize+2*c:])))
+		}
+		s.samples = append(s.samples, float64(sum)/float64(s.channels))
+	}
+	s.in = append(s.in[:0], s.in[frames*frameSize:]...)
+	if err == io.EOF {
+		s.eof = true
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,134 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"slices"
+	"testing"
+	"time"
+)
+
+// wavFile returns a 16-bit PCM WAV file of frames, each holding a sample
+// per channel.
+func wavFile(rate, channels int, frames [][]int16) []byte {
+	var samples bytes.Buffer
+	for _, f := range frames {
+		binary.Write(&samples, binary.LittleEndian, f)
+	}
+	var b bytes.Buffer
+	le := binary.LittleEndian
+	b.WriteString("RIFF")
+	binary.Write(&b, le, uint32(36+8+4+samples.Len()))
+	b.WriteString("WAVEfmt ")
+	binary.Write(&b, le, uint32(16))
+	binary.Write(&b, le, uint16(1))
+	binary.Write(&b, le, uint16(channels))
+	binary.Write(&b, le, uint32(rate))
+	binary.Write(&b, le, uint32(rate*channels*2))
+	binary.Write(&b, le, uint16(channels*2))
+	binary.Write(&b, le, uint16(16))
+	// A chunk the reader must skip.
+	b.WriteString("LIST")
+	binary.Write(&b, le, uint32(4))
+	b.WriteString("INFO")
+	b.WriteString("data")
+	binary.Write(&b, le, uint32(samples.Len()))
+	b.Write(samples.Bytes())
+	return b.Bytes()
+}
+
+func readSamples(t *testing.T, r io.Reader) []int16 {
+	t.Helper()
+	b, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	samples := make([]int16, len(b)/2)
+	binary.Read(bytes.NewReader(b), binary.LittleEndian, samples)
+	return samples
+}
+
+func TestOpenAudio(t *testing.T) {
+	t.Run("raw", func(t *testing.T) {
+		raw := []byte{1, 0, 2, 0, 3, 0}
+		r, err := openAudio(bytes.NewReader(raw), 16000)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, _ := io.ReadAll(r); !bytes.Equal(got, raw) {
+			t.Errorf("got %v, want %v", got, raw)
+		}
+	})
+
+	t.Run("WAV at the target rate", func(t *testing.T) {
+		r, err := openAudio(bytes.NewReader(wavFile(16000, 1, [][]int16{{1}, {-2}, {3}})), 16000)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := readSamples(t, r), []int16{1, -2, 3}; !slices.Equal(got, want) {
+			t.Errorf("got %v, want %v", got, want)
+		}
+	})
+
+	t.Run("stereo downmix and downsampling", func(t *testing.T) {
+		var frames [][]int16
+		for i := 0; i < 8; i++ {
+			frames = append(frames, []int16{int16(100 * i), int16(100*i + 50)})
+		}
+		r, err := openAudio(bytes.NewReader(wavFile(32000, 2, frames)), 16000)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := readSamples(t, r), []int16{25, 225, 425, 625}; !slices.Equal(got, want) {
+			t.Errorf("got %v, want %v", got, want)
+		}
+	})
+
+	t.Run("upsampling", func(t *testing.T) {
+		r, err := openAudio(bytes.NewReader(wavFile(8000, 1, [][]int16{{0}, {100}, {200}})), 16000)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := readSamples(t, r), []int16{0, 50, 100, 150}; !slices.Equal(got, want) {
+			t.Errorf("got %v, want %v", got, want)
+		}
+	})
+
+	t.Run("unsupported encoding", func(t *testing.T) {
+		wav := wavFile(16000, 1, [][]int16{{1}})
+		wav[34] = 8 // Bits per sample.
+		if _, err := openAudio(bytes.NewReader(wav), 16000); err == nil {
+			t.Error("openAudio succeeded with 8-bit samples, want error")
+		}
+	})
+}
+
+func TestSkip(t *testing.T) {
+	r := bytes.NewReader(testAudio(0, 19))
+	if err := skip(r, time.Second, testRate); err != nil {
+		t.Fatal(err)
+	}
+	var first [4]byte
+	r.Read(first[:])
+	if got := binary.BigEndian.Uint32(first[:]); got != 10 {
+		t.Errorf("after skipping 1s, got chunk %d, want 10", got)
+	}
+	if err := skip(r, time.Minute, testRate); err != io.EOF {
+		t.Errorf("skipping past the end: got %v, want EOF", err)
+	}
+}
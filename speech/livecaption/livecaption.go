@@ -12,99 +12,153 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
-// Command livecaption pipes the stdin audio data to
-// Google Speech API and outputs the transcript.
+// Command livecaption streams audio to the Google Speech API and outputs
+// the transcript as it is recognized. The audio is read from a file or from
+// stdin, as raw 16-bit mono PCM or as a WAV file, which is converted to the
+// recognition sample rate. There is no limit to the length of the audio:
+// streaming sessions are rotated before they reach the limit of the API.
+//
+// Final results are written to stdout with their offsets in the audio;
+// interim results are shown on stderr. An interrupted transcription can be
+// resumed from the end offset of its last result with -resume.
 //
 // As an example, gst-launch can be used to capture the mic input:
 //
 //	$ gst-launch-1.0 -v pulsesrc ! audioconvert ! audioresample ! audio/x-raw,channels=1,rate=16000 ! filesink location=/dev/stdout | livecaption
 package main
 
-// [START speech_transcribe_streaming_mic]
 import (
 	"context"
+	"flag"
 	"fmt"
 	"io"
 	"log"
 	"os"
+	"strings"
+	"time"
 
 	speech "cloud.google.com/go/speech/apiv1"
 	"cloud.google.com/go/speech/apiv1/speechpb"
 )
 
 func main() {
-	ctx := context.Background()
+	rate := flag.Int("rate", 16000, "sample rate of the recognition; WAV input is converted to it")
+	language := flag.String("language", "en-US", "language of the audio")
+	sessionLimit := flag.Duration("session", 290*time.Second, "audio sent in a streaming session before it is rotated")
+	overlap := flag.Duration("overlap", time.Second, "finalized audio replayed at the start of a session for context")
+	resumeAt := flag.String("resume", "", "skip audio up to this offset, as printed with the results (h:mm:ss.mmm) or as a duration")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: livecaption [flags] [FILE|-]")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	log.SetFlags(0)
 
-	client, err := speech.NewClient(ctx)
+	in := io.Reader(os.Stdin)
+	if name := flag.Arg(0); name != "" && name != "-" {
+		f, err := os.Open(name)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer f.Close()
+		in = f
+	}
+	audio, err := openAudio(in, *rate)
 	if err != nil {
 		log.Fatal(err)
 	}
-	stream, err := client.StreamingRecognize(ctx)
+	resume, err := parseOffset(*resumeAt)
+	if err != nil {
+		log.Fatalf("Invalid -resume: %v", err)
+	}
+	if err := skip(audio, resume, *rate); err != nil {
+		log.Fatalf("Could not resume at %s: %v", offset(resume), err)
+	}
+
+	ctx := context.Background()
+	client, err := speech.NewClient(ctx)
 	if err != nil {
 		log.Fatal(err)
 	}
-	// Send the initial configuration message.
-	if err := stream.Send(&speechpb.StreamingRecognizeRequest{
-		StreamingRequest: &speechpb.StreamingRecognizeRequest_StreamingConfig{
-			StreamingConfig: &speechpb.StreamingRecognitionConfig{
-				Config: &speechpb.RecognitionConfig{
-					Encoding:        speechpb.RecognitionConfig_LINEAR16,
-					SampleRateHertz: 16000,
-					LanguageCode:    "en-US",
-				},
-			},
-		},
-	}); err != nil {
+	defer client.Close()
+
+	p := &printer{out: os.Stdout, status: os.Stderr}
+	s := newStreamer(client, &speechpb.RecognitionConfig{
+		Encoding:                   speechpb.RecognitionConfig_LINEAR16,
+		SampleRateHertz:            int32(*rate),
+		LanguageCode:               *language,
+		EnableWordTimeOffsets:      true,
+		EnableAutomaticPunctuation: true,
+	}, resume, p.print)
+	s.sessionLimit = *sessionLimit
+	s.overlap = *overlap
+	err = s.run(ctx, audio)
+	p.clear()
+	if err != nil {
 		log.Fatal(err)
 	}
+}
 
-	go func() {
-		// Pipe stdin to the API.
-		buf := make([]byte, 1024)
-		for {
-			n, err := os.Stdin.Read(buf)
-			if n > 0 {
-				if err := stream.Send(&speechpb.StreamingRecognizeRequest{
-					StreamingRequest: &speechpb.StreamingRecognizeRequest_AudioContent{
-						AudioContent: buf[:n],
-					},
-				}); err != nil {
-					log.Printf("Could not send audio: %v", err)
-				}
-			}
-			if err == io.EOF {
-				// Nothing else to pipe, close the stream.
-				if err := stream.CloseSend(); err != nil {
-					log.Fatalf("Could not close stream: %v", err)
-				}
-				return
-			}
-			if err != nil {
-				log.Printf("Could not read from stdin: %v", err)
-				continue
-			}
-		}
-	}()
+// printer writes final results to out, and the latest interim results to
+// status on a single line.
+type printer struct {
+	out, status io.Writer
+	shown       int // Length of the interim line on status.
+}
 
-	for {
-		resp, err := stream.Recv()
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			log.Fatalf("Cannot stream results: %v", err)
-		}
-		if err := resp.Error; err != nil {
-			// Workaround while the API doesn't give a more informative error.
-			if err.Code == 3 || err.Code == 11 {
-				log.Print("WARNING: Speech recognition request exceeded limit of 60 seconds.")
-			}
-			log.Fatalf("Could not recognize: %v", err)
-		}
-		for _, result := range resp.Results {
-			fmt.Printf("Result: %+v\n", result)
+func (p *printer) print(results []result) {
+	var stable, unstable []string
+	for _, r := range results {
+		switch {
+		case r.final:
+			p.clear()
+			fmt.Fprintf(p.out, "[%s - %s] %s\n", offset(r.start), offset(r.end), r.text)
+		case r.stable:
+			stable = append(stable, r.text)
+		default:
+			unstable = append(unstable, r.text)
 		}
 	}
+	if len(stable)+len(unstable) == 0 {
+		return
+	}
+	line := strings.Join(stable, " ")
+	if len(unstable) > 0 {
+		line = strings.TrimSpace(line + " (" + strings.Join(unstable, " ") + ")")
+	}
+	p.clear()
+	fmt.Fprint(p.status, line)
+	p.shown = len(line)
+}
+
+// clear erases the interim line.
+func (p *printer) clear() {
+	if p.shown > 0 {
+		fmt.Fprintf(p.status, "\r%s\r", strings.Repeat(" ", p.shown))
+		p.shown = 0
+	}
+}
+
+// offset formats d as h:mm:ss.mmm.
+func offset(d time.Duration) string {
+	ms := d.Milliseconds()
+	return fmt.Sprintf("%d:%02d:%02d.%03d", ms/3600000, ms/60000%60, ms/1000%60, ms%1000)
 }
 
-// [END speech_transcribe_streaming_mic]
+// parseOffset parses an offset formatted by offset, or a duration such as
+// 1m30s. The empty string is the start of the audio.
+func parseOffset(s string) (time.Duration, error) {
+	if s == "" {
+		return 0, nil
+	}
+	if !strings.Contains(s, ":") {
+		return time.ParseDuration(s)
+	}
+	var h, m int
+	var sec float64
+	if _, err := fmt.Sscanf(s, "%d:%d:%f", &h, &m, &sec); err != nil {
+		return 0, fmt.Errorf("offset %q: want h:mm:ss.mmm", s)
+	}
+	d := time.Duration(h)*time.Hour + time.Duration(m)*time.Minute + time.Duration(sec*float64(time.Second))
+	return d.Round(time.Millisecond), nil
+}
@@ -0,0 +1,352 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	speech "cloud.google.com/go/speech/apiv1"
+	"cloud.google.com/go/speech/apiv1/speechpb"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+
+	"github.com/GoogleCloudPlatform/golang-samples/internal/testutil"
+)
+
+const (
+	testRate      = 16000
+	testChunkSize = testRate * 2 / 10 // 100ms.
+)
+
+// fakeSpeech recognizes audio made by testAudio: each 100ms chunk starts
+// with its index, and is transcribed as the word "w<index>".
+type fakeSpeech struct {
+	speechpb.UnimplementedSpeechServer
+	// finalEvery is the number of chunks in each final result.
+	finalEvery int
+	// maxChunks, if set, is the number of chunks after which a session
+	// fails with OUT_OF_RANGE.
+	maxChunks int
+
+	mu       sync.Mutex
+	sessions int
+}
+
+type fakeWord struct {
+	text       string
+	start, end time.Duration
+}
+
+func (f *fakeSpeech) StreamingRecognize(stream speechpb.Speech_StreamingRecognizeServer) error {
+	f.mu.Lock()
+	f.sessions++
+	f.mu.Unlock()
+
+	req, err := stream.Recv()
+	if err != nil {
+		return err
+	}
+	cfg := req.GetStreamingConfig()
+	if cfg == nil || !cfg.GetInterimResults() || cfg.GetConfig().GetSampleRateHertz() != testRate {
+		return status.Errorf(codes.InvalidArgument, "unexpected first request %v", req)
+	}
+
+	var pending []fakeWord
+	var offset time.Duration
+	finalize := func() error {
+		if len(pending) == 0 {
+			return nil
+		}
+		r := fakeResult(pending, true)
+		pending = nil
+		return stream.Send(&speechpb.StreamingRecognizeResponse{Results: []*speechpb.StreamingRecognitionResult{r}})
+	}
+	for chunks := 1; ; chunks++ {
+		req, err := stream.Recv()
+		if err == io.EOF {
+			return finalize()
+		}
+		if err != nil {
+			return err
+		}
+		if f.maxChunks > 0 && chunks > f.maxChunks {
+			return status.Error(codes.OutOfRange, "exceeded maximum allowed stream duration")
+		}
+		audio := req.GetAudioContent()
+		end := offset + time.Duration(len(audio))*time.Second/(testRate*2)
+		pending = append(pending, fakeWord{
+			text:  fmt.Sprintf("w%d", binary.BigEndian.Uint32(audio)),
+			start: offset,
+			end:   end,
+		})
+		offset = end
+		if len(pending) == f.finalEvery {
+			if err := finalize(); err != nil {
+				return err
+			}
+			continue
+		}
+		// The last word is unstable, the others are stable.
+		results := []*speechpb.StreamingRecognitionResult{fakeResult(pending[len(pending)-1:], false)}
+		results[0].Stability = 0.1
+		if len(pending) > 1 {
+			stable := fakeResult(pending[:len(pending)-1], false)
+			stable.Stability = 0.9
+			results = append([]*speechpb.StreamingRecognitionResult{stable}, results...)
+		}
+		if err := stream.Send(&speechpb.StreamingRecognizeResponse{Results: results}); err != nil {
+			return err
+		}
+	}
+}
+
+// fakeResult returns a result with words. Like the API, only final results
+// have word offsets.
+func fakeResult(words []fakeWord, final bool) *speechpb.StreamingRecognitionResult {
+	var text []string
+	alt := &speechpb.SpeechRecognitionAlternative{}
+	for _, w := range words {
+		text = append(text, w.text)
+		if final {
+			alt.Words = append(alt.Words, &speechpb.WordInfo{
+				Word:      w.text,
+				StartTime: durationpb.New(w.start),
+				EndTime:   durationpb.New(w.end),
+			})
+		}
+	}
+	alt.Transcript = strings.Join(text, " ")
+	return &speechpb.StreamingRecognitionResult{
+		Alternatives:  []*speechpb.SpeechRecognitionAlternative{alt},
+		IsFinal:       final,
+		ResultEndTime: durationpb.New(words[len(words)-1].end),
+	}
+}
+
+// newFakeClient starts f and returns a client connected to it.
+func newFakeClient(t *testing.T, f *fakeSpeech) *speech.Client {
+	t.Helper()
+	lis, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := grpc.NewServer()
+	speechpb.RegisterSpeechServer(srv, f)
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	client, err := speech.NewClient(context.Background(),
+		option.WithEndpoint(lis.Addr().String()),
+		option.WithoutAuthentication(),
+		option.WithGRPCDialOption(grpc.WithTransportCredentials(insecure.NewCredentials())),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+// testAudio returns the chunks from first to last, each starting with its
+// index.
+func testAudio(first, last int) []byte {
+	var b bytes.Buffer
+	for i := first; i <= last; i++ {
+		c := make([]byte, testChunkSize)
+		binary.BigEndian.PutUint32(c, uint32(i))
+		b.Write(c)
+	}
+	return b.Bytes()
+}
+
+// words returns "w<first> ... w<last>".
+func words(first, last int) string {
+	var w []string
+	for i := first; i <= last; i++ {
+		w = append(w, fmt.Sprintf("w%d", i))
+	}
+	return strings.Join(w, " ")
+}
+
+func TestStreamer(t *testing.T) {
+	tests := []struct {
+		name         string
+		fake         *fakeSpeech
+		sessionLimit time.Duration
+		overlap      time.Duration
+		resume       time.Duration
+		first, last  int
+		wantSessions int
+	}{
+		{
+			name:         "single session",
+			fake:         &fakeSpeech{finalEvery: 3},
+			sessionLimit: time.Minute,
+			last:         9,
+			wantSessions: 1,
+		},
+		{
+			name:         "rotation",
+			fake:         &fakeSpeech{finalEvery: 3},
+			sessionLimit: 500 * time.Millisecond,
+			last:         24,
+			wantSessions: 6,
+		},
+		{
+			name:         "rotation with overlap",
+			fake:         &fakeSpeech{finalEvery: 3},
+			sessionLimit: 500 * time.Millisecond,
+			overlap:      200 * time.Millisecond,
+			last:         24,
+			wantSessions: 8,
+		},
+		{
+			name:         "replay after OUT_OF_RANGE",
+			fake:         &fakeSpeech{finalEvery: 3, maxChunks: 4},
+			sessionLimit: time.Minute,
+			last:         24,
+			wantSessions: 8,
+		},
+		{
+			name:         "resume",
+			fake:         &fakeSpeech{finalEvery: 4},
+			sessionLimit: 500 * time.Millisecond,
+			resume:       time.Second,
+			first:        10,
+			last:         19,
+			wantSessions: 3,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			client := newFakeClient(t, tc.fake)
+			var got []result
+			s := newStreamer(client, &speechpb.RecognitionConfig{
+				Encoding:        speechpb.RecognitionConfig_LINEAR16,
+				SampleRateHertz: testRate,
+				LanguageCode:    "en-US",
+			}, tc.resume, func(r []result) { got = append(got, r...) })
+			s.sessionLimit = tc.sessionLimit
+			s.overlap = tc.overlap
+
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			if err := s.run(ctx, bytes.NewReader(testAudio(tc.first, tc.last))); err != nil {
+				t.Fatalf("run: %v", err)
+			}
+
+			var text []string
+			var stable, unstable bool
+			end := tc.resume
+			for _, r := range got {
+				if !r.final {
+					stable = stable || r.stable
+					unstable = unstable || !r.stable
+					continue
+				}
+				if r.start != end || r.end <= r.start {
+					t.Errorf("final result %q at [%v, %v], want it to start at %v", r.text, r.start, r.end, end)
+				}
+				end = r.end
+				text = append(text, r.text)
+			}
+			if got, want := strings.Join(text, " "), words(tc.first, tc.last); got != want {
+				t.Errorf("transcript:\n got %q\nwant %q", got, want)
+			}
+			if want := tc.resume + time.Duration(tc.last-tc.first+1)*100*time.Millisecond; end != want {
+				t.Errorf("last result ends at %v, want %v", end, want)
+			}
+			if !stable || !unstable {
+				t.Errorf("got stable interim results %v and unstable ones %v, want both", stable, unstable)
+			}
+			if tc.fake.sessions != tc.wantSessions {
+				t.Errorf("got %d sessions, want %d", tc.fake.sessions, tc.wantSessions)
+			}
+		})
+	}
+}
+
+func TestStreamerError(t *testing.T) {
+	client := newFakeClient(t, &fakeSpeech{finalEvery: 3})
+	// The fake rejects any other sample rate.
+	s := newStreamer(client, &speechpb.RecognitionConfig{SampleRateHertz: 8000}, 0, func([]result) {})
+	err := s.run(context.Background(), bytes.NewReader(make([]byte, 1600)))
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("run: got %v, want InvalidArgument", err)
+	}
+}
+
+func TestPrinter(t *testing.T) {
+	var out, status bytes.Buffer
+	p := &printer{out: &out, status: &status}
+	p.print([]result{{text: "hello", stable: true}, {text: "word"}})
+	p.print([]result{{text: "hello world", final: true, stable: true, start: 1500 * time.Millisecond, end: 62 * time.Second}})
+	if got, want := out.String(), "[0:00:01.500 - 0:01:02.000] hello world\n"; got != want {
+		t.Errorf("out = %q, want %q", got, want)
+	}
+	if got, want := status.String(), "hello (word)\r            \r"; got != want {
+		t.Errorf("status = %q, want %q", got, want)
+	}
+}
+
+func TestParseOffset(t *testing.T) {
+	for _, tc := range []struct {
+		in   string
+		want time.Duration
+	}{
+		{"", 0},
+		{"90s", 90 * time.Second},
+		{offset(62*time.Minute + 3500*time.Millisecond), 62*time.Minute + 3500*time.Millisecond},
+	} {
+		got, err := parseOffset(tc.in)
+		if err != nil || got != tc.want {
+			t.Errorf("parseOffset(%q) = %v, %v, want %v", tc.in, got, err, tc.want)
+		}
+	}
+	if _, err := parseOffset("1:xx"); err == nil {
+		t.Errorf("parseOffset(%q) succeeded, want error", "1:xx")
+	}
+}
+
+func TestTranscribeStream(t *testing.T) {
+	testutil.SystemTest(t)
+
+	f, err := os.Open("../testdata/audio.raw")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var buf bytes.Buffer
+	if err := transcribeStream(&buf, f); err != nil {
+		t.Fatalf("transcribeStream: %v", err)
+	}
+	if got, want := buf.String(), "how old is the Brooklyn Bridge"; !strings.Contains(got, want) {
+		t.Errorf("transcribeStream got %q, want to contain %q", got, want)
+	}
+}
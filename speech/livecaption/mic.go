@@ -0,0 +1,103 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+// [START speech_transcribe_streaming_mic]
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+
+	speech "cloud.google.com/go/speech/apiv1"
+	"cloud.google.com/go/speech/apiv1/speechpb"
+)
+
+// transcribeStream pipes the 16kHz LINEAR16 audio read from audio, such as
+// the mic input, to the Speech API, and prints the results to w. A single
+// stream is limited to a few minutes of audio.
+func transcribeStream(w io.Writer, audio io.Reader) error {
+	ctx := context.Background()
+
+	client, err := speech.NewClient(ctx)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	stream, err := client.StreamingRecognize(ctx)
+	if err != nil {
+		return err
+	}
+	// Send the initial configuration message.
+	if err := stream.Send(&speechpb.StreamingRecognizeRequest{
+		StreamingRequest: &speechpb.StreamingRecognizeRequest_StreamingConfig{
+			StreamingConfig: &speechpb.StreamingRecognitionConfig{
+				Config: &speechpb.RecognitionConfig{
+					Encoding:        speechpb.RecognitionConfig_LINEAR16,
+					SampleRateHertz: 16000,
+					LanguageCode:    "en-US",
+				},
+			},
+		},
+	}); err != nil {
+		return err
+	}
+
+	go func() {
+		// Pipe the audio to the API.
+		buf := make([]byte, 1024)
+		for {
+			n, err := audio.Read(buf)
+			if n > 0 {
+				if err := stream.Send(&speechpb.StreamingRecognizeRequest{
+					StreamingRequest: &speechpb.StreamingRecognizeRequest_AudioContent{
+						AudioContent: buf[:n],
+					},
+				}); err != nil {
+					log.Printf("Could not send audio: %v", err)
+				}
+			}
+			if err == io.EOF {
+				// Nothing else to pipe, close the stream.
+				if err := stream.CloseSend(); err != nil {
+					log.Printf("Could not close stream: %v", err)
+				}
+				return
+			}
+			if err != nil {
+				log.Printf("Could not read audio: %v", err)
+				continue
+			}
+		}
+	}()
+
+	for {
+		resp, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("cannot stream results: %w", err)
+		}
+		if err := resp.Error; err != nil {
+			return fmt.Errorf("could not recognize: %v", err)
+		}
+		for _, result := range resp.Results {
+			fmt.Fprintf(w, "Result: %+v\n", result)
+		}
+	}
+}
+
+// [END speech_transcribe_streaming_mic]
@@ -0,0 +1,318 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	speech "cloud.google.com/go/speech/apiv1"
+	"cloud.google.com/go/speech/apiv1/speechpb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// stableThreshold is the stability above which an interim result is not
+// expected to change anymore.
+const stableThreshold = 0.8
+
+// result is a transcript, with its position in the whole audio input.
+type result struct {
+	text       string
+	start, end time.Duration
+	final      bool
+	// stable is set for final results, and interim results that are
+	// unlikely to change.
+	stable bool
+}
+
+// chunk is a piece of audio and where it starts in the input.
+type chunk struct {
+	data  []byte
+	start time.Duration
+}
+
+// streamer transcribes audio of any length. A streaming session is limited
+// to a few minutes of audio, so the streamer rotates sessions: it closes a
+// session once it has sent sessionLimit of audio, and starts the next one by
+// replaying the audio that wasn't finalized yet, preceded by overlap of
+// finalized audio for context. Words from the overlap are not repeated.
+type streamer struct {
+	client *speech.Client
+	config *speechpb.RecognitionConfig
+
+	sessionLimit time.Duration
+	overlap      time.Duration
+	chunkSize    int // In bytes.
+
+	// handle is called with the results of each response.
+	handle func([]result)
+
+	mu sync.Mutex
+	// buf holds the audio of the current session that is not finalized,
+	// and up to overlap of finalized audio before it.
+	buf []chunk
+	// read is the offset of the end of the audio read so far.
+	read time.Duration
+	// finalEnd is the offset of the end of the last final result.
+	finalEnd time.Duration
+}
+
+// newStreamer returns a streamer of mono LINEAR16 audio with config, whose
+// offsets start at offset.
+func newStreamer(client *speech.Client, config *speechpb.RecognitionConfig, offset time.Duration, handle func([]result)) *streamer {
+	return &streamer{
+		client:       client,
+		config:       config,
+		sessionLimit: 290 * time.Second,
+		overlap:      time.Second,
+		chunkSize:    int(config.GetSampleRateHertz()) * 2 / 10, // 100ms.
+		handle:       handle,
+		read:         offset,
+		finalEnd:     offset,
+	}
+}
+
+// duration returns the duration of n bytes of audio.
+func (s *streamer) duration(n int) time.Duration {
+	return time.Duration(n) * time.Second / time.Duration(s.config.GetSampleRateHertz()*2)
+}
+
+// run transcribes the audio read from r until its end, or until ctx is
+// done.
+func (s *streamer) run(ctx context.Context, r io.Reader) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	chunks := make(chan chunk, 16)
+	var readErr error
+	go func() {
+		defer close(chunks)
+		offset := s.read
+		for {
+			data := make([]byte, s.chunkSize)
+			n, err := io.ReadFull(r, data)
+			if n > 0 {
+				select {
+				case chunks <- chunk{data: data[:n], start: offset}:
+				case <-ctx.Done():
+					return
+				}
+				offset += s.duration(n)
+			}
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				return
+			}
+			if err != nil {
+				readErr = err
+				return
+			}
+		}
+	}()
+	for {
+		done, err := s.session(ctx, chunks)
+		if err != nil {
+			return err
+		}
+		if done {
+			// chunks is closed, so readErr is set.
+			return readErr
+		}
+	}
+}
+
+// session streams audio to a new StreamingRecognize call, until the input
+// ends or the session must be rotated. It reports whether the input ended
+// and every result was received.
+func (s *streamer) session(ctx context.Context, chunks <-chan chunk) (done bool, err error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stream, err := s.client.StreamingRecognize(ctx)
+	if err != nil {
+		return false, err
+	}
+	// Send the initial configuration message.
+	if err := stream.Send(&speechpb.StreamingRecognizeRequest{
+		StreamingRequest: &speechpb.StreamingRecognizeRequest_StreamingConfig{
+			StreamingConfig: &speechpb.StreamingRecognitionConfig{
+				Config:         s.config,
+				InterimResults: true,
+			},
+		},
+	}); err != nil {
+		return false, err
+	}
+
+	s.mu.Lock()
+	replay := append([]chunk(nil), s.buf...)
+	base := s.read
+	if len(replay) > 0 {
+		base = replay[0].start
+	}
+	s.mu.Unlock()
+
+	var wg sync.WaitGroup
+	var inputDone bool
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		send := func(c chunk) error {
+			return stream.Send(&speechpb.StreamingRecognizeRequest{
+				StreamingRequest: &speechpb.StreamingRecognizeRequest_AudioContent{
+					AudioContent: c.data,
+				},
+			})
+		}
+		var sent time.Duration
+		for _, c := range replay {
+			if err := send(c); err != nil {
+				return
+			}
+			sent += s.duration(len(c.data))
+		}
+		for sent < s.sessionLimit {
+			select {
+			case c, ok := <-chunks:
+				if !ok {
+					inputDone = true
+					stream.CloseSend()
+					return
+				}
+				// Buffer the chunk before sending it, so that it is
+				// replayed if the session ends before it is finalized.
+				s.mu.Lock()
+				s.buf = append(s.buf, c)
+				s.read = c.start + s.duration(len(c.data))
+				s.mu.Unlock()
+				if err := send(c); err != nil {
+					return
+				}
+				sent += s.duration(len(c.data))
+			case <-ctx.Done():
+				return
+			}
+		}
+		// Closing the stream makes the API finalize the audio it has.
+		stream.CloseSend()
+	}()
+	defer func() {
+		cancel()
+		wg.Wait()
+	}()
+
+	for {
+		resp, err := stream.Recv()
+		if err == io.EOF {
+			cancel()
+			wg.Wait()
+			return inputDone, nil
+		}
+		if status.Code(err) == codes.OutOfRange {
+			// The session reached the limit of the API before ours.
+			return false, nil
+		}
+		if err != nil {
+			return false, fmt.Errorf("could not stream results: %w", err)
+		}
+		if err := resp.GetError(); err != nil {
+			if codes.Code(err.GetCode()) == codes.OutOfRange {
+				return false, nil
+			}
+			return false, fmt.Errorf("could not recognize: %v", err.GetMessage())
+		}
+		if results := s.results(base, resp); len(results) > 0 {
+			s.handle(results)
+		}
+	}
+}
+
+// results converts the results of a response of the session whose audio
+// starts at base. Final results advance finalEnd and release the audio they
+// cover; text already finalized by an earlier session is dropped.
+func (s *streamer) results(base time.Duration, resp *speechpb.StreamingRecognizeResponse) []result {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var results []result
+	for _, r := range resp.GetResults() {
+		alts := r.GetAlternatives()
+		if len(alts) == 0 {
+			continue
+		}
+		end := base + r.GetResultEndTime().AsDuration()
+		if end <= s.finalEnd {
+			// Replayed overlap.
+			continue
+		}
+		res := result{
+			text:   strings.TrimSpace(alts[0].GetTranscript()),
+			start:  s.finalEnd,
+			end:    end,
+			final:  r.GetIsFinal(),
+			stable: r.GetIsFinal() || r.GetStability() >= stableThreshold,
+		}
+		if words := alts[0].GetWords(); len(words) > 0 && base < s.finalEnd {
+			res.text = textAfter(words, base, s.finalEnd)
+		}
+		if res.final {
+			s.finalEnd = end
+			s.trim()
+		}
+		if res.text != "" {
+			results = append(results, res)
+		}
+	}
+	return results
+}
+
+// textAfter returns the words whose middle is after offset, for a session
+// whose audio starts at base.
+func textAfter(words []*speechpb.WordInfo, base, offset time.Duration) string {
+	var kept []string
+	for _, w := range words {
+		mid := base + (w.GetStartTime().AsDuration()+w.GetEndTime().AsDuration())/2
+		if mid >= offset {
+			kept = append(kept, w.GetWord())
+		}
+	}
+	return strings.Join(kept, " ")
+}
+
+// trim drops the buffered audio that ends more than overlap before
+// finalEnd. s.mu must be held.
+func (s *streamer) trim() {
+	i := 0
+	for i < len(s.buf) && s.buf[i].start+s.duration(len(s.buf[i].data)) <= s.finalEnd-s.overlap {
+		i++
+	}
+	s.buf = s.buf[i:]
+}
+
+// errInvalidOffset is returned by skip for negative offsets.
+var errInvalidOffset = errors.New("offset must not be negative")
+
+// skip discards offset of audio from r, so that a transcription can be
+// resumed where a previous one stopped.
+func skip(r io.Reader, offset time.Duration, sampleRate int) error {
+	if offset < 0 {
+		return errInvalidOffset
+	}
+	n := int64(offset.Seconds()*float64(sampleRate)) * 2
+	_, err := io.CopyN(io.Discard, r, n)
+	return err
+}
@@ -0,0 +1,102 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bucketconfig
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"cloud.google.com/go/iam"
+	"cloud.google.com/go/storage"
+	"gopkg.in/yaml.v3"
+)
+
+// ErrNotConfirmed is returned when applying irreversible changes without
+// confirmation.
+var ErrNotConfirmed = errors.New("irreversible changes not confirmed")
+
+// Read returns the state of a bucket. If readIAM is false, the IAM policy
+// is not read, and the IAM bindings are not managed.
+func Read(ctx context.Context, b *storage.BucketHandle, readIAM bool) (*State, error) {
+	attrs, err := b.Attrs(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("Bucket(%q).Attrs: %w", b.BucketName(), err)
+	}
+	var policy *iam.Policy3
+	if readIAM {
+		policy, err = b.IAM().V3().Policy(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("Bucket(%q).IAM().V3().Policy: %w", b.BucketName(), err)
+		}
+	}
+	return NewState(attrs, policy), nil
+}
+
+// Apply applies the plan to the bucket it was made for. Irreversible
+// changes are only applied if confirmed.
+//
+// Each request fails if the bucket changed since it was read: read it
+// again, and make a new plan.
+func Apply(ctx context.Context, b *storage.BucketHandle, p *Plan, confirm bool) error {
+	if irreversible := p.Irreversible(); len(irreversible) > 0 && !confirm {
+		var fields []string
+		for _, c := range irreversible {
+			fields = append(fields, c.Field)
+		}
+		return fmt.Errorf("%w: %s", ErrNotConfirmed, strings.Join(fields, ", "))
+	}
+
+	metageneration := p.metageneration
+	if p.updateAttrs {
+		attrs, err := b.If(storage.BucketConditions{MetagenerationMatch: metageneration}).Update(ctx, p.update)
+		if err != nil {
+			return fmt.Errorf("Bucket(%q).Update: %w", b.BucketName(), err)
+		}
+		metageneration = attrs.MetaGeneration
+	}
+	if p.lockRetention {
+		if err := b.If(storage.BucketConditions{MetagenerationMatch: metageneration}).LockRetentionPolicy(ctx); err != nil {
+			return fmt.Errorf("Bucket(%q).LockRetentionPolicy: %w", b.BucketName(), err)
+		}
+	}
+	if p.policy != nil {
+		// The policy has the etag it was read with, so SetPolicy fails if
+		// it changed since.
+		p.policy.Bindings = toIAMBindings(p.bindings)
+		if err := b.IAM().V3().SetPolicy(ctx, p.policy); err != nil {
+			return fmt.Errorf("Bucket(%q).IAM().V3().SetPolicy: %w", b.BucketName(), err)
+		}
+	}
+	return nil
+}
+
+// Parse parses a YAML configuration. Unknown fields are errors, so that
+// typos don't go unnoticed as unmanaged settings.
+func Parse(data []byte) (*Config, error) {
+	dec := yaml.NewDecoder(strings.NewReader(string(data)))
+	dec.KnownFields(true)
+	var c Config
+	if err := dec.Decode(&c); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// Marshal formats a configuration as YAML.
+func Marshal(c *Config) ([]byte, error) {
+	return yaml.Marshal(c)
+}
@@ -0,0 +1,118 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// The bucketconfig command manages the configuration of a Cloud Storage
+// bucket as a YAML document:
+//
+//	bucketconfig export -bucket BUCKET > bucket.yaml
+//	bucketconfig plan -bucket BUCKET -f bucket.yaml
+//	bucketconfig apply -bucket BUCKET -f bucket.yaml [-confirm-irreversible]
+//
+// apply prints the plan, and only applies irreversible changes, such as
+// locking a retention policy, with -confirm-irreversible.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"github.com/GoogleCloudPlatform/golang-samples/storage/buckets/bucketconfig"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+	cmd := os.Args[1]
+	fs := flag.NewFlagSet(cmd, flag.ExitOnError)
+	bucketName := fs.String("bucket", "", "bucket name, by default the name in the configuration")
+	file := fs.String("f", "", "desired configuration")
+	withIAM := fs.Bool("iam", true, "manage the IAM bindings")
+	confirm := fs.Bool("confirm-irreversible", false, "apply irreversible changes")
+	fs.Parse(os.Args[2:])
+
+	var desired *bucketconfig.Config
+	switch cmd {
+	case "export":
+	case "plan", "apply":
+		if *file == "" {
+			log.Fatalf("%s: -f is required", cmd)
+		}
+		data, err := os.ReadFile(*file)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if desired, err = bucketconfig.Parse(data); err != nil {
+			log.Fatalf("%s: %v", *file, err)
+		}
+		if *bucketName == "" {
+			*bucketName = desired.Name
+		}
+	default:
+		usage()
+	}
+	if *bucketName == "" {
+		log.Fatalf("%s: -bucket is required", cmd)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	client, err := storage.NewClient(ctx)
+	if err != nil {
+		log.Fatalf("storage.NewClient: %v", err)
+	}
+	defer client.Close()
+	bucket := client.Bucket(*bucketName)
+
+	state, err := bucketconfig.Read(ctx, bucket, *withIAM)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if cmd == "export" {
+		out, err := bucketconfig.Marshal(state.Config)
+		if err != nil {
+			log.Fatal(err)
+		}
+		os.Stdout.Write(out)
+		return
+	}
+
+	plan, err := state.Plan(desired)
+	if err != nil {
+		log.Fatalf("plan: %v", err)
+	}
+	plan.WriteTo(os.Stdout)
+	if cmd == "plan" || plan.Empty() {
+		return
+	}
+	err = bucketconfig.Apply(ctx, bucket, plan, *confirm)
+	if errors.Is(err, bucketconfig.ErrNotConfirmed) {
+		log.Fatalf("%v: nothing applied; run again with -confirm-irreversible", err)
+	}
+	if err != nil {
+		log.Fatalf("apply: %v", err)
+	}
+	fmt.Printf("Applied %d changes to %s.\n", len(plan.Changes), *bucketName)
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: %s export|plan|apply [flags]\n", os.Args[0])
+	os.Exit(2)
+}
@@ -0,0 +1,358 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package bucketconfig manages the configuration of Cloud Storage buckets
+// as YAML documents: it exports the configuration of a bucket, plans the
+// changes from a desired configuration, and applies them.
+//
+// The settings are those of the storage/buckets samples. Settings missing
+// from a desired configuration are left as they are.
+package bucketconfig
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/iam"
+	"cloud.google.com/go/iam/apiv1/iampb"
+	"cloud.google.com/go/storage"
+	"google.golang.org/genproto/googleapis/type/expr"
+)
+
+// Config is the configuration of a bucket. Nil fields are not managed.
+type Config struct {
+	// Name and Location describe the bucket, and can't be changed.
+	Name     string `yaml:"name,omitempty"`
+	Location string `yaml:"location,omitempty"`
+
+	StorageClass             *string           `yaml:"storageClass"`
+	Labels                   map[string]string `yaml:"labels"`
+	Versioning               *bool             `yaml:"versioning"`
+	RequesterPays            *bool             `yaml:"requesterPays"`
+	UniformBucketLevelAccess *bool             `yaml:"uniformBucketLevelAccess"`
+	// PublicAccessPrevention is "enforced" or "inherited".
+	PublicAccessPrevention *string `yaml:"publicAccessPrevention"`
+	DefaultEventBasedHold  *bool   `yaml:"defaultEventBasedHold"`
+	// DefaultKMSKeyName is empty for Google-managed encryption keys.
+	DefaultKMSKeyName *string `yaml:"defaultKmsKeyName"`
+	// RPO is "DEFAULT" or "ASYNC_TURBO" for dual-region buckets, and empty
+	// for others.
+	RPO              *string           `yaml:"rpo"`
+	RetentionPolicy  *RetentionPolicy  `yaml:"retentionPolicy"`
+	SoftDeletePolicy *SoftDeletePolicy `yaml:"softDeletePolicy"`
+	Autoclass        *Autoclass        `yaml:"autoclass"`
+	Website          *Website          `yaml:"website"`
+	CORS             []CORS            `yaml:"cors"`
+	Lifecycle        []LifecycleRule   `yaml:"lifecycle"`
+	IAM              []Binding         `yaml:"iam"`
+}
+
+// RetentionPolicy is the retention policy of a bucket. A zero period means
+// there is no policy.
+type RetentionPolicy struct {
+	Period Duration `yaml:"period"`
+	// Locked policies can't be removed or shortened, ever.
+	Locked bool `yaml:"locked"`
+}
+
+// SoftDeletePolicy is the soft delete policy of a bucket. A zero duration
+// disables soft delete.
+type SoftDeletePolicy struct {
+	RetentionDuration Duration `yaml:"retentionDuration"`
+}
+
+// Autoclass is the Autoclass configuration of a bucket.
+type Autoclass struct {
+	Enabled              bool   `yaml:"enabled"`
+	TerminalStorageClass string `yaml:"terminalStorageClass,omitempty"`
+}
+
+// Website is the static website configuration of a bucket.
+type Website struct {
+	MainPageSuffix string `yaml:"mainPageSuffix,omitempty"`
+	NotFoundPage   string `yaml:"notFoundPage,omitempty"`
+}
+
+// CORS is a CORS rule of a bucket.
+type CORS struct {
+	Origins         []string `yaml:"origins"`
+	Methods         []string `yaml:"methods"`
+	ResponseHeaders []string `yaml:"responseHeaders,omitempty"`
+	MaxAge          Duration `yaml:"maxAge,omitempty"`
+}
+
+// LifecycleRule is an object lifecycle rule of a bucket.
+type LifecycleRule struct {
+	// Action is "Delete", "SetStorageClass" or
+	// "AbortIncompleteMultipartUpload".
+	Action       string             `yaml:"action"`
+	StorageClass string             `yaml:"storageClass,omitempty"`
+	Condition    LifecycleCondition `yaml:"condition"`
+}
+
+// LifecycleCondition is the condition of a lifecycle rule. Dates are
+// formatted as YYYY-MM-DD.
+type LifecycleCondition struct {
+	AgeInDays int64 `yaml:"ageInDays,omitempty"`
+	// Liveness is "live" or "archived", or empty for both.
+	Liveness                string   `yaml:"liveness,omitempty"`
+	CreatedBefore           string   `yaml:"createdBefore,omitempty"`
+	CustomTimeBefore        string   `yaml:"customTimeBefore,omitempty"`
+	DaysSinceCustomTime     int64    `yaml:"daysSinceCustomTime,omitempty"`
+	DaysSinceNoncurrentTime int64    `yaml:"daysSinceNoncurrentTime,omitempty"`
+	NoncurrentTimeBefore    string   `yaml:"noncurrentTimeBefore,omitempty"`
+	NumNewerVersions        int64    `yaml:"numNewerVersions,omitempty"`
+	MatchesPrefix           []string `yaml:"matchesPrefix,omitempty"`
+	MatchesSuffix           []string `yaml:"matchesSuffix,omitempty"`
+	MatchesStorageClasses   []string `yaml:"matchesStorageClasses,omitempty"`
+	AllObjects              bool     `yaml:"allObjects,omitempty"`
+}
+
+// Binding is an IAM binding of a bucket.
+type Binding struct {
+	Role      string     `yaml:"role"`
+	Members   []string   `yaml:"members"`
+	Condition *Condition `yaml:"condition,omitempty"`
+}
+
+// Condition is the condition of an IAM binding.
+type Condition struct {
+	Title       string `yaml:"title"`
+	Description string `yaml:"description,omitempty"`
+	Expression  string `yaml:"expression"`
+}
+
+// Duration is a time.Duration written as "30d" when a whole number of days,
+// and as by time.Duration.String otherwise.
+type Duration time.Duration
+
+const day = 24 * time.Hour
+
+// MarshalText formats d.
+func (d Duration) MarshalText() ([]byte, error) {
+	if td := time.Duration(d); td != 0 && td%day == 0 {
+		return []byte(fmt.Sprintf("%dd", td/day)), nil
+	}
+	return []byte(time.Duration(d).String()), nil
+}
+
+// UnmarshalText parses a number of days such as "30d", or a duration
+// accepted by time.ParseDuration.
+func (d *Duration) UnmarshalText(b []byte) error {
+	s := string(b)
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.ParseInt(days, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q", s)
+		}
+		*d = Duration(time.Duration(n) * day)
+		return nil
+	}
+	td, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(td)
+	return nil
+}
+
+// Export returns the configuration of a bucket with the attributes and IAM
+// policy. The IAM bindings are not managed if policy is nil.
+func Export(attrs *storage.BucketAttrs, policy *iam.Policy3) *Config {
+	c := &Config{
+		Name:                     attrs.Name,
+		Location:                 attrs.Location,
+		StorageClass:             &attrs.StorageClass,
+		Labels:                   map[string]string{},
+		Versioning:               &attrs.VersioningEnabled,
+		RequesterPays:            &attrs.RequesterPays,
+		UniformBucketLevelAccess: &attrs.UniformBucketLevelAccess.Enabled,
+		PublicAccessPrevention:   ptr(attrs.PublicAccessPrevention.String()),
+		DefaultEventBasedHold:    &attrs.DefaultEventBasedHold,
+		DefaultKMSKeyName:        ptr(""),
+		RPO:                      ptr(attrs.RPO.String()),
+		RetentionPolicy:          &RetentionPolicy{},
+		SoftDeletePolicy:         &SoftDeletePolicy{},
+		Autoclass:                &Autoclass{},
+		Website:                  &Website{},
+		CORS:                     []CORS{},
+		Lifecycle:                []LifecycleRule{},
+	}
+	for k, v := range attrs.Labels {
+		c.Labels[k] = v
+	}
+	if attrs.Encryption != nil {
+		c.DefaultKMSKeyName = &attrs.Encryption.DefaultKMSKeyName
+	}
+	if rp := attrs.RetentionPolicy; rp != nil {
+		c.RetentionPolicy = &RetentionPolicy{Period: Duration(rp.RetentionPeriod), Locked: rp.IsLocked}
+	}
+	if sd := attrs.SoftDeletePolicy; sd != nil {
+		c.SoftDeletePolicy.RetentionDuration = Duration(sd.RetentionDuration)
+	}
+	if ac := attrs.Autoclass; ac != nil {
+		c.Autoclass = &Autoclass{Enabled: ac.Enabled, TerminalStorageClass: ac.TerminalStorageClass}
+	}
+	if ws := attrs.Website; ws != nil {
+		c.Website = &Website{MainPageSuffix: ws.MainPageSuffix, NotFoundPage: ws.NotFoundPage}
+	}
+	for _, r := range attrs.CORS {
+		c.CORS = append(c.CORS, CORS{
+			Origins:         r.Origins,
+			Methods:         r.Methods,
+			ResponseHeaders: r.ResponseHeaders,
+			MaxAge:          Duration(r.MaxAge),
+		})
+	}
+	for _, r := range attrs.Lifecycle.Rules {
+		c.Lifecycle = append(c.Lifecycle, exportRule(r))
+	}
+	if policy != nil {
+		c.IAM = []Binding{}
+		for _, b := range policy.Bindings {
+			binding := Binding{Role: b.Role, Members: b.Members}
+			if cond := b.Condition; cond != nil {
+				binding.Condition = &Condition{Title: cond.Title, Description: cond.Description, Expression: cond.Expression}
+			}
+			c.IAM = append(c.IAM, binding)
+		}
+		sortBindings(c.IAM)
+	}
+	return c
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+const dateLayout = "2006-01-02"
+
+func formatDate(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(dateLayout)
+}
+
+func parseDate(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(dateLayout, s)
+}
+
+func exportRule(r storage.LifecycleRule) LifecycleRule {
+	c := r.Condition
+	rule := LifecycleRule{
+		Action:       r.Action.Type,
+		StorageClass: r.Action.StorageClass,
+		Condition: LifecycleCondition{
+			AgeInDays:               c.AgeInDays,
+			CreatedBefore:           formatDate(c.CreatedBefore),
+			CustomTimeBefore:        formatDate(c.CustomTimeBefore),
+			DaysSinceCustomTime:     c.DaysSinceCustomTime,
+			DaysSinceNoncurrentTime: c.DaysSinceNoncurrentTime,
+			NoncurrentTimeBefore:    formatDate(c.NoncurrentTimeBefore),
+			NumNewerVersions:        c.NumNewerVersions,
+			MatchesPrefix:           c.MatchesPrefix,
+			MatchesSuffix:           c.MatchesSuffix,
+			MatchesStorageClasses:   c.MatchesStorageClasses,
+			AllObjects:              c.AllObjects,
+		},
+	}
+	switch c.Liveness {
+	case storage.Live:
+		rule.Condition.Liveness = "live"
+	case storage.Archived:
+		rule.Condition.Liveness = "archived"
+	}
+	return rule
+}
+
+func (r LifecycleRule) toStorage() (storage.LifecycleRule, error) {
+	c := r.Condition
+	rule := storage.LifecycleRule{
+		Action: storage.LifecycleAction{Type: r.Action, StorageClass: r.StorageClass},
+		Condition: storage.LifecycleCondition{
+			AgeInDays:               c.AgeInDays,
+			DaysSinceCustomTime:     c.DaysSinceCustomTime,
+			DaysSinceNoncurrentTime: c.DaysSinceNoncurrentTime,
+			NumNewerVersions:        c.NumNewerVersions,
+			MatchesPrefix:           c.MatchesPrefix,
+			MatchesSuffix:           c.MatchesSuffix,
+			MatchesStorageClasses:   c.MatchesStorageClasses,
+			AllObjects:              c.AllObjects,
+		},
+	}
+	switch r.Action {
+	case storage.DeleteAction, storage.SetStorageClassAction, storage.AbortIncompleteMPUAction:
+	default:
+		return rule, fmt.Errorf("unknown lifecycle action %q", r.Action)
+	}
+	switch c.Liveness {
+	case "":
+	case "live":
+		rule.Condition.Liveness = storage.Live
+	case "archived":
+		rule.Condition.Liveness = storage.Archived
+	default:
+		return rule, fmt.Errorf("unknown liveness %q", c.Liveness)
+	}
+	for _, d := range []struct {
+		s string
+		t *time.Time
+	}{
+		{c.CreatedBefore, &rule.Condition.CreatedBefore},
+		{c.CustomTimeBefore, &rule.Condition.CustomTimeBefore},
+		{c.NoncurrentTimeBefore, &rule.Condition.NoncurrentTimeBefore},
+	} {
+		t, err := parseDate(d.s)
+		if err != nil {
+			return rule, err
+		}
+		*d.t = t
+	}
+	return rule, nil
+}
+
+// sortBindings sorts bindings and their members, as the order of IAM
+// bindings doesn't matter.
+func sortBindings(bindings []Binding) {
+	for i := range bindings {
+		bindings[i].Members = slices.Clone(bindings[i].Members)
+		slices.Sort(bindings[i].Members)
+	}
+	key := func(b Binding) string {
+		if b.Condition == nil {
+			return b.Role
+		}
+		return b.Role + "\x00" + b.Condition.Title + "\x00" + b.Condition.Expression
+	}
+	slices.SortFunc(bindings, func(a, b Binding) int { return strings.Compare(key(a), key(b)) })
+}
+
+func toIAMBindings(bindings []Binding) []*iampb.Binding {
+	var pb []*iampb.Binding
+	for _, b := range bindings {
+		binding := &iampb.Binding{Role: b.Role, Members: b.Members}
+		if c := b.Condition; c != nil {
+			binding.Condition = &expr.Expr{Title: c.Title, Description: c.Description, Expression: c.Expression}
+		}
+		pb = append(pb, binding)
+	}
+	return pb
+}
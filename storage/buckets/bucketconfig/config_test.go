@@ -0,0 +1,259 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bucketconfig
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/iam"
+	"cloud.google.com/go/iam/apiv1/iampb"
+	"cloud.google.com/go/storage"
+	"google.golang.org/api/option"
+)
+
+func testAttrs() *storage.BucketAttrs {
+	return &storage.BucketAttrs{
+		Name:                     "my-bucket",
+		Location:                 "US-EAST1",
+		StorageClass:             "STANDARD",
+		MetaGeneration:           7,
+		Labels:                   map[string]string{"env": "prod", "team": "storage"},
+		VersioningEnabled:        true,
+		UniformBucketLevelAccess: storage.UniformBucketLevelAccess{Enabled: true},
+		PublicAccessPrevention:   storage.PublicAccessPreventionEnforced,
+		RetentionPolicy:          &storage.RetentionPolicy{RetentionPeriod: 30 * day},
+		SoftDeletePolicy:         &storage.SoftDeletePolicy{RetentionDuration: 7 * day},
+		CORS: []storage.CORS{{
+			Origins: []string{"https://example.com"},
+			Methods: []string{"GET"},
+			MaxAge:  time.Hour,
+		}},
+		Lifecycle: storage.Lifecycle{Rules: []storage.LifecycleRule{{
+			Action:    storage.LifecycleAction{Type: storage.SetStorageClassAction, StorageClass: "NEARLINE"},
+			Condition: storage.LifecycleCondition{AgeInDays: 30, Liveness: storage.Live, CreatedBefore: time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC)},
+		}}},
+	}
+}
+
+func testPolicy() *iam.Policy3 {
+	return &iam.Policy3{Bindings: []*iampb.Binding{
+		{Role: "roles/storage.objectViewer", Members: []string{"user:b@example.com", "user:a@example.com"}},
+	}}
+}
+
+func TestExportRoundTrip(t *testing.T) {
+	state := NewState(testAttrs(), testPolicy())
+	data, err := Marshal(state.Config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"period: 30d", "maxAge: 1h0m0s", "createdBefore: \"2026-01-02\"", "liveness: live", "- user:a@example.com\n"} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("exported configuration does not contain %q:\n%s", want, data)
+		}
+	}
+	desired, err := Parse(data)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	plan, err := state.Plan(desired)
+	if err != nil {
+		t.Fatalf("Plan: %v", err)
+	}
+	if !plan.Empty() {
+		t.Errorf("plan of the exported configuration has changes: %+v", plan.Changes)
+	}
+}
+
+func TestPlan(t *testing.T) {
+	desired, err := Parse([]byte(`
+labels:
+  env: staging
+  owner: me
+versioning: false
+cors: []
+retentionPolicy:
+  period: 60d
+  locked: false
+iam:
+  - role: roles/storage.objectViewer
+    members: [user:a@example.com, user:b@example.com]
+  - role: roles/storage.admin
+    members: [group:admins@example.com]
+`))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	plan, err := NewState(testAttrs(), testPolicy()).Plan(desired)
+	if err != nil {
+		t.Fatalf("Plan: %v", err)
+	}
+
+	var fields []string
+	for _, c := range plan.Changes {
+		fields = append(fields, c.Field)
+	}
+	want := "labels.env labels.owner labels.team versioning retentionPolicy.period cors iam"
+	if got := strings.Join(fields, " "); got != want {
+		t.Errorf("changed fields = %s, want %s", got, want)
+	}
+	if len(plan.Irreversible()) != 0 {
+		t.Errorf("irreversible changes: %+v", plan.Irreversible())
+	}
+
+	u := plan.update
+	if u.VersioningEnabled != false || u.CORS == nil || len(u.CORS) != 0 {
+		t.Errorf("update: versioning %v, CORS %v, want false, empty", u.VersioningEnabled, u.CORS)
+	}
+	if u.RetentionPolicy == nil || u.RetentionPolicy.RetentionPeriod != 60*day {
+		t.Errorf("update: retention policy %+v, want 60 days", u.RetentionPolicy)
+	}
+	// Unmanaged settings are not updated.
+	if u.StorageClass != "" || u.Lifecycle != nil || u.SoftDeletePolicy != nil {
+		t.Errorf("update changes unmanaged settings: %+v", u)
+	}
+	if !plan.updateAttrs || plan.lockRetention || len(plan.bindings) != 2 {
+		t.Errorf("plan: update %t, lock %t, %d bindings; want true, false, 2", plan.updateAttrs, plan.lockRetention, len(plan.bindings))
+	}
+}
+
+func TestPlanWriteTo(t *testing.T) {
+	desired := &Config{
+		Versioning:      ptr(false),
+		RetentionPolicy: &RetentionPolicy{Period: Duration(30 * day), Locked: true},
+		Website:         &Website{MainPageSuffix: "index.html"},
+	}
+	plan, err := NewState(testAttrs(), nil).Plan(desired)
+	if err != nil {
+		t.Fatalf("Plan: %v", err)
+	}
+	var b strings.Builder
+	plan.WriteTo(&b)
+	want := `~ versioning: true => false
+! retentionPolicy.locked: false => true (irreversible)
+~ website: {} => mainPageSuffix: index.html
+`
+	if got := b.String(); got != want {
+		t.Errorf("plan:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestPlanErrors(t *testing.T) {
+	locked := testAttrs()
+	locked.RetentionPolicy.IsLocked = true
+	tests := []struct {
+		name    string
+		attrs   *storage.BucketAttrs
+		desired *Config
+	}{
+		{"other bucket", testAttrs(), &Config{Name: "other"}},
+		{"location", testAttrs(), &Config{Location: "EU"}},
+		{"unlock", locked, &Config{RetentionPolicy: &RetentionPolicy{Period: Duration(30 * day)}}},
+		{"shorten locked", locked, &Config{RetentionPolicy: &RetentionPolicy{Period: Duration(day), Locked: true}}},
+		{"lock empty", testAttrs(), &Config{RetentionPolicy: &RetentionPolicy{Locked: true}}},
+		{"public access prevention", testAttrs(), &Config{PublicAccessPrevention: ptr("on")}},
+		{"lifecycle action", testAttrs(), &Config{Lifecycle: []LifecycleRule{{Action: "Archive"}}}},
+		{"iam not read", testAttrs(), &Config{IAM: []Binding{}}},
+	}
+	for _, tc := range tests {
+		if _, err := NewState(tc.attrs, nil).Plan(tc.desired); err == nil {
+			t.Errorf("%s: Plan succeeded, want error", tc.name)
+		}
+	}
+
+	// Extending a locked policy is allowed, and irreversible.
+	plan, err := NewState(locked, nil).Plan(&Config{RetentionPolicy: &RetentionPolicy{Period: Duration(60 * day), Locked: true}})
+	if err != nil {
+		t.Fatalf("Plan: %v", err)
+	}
+	if len(plan.Irreversible()) != 1 {
+		t.Errorf("extending a locked policy: irreversible changes %+v, want 1", plan.Irreversible())
+	}
+}
+
+func TestParseUnknownField(t *testing.T) {
+	if _, err := Parse([]byte("versionning: true\n")); err == nil {
+		t.Error("Parse with a typo succeeded, want error")
+	}
+}
+
+func TestDuration(t *testing.T) {
+	for s, want := range map[string]time.Duration{"30d": 30 * day, "1h30m0s": 90 * time.Minute, "0s": 0} {
+		var d Duration
+		if err := d.UnmarshalText([]byte(s)); err != nil || time.Duration(d) != want {
+			t.Errorf("UnmarshalText(%q) = %v, %v, want %v", s, time.Duration(d), err, want)
+		}
+		if b, _ := d.MarshalText(); string(b) != s {
+			t.Errorf("MarshalText(%v) = %q, want %q", want, b, s)
+		}
+	}
+	var d Duration
+	if err := d.UnmarshalText([]byte("xd")); err == nil {
+		t.Error("UnmarshalText(xd) succeeded, want error")
+	}
+}
+
+func TestApply(t *testing.T) {
+	var requests []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests = append(requests, r.Method+" "+r.URL.Path+" "+r.URL.Query().Get("ifMetagenerationMatch")+" "+string(body))
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"name": "my-bucket", "metageneration": "8"})
+	}))
+	defer srv.Close()
+	client, err := storage.NewClient(context.Background(), option.WithEndpoint(srv.URL+"/storage/v1/"), option.WithoutAuthentication())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	bucket := client.Bucket("my-bucket")
+
+	plan, err := NewState(testAttrs(), nil).Plan(&Config{
+		Versioning:      ptr(false),
+		RetentionPolicy: &RetentionPolicy{Period: Duration(30 * day), Locked: true},
+	})
+	if err != nil {
+		t.Fatalf("Plan: %v", err)
+	}
+	if err := Apply(context.Background(), bucket, plan, false); !errors.Is(err, ErrNotConfirmed) {
+		t.Fatalf("Apply without confirmation: %v, want ErrNotConfirmed", err)
+	}
+	if len(requests) != 0 {
+		t.Fatalf("Apply without confirmation made requests: %q", requests)
+	}
+
+	if err := Apply(context.Background(), bucket, plan, true); err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+	if len(requests) != 2 {
+		t.Fatalf("Apply made %d requests, want 2: %q", len(requests), requests)
+	}
+	// The update only sends the changed fields, and the lock follows it.
+	if want := `PATCH /storage/v1/b/my-bucket 7 {"versioning":{"enabled":false}}`; strings.TrimSpace(requests[0]) != want {
+		t.Errorf("first request = %q, want %q", requests[0], want)
+	}
+	if want := "POST /storage/v1/b/my-bucket/lockRetentionPolicy 8 "; requests[1] != want {
+		t.Errorf("second request = %q, want %q", requests[1], want)
+	}
+}
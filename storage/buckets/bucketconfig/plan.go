@@ -0,0 +1,303 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bucketconfig
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/iam"
+	"cloud.google.com/go/storage"
+	"gopkg.in/yaml.v3"
+)
+
+// State is the configuration of a bucket when it was read.
+type State struct {
+	Config         *Config
+	metageneration int64
+	policy         *iam.Policy3
+}
+
+// NewState returns the state of a bucket with the attributes and IAM
+// policy, which may be nil.
+func NewState(attrs *storage.BucketAttrs, policy *iam.Policy3) *State {
+	return &State{Config: Export(attrs, policy), metageneration: attrs.MetaGeneration, policy: policy}
+}
+
+// Change is a change of a setting.
+type Change struct {
+	Field    string
+	From, To string
+	// Irreversible changes can't be undone.
+	Irreversible bool
+}
+
+// Plan is the changes that make a bucket match a desired configuration.
+type Plan struct {
+	Changes []Change
+
+	metageneration int64
+	update         storage.BucketAttrsToUpdate
+	updateAttrs    bool
+	lockRetention  bool
+	policy         *iam.Policy3
+	bindings       []Binding
+}
+
+// Empty reports whether the bucket already has the desired configuration.
+func (p *Plan) Empty() bool {
+	return len(p.Changes) == 0
+}
+
+// Irreversible returns the irreversible changes.
+func (p *Plan) Irreversible() []Change {
+	var changes []Change
+	for _, c := range p.Changes {
+		if c.Irreversible {
+			changes = append(changes, c)
+		}
+	}
+	return changes
+}
+
+// WriteTo writes the changes, one per line. Multi-line values are written
+// on the following lines.
+func (p *Plan) WriteTo(w io.Writer) (int64, error) {
+	var b strings.Builder
+	if p.Empty() {
+		b.WriteString("No changes.\n")
+	}
+	for _, c := range p.Changes {
+		mark := "~"
+		if c.Irreversible {
+			mark = "!"
+		}
+		if !strings.Contains(c.From+c.To, "\n") {
+			fmt.Fprintf(&b, "%s %s: %s => %s", mark, c.Field, c.From, c.To)
+		} else {
+			fmt.Fprintf(&b, "%s %s:\n    from:\n%s\n    to:\n%s", mark, c.Field, indent(c.From), indent(c.To))
+		}
+		if c.Irreversible {
+			b.WriteString(" (irreversible)")
+		}
+		b.WriteString("\n")
+	}
+	n, err := io.WriteString(w, b.String())
+	return int64(n), err
+}
+
+func indent(s string) string {
+	return "      " + strings.ReplaceAll(s, "\n", "\n      ")
+}
+
+// format returns v as YAML.
+func format(v interface{}) string {
+	b, err := yaml.Marshal(v)
+	if err != nil {
+		return fmt.Sprintf("<%v>", err)
+	}
+	return strings.TrimSuffix(string(b), "\n")
+}
+
+// diff adds a change if desired is managed and differs from current, and
+// returns whether it did.
+func (p *Plan) diff(field string, current, desired interface{}) bool {
+	if v := reflect.ValueOf(desired); v.Kind() == reflect.Pointer || v.Kind() == reflect.Slice {
+		if v.IsNil() {
+			return false
+		}
+	}
+	from, to := format(current), format(desired)
+	if from == to {
+		return false
+	}
+	p.Changes = append(p.Changes, Change{Field: field, From: from, To: to})
+	return true
+}
+
+// Plan returns the changes from the state to the desired configuration.
+// It fails if the desired configuration is invalid, or changes settings
+// that can't be changed.
+func (s *State) Plan(desired *Config) (*Plan, error) {
+	cur := s.Config
+	p := &Plan{metageneration: s.metageneration}
+	u := &p.update
+
+	if desired.Name != "" && desired.Name != cur.Name {
+		return nil, fmt.Errorf("configuration of bucket %q, not %q", desired.Name, cur.Name)
+	}
+	if desired.Location != "" && !strings.EqualFold(desired.Location, cur.Location) {
+		return nil, fmt.Errorf("location: can't change from %s to %s", cur.Location, desired.Location)
+	}
+
+	if p.diff("storageClass", cur.StorageClass, desired.StorageClass) {
+		u.StorageClass = *desired.StorageClass
+	}
+	if desired.Labels != nil {
+		var keys []string
+		for k := range cur.Labels {
+			keys = append(keys, k)
+		}
+		for k := range desired.Labels {
+			if _, ok := cur.Labels[k]; !ok {
+				keys = append(keys, k)
+			}
+		}
+		slices.Sort(keys)
+		for _, k := range keys {
+			from, inCur := cur.Labels[k]
+			to, inDesired := desired.Labels[k]
+			switch {
+			case !inDesired:
+				p.Changes = append(p.Changes, Change{Field: "labels." + k, From: format(from), To: "(deleted)"})
+				u.DeleteLabel(k)
+			case !inCur:
+				p.Changes = append(p.Changes, Change{Field: "labels." + k, From: "(none)", To: format(to)})
+				u.SetLabel(k, to)
+			case from != to:
+				p.diff("labels."+k, from, to)
+				u.SetLabel(k, to)
+			}
+		}
+	}
+	if p.diff("versioning", cur.Versioning, desired.Versioning) {
+		u.VersioningEnabled = *desired.Versioning
+	}
+	if p.diff("requesterPays", cur.RequesterPays, desired.RequesterPays) {
+		u.RequesterPays = *desired.RequesterPays
+	}
+	if p.diff("uniformBucketLevelAccess", cur.UniformBucketLevelAccess, desired.UniformBucketLevelAccess) {
+		u.UniformBucketLevelAccess = &storage.UniformBucketLevelAccess{Enabled: *desired.UniformBucketLevelAccess}
+	}
+	if p.diff("publicAccessPrevention", cur.PublicAccessPrevention, desired.PublicAccessPrevention) {
+		switch *desired.PublicAccessPrevention {
+		case "enforced":
+			u.PublicAccessPrevention = storage.PublicAccessPreventionEnforced
+		case "inherited":
+			u.PublicAccessPrevention = storage.PublicAccessPreventionInherited
+		default:
+			return nil, fmt.Errorf("publicAccessPrevention: unknown value %q", *desired.PublicAccessPrevention)
+		}
+	}
+	if p.diff("defaultEventBasedHold", cur.DefaultEventBasedHold, desired.DefaultEventBasedHold) {
+		u.DefaultEventBasedHold = *desired.DefaultEventBasedHold
+	}
+	if p.diff("defaultKmsKeyName", cur.DefaultKMSKeyName, desired.DefaultKMSKeyName) {
+		// An empty key name removes the default key.
+		u.Encryption = &storage.BucketEncryption{DefaultKMSKeyName: *desired.DefaultKMSKeyName}
+	}
+	if p.diff("rpo", cur.RPO, desired.RPO) {
+		switch *desired.RPO {
+		case "DEFAULT":
+			u.RPO = storage.RPODefault
+		case "ASYNC_TURBO":
+			u.RPO = storage.RPOAsyncTurbo
+		default:
+			return nil, fmt.Errorf("rpo: unknown value %q", *desired.RPO)
+		}
+	}
+	if err := p.diffRetention(cur.RetentionPolicy, desired.RetentionPolicy); err != nil {
+		return nil, err
+	}
+	if p.diff("softDeletePolicy", cur.SoftDeletePolicy, desired.SoftDeletePolicy) {
+		u.SoftDeletePolicy = &storage.SoftDeletePolicy{RetentionDuration: time.Duration(desired.SoftDeletePolicy.RetentionDuration)}
+	}
+	if p.diff("autoclass", cur.Autoclass, desired.Autoclass) {
+		u.Autoclass = &storage.Autoclass{Enabled: desired.Autoclass.Enabled, TerminalStorageClass: desired.Autoclass.TerminalStorageClass}
+	}
+	if p.diff("website", cur.Website, desired.Website) {
+		u.Website = &storage.BucketWebsite{MainPageSuffix: desired.Website.MainPageSuffix, NotFoundPage: desired.Website.NotFoundPage}
+	}
+	if p.diff("cors", cur.CORS, desired.CORS) {
+		// An empty list removes the CORS configuration.
+		u.CORS = []storage.CORS{}
+		for _, r := range desired.CORS {
+			u.CORS = append(u.CORS, storage.CORS{
+				Origins:         r.Origins,
+				Methods:         r.Methods,
+				ResponseHeaders: r.ResponseHeaders,
+				MaxAge:          time.Duration(r.MaxAge),
+			})
+		}
+	}
+	if p.diff("lifecycle", cur.Lifecycle, desired.Lifecycle) {
+		u.Lifecycle = &storage.Lifecycle{}
+		for i, r := range desired.Lifecycle {
+			rule, err := r.toStorage()
+			if err != nil {
+				return nil, fmt.Errorf("lifecycle rule %d: %w", i, err)
+			}
+			u.Lifecycle.Rules = append(u.Lifecycle.Rules, rule)
+		}
+	}
+	// Locking the retention policy is a separate request.
+	attrChanges := len(p.Changes)
+	if p.lockRetention {
+		attrChanges--
+	}
+	p.updateAttrs = attrChanges > 0
+
+	if desired.IAM != nil {
+		if s.policy == nil {
+			return nil, fmt.Errorf("iam: the IAM policy of the bucket was not read")
+		}
+		bindings := slices.Clone(desired.IAM)
+		sortBindings(bindings)
+		if p.diff("iam", cur.IAM, bindings) {
+			p.policy = s.policy
+			p.bindings = bindings
+		}
+	}
+	return p, nil
+}
+
+// diffRetention plans the changes of the retention policy. Locking the
+// policy is irreversible, and so is extending a locked policy, which can't
+// be shortened afterwards.
+func (p *Plan) diffRetention(cur, desired *RetentionPolicy) error {
+	if desired == nil || *cur == *desired {
+		return nil
+	}
+	if cur.Locked {
+		switch {
+		case !desired.Locked:
+			return fmt.Errorf("retentionPolicy: a locked policy can't be unlocked")
+		case desired.Period < cur.Period:
+			return fmt.Errorf("retentionPolicy: a locked policy can't be shortened or removed")
+		}
+	}
+	if desired.Locked && desired.Period == 0 {
+		return fmt.Errorf("retentionPolicy: can't lock an empty policy")
+	}
+	if desired.Period != cur.Period {
+		p.Changes = append(p.Changes, Change{
+			Field:        "retentionPolicy.period",
+			From:         format(cur.Period),
+			To:           format(desired.Period),
+			Irreversible: cur.Locked,
+		})
+		// A zero period removes the policy.
+		p.update.RetentionPolicy = &storage.RetentionPolicy{RetentionPeriod: time.Duration(desired.Period)}
+	}
+	if desired.Locked && !cur.Locked {
+		p.Changes = append(p.Changes, Change{Field: "retentionPolicy.locked", From: "false", To: "true", Irreversible: true})
+		p.lockRetention = true
+	}
+	return nil
+}
@@ -11,6 +11,7 @@ require (
 	github.com/googleapis/gax-go/v2 v2.14.1
 	google.golang.org/api v0.224.0
 	google.golang.org/genproto v0.0.0-20250303144028-a0af3efb3deb
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
@@ -0,0 +1,89 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// The transfer command copies a large file to or from Cloud Storage, in
+// parts transferred in parallel:
+//
+//	transfer [flags] FILE gs://BUCKET/OBJECT
+//	transfer [flags] gs://BUCKET/OBJECT FILE
+//
+// Interrupted uploads resume where they stopped when run again.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"github.com/GoogleCloudPlatform/golang-samples/storage/objects/transfer"
+)
+
+func main() {
+	partSize := flag.Int64("part-size", 32<<20, "size of the parts, in bytes")
+	parallelism := flag.Int("parallelism", 8, "number of parts transferred at once")
+	contentType := flag.String("content-type", "", "content type of uploaded objects")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s [flags] SRC DST\n\nOne of SRC and DST is a file, the other a gs://BUCKET/OBJECT URL.\n\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 2 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	src, dst := flag.Arg(0), flag.Arg(1)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	client, err := storage.NewClient(ctx)
+	if err != nil {
+		log.Fatalf("storage.NewClient: %v", err)
+	}
+	defer client.Close()
+
+	start := time.Now()
+	var attrs *storage.ObjectAttrs
+	if bucket, object, ok := parseURL(dst); ok {
+		u := &transfer.Uploader{Client: client, PartSize: *partSize, Parallelism: *parallelism, ContentType: *contentType}
+		attrs, err = u.Upload(ctx, bucket, object, src)
+	} else if bucket, object, ok := parseURL(src); ok {
+		d := &transfer.Downloader{Client: client, PartSize: *partSize, Parallelism: *parallelism}
+		attrs, err = d.Download(ctx, bucket, object, dst)
+	} else {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		log.Fatalf("%s to %s: %v", src, dst, err)
+	}
+	elapsed := time.Since(start)
+	fmt.Printf("Copied %s to %s: %d bytes in %v (%.1f MiB/s), CRC32C %08x\n",
+		src, dst, attrs.Size, elapsed.Round(time.Millisecond), float64(attrs.Size)/(1<<20)/elapsed.Seconds(), attrs.CRC32C)
+}
+
+// parseURL splits a gs://BUCKET/OBJECT URL.
+func parseURL(s string) (bucket, object string, ok bool) {
+	rest, ok := strings.CutPrefix(s, "gs://")
+	if !ok {
+		return "", "", false
+	}
+	bucket, object, ok = strings.Cut(rest, "/")
+	return bucket, object, ok && bucket != "" && object != ""
+}
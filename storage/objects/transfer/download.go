@@ -0,0 +1,110 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transfer
+
+import (
+	"context"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+
+	"cloud.google.com/go/storage"
+)
+
+// Downloader downloads objects with parallel byte-range reads, as in the
+// storage_download_byte_range sample.
+type Downloader struct {
+	Client *storage.Client
+	// PartSize is the size of the ranges, 32 MiB by default.
+	PartSize int64
+	// Parallelism is the number of ranges read at once, 8 by default.
+	Parallelism int
+}
+
+// Download downloads the object to the file at path, and returns its
+// attributes. The file is only created once the whole object is downloaded
+// and matches its CRC32C.
+func (d *Downloader) Download(ctx context.Context, bucket, object, path string) (attrs *storage.ObjectAttrs, err error) {
+	partSize := d.PartSize
+	if partSize <= 0 {
+		partSize = defaultPartSize
+	}
+	o := d.Client.Bucket(bucket).Object(object)
+	attrs, err = o.Attrs(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("Object(%q).Attrs: %w", object, err)
+	}
+	// Read every range from the same generation, even if the object is
+	// overwritten during the download.
+	o = o.Generation(attrs.Generation)
+
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			f.Close()
+			os.Remove(f.Name())
+		}
+	}()
+	if err := f.Truncate(attrs.Size); err != nil {
+		return nil, err
+	}
+
+	n := int((attrs.Size + partSize - 1) / partSize)
+	err = forEach(ctx, n, d.Parallelism, func(ctx context.Context, i int) error {
+		offset := int64(i) * partSize
+		return downloadRange(ctx, o, f, offset, min(partSize, attrs.Size-offset))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	h := crc32.New(crc32cTable)
+	if _, err := io.Copy(h, io.NewSectionReader(f, 0, attrs.Size)); err != nil {
+		return nil, err
+	}
+	if got := h.Sum32(); got != attrs.CRC32C {
+		return nil, fmt.Errorf("object %q: downloaded CRC32C %08x, want %08x", object, got, attrs.CRC32C)
+	}
+	if err := f.Close(); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(f.Name(), path); err != nil {
+		return nil, err
+	}
+	return attrs, nil
+}
+
+// downloadRange writes length bytes of the object at offset to the same
+// offset of f.
+func downloadRange(ctx context.Context, o *storage.ObjectHandle, f io.WriterAt, offset, length int64) error {
+	rc, err := o.NewRangeReader(ctx, offset, length)
+	if err != nil {
+		return fmt.Errorf("NewRangeReader(%d, %d): %w", offset, length, err)
+	}
+	defer rc.Close()
+	n, err := io.Copy(io.NewOffsetWriter(f, offset), rc)
+	if err != nil {
+		return fmt.Errorf("range %d-%d: %w", offset, offset+length, err)
+	}
+	if n != length {
+		return fmt.Errorf("range %d-%d: got %d bytes", offset, offset+length, n)
+	}
+	return nil
+}
@@ -0,0 +1,268 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transfer
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"google.golang.org/api/option"
+)
+
+type fakeObject struct {
+	data        []byte
+	generation  int64
+	contentType string
+}
+
+// fakeGCS implements the parts of the Cloud Storage JSON API used by the
+// package, for a single bucket.
+type fakeGCS struct {
+	mu         sync.Mutex
+	objects    map[string]*fakeObject
+	generation int64
+	// Counts of requests.
+	uploads, composes, rangeReads int
+	// failUpload, if set, makes the uploads of the objects it returns true
+	// for fail.
+	failUpload func(name string) bool
+}
+
+// newFakeGCS starts a fake server, and returns a client using it.
+func newFakeGCS(t *testing.T) (*fakeGCS, *storage.Client) {
+	t.Helper()
+	f := &fakeGCS{objects: map[string]*fakeObject{}}
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+	client, err := storage.NewClient(context.Background(),
+		option.WithEndpoint(srv.URL+"/storage/v1/"),
+		option.WithoutAuthentication(),
+		storage.WithJSONReads())
+	if err != nil {
+		t.Fatalf("storage.NewClient: %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return f, client
+}
+
+func (f *fakeGCS) put(name string, data []byte) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.putLocked(name, data, "")
+}
+
+func (f *fakeGCS) putLocked(name string, data []byte, contentType string) *fakeObject {
+	f.generation++
+	o := &fakeObject{data: data, generation: f.generation, contentType: contentType}
+	f.objects[name] = o
+	return o
+}
+
+// names returns the sorted names of the objects.
+func (f *fakeGCS) names() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var names []string
+	for name := range f.objects {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func crc32c(data []byte) uint32 {
+	return crc32.Checksum(data, crc32cTable)
+}
+
+func encodeCRC(crc uint32) string {
+	return base64.StdEncoding.EncodeToString(binary.BigEndian.AppendUint32(nil, crc))
+}
+
+func (f *fakeGCS) resource(name string, o *fakeObject) map[string]string {
+	return map[string]string{
+		"kind":           "storage#object",
+		"bucket":         "bucket",
+		"name":           name,
+		"size":           strconv.Itoa(len(o.data)),
+		"generation":     strconv.FormatInt(o.generation, 10),
+		"metageneration": "1",
+		"contentType":    o.contentType,
+		"crc32c":         encodeCRC(crc32c(o.data)),
+		"updated":        time.Unix(0, 0).UTC().Format(time.RFC3339),
+	}
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, code int, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error": map[string]interface{}{"code": code, "message": msg},
+	})
+}
+
+func (f *fakeGCS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	// Object names are escaped in the path.
+	var segs []string
+	for _, s := range strings.Split(strings.Trim(r.URL.EscapedPath(), "/"), "/") {
+		u, _ := url.PathUnescape(s)
+		segs = append(segs, u)
+	}
+	switch {
+	case r.Method == "POST" && len(segs) == 6 && segs[0] == "upload":
+		f.upload(w, r)
+	case r.Method == "GET" && len(segs) == 5:
+		f.list(w, r)
+	case r.Method == "POST" && len(segs) == 7 && segs[6] == "compose":
+		f.compose(w, r, segs[5])
+	case len(segs) == 6:
+		o, ok := f.objects[segs[5]]
+		if gen := r.URL.Query().Get("generation"); ok && gen != "" && gen != strconv.FormatInt(o.generation, 10) {
+			ok = false
+		}
+		if !ok {
+			writeError(w, http.StatusNotFound, "No such object")
+			return
+		}
+		switch {
+		case r.Method == "DELETE":
+			delete(f.objects, segs[5])
+			w.WriteHeader(http.StatusNoContent)
+		case r.Method == "GET" && r.URL.Query().Get("alt") == "media":
+			if r.Header.Get("Range") != "" {
+				f.rangeReads++
+			}
+			w.Header().Set("X-Goog-Generation", strconv.FormatInt(o.generation, 10))
+			http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(o.data))
+		case r.Method == "GET":
+			writeJSON(w, f.resource(segs[5], o))
+		default:
+			writeError(w, http.StatusMethodNotAllowed, r.Method)
+		}
+	default:
+		writeError(w, http.StatusNotFound, "unexpected request "+r.Method+" "+r.URL.String())
+	}
+}
+
+// upload handles multipart uploads.
+func (f *fakeGCS) upload(w http.ResponseWriter, r *http.Request) {
+	_, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || r.URL.Query().Get("uploadType") != "multipart" {
+		writeError(w, http.StatusBadRequest, "want a multipart upload")
+		return
+	}
+	mr := multipart.NewReader(r.Body, params["boundary"])
+	var meta struct {
+		Name        string `json:"name"`
+		ContentType string `json:"contentType"`
+		CRC32C      string `json:"crc32c"`
+	}
+	p, err := mr.NextPart()
+	if err == nil {
+		err = json.NewDecoder(p).Decode(&meta)
+	}
+	var data []byte
+	if err == nil {
+		p, err = mr.NextPart()
+	}
+	if err == nil {
+		data, err = io.ReadAll(p)
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	f.uploads++
+	if f.failUpload != nil && f.failUpload(meta.Name) {
+		writeError(w, http.StatusForbidden, "upload failed")
+		return
+	}
+	if meta.CRC32C != "" && meta.CRC32C != encodeCRC(crc32c(data)) {
+		writeError(w, http.StatusBadRequest, "CRC32C mismatch")
+		return
+	}
+	writeJSON(w, f.resource(meta.Name, f.putLocked(meta.Name, data, meta.ContentType)))
+}
+
+func (f *fakeGCS) list(w http.ResponseWriter, r *http.Request) {
+	prefix := r.URL.Query().Get("prefix")
+	var items []map[string]string
+	for name, o := range f.objects {
+		if strings.HasPrefix(name, prefix) {
+			items = append(items, f.resource(name, o))
+		}
+	}
+	writeJSON(w, map[string]interface{}{"kind": "storage#objects", "items": items})
+}
+
+func (f *fakeGCS) compose(w http.ResponseWriter, r *http.Request, name string) {
+	var req struct {
+		SourceObjects []struct {
+			Name string `json:"name"`
+		} `json:"sourceObjects"`
+		Destination struct {
+			ContentType string `json:"contentType"`
+			CRC32C      string `json:"crc32c"`
+		} `json:"destination"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if n := len(req.SourceObjects); n == 0 || n > maxComposeSources {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("%d source objects", n))
+		return
+	}
+	f.composes++
+	var data []byte
+	for _, src := range req.SourceObjects {
+		o, ok := f.objects[src.Name]
+		if !ok {
+			writeError(w, http.StatusNotFound, "No such object: "+src.Name)
+			return
+		}
+		data = append(data, o.data...)
+	}
+	if req.Destination.CRC32C != "" && req.Destination.CRC32C != encodeCRC(crc32c(data)) {
+		writeError(w, http.StatusBadRequest, "CRC32C mismatch")
+		return
+	}
+	writeJSON(w, f.resource(name, f.putLocked(name, data, req.Destination.ContentType)))
+}
@@ -0,0 +1,175 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transfer
+
+import (
+	"bytes"
+	"context"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeFile writes size random bytes to a file, and returns its path and
+// contents.
+func writeFile(t *testing.T, size int) (string, []byte) {
+	t.Helper()
+	data := make([]byte, size)
+	rand.New(rand.NewSource(int64(size))).Read(data)
+	path := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path, data
+}
+
+func TestUpload(t *testing.T) {
+	tests := []struct {
+		name     string
+		size     int
+		composes int
+	}{
+		{name: "empty", size: 0, composes: 1},
+		{name: "one part", size: 100, composes: 1},
+		{name: "32 parts", size: 32 * 100, composes: 1},
+		// 40 parts are composed into 2 objects, then the object.
+		{name: "40 parts", size: 4000, composes: 3},
+		// 1100 parts: 35 objects, then 2, then the object.
+		{name: "1100 parts", size: 110000 - 50, composes: 38},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			fake, client := newFakeGCS(t)
+			path, data := writeFile(t, tc.size)
+			u := &Uploader{Client: client, PartSize: 100, Parallelism: 4, ContentType: "application/octet-stream"}
+
+			attrs, err := u.Upload(context.Background(), "bucket", "dir/object", path)
+			if err != nil {
+				t.Fatalf("Upload: %v", err)
+			}
+			if attrs.Size != int64(tc.size) || attrs.CRC32C != crc32c(data) || attrs.ContentType != "application/octet-stream" {
+				t.Errorf("Upload = size %d, CRC32C %08x, content type %q; want %d, %08x", attrs.Size, attrs.CRC32C, attrs.ContentType, tc.size, crc32c(data))
+			}
+			if !bytes.Equal(fake.objects["dir/object"].data, data) {
+				t.Errorf("object differs from the file")
+			}
+			if fake.composes != tc.composes {
+				t.Errorf("%d compose requests, want %d", fake.composes, tc.composes)
+			}
+			if names := fake.names(); len(names) != 1 {
+				t.Errorf("objects after upload = %v, want only dir/object", names)
+			}
+		})
+	}
+}
+
+func TestUploadResume(t *testing.T) {
+	fake, client := newFakeGCS(t)
+	path, data := writeFile(t, 1000)
+	u := &Uploader{Client: client, PartSize: 100, Parallelism: 1}
+
+	fake.failUpload = func(name string) bool { return strings.HasSuffix(name, "part-00004") }
+	if _, err := u.Upload(context.Background(), "bucket", "object", path); err == nil {
+		t.Fatal("Upload succeeded, want error")
+	}
+	if _, ok := fake.objects["object"]; ok {
+		t.Fatal("object created despite the failure")
+	}
+	// Parts are uploaded in order, one at a time.
+	if got := len(fake.names()); got != 4 {
+		t.Errorf("%d parts left after the failure, want 4", got)
+	}
+
+	fake.failUpload = nil
+	fake.uploads = 0
+	if _, err := u.Upload(context.Background(), "bucket", "object", path); err != nil {
+		t.Fatalf("resumed Upload: %v", err)
+	}
+	if fake.uploads != 6 {
+		t.Errorf("resumed Upload uploaded %d parts, want the 6 missing ones", fake.uploads)
+	}
+	if !bytes.Equal(fake.objects["object"].data, data) {
+		t.Errorf("object differs from the file")
+	}
+	if names := fake.names(); len(names) != 1 {
+		t.Errorf("objects after upload = %v, want only object", names)
+	}
+}
+
+func TestUploadResumeChangedPart(t *testing.T) {
+	fake, client := newFakeGCS(t)
+	path, data := writeFile(t, 300)
+	u := &Uploader{Client: client, PartSize: 100}
+
+	// A part left by an earlier upload, with other contents.
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	prefix := partPrefix("object", fi, 100)
+	fake.put(partName(prefix, 1), []byte("stale"))
+
+	if _, err := u.Upload(context.Background(), "bucket", "object", path); err != nil {
+		t.Fatalf("Upload: %v", err)
+	}
+	if fake.uploads != 3 {
+		t.Errorf("Upload uploaded %d parts, want 3", fake.uploads)
+	}
+	if !bytes.Equal(fake.objects["object"].data, data) {
+		t.Errorf("object differs from the file")
+	}
+}
+
+func TestDownload(t *testing.T) {
+	for _, size := range []int{0, 1, 250, 1000} {
+		fake, client := newFakeGCS(t)
+		_, data := writeFile(t, size)
+		fake.put("object", data)
+		d := &Downloader{Client: client, PartSize: 100, Parallelism: 3}
+
+		path := filepath.Join(t.TempDir(), "download")
+		attrs, err := d.Download(context.Background(), "bucket", "object", path)
+		if err != nil {
+			t.Fatalf("Download(%d bytes): %v", size, err)
+		}
+		if attrs.Size != int64(size) {
+			t.Errorf("Download(%d bytes) size = %d", size, attrs.Size)
+		}
+		got, err := os.ReadFile(path)
+		if err != nil || !bytes.Equal(got, data) {
+			t.Errorf("Download(%d bytes): file differs from the object (%v)", size, err)
+		}
+		if want := (size + 99) / 100; fake.rangeReads != want {
+			t.Errorf("Download(%d bytes) made %d range reads, want %d", size, fake.rangeReads, want)
+		}
+		if entries, _ := os.ReadDir(filepath.Dir(path)); len(entries) != 1 {
+			t.Errorf("Download(%d bytes) left %d files, want 1", size, len(entries))
+		}
+	}
+}
+
+func TestDownloadErrors(t *testing.T) {
+	_, client := newFakeGCS(t)
+	d := &Downloader{Client: client, PartSize: 100}
+	dir := t.TempDir()
+	if _, err := d.Download(context.Background(), "bucket", "missing", filepath.Join(dir, "download")); err == nil {
+		t.Error("Download of a missing object succeeded, want error")
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("failed Download left %d files", len(entries))
+	}
+}
@@ -0,0 +1,236 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package transfer moves large files to and from Cloud Storage, splitting
+// them into parts transferred in parallel.
+//
+// Uploads are parallel composite uploads: parts are uploaded as temporary
+// objects, then composed into the destination object. Downloads read byte
+// ranges of the object in parallel.
+package transfer
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+
+	"cloud.google.com/go/storage"
+	"google.golang.org/api/iterator"
+)
+
+// maxComposeSources is the maximum number of objects composed at once.
+const maxComposeSources = 32
+
+const (
+	defaultPartSize    = 32 << 20
+	defaultParallelism = 8
+)
+
+var crc32cTable = crc32.MakeTable(crc32.Castagnoli)
+
+// Uploader uploads files as parallel composite uploads.
+//
+// Parts are named after the destination object, the size and modification
+// time of the file, and the part size. Uploading the same file again after
+// a failure only uploads the parts that are missing.
+type Uploader struct {
+	Client *storage.Client
+	// PartSize is the size of the parts, 32 MiB by default.
+	PartSize int64
+	// Parallelism is the number of parts uploaded at once, 8 by default.
+	Parallelism int
+	// ContentType is the content type of the destination object.
+	ContentType string
+}
+
+// part is a part of a file.
+type part struct {
+	offset, size int64
+	crc          uint32
+}
+
+// Upload uploads the file at path to the object, and returns its attributes.
+// The temporary objects are deleted once the object is created, and kept
+// when the upload fails so that it can be resumed.
+func (u *Uploader) Upload(ctx context.Context, bucket, object, path string) (*storage.ObjectAttrs, error) {
+	partSize := u.PartSize
+	if partSize <= 0 {
+		partSize = defaultPartSize
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	parts, crc, err := checksums(f, fi.Size(), partSize)
+	if err != nil {
+		return nil, fmt.Errorf("checksums: %w", err)
+	}
+
+	b := u.Client.Bucket(bucket)
+	prefix := partPrefix(object, fi, partSize)
+	existing, err := listParts(ctx, b, prefix)
+	if err != nil {
+		return nil, fmt.Errorf("listParts: %w", err)
+	}
+	if err := u.uploadParts(ctx, b, f, prefix, parts, existing); err != nil {
+		return nil, err
+	}
+
+	sources := make([]*storage.ObjectHandle, len(parts))
+	for i := range parts {
+		sources[i] = b.Object(partName(prefix, i))
+	}
+	attrs, err := u.compose(ctx, b, prefix, sources, object, crc)
+	if err != nil {
+		return nil, err
+	}
+	if err := deletePrefix(ctx, b, prefix); err != nil {
+		return attrs, fmt.Errorf("deleting temporary objects: %w", err)
+	}
+	return attrs, nil
+}
+
+// checksums returns the parts of the file, and its CRC32C.
+func checksums(f io.ReaderAt, size, partSize int64) ([]part, uint32, error) {
+	var parts []part
+	file := crc32.New(crc32cTable)
+	// Empty files have an empty part, as objects are composed of at
+	// least one object.
+	for offset := int64(0); offset < size || len(parts) == 0; offset += partSize {
+		p := part{offset: offset, size: min(partSize, size-offset)}
+		h := crc32.New(crc32cTable)
+		if _, err := io.Copy(io.MultiWriter(h, file), io.NewSectionReader(f, p.offset, p.size)); err != nil {
+			return nil, 0, err
+		}
+		p.crc = h.Sum32()
+		parts = append(parts, p)
+	}
+	return parts, file.Sum32(), nil
+}
+
+// partPrefix returns the prefix of the temporary objects of an upload.
+func partPrefix(object string, fi os.FileInfo, partSize int64) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\n%d\n%d\n%d", object, fi.Size(), fi.ModTime().UnixNano(), partSize)
+	return fmt.Sprintf("%s.parts/%s/", object, hex.EncodeToString(h.Sum(nil))[:16])
+}
+
+func partName(prefix string, i int) string {
+	return fmt.Sprintf("%spart-%05d", prefix, i)
+}
+
+// listParts returns the CRC32C of the objects under prefix.
+func listParts(ctx context.Context, b *storage.BucketHandle, prefix string) (map[string]uint32, error) {
+	parts := map[string]uint32{}
+	it := b.Objects(ctx, &storage.Query{Prefix: prefix})
+	for {
+		attrs, err := it.Next()
+		if err == iterator.Done {
+			return parts, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		parts[attrs.Name] = attrs.CRC32C
+	}
+}
+
+// uploadParts uploads the parts that don't exist yet.
+func (u *Uploader) uploadParts(ctx context.Context, b *storage.BucketHandle, f io.ReaderAt, prefix string, parts []part, existing map[string]uint32) error {
+	var missing []int
+	for i, p := range parts {
+		if crc, ok := existing[partName(prefix, i)]; !ok || crc != p.crc {
+			missing = append(missing, i)
+		}
+	}
+	return forEach(ctx, len(missing), u.Parallelism, func(ctx context.Context, j int) error {
+		i := missing[j]
+		if err := uploadPart(ctx, b.Object(partName(prefix, i)), f, parts[i]); err != nil {
+			return fmt.Errorf("part %d: %w", i, err)
+		}
+		return nil
+	})
+}
+
+// uploadPart uploads a part, which Cloud Storage checks against its CRC32C.
+func uploadPart(ctx context.Context, o *storage.ObjectHandle, f io.ReaderAt, p part) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	w := o.NewWriter(ctx)
+	// Parts are uploaded in a single request.
+	w.ChunkSize = 0
+	w.CRC32C = p.crc
+	w.SendCRC32C = true
+	if _, err := io.Copy(w, io.NewSectionReader(f, p.offset, p.size)); err != nil {
+		cancel()
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
+
+// compose composes sources into the object, composing groups of sources
+// into intermediate objects first when there are too many. Cloud Storage
+// checks that the object has the CRC32C crc.
+func (u *Uploader) compose(ctx context.Context, b *storage.BucketHandle, prefix string, sources []*storage.ObjectHandle, object string, crc uint32) (*storage.ObjectAttrs, error) {
+	for level := 0; len(sources) > maxComposeSources; level++ {
+		var next []*storage.ObjectHandle
+		for i := 0; i < len(sources); i += maxComposeSources {
+			dst := b.Object(fmt.Sprintf("%scompose-%d-%05d", prefix, level, len(next)))
+			if _, err := dst.ComposerFrom(sources[i:min(i+maxComposeSources, len(sources))]...).Run(ctx); err != nil {
+				return nil, fmt.Errorf("ComposerFrom(%q): %w", dst.ObjectName(), err)
+			}
+			next = append(next, dst)
+		}
+		sources = next
+	}
+
+	c := b.Object(object).ComposerFrom(sources...)
+	c.ContentType = u.ContentType
+	c.CRC32C = crc
+	c.SendCRC32C = true
+	attrs, err := c.Run(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("ComposerFrom(%q): %w", object, err)
+	}
+	if attrs.CRC32C != crc {
+		return nil, fmt.Errorf("object %q has CRC32C %08x, want %08x", object, attrs.CRC32C, crc)
+	}
+	return attrs, nil
+}
+
+// deletePrefix deletes the objects under prefix.
+func deletePrefix(ctx context.Context, b *storage.BucketHandle, prefix string) error {
+	names, err := listParts(ctx, b, prefix)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for name := range names {
+		if err := b.Object(name).Delete(ctx); err != nil && !errors.Is(err, storage.ErrObjectNotExist) {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,148 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package taskqueue
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/GoogleCloudPlatform/golang-samples/tasks/tasktest"
+)
+
+// handleTaskDir is the App Engine app whose Dispatcher handles the tasks.
+const handleTaskDir = "../../appengine/go11x/tasks/handle_task"
+
+// syncBuffer is a buffer written by a command while the test reads it.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// TestDispatcher adds tasks with a Queue to a tasktest server, which
+// delivers them with signed OIDC tokens to the Dispatcher of handle_task.
+func TestDispatcher(t *testing.T) {
+	if testing.Short() {
+		t.Skip("builds handle_task")
+	}
+	bin := filepath.Join(t.TempDir(), "handle_task")
+	build := exec.Command("go", "build", "-o", bin)
+	build.Dir = handleTaskDir
+	if out, err := build.CombinedOutput(); err != nil {
+		t.Fatalf("go build: %v\n%s", err, out)
+	}
+
+	srv, err := tasktest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(srv.Close)
+	client, err := srv.Client(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Close() })
+
+	lis, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, port, _ := net.SplitHostPort(lis.Addr().String())
+	lis.Close()
+	base := "http://localhost:" + port
+	const serviceAccount = "invoker@my-project.iam.gserviceaccount.com"
+
+	var logs syncBuffer
+	cmd := exec.Command(bin)
+	cmd.Env = append(os.Environ(),
+		"PORT="+port,
+		"TASKS_AUDIENCE="+base+"/tasks",
+		"TASKS_SERVICE_ACCOUNT="+serviceAccount,
+		"TASKS_CERTS_URL="+srv.CertsURL)
+	cmd.Stdout, cmd.Stderr = &logs, &logs
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	})
+	for start := time.Now(); ; time.Sleep(50 * time.Millisecond) {
+		resp, err := http.Get(base + "/")
+		if err == nil {
+			resp.Body.Close()
+			break
+		}
+		if time.Since(start) > 10*time.Second {
+			t.Fatalf("handle_task not listening: %v\n%s", err, logs.String())
+		}
+	}
+
+	ctx := context.Background()
+	q := &Queue{Client: client, Path: QueuePath("my-project", "us-central1", "emails"), URL: base + "/tasks", ServiceAccountEmail: serviceAccount}
+	if _, err := q.Add(ctx, "email", email{To: "a@example.com", Subject: "Hi"}, nil); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	// Without a recipient, the task fails permanently and is dropped.
+	if _, err := q.Add(ctx, "email", email{Subject: "Nobody"}, nil); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	// Tokens of other service accounts are rejected, until the task runs
+	// out of attempts.
+	other := *q
+	other.ServiceAccountEmail = "other@my-project.iam.gserviceaccount.com"
+	if _, err := other.Add(ctx, "email", email{To: "b@example.com", Subject: "Spoofed"}, nil); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	wait(t, srv)
+
+	statuses := map[string]int{}
+	for _, r := range srv.Results() {
+		var e email
+		if err := json.Unmarshal(r.Task.GetHttpRequest().GetBody(), &e); err != nil {
+			t.Fatal(err)
+		}
+		statuses[e.Subject] = r.Status
+	}
+	for subject, want := range map[string]int{"Hi": http.StatusOK, "Nobody": http.StatusOK, "Spoofed": http.StatusForbidden} {
+		if got := statuses[subject]; got != want {
+			t.Errorf("task %q: got status %d, want %d\n%s", subject, got, want, logs.String())
+		}
+	}
+	if got := logs.String(); !strings.Contains(got, `Sending "Hi" to a@example.com`) {
+		t.Errorf("handle_task logs don't show the email sent:\n%s", got)
+	}
+}
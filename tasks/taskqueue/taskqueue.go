@@ -0,0 +1,122 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package taskqueue adds typed JSON tasks to a Cloud Tasks queue.
+//
+// Tasks are delivered as POST requests with a JSON body, and the type of
+// the task in the X-Task-Type header, which handlers use to decode the
+// payload.
+package taskqueue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+
+	cloudtasks "cloud.google.com/go/cloudtasks/apiv2"
+	taskspb "cloud.google.com/go/cloudtasks/apiv2/cloudtaskspb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// TypeHeader is the header with the type of the task.
+const TypeHeader = "X-Task-Type"
+
+// ErrDuplicate is returned when a task with the same ID was already added
+// to the queue. Cloud Tasks keeps the IDs of deleted and completed tasks
+// for up to an hour, and of the tasks in the queue until they're done.
+var ErrDuplicate = errors.New("taskqueue: duplicate task")
+
+var validID = regexp.MustCompile(`^[A-Za-z0-9_-]{1,500}$`)
+
+// Queue adds tasks to a queue, delivered to a URL.
+type Queue struct {
+	Client *cloudtasks.Client
+	// Path is the queue name, projects/PROJECT/locations/LOCATION/queues/QUEUE.
+	Path string
+	// URL is the URL of the handler.
+	URL string
+
+	// ServiceAccountEmail, if set, is the service account of the OIDC
+	// token sent with the tasks.
+	ServiceAccountEmail string
+	// Audience is the audience of the OIDC token. It defaults to URL.
+	Audience string
+}
+
+// QueuePath returns the name of a queue.
+func QueuePath(projectID, locationID, queueID string) string {
+	return fmt.Sprintf("projects/%s/locations/%s/queues/%s", projectID, locationID, queueID)
+}
+
+// TaskOptions are the options of a task.
+type TaskOptions struct {
+	// ScheduleTime is when the task is delivered. The zero value means now.
+	ScheduleTime time.Time
+	// ID, if set, names the task, so that adding it again fails with
+	// ErrDuplicate. It's made of letters, digits, hyphens and underscores.
+	ID string
+}
+
+// Add adds a task with a payload, encoded as JSON. opts may be nil.
+func (q *Queue) Add(ctx context.Context, taskType string, payload interface{}, opts *TaskOptions) (*taskspb.Task, error) {
+	if opts == nil {
+		opts = &TaskOptions{}
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("taskqueue: encoding %s payload: %w", taskType, err)
+	}
+
+	hr := &taskspb.HttpRequest{
+		HttpMethod: taskspb.HttpMethod_POST,
+		Url:        q.URL,
+		Headers: map[string]string{
+			"Content-Type": "application/json",
+			TypeHeader:     taskType,
+		},
+		Body: body,
+	}
+	if q.ServiceAccountEmail != "" {
+		hr.AuthorizationHeader = &taskspb.HttpRequest_OidcToken{
+			OidcToken: &taskspb.OidcToken{
+				ServiceAccountEmail: q.ServiceAccountEmail,
+				Audience:            q.Audience,
+			},
+		}
+	}
+	task := &taskspb.Task{MessageType: &taskspb.Task_HttpRequest{HttpRequest: hr}}
+	if opts.ID != "" {
+		if !validID.MatchString(opts.ID) {
+			return nil, fmt.Errorf("taskqueue: invalid task ID %q", opts.ID)
+		}
+		task.Name = q.Path + "/tasks/" + opts.ID
+	}
+	if !opts.ScheduleTime.IsZero() {
+		task.ScheduleTime = timestamppb.New(opts.ScheduleTime)
+	}
+
+	created, err := q.Client.CreateTask(ctx, &taskspb.CreateTaskRequest{Parent: q.Path, Task: task})
+	if status.Code(err) == codes.AlreadyExists {
+		return nil, fmt.Errorf("%w: %s", ErrDuplicate, task.Name)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cloudtasks.CreateTask: %w", err)
+	}
+	return created, nil
+}
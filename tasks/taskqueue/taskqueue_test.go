@@ -0,0 +1,167 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package taskqueue
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/GoogleCloudPlatform/golang-samples/tasks/tasktest"
+)
+
+type email struct {
+	To      string `json:"to"`
+	Subject string `json:"subject"`
+}
+
+type delivery struct {
+	header http.Header
+	body   string
+	at     time.Time
+}
+
+func setup(t *testing.T, h http.HandlerFunc) (*Queue, *tasktest.Server) {
+	t.Helper()
+	srv, err := tasktest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(srv.Close)
+	client, err := srv.Client(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Close() })
+	handler := httptest.NewServer(h)
+	t.Cleanup(handler.Close)
+	return &Queue{Client: client, Path: QueuePath("my-project", "us-central1", "emails"), URL: handler.URL + "/tasks"}, srv
+}
+
+func recorder() (http.HandlerFunc, func() []delivery) {
+	var mu sync.Mutex
+	var got []delivery
+	return func(w http.ResponseWriter, r *http.Request) {
+			body, _ := io.ReadAll(r.Body)
+			mu.Lock()
+			got = append(got, delivery{r.Header, string(body), time.Now()})
+			mu.Unlock()
+		}, func() []delivery {
+			mu.Lock()
+			defer mu.Unlock()
+			return got
+		}
+}
+
+func wait(t *testing.T, srv *tasktest.Server) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := srv.Wait(ctx); err != nil {
+		t.Fatalf("Wait: %v", err)
+	}
+}
+
+func TestAdd(t *testing.T) {
+	h, deliveries := recorder()
+	q, srv := setup(t, h)
+	q.ServiceAccountEmail = "invoker@my-project.iam.gserviceaccount.com"
+
+	task, err := q.Add(context.Background(), "email", email{To: "a@example.com", Subject: "Hi"}, nil)
+	if err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	if !strings.HasPrefix(task.GetName(), q.Path+"/tasks/") {
+		t.Errorf("task name = %q, want in %q", task.GetName(), q.Path)
+	}
+	wait(t, srv)
+
+	got := deliveries()
+	if len(got) != 1 {
+		t.Fatalf("%d deliveries, want 1", len(got))
+	}
+	d := got[0]
+	if want := `{"to":"a@example.com","subject":"Hi"}`; d.body != want {
+		t.Errorf("body = %s, want %s", d.body, want)
+	}
+	for k, want := range map[string]string{
+		"Content-Type":                "application/json",
+		TypeHeader:                    "email",
+		"X-CloudTasks-QueueName":      "emails",
+		"X-CloudTasks-TaskRetryCount": "0",
+	} {
+		if v := d.header.Get(k); v != want {
+			t.Errorf("header %s = %q, want %q", k, v, want)
+		}
+	}
+
+	// The token is for the service account, and the URL by default.
+	v, err := srv.Validator(context.Background())
+	if err != nil {
+		t.Fatalf("Validator: %v", err)
+	}
+	token := strings.TrimPrefix(d.header.Get("Authorization"), "Bearer ")
+	payload, err := v.Validate(context.Background(), token, q.URL)
+	if err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+	if email := payload.Claims["email"]; email != q.ServiceAccountEmail {
+		t.Errorf("token email %v, want %s", email, q.ServiceAccountEmail)
+	}
+}
+
+func TestAddScheduleTime(t *testing.T) {
+	h, deliveries := recorder()
+	q, srv := setup(t, h)
+	at := time.Now().Add(200 * time.Millisecond)
+	if _, err := q.Add(context.Background(), "email", email{}, &TaskOptions{ScheduleTime: at}); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	wait(t, srv)
+	if got := deliveries(); len(got) != 1 || got[0].at.Before(at) {
+		t.Errorf("deliveries %+v, want 1 after %v", got, at)
+	}
+}
+
+func TestAddDuplicate(t *testing.T) {
+	h, deliveries := recorder()
+	q, srv := setup(t, h)
+	ctx := context.Background()
+	opts := &TaskOptions{ID: "welcome-42"}
+	task, err := q.Add(ctx, "email", email{}, opts)
+	if err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	if want := q.Path + "/tasks/welcome-42"; task.GetName() != want {
+		t.Errorf("task name = %q, want %q", task.GetName(), want)
+	}
+	wait(t, srv)
+	// The ID can't be reused after the task is done either.
+	if _, err := q.Add(ctx, "email", email{}, opts); !errors.Is(err, ErrDuplicate) {
+		t.Errorf("Add again: %v, want ErrDuplicate", err)
+	}
+	if n := len(deliveries()); n != 1 {
+		t.Errorf("%d deliveries, want 1", n)
+	}
+	if _, err := q.Add(ctx, "email", email{}, &TaskOptions{ID: "a/b"}); err == nil {
+		t.Error("Add with an invalid ID succeeded, want error")
+	}
+}
@@ -0,0 +1,525 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package tasktest provides a local Cloud Tasks server for offline tests.
+//
+// The server implements the queue and task methods of the CloudTasks gRPC
+// service, and delivers tasks over HTTP with the headers and retries of
+// Cloud Tasks. It keeps everything in memory.
+//
+// The OIDC tokens of HTTP target tasks are signed with a key of the server,
+// published at CertsURL instead of Google's certificates URL. Handlers
+// verify them with the validator of Server.Validator.
+package tasktest
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"fmt"
+	mrand "math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	cloudtasks "cloud.google.com/go/cloudtasks/apiv2"
+	taskspb "cloud.google.com/go/cloudtasks/apiv2/cloudtaskspb"
+	"github.com/go-jose/go-jose/v4"
+	"github.com/go-jose/go-jose/v4/jwt"
+	"google.golang.org/api/idtoken"
+	"google.golang.org/api/option"
+	spb "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// DefaultRetryConfig is the retry configuration of queues created without
+// one. The backoffs are shorter than those of Cloud Tasks, to keep tests
+// fast.
+var DefaultRetryConfig = &taskspb.RetryConfig{
+	MaxAttempts:  5,
+	MinBackoff:   durationpb.New(10 * time.Millisecond),
+	MaxBackoff:   durationpb.New(time.Second),
+	MaxDoublings: 16,
+}
+
+// Server is a local Cloud Tasks server.
+type Server struct {
+	taskspb.UnimplementedCloudTasksServer
+
+	// Addr is the address of the gRPC server.
+	Addr string
+	// CertsURL is the URL of the JSON Web Key Set with the public key of
+	// the OIDC tokens, in the format of Google's certificates.
+	CertsURL string
+	// AppEngineURL is the base URL App Engine tasks are delivered to.
+	AppEngineURL string
+	// HTTPClient delivers the tasks. It defaults to http.DefaultClient.
+	HTTPClient *http.Client
+
+	grpc   *grpc.Server
+	certs  *http.Server
+	signer jose.Signer
+	ctx    context.Context
+	stop   context.CancelFunc
+	wg     sync.WaitGroup
+
+	mu      sync.Mutex
+	queues  map[string]*taskspb.Queue
+	tasks   map[string]*task
+	names   map[string]bool // names of all the tasks, for deduplication
+	idle    chan struct{}   // closed when there are no tasks
+	results []Result
+}
+
+// Result is the outcome of a task, once it succeeded or ran out of
+// attempts.
+type Result struct {
+	Task *taskspb.Task
+	// Status is the HTTP status of the last attempt, or 0 if it failed
+	// without a response.
+	Status int
+}
+
+type task struct {
+	pb       *taskspb.Task
+	run      chan struct{}
+	cancel   context.CancelFunc
+	previous int // HTTP status of the previous attempt
+}
+
+// NewServer starts a server on a local port, and its certificates on
+// another.
+func NewServer() (*Server, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, err
+	}
+	const keyID = "tasktest"
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.RS256, Key: key},
+		(&jose.SignerOptions{}).WithType("JWT").WithHeader("kid", keyID))
+	if err != nil {
+		return nil, err
+	}
+	certs, err := json.Marshal(jose.JSONWebKeySet{Keys: []jose.JSONWebKey{
+		{Key: &key.PublicKey, KeyID: keyID, Algorithm: string(jose.RS256), Use: "sig"},
+	}})
+	if err != nil {
+		return nil, err
+	}
+
+	lis, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		return nil, err
+	}
+	certsLis, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		lis.Close()
+		return nil, err
+	}
+	s := &Server{
+		Addr:     lis.Addr().String(),
+		CertsURL: "http://" + certsLis.Addr().String() + "/certs",
+		grpc:     grpc.NewServer(),
+		certs: &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.Write(certs)
+		})},
+		signer: signer,
+		queues: map[string]*taskspb.Queue{},
+		tasks:  map[string]*task{},
+		names:  map[string]bool{},
+	}
+	s.ctx, s.stop = context.WithCancel(context.Background())
+	taskspb.RegisterCloudTasksServer(s.grpc, s)
+	go s.grpc.Serve(lis)
+	go s.certs.Serve(certsLis)
+	return s, nil
+}
+
+// Validator returns a validator of the OIDC tokens of the server, to use
+// instead of idtoken.Validate.
+func (s *Server) Validator(ctx context.Context) (*idtoken.Validator, error) {
+	return idtoken.NewValidator(ctx, option.WithHTTPClient(&http.Client{
+		Transport: certsTransport(s.CertsURL),
+	}))
+}
+
+// certsTransport sends all requests, for Google's certificates, to a URL.
+type certsTransport string
+
+func (t certsTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	r, err := http.NewRequestWithContext(req.Context(), req.Method, string(t), nil)
+	if err != nil {
+		return nil, err
+	}
+	return http.DefaultTransport.RoundTrip(r)
+}
+
+// Client returns a client of the server.
+func (s *Server) Client(ctx context.Context) (*cloudtasks.Client, error) {
+	return cloudtasks.NewClient(ctx,
+		option.WithEndpoint(s.Addr),
+		option.WithoutAuthentication(),
+		option.WithGRPCDialOption(grpc.WithTransportCredentials(insecure.NewCredentials())))
+}
+
+// Close stops the server, and the deliveries in progress.
+func (s *Server) Close() {
+	s.stop()
+	s.grpc.Stop()
+	s.certs.Close()
+	s.wg.Wait()
+}
+
+// Wait waits until there are no tasks left.
+func (s *Server) Wait(ctx context.Context) error {
+	s.mu.Lock()
+	idle := s.idleLocked()
+	s.mu.Unlock()
+	select {
+	case <-idle:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s *Server) idleLocked() chan struct{} {
+	if s.idle == nil {
+		s.idle = make(chan struct{})
+		if len(s.tasks) == 0 {
+			close(s.idle)
+		}
+	}
+	return s.idle
+}
+
+// Results returns the outcomes of the tasks done so far.
+func (s *Server) Results() []Result {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Result(nil), s.results...)
+}
+
+// queueLocked returns the queue, creating it if needed.
+func (s *Server) queueLocked(name string) *taskspb.Queue {
+	q, ok := s.queues[name]
+	if !ok {
+		q = &taskspb.Queue{Name: name, RetryConfig: DefaultRetryConfig, State: taskspb.Queue_RUNNING}
+		s.queues[name] = q
+	}
+	return q
+}
+
+// CreateQueue creates a queue. Queues are also created when tasks are
+// added to them.
+func (s *Server) CreateQueue(ctx context.Context, req *taskspb.CreateQueueRequest) (*taskspb.Queue, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	q := proto.Clone(req.GetQueue()).(*taskspb.Queue)
+	if !strings.HasPrefix(q.Name, req.GetParent()+"/queues/") {
+		return nil, status.Errorf(codes.InvalidArgument, "queue name %q not in %q", q.Name, req.GetParent())
+	}
+	if _, ok := s.queues[q.Name]; ok {
+		return nil, status.Errorf(codes.AlreadyExists, "queue %q already exists", q.Name)
+	}
+	if q.RetryConfig == nil {
+		q.RetryConfig = DefaultRetryConfig
+	}
+	q.State = taskspb.Queue_RUNNING
+	s.queues[q.Name] = q
+	return proto.Clone(q).(*taskspb.Queue), nil
+}
+
+// GetQueue returns a queue.
+func (s *Server) GetQueue(ctx context.Context, req *taskspb.GetQueueRequest) (*taskspb.Queue, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	q, ok := s.queues[req.GetName()]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "queue %q not found", req.GetName())
+	}
+	return proto.Clone(q).(*taskspb.Queue), nil
+}
+
+// CreateTask adds a task, and schedules its delivery.
+func (s *Server) CreateTask(ctx context.Context, req *taskspb.CreateTaskRequest) (*taskspb.Task, error) {
+	pb := proto.Clone(req.GetTask()).(*taskspb.Task)
+	if pb.GetHttpRequest() == nil && pb.GetAppEngineHttpRequest() == nil {
+		return nil, status.Error(codes.InvalidArgument, "task has no HTTP request")
+	}
+	if pb.GetAppEngineHttpRequest() != nil && s.AppEngineURL == "" {
+		return nil, status.Error(codes.FailedPrecondition, "App Engine task without AppEngineURL")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.queueLocked(req.GetParent())
+	if pb.Name == "" {
+		pb.Name = fmt.Sprintf("%s/tasks/%d", req.GetParent(), mrand.Int63())
+	} else if !strings.HasPrefix(pb.Name, req.GetParent()+"/tasks/") {
+		return nil, status.Errorf(codes.InvalidArgument, "task name %q not in %q", pb.Name, req.GetParent())
+	}
+	// Like Cloud Tasks, names can't be reused, even after the task is done.
+	if s.names[pb.Name] {
+		return nil, status.Errorf(codes.AlreadyExists, "task %q already exists", pb.Name)
+	}
+	s.names[pb.Name] = true
+	now := time.Now()
+	pb.CreateTime = timestamppb.New(now)
+	if pb.ScheduleTime == nil {
+		pb.ScheduleTime = pb.CreateTime
+	}
+
+	ctx, cancel := context.WithCancel(s.ctx)
+	t := &task{pb: pb, run: make(chan struct{}, 1), cancel: cancel}
+	if len(s.tasks) == 0 {
+		s.idle = nil
+	}
+	s.tasks[pb.Name] = t
+	s.wg.Add(1)
+	go s.deliver(ctx, t)
+	return proto.Clone(pb).(*taskspb.Task), nil
+}
+
+// GetTask returns a task that is not done yet.
+func (s *Server) GetTask(ctx context.Context, req *taskspb.GetTaskRequest) (*taskspb.Task, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, ok := s.tasks[req.GetName()]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "task %q not found", req.GetName())
+	}
+	return proto.Clone(t.pb).(*taskspb.Task), nil
+}
+
+// ListTasks returns the tasks of a queue that are not done yet, in a single
+// page.
+func (s *Server) ListTasks(ctx context.Context, req *taskspb.ListTasksRequest) (*taskspb.ListTasksResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	resp := &taskspb.ListTasksResponse{}
+	for name, t := range s.tasks {
+		if strings.HasPrefix(name, req.GetParent()+"/tasks/") {
+			resp.Tasks = append(resp.Tasks, proto.Clone(t.pb).(*taskspb.Task))
+		}
+	}
+	return resp, nil
+}
+
+// DeleteTask deletes a task, cancelling its delivery.
+func (s *Server) DeleteTask(ctx context.Context, req *taskspb.DeleteTaskRequest) (*emptypb.Empty, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, ok := s.tasks[req.GetName()]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "task %q not found", req.GetName())
+	}
+	t.cancel()
+	s.removeLocked(t)
+	return &emptypb.Empty{}, nil
+}
+
+// RunTask delivers a task now, regardless of its schedule time.
+func (s *Server) RunTask(ctx context.Context, req *taskspb.RunTaskRequest) (*taskspb.Task, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, ok := s.tasks[req.GetName()]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "task %q not found", req.GetName())
+	}
+	select {
+	case t.run <- struct{}{}:
+	default:
+	}
+	return proto.Clone(t.pb).(*taskspb.Task), nil
+}
+
+func (s *Server) removeLocked(t *task) {
+	delete(s.tasks, t.pb.Name)
+	if len(s.tasks) == 0 && s.idle != nil {
+		close(s.idle)
+	}
+}
+
+// deliver attempts to deliver a task until it succeeds, runs out of
+// attempts, or is deleted.
+func (s *Server) deliver(ctx context.Context, t *task) {
+	defer s.wg.Done()
+	s.mu.Lock()
+	retry := s.queueLocked(t.pb.Name[:strings.Index(t.pb.Name, "/tasks/")]).RetryConfig
+	s.mu.Unlock()
+
+	for attempt := 0; ; attempt++ {
+		s.mu.Lock()
+		wait := time.Until(t.pb.ScheduleTime.AsTime())
+		s.mu.Unlock()
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-t.run:
+			timer.Stop()
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		}
+
+		code, err := s.attempt(ctx, t)
+		if ctx.Err() != nil {
+			return
+		}
+		s.mu.Lock()
+		last := t.pb.LastAttempt
+		last.ResponseTime = timestamppb.Now()
+		ok := err == nil && code >= 200 && code < 300
+		switch {
+		case ok:
+			last.ResponseStatus = &spb.Status{Code: int32(codes.OK)}
+		case err != nil:
+			last.ResponseStatus = &spb.Status{Code: int32(codes.Unavailable), Message: err.Error()}
+		default:
+			last.ResponseStatus = &spb.Status{Code: int32(codes.Unknown), Message: http.StatusText(code)}
+		}
+		if code != 0 {
+			t.pb.ResponseCount++
+			t.previous = code
+		}
+		if ok || (retry.MaxAttempts > 0 && attempt+1 >= int(retry.MaxAttempts)) {
+			s.results = append(s.results, Result{Task: proto.Clone(t.pb).(*taskspb.Task), Status: code})
+			s.removeLocked(t)
+			s.mu.Unlock()
+			return
+		}
+		t.pb.ScheduleTime = timestamppb.New(time.Now().Add(backoff(retry, attempt)))
+		s.mu.Unlock()
+	}
+}
+
+// backoff returns the delay before the retry following an attempt,
+// doubling from the minimum backoff, MaxDoublings times at most.
+func backoff(retry *taskspb.RetryConfig, attempt int) time.Duration {
+	d := retry.GetMinBackoff().AsDuration()
+	for i := 0; i < attempt && i < int(retry.GetMaxDoublings()); i++ {
+		d *= 2
+	}
+	return min(d, retry.GetMaxBackoff().AsDuration())
+}
+
+// attempt sends the request of a task, and returns the response status.
+func (s *Server) attempt(ctx context.Context, t *task) (int, error) {
+	s.mu.Lock()
+	req, err := s.request(ctx, t)
+	now := timestamppb.Now()
+	t.pb.DispatchCount++
+	t.pb.LastAttempt = &taskspb.Attempt{ScheduleTime: t.pb.ScheduleTime, DispatchTime: now}
+	if t.pb.FirstAttempt == nil {
+		t.pb.FirstAttempt = &taskspb.Attempt{ScheduleTime: t.pb.ScheduleTime, DispatchTime: now}
+	}
+	s.mu.Unlock()
+	if err != nil {
+		return 0, err
+	}
+
+	client := s.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	return resp.StatusCode, nil
+}
+
+// request builds the request of a task, with the headers set by Cloud Tasks.
+func (s *Server) request(ctx context.Context, t *task) (*http.Request, error) {
+	pb := t.pb
+	var (
+		method  taskspb.HttpMethod
+		url     string
+		headers map[string]string
+		body    []byte
+		prefix  string
+	)
+	if hr := pb.GetHttpRequest(); hr != nil {
+		method, url, headers, body = hr.HttpMethod, hr.Url, hr.Headers, hr.Body
+		prefix = "X-CloudTasks-"
+	} else {
+		ae := pb.GetAppEngineHttpRequest()
+		method, url, headers, body = ae.HttpMethod, strings.TrimSuffix(s.AppEngineURL, "/")+ae.RelativeUri, ae.Headers, ae.Body
+		prefix = "X-AppEngine-"
+	}
+	if method == taskspb.HttpMethod_HTTP_METHOD_UNSPECIFIED {
+		method = taskspb.HttpMethod_POST
+	}
+	req, err := http.NewRequestWithContext(ctx, method.String(), url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	queue := pb.Name[:strings.Index(pb.Name, "/tasks/")]
+	req.Header.Set(prefix+"QueueName", queue[strings.LastIndex(queue, "/")+1:])
+	req.Header.Set(prefix+"TaskName", pb.Name[strings.LastIndex(pb.Name, "/")+1:])
+	req.Header.Set(prefix+"TaskRetryCount", strconv.Itoa(int(pb.DispatchCount)))
+	req.Header.Set(prefix+"TaskExecutionCount", strconv.Itoa(int(pb.ResponseCount)))
+	eta := pb.ScheduleTime.AsTime()
+	req.Header.Set(prefix+"TaskETA", fmt.Sprintf("%d.%06d", eta.Unix(), eta.Nanosecond()/1000))
+	if t.previous != 0 {
+		req.Header.Set(prefix+"TaskPreviousResponse", strconv.Itoa(t.previous))
+	}
+
+	if oidc := pb.GetHttpRequest().GetOidcToken(); oidc != nil {
+		aud := oidc.Audience
+		if aud == "" {
+			aud = url
+		}
+		token, err := s.token(oidc.ServiceAccountEmail, aud)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return req, nil
+}
+
+// token returns an ID token with the claims of the tokens of Cloud Tasks,
+// signed with the key of the server.
+func (s *Server) token(email, audience string) (string, error) {
+	now := time.Now()
+	return jwt.Signed(s.signer).Claims(map[string]interface{}{
+		"iss":            "https://accounts.google.com",
+		"aud":            audience,
+		"email":          email,
+		"email_verified": true,
+		"sub":            email,
+		"iat":            now.Unix(),
+		"exp":            now.Add(time.Hour).Unix(),
+	}).Serialize()
+}
@@ -0,0 +1,189 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tasktest
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	cloudtasks "cloud.google.com/go/cloudtasks/apiv2"
+	taskspb "cloud.google.com/go/cloudtasks/apiv2/cloudtaskspb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const queue = "projects/p/locations/l/queues/q"
+
+func setup(t *testing.T) (*Server, *cloudtasks.Client) {
+	t.Helper()
+	s, err := NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(s.Close)
+	c, err := s.Client(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return s, c
+}
+
+func httpTask(url string) *taskspb.Task {
+	return &taskspb.Task{MessageType: &taskspb.Task_HttpRequest{HttpRequest: &taskspb.HttpRequest{Url: url}}}
+}
+
+func wait(t *testing.T, s *Server) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := s.Wait(ctx); err != nil {
+		t.Fatalf("Wait: %v", err)
+	}
+}
+
+func TestRetries(t *testing.T) {
+	s, c := setup(t)
+	ctx := context.Background()
+	if _, err := c.CreateQueue(ctx, &taskspb.CreateQueueRequest{
+		Parent: "projects/p/locations/l",
+		Queue: &taskspb.Queue{Name: queue, RetryConfig: &taskspb.RetryConfig{
+			MaxAttempts: 3,
+			MinBackoff:  durationpb.New(time.Millisecond),
+			MaxBackoff:  durationpb.New(10 * time.Millisecond),
+		}},
+	}); err != nil {
+		t.Fatalf("CreateQueue: %v", err)
+	}
+
+	var mu sync.Mutex
+	var headers []http.Header
+	h := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		headers = append(headers, r.Header)
+		mu.Unlock()
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer h.Close()
+	if _, err := c.CreateTask(ctx, &taskspb.CreateTaskRequest{Parent: queue, Task: httpTask(h.URL)}); err != nil {
+		t.Fatalf("CreateTask: %v", err)
+	}
+	wait(t, s)
+
+	if len(headers) != 3 {
+		t.Fatalf("%d attempts, want 3", len(headers))
+	}
+	for i, want := range []struct{ retries, previous string }{{"0", ""}, {"1", "503"}, {"2", "503"}} {
+		if got := headers[i].Get("X-CloudTasks-TaskRetryCount"); got != want.retries {
+			t.Errorf("attempt %d: retry count %q, want %q", i, got, want.retries)
+		}
+		if got := headers[i].Get("X-CloudTasks-TaskPreviousResponse"); got != want.previous {
+			t.Errorf("attempt %d: previous response %q, want %q", i, got, want.previous)
+		}
+	}
+	results := s.Results()
+	if len(results) != 1 || results[0].Status != http.StatusServiceUnavailable || results[0].Task.DispatchCount != 3 {
+		t.Errorf("results %+v, want 1 with status 503 after 3 dispatches", results)
+	}
+}
+
+func TestAppEngineTask(t *testing.T) {
+	s, c := setup(t)
+	var got http.Header
+	var path string
+	h := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, path = r.Header, r.URL.Path
+	}))
+	defer h.Close()
+	s.AppEngineURL = h.URL
+
+	task := &taskspb.Task{MessageType: &taskspb.Task_AppEngineHttpRequest{AppEngineHttpRequest: &taskspb.AppEngineHttpRequest{
+		RelativeUri: "/task_handler",
+	}}}
+	if _, err := c.CreateTask(context.Background(), &taskspb.CreateTaskRequest{Parent: queue, Task: task}); err != nil {
+		t.Fatalf("CreateTask: %v", err)
+	}
+	wait(t, s)
+	if path != "/task_handler" || got.Get("X-AppEngine-QueueName") != "q" || got.Get("X-AppEngine-TaskName") == "" {
+		t.Errorf("request to %s with headers %v, want App Engine headers", path, got)
+	}
+}
+
+func TestRunAndDeleteTask(t *testing.T) {
+	s, c := setup(t)
+	ctx := context.Background()
+	delivered := make(chan struct{}, 2)
+	h := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		delivered <- struct{}{}
+	}))
+	defer h.Close()
+
+	later := timestamppb.New(time.Now().Add(time.Hour))
+	newTask := func(id string) *taskspb.Task {
+		task := httpTask(h.URL)
+		task.Name = queue + "/tasks/" + id
+		task.ScheduleTime = later
+		created, err := c.CreateTask(ctx, &taskspb.CreateTaskRequest{Parent: queue, Task: task})
+		if err != nil {
+			t.Fatalf("CreateTask: %v", err)
+		}
+		return created
+	}
+	run, del := newTask("run"), newTask("delete")
+	if _, err := c.CreateTask(ctx, &taskspb.CreateTaskRequest{Parent: queue, Task: run}); status.Code(err) != codes.AlreadyExists {
+		t.Errorf("CreateTask with the same name: %v, want AlreadyExists", err)
+	}
+	it := c.ListTasks(ctx, &taskspb.ListTasksRequest{Parent: queue})
+	n := 0
+	for _, err := it.Next(); err == nil; _, err = it.Next() {
+		n++
+	}
+	if n != 2 {
+		t.Errorf("ListTasks returned %d tasks, want 2", n)
+	}
+
+	if _, err := c.RunTask(ctx, &taskspb.RunTaskRequest{Name: run.Name}); err != nil {
+		t.Fatalf("RunTask: %v", err)
+	}
+	if err := c.DeleteTask(ctx, &taskspb.DeleteTaskRequest{Name: del.Name}); err != nil {
+		t.Fatalf("DeleteTask: %v", err)
+	}
+	wait(t, s)
+	if len(delivered) != 1 {
+		t.Errorf("%d deliveries, want 1", len(delivered))
+	}
+	if _, err := c.GetTask(ctx, &taskspb.GetTaskRequest{Name: run.Name}); status.Code(err) != codes.NotFound {
+		t.Errorf("GetTask of a done task: %v, want NotFound", err)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	retry := &taskspb.RetryConfig{
+		MinBackoff:   durationpb.New(time.Second),
+		MaxBackoff:   durationpb.New(10 * time.Second),
+		MaxDoublings: 2,
+	}
+	for attempt, want := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 4 * time.Second} {
+		if got := backoff(retry, attempt); got != want {
+			t.Errorf("backoff(%d) = %v, want %v", attempt, got, want)
+		}
+	}
+}